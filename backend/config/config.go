@@ -34,12 +34,63 @@ type Config struct {
 	MySQLDSN     string
 	JWTSecret    string
 
-	SchedulerInterval         string
-	SchedulerIntervalDuration time.Duration
-	WorkspaceBaseDir          string
-	DevSessionsDir            string
-	AttachmentsDir            string
-	MaxConcurrentTasks        int
+	SchedulerInterval            string
+	SchedulerIntervalDuration    time.Duration
+	LogRetentionInterval         string
+	LogRetentionIntervalDuration time.Duration
+	WorkspaceBaseDir             string
+	DevSessionsDir               string
+	AttachmentsDir               string
+	MaxConcurrentTasks           int
+	ExecutionQueueEnabled        bool
+	LogLineMaxBytes              int
+
+	ReferenceCacheRefreshInterval         string
+	ReferenceCacheRefreshIntervalDuration time.Duration
+
+	WorkspaceCleanupInterval         string
+	WorkspaceCleanupIntervalDuration time.Duration
+
+	BranchCleanupInterval         string
+	BranchCleanupIntervalDuration time.Duration
+
+	// EnvFilesDir holds files that dev environments may load env var values from
+	EnvFilesDir string
+
+	// WorkspaceSnapshotsDir holds the archives of task workspace snapshots
+	WorkspaceSnapshotsDir string
+	// WorkspaceSnapshotMaxSizeMB is the largest workspace a snapshot is taken of, 0 means no limit
+	WorkspaceSnapshotMaxSizeMB int
+	// WorkspaceSnapshotsPerTask is how many snapshots a task keeps, older ones are pruned, 0 keeps all
+	WorkspaceSnapshotsPerTask int
+
+	// EncryptionKey is the secret AES keys are derived from for data encrypted at rest
+	EncryptionKey string
+
+	// LogStorage* point at an S3-compatible bucket completed execution logs are moved to,
+	// offloading is disabled while LogStorageBucket is empty
+	LogStorageEndpoint  string
+	LogStorageRegion    string
+	LogStorageBucket    string
+	LogStorageAccessKey string
+	LogStorageSecretKey string
+	LogStoragePrefix    string
+
+	// MetricsToken, when set, is the bearer token required to scrape /metrics
+	MetricsToken string
+
+	// TracingOTLPEndpoint is the OTLP/HTTP traces URL spans are exported to, tracing is a
+	// no-op while it is empty
+	TracingOTLPEndpoint string
+	TracingServiceName  string
+	TracingSampleRatio  float64
+
+	// EventBroker is kafka, nats or redis to publish execution lifecycle events to
+	// EventTopic, publishing is disabled while it is empty. EventBrokerURL is a comma
+	// separated broker list for Kafka and a connection URL for NATS and Redis.
+	EventBroker    string
+	EventBrokerURL string
+	EventTopic     string
 
 	LogLevel  LogLevel
 	LogFormat LogFormat
@@ -73,14 +124,48 @@ func Load() *Config {
 		MySQLDSN:     getEnv("XSHA_MYSQL_DSN", ""),
 		JWTSecret:    getEnv("XSHA_JWT_SECRET", "your-jwt-secret-key-change-this-in-production"),
 
-		SchedulerInterval:  getEnv("XSHA_SCHEDULER_INTERVAL", "5s"),
-		WorkspaceBaseDir:   getEnv("XSHA_WORKSPACE_BASE_DIR", "_data/workspaces"),
-		DevSessionsDir:     getEnv("XSHA_DEV_SESSIONS_DIR", "_data/sessions"),
-		AttachmentsDir:     getEnv("XSHA_ATTACHMENTS_DIR", "_data/attachments"),
-		MaxConcurrentTasks: getEnvInt("XSHA_MAX_CONCURRENT_TASKS", 8),
-		LogLevel:           LogLevel(getEnv("XSHA_LOG_LEVEL", defaultLogLevel)),
-		LogFormat:          LogFormat(getEnv("XSHA_LOG_FORMAT", defaultLogFormat)),
-		LogOutput:          getEnv("XSHA_LOG_OUTPUT", "stdout"),
+		SchedulerInterval:     getEnv("XSHA_SCHEDULER_INTERVAL", "5s"),
+		LogRetentionInterval:  getEnv("XSHA_LOG_RETENTION_INTERVAL", "1h"),
+		WorkspaceBaseDir:      getEnv("XSHA_WORKSPACE_BASE_DIR", "_data/workspaces"),
+		DevSessionsDir:        getEnv("XSHA_DEV_SESSIONS_DIR", "_data/sessions"),
+		AttachmentsDir:        getEnv("XSHA_ATTACHMENTS_DIR", "_data/attachments"),
+		MaxConcurrentTasks:    getEnvInt("XSHA_MAX_CONCURRENT_TASKS", 8),
+		ExecutionQueueEnabled: getEnvBool("XSHA_EXECUTION_QUEUE_ENABLED", true),
+		LogLineMaxBytes:       getEnvInt("XSHA_LOG_LINE_MAX_BYTES", 10*1024*1024),
+		LogLevel:              LogLevel(getEnv("XSHA_LOG_LEVEL", defaultLogLevel)),
+		LogFormat:             LogFormat(getEnv("XSHA_LOG_FORMAT", defaultLogFormat)),
+		LogOutput:             getEnv("XSHA_LOG_OUTPUT", "stdout"),
+
+		ReferenceCacheRefreshInterval: getEnv("XSHA_REFERENCE_CACHE_REFRESH_INTERVAL", "6h"),
+
+		WorkspaceCleanupInterval: getEnv("XSHA_WORKSPACE_CLEANUP_INTERVAL", "1h"),
+
+		BranchCleanupInterval: getEnv("XSHA_BRANCH_CLEANUP_INTERVAL", "10m"),
+
+		EnvFilesDir: getEnv("XSHA_ENV_FILES_DIR", "_data/env-files"),
+
+		WorkspaceSnapshotsDir:      getEnv("XSHA_WORKSPACE_SNAPSHOTS_DIR", "_data/workspace-snapshots"),
+		WorkspaceSnapshotMaxSizeMB: getEnvInt("XSHA_WORKSPACE_SNAPSHOT_MAX_SIZE_MB", 2048),
+		WorkspaceSnapshotsPerTask:  getEnvInt("XSHA_WORKSPACE_SNAPSHOTS_PER_TASK", 10),
+
+		EncryptionKey: getEnv("XSHA_ENCRYPTION_KEY", ""),
+
+		LogStorageEndpoint:  getEnv("XSHA_LOG_STORAGE_ENDPOINT", "https://s3.amazonaws.com"),
+		LogStorageRegion:    getEnv("XSHA_LOG_STORAGE_REGION", "us-east-1"),
+		LogStorageBucket:    getEnv("XSHA_LOG_STORAGE_BUCKET", ""),
+		LogStorageAccessKey: getEnv("XSHA_LOG_STORAGE_ACCESS_KEY", ""),
+		LogStorageSecretKey: getEnv("XSHA_LOG_STORAGE_SECRET_KEY", ""),
+		LogStoragePrefix:    getEnv("XSHA_LOG_STORAGE_PREFIX", "execution-logs/"),
+
+		MetricsToken: getEnv("XSHA_METRICS_TOKEN", ""),
+
+		TracingOTLPEndpoint: getEnv("XSHA_TRACING_OTLP_ENDPOINT", ""),
+		TracingServiceName:  getEnv("XSHA_TRACING_SERVICE_NAME", "xsha-backend"),
+		TracingSampleRatio:  getEnvFloat("XSHA_TRACING_SAMPLE_RATIO", 1),
+
+		EventBroker:    getEnv("XSHA_EVENT_BROKER", ""),
+		EventBrokerURL: getEnv("XSHA_EVENT_BROKER_URL", ""),
+		EventTopic:     getEnv("XSHA_EVENT_TOPIC", "xsha.execution-events"),
 	}
 
 	schedulerInterval, err := time.ParseDuration(config.SchedulerInterval)
@@ -92,10 +177,48 @@ func Load() *Config {
 	}
 	config.SchedulerIntervalDuration = schedulerInterval
 
+	logRetentionInterval, err := time.ParseDuration(config.LogRetentionInterval)
+	if err != nil {
+		logger.Warn("Failed to parse log retention interval, using default 1 hour",
+			zap.String("interval", config.LogRetentionInterval),
+			zap.Error(err))
+		logRetentionInterval = time.Hour
+	}
+	config.LogRetentionIntervalDuration = logRetentionInterval
+
+	referenceCacheRefreshInterval, err := time.ParseDuration(config.ReferenceCacheRefreshInterval)
+	if err != nil {
+		logger.Warn("Failed to parse reference cache refresh interval, using default 6 hours",
+			zap.String("interval", config.ReferenceCacheRefreshInterval),
+			zap.Error(err))
+		referenceCacheRefreshInterval = 6 * time.Hour
+	}
+	config.ReferenceCacheRefreshIntervalDuration = referenceCacheRefreshInterval
+
+	workspaceCleanupInterval, err := time.ParseDuration(config.WorkspaceCleanupInterval)
+	if err != nil {
+		logger.Warn("Failed to parse workspace cleanup interval, using default 1 hour",
+			zap.String("interval", config.WorkspaceCleanupInterval),
+			zap.Error(err))
+		workspaceCleanupInterval = time.Hour
+	}
+	config.WorkspaceCleanupIntervalDuration = workspaceCleanupInterval
+
+	branchCleanupInterval, err := time.ParseDuration(config.BranchCleanupInterval)
+	if err != nil {
+		logger.Warn("Failed to parse branch cleanup interval, using default 10 minutes",
+			zap.String("interval", config.BranchCleanupInterval),
+			zap.Error(err))
+		branchCleanupInterval = 10 * time.Minute
+	}
+	config.BranchCleanupIntervalDuration = branchCleanupInterval
+
 	// Normalize paths to absolute paths for Docker compatibility
 	config.WorkspaceBaseDir = normalizeConfigPath(config.WorkspaceBaseDir)
 	config.DevSessionsDir = normalizeConfigPath(config.DevSessionsDir)
 	config.AttachmentsDir = normalizeConfigPath(config.AttachmentsDir)
+	config.EnvFilesDir = normalizeConfigPath(config.EnvFilesDir)
+	config.WorkspaceSnapshotsDir = normalizeConfigPath(config.WorkspaceSnapshotsDir)
 
 	return config
 }
@@ -123,6 +246,36 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+		logger, _ := zap.NewDevelopment()
+		defer logger.Sync()
+		logger.Warn("Failed to parse environment variable as boolean, using default value",
+			zap.String("key", key),
+			zap.String("value", value),
+			zap.Bool("default", defaultValue))
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		logger, _ := zap.NewDevelopment()
+		defer logger.Sync()
+		logger.Warn("Failed to parse environment variable as float, using default value",
+			zap.String("key", key),
+			zap.String("value", value),
+			zap.Float64("default", defaultValue))
+	}
+	return defaultValue
+}
+
 // normalizeConfigPath converts relative paths to absolute paths
 func normalizeConfigPath(path string) string {
 	if path == "" {
@@ -66,7 +66,7 @@ func NewDatabaseManager(cfg *config.Config) (*DatabaseManager, error) {
 		panic(fmt.Sprintf("Unsupported database type: %s", cfg.DatabaseType))
 	}
 
-	if err := db.AutoMigrate(&Migration{}, &TokenBlacklist{}, &LoginLog{}, &GitCredential{}, &Project{}, &AdminOperationLog{}, &DevEnvironment{}, &Task{}, &TaskConversation{}, &TaskExecutionLog{}, &TaskConversationResult{}, &TaskConversationAttachment{}, &SystemConfig{}); err != nil {
+	if err := db.AutoMigrate(&Migration{}, &TokenBlacklist{}, &LoginLog{}, &UserPreference{}, &GitCredential{}, &Project{}, &AdminOperationLog{}, &DevEnvironment{}, &Task{}, &TaskConversation{}, &TaskExecutionLog{}, &TaskConversationResult{}, &TaskConversationAttachment{}, &SystemConfig{}, &WorkspaceSnapshot{}, &TaskTemplate{}, &ScheduledTask{}, &Secret{}); err != nil {
 		return nil, err
 	}
 	utils.Info("Database table migration completed")
@@ -130,6 +130,21 @@ func runMigrations(db *gorm.DB, cfg *config.Config) error {
 		return fmt.Errorf("dev environment session dir migration failed: %v", err)
 	}
 
+	// Run conversation changes committed backfill migration
+	if err := runChangesCommittedMigration(db); err != nil {
+		return fmt.Errorf("conversation changes committed migration failed: %v", err)
+	}
+
+	// Run project repository URL normalization migration
+	if err := runRepoURLNormalizationMigration(db); err != nil {
+		return fmt.Errorf("project repository URL normalization migration failed: %v", err)
+	}
+
+	// Run secret owner name index migration
+	if err := runSecretOwnerNameIndexMigration(db); err != nil {
+		return fmt.Errorf("secret owner name index migration failed: %v", err)
+	}
+
 	utils.Info("Custom migrations completed successfully")
 	return nil
 }
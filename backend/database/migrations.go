@@ -264,3 +264,125 @@ func migrateDevEnvironmentSessionDir(db *gorm.DB, devEnv *DevEnvironment, devSes
 
 	return nil
 }
+
+// runChangesCommittedMigration marks the conversations committed before changes_committed
+// existed, their commit hash shows they committed changes
+func runChangesCommittedMigration(db *gorm.DB) error {
+	migrationName := "003_conversation_changes_committed"
+
+	var existing Migration
+	if err := db.Where("name = ?", migrationName).First(&existing).Error; err == nil {
+		utils.Info("Migration already applied, skipping", "migration", migrationName)
+		return nil
+	} else if err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to check migration status: %v", err)
+	}
+
+	result := db.Model(&TaskConversation{}).
+		Where("commit_hash <> '' AND commit_hash IS NOT NULL").
+		Update("changes_committed", true)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill changes_committed: %v", result.Error)
+	}
+
+	utils.Info("Migration completed", "migration", migrationName, "migrated", result.RowsAffected)
+
+	migration := Migration{
+		Name:      migrationName,
+		AppliedAt: time.Now(),
+	}
+	if err := db.Create(&migration).Error; err != nil {
+		return fmt.Errorf("failed to record migration: %v", err)
+	}
+
+	return nil
+}
+
+// runRepoURLNormalizationMigration rewrites the repository URLs of projects created before URLs
+// were normalized, so they match what new projects store. URLs that no longer validate are left
+// as they are and logged.
+func runRepoURLNormalizationMigration(db *gorm.DB) error {
+	migrationName := "004_project_repo_url_normalization"
+
+	var existing Migration
+	if err := db.Where("name = ?", migrationName).First(&existing).Error; err == nil {
+		utils.Info("Migration already applied, skipping", "migration", migrationName)
+		return nil
+	} else if err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to check migration status: %v", err)
+	}
+
+	var projects []Project
+	if err := db.Find(&projects).Error; err != nil {
+		return fmt.Errorf("failed to fetch projects: %v", err)
+	}
+
+	migratedCount := 0
+	errorCount := 0
+	for _, project := range projects {
+		normalized, err := utils.NormalizeRepoURL(project.RepoURL, utils.GitProtocolType(project.Protocol))
+		if err != nil {
+			utils.Warn("Project repository URL cannot be normalized, leaving it unchanged",
+				"projectId", project.ID, "error", err)
+			errorCount++
+			continue
+		}
+		if normalized == project.RepoURL {
+			continue
+		}
+		if err := db.Model(&Project{}).Where("id = ?", project.ID).Update("repo_url", normalized).Error; err != nil {
+			utils.Error("Failed to normalize project repository URL", "projectId", project.ID, "error", err)
+			errorCount++
+			continue
+		}
+		migratedCount++
+	}
+
+	utils.Info("Migration completed",
+		"migration", migrationName,
+		"migrated", migratedCount,
+		"errors", errorCount,
+		"total", len(projects))
+
+	migration := Migration{
+		Name:      migrationName,
+		AppliedAt: time.Now(),
+	}
+	if err := db.Create(&migration).Error; err != nil {
+		return fmt.Errorf("failed to record migration: %v", err)
+	}
+
+	return nil
+}
+
+// runSecretOwnerNameIndexMigration drops the unique index on secrets.name, secret names are
+// unique per owner through idx_secrets_owner_name
+func runSecretOwnerNameIndexMigration(db *gorm.DB) error {
+	migrationName := "005_secret_owner_name_index"
+
+	var existing Migration
+	if err := db.Where("name = ?", migrationName).First(&existing).Error; err == nil {
+		utils.Info("Migration already applied, skipping", "migration", migrationName)
+		return nil
+	} else if err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to check migration status: %v", err)
+	}
+
+	if db.Migrator().HasIndex(&Secret{}, "idx_secrets_name") {
+		if err := db.Migrator().DropIndex(&Secret{}, "idx_secrets_name"); err != nil {
+			return fmt.Errorf("failed to drop secrets name index: %v", err)
+		}
+	}
+
+	utils.Info("Migration completed", "migration", migrationName)
+
+	migration := Migration{
+		Name:      migrationName,
+		AppliedAt: time.Now(),
+	}
+	if err := db.Create(&migration).Error; err != nil {
+		return fmt.Errorf("failed to record migration: %v", err)
+	}
+
+	return nil
+}
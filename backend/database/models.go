@@ -38,12 +38,26 @@ type LoginLog struct {
 	LoginTime time.Time      `gorm:"not null;index" json:"login_time"`
 }
 
+// UserPreference holds the settings a user keeps across sessions
+type UserPreference struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	Username  string         `gorm:"uniqueIndex;size:191;not null" json:"username"`
+
+	// PreferredLanguage applies to requests without a lang query, empty uses the default
+	PreferredLanguage string `gorm:"default:''" json:"preferred_language"`
+}
+
 type GitCredentialType string
 
 const (
 	GitCredentialTypePassword GitCredentialType = "password"
 	GitCredentialTypeToken    GitCredentialType = "token"
 	GitCredentialTypeSSHKey   GitCredentialType = "ssh_key"
+	// GitCredentialTypeGitHubApp authenticates over HTTPS with short-lived GitHub App installation tokens
+	GitCredentialTypeGitHubApp GitCredentialType = "github_app"
 )
 
 type GitCredential struct {
@@ -62,9 +76,29 @@ type GitCredential struct {
 	PrivateKey   string `gorm:"type:text" json:"-"`
 	PublicKey    string `gorm:"type:text" json:"public_key"`
 
+	// GitHub App identifiers, the app's private key is stored in PrivateKey
+	GitHubAppID          string `gorm:"default:''" json:"github_app_id"`
+	GitHubInstallationID string `gorm:"default:''" json:"github_installation_id"`
+
+	// ProxyMode decides the git proxy for repositories accessed with this credential:
+	// inherit the global proxy, connect directly, or use the custom proxy below
+	ProxyMode  GitProxyMode `gorm:"default:'inherit'" json:"proxy_mode"`
+	ProxyHTTP  string       `gorm:"default:''" json:"proxy_http"`
+	ProxyHTTPS string       `gorm:"default:''" json:"proxy_https"`
+	// ProxyNoProxy is a comma-separated list of hosts that bypass the custom proxy, as in NO_PROXY
+	ProxyNoProxy string `gorm:"default:''" json:"proxy_no_proxy"`
+
 	CreatedBy string `gorm:"not null;index" json:"created_by"`
 }
 
+type GitProxyMode string
+
+const (
+	GitProxyModeInherit GitProxyMode = "inherit"
+	GitProxyModeNone    GitProxyMode = "none"
+	GitProxyModeCustom  GitProxyMode = "custom"
+)
+
 type GitProtocolType string
 
 const (
@@ -87,6 +121,50 @@ type Project struct {
 	CredentialID *uint           `gorm:"index" json:"credential_id"`
 	Credential   *GitCredential  `gorm:"foreignKey:CredentialID" json:"credential"`
 
+	// ExecutionLogRetentionDays overrides the global execution log retention, nil inherits it and 0 keeps logs forever
+	ExecutionLogRetentionDays *int `json:"execution_log_retention_days"`
+
+	// MaxConcurrentTasks caps running conversations of this project, 0 only applies the global limit
+	MaxConcurrentTasks int `gorm:"default:0" json:"max_concurrent_tasks"`
+
+	// ShallowClone clones only the start branch history up to CloneDepth commits (1 when unset)
+	ShallowClone bool `gorm:"default:false" json:"shallow_clone"`
+	CloneDepth   int  `gorm:"default:0" json:"clone_depth"`
+
+	RecurseSubmodules bool `gorm:"default:false" json:"recurse_submodules"`
+
+	// UseWorktrees makes task workspaces worktrees of one shared clone of the repository instead
+	// of full clones, tasks that already have a clone keep it
+	UseWorktrees bool `gorm:"default:false" json:"use_worktrees"`
+
+	// RequireCommitApproval stops conversations with changes in awaiting_approval instead of
+	// committing, a user approves or rejects the changes
+	RequireCommitApproval bool `gorm:"default:false" json:"require_commit_approval"`
+
+	// Once a task's work branch is merged, DeleteBranchAfterMerge deletes it from the remote and
+	// RemoveWorkspaceAfterMerge removes the task workspace
+	DeleteBranchAfterMerge    bool `gorm:"default:false" json:"delete_branch_after_merge"`
+	RemoveWorkspaceAfterMerge bool `gorm:"default:false" json:"remove_workspace_after_merge"`
+
+	// Commit identity and message template, empty values fall back to system config
+	CommitAuthorName      string `gorm:"default:''" json:"commit_author_name"`
+	CommitAuthorEmail     string `gorm:"default:''" json:"commit_author_email"`
+	CommitMessageTemplate string `gorm:"type:text" json:"commit_message_template"`
+
+	// WebhookURL overrides the global notification webhook for conversations of this project
+	WebhookURL string `gorm:"default:''" json:"webhook_url"`
+
+	// GitConfig holds a JSON object of git config keys applied to task workspaces before each run
+	GitConfig string `gorm:"type:text" json:"git_config"`
+
+	// CostBudgetUSD stops new conversations once the project's AI cost reaches it, 0 means no cap.
+	// BudgetOverride is set by an admin to let conversations run past the budget.
+	CostBudgetUSD  float64 `gorm:"type:decimal(10,2);not null;default:0" json:"cost_budget_usd"`
+	BudgetOverride bool    `gorm:"default:false" json:"budget_override"`
+
+	// ReferenceCacheUpdatedAt is set once the project is prewarmed, and the cache is refreshed periodically after that
+	ReferenceCacheUpdatedAt *time.Time `json:"reference_cache_updated_at"`
+
 	CreatedBy string `gorm:"not null;index" json:"created_by"`
 }
 
@@ -143,13 +221,52 @@ type DevEnvironment struct {
 
 	CPULimit    float64 `gorm:"default:1.0" json:"cpu_limit"`
 	MemoryLimit int64   `gorm:"default:1024" json:"memory_limit"`
+	NetworkMode string  `gorm:"default:'bridge'" json:"network_mode"`
+	GPUEnabled  bool    `gorm:"default:false" json:"gpu_enabled"`
+	GPUDevice   string  `gorm:"default:''" json:"gpu_device"`
+	// ConcurrencyWeight is how much of the executor capacity a run in this environment uses,
+	// 0 derives it from the CPU and memory limits
+	ConcurrencyWeight int `gorm:"default:0" json:"concurrency_weight"`
 
 	EnvVars    string `gorm:"type:text" json:"env_vars"`
 	SessionDir string `gorm:"type:text" json:"session_dir"`
+	// Ulimits JSON map of ulimit name to "soft[:hard]", e.g. {"nofile":"4096:8192"}
+	Ulimits string `gorm:"type:text" json:"ulimits"`
+	// EnvFiles JSON map of env var name to a file path relative to the env files directory,
+	// the file is read when the container starts and its content is never stored
+	EnvFiles string `gorm:"type:text" json:"env_files"`
+	// PermissionMode controls whether the AI may run tools without approval, "skip" passes
+	// --dangerously-skip-permissions and "restricted" only allows edits plus AllowedTools
+	PermissionMode string `gorm:"default:'skip'" json:"permission_mode"`
+	// AllowedTools JSON array of tool patterns allowed in restricted mode, e.g. ["Bash(git:*)"]
+	AllowedTools string `gorm:"type:text" json:"allowed_tools"`
+	// ExtraDockerArgs JSON array of allowlisted docker run flags, e.g. ["--shm-size=1g"]
+	ExtraDockerArgs string `gorm:"type:text" json:"extra_docker_args"`
+
+	// ReadOnlyRootFS runs the container with --read-only, the mounted workspace and session
+	// volumes stay writable and TmpfsMounts provide scratch space
+	ReadOnlyRootFS  bool `gorm:"default:false" json:"read_only_root_fs"`
+	NoNewPrivileges bool `gorm:"default:false" json:"no_new_privileges"`
+	// RunAsUser is passed to --user as "uid" or "uid:gid", empty uses the image's user
+	RunAsUser string `gorm:"default:''" json:"run_as_user"`
+	// TmpfsMounts JSON array of tmpfs mounts as "path[:options]", e.g. ["/tmp:rw,size=256m"],
+	// empty mounts /tmp when the root filesystem is read-only
+	TmpfsMounts string `gorm:"type:text" json:"tmpfs_mounts"`
 
 	CreatedBy string `gorm:"not null;index" json:"created_by"`
 }
 
+const (
+	NetworkModeBridge = "bridge"
+	NetworkModeNone   = "none"
+	NetworkModeHost   = "host"
+)
+
+const (
+	PermissionModeSkip       = "skip"
+	PermissionModeRestricted = "restricted"
+)
+
 type TaskStatus string
 
 const (
@@ -162,11 +279,15 @@ const (
 type ConversationStatus string
 
 const (
+	ConversationStatusDraft     ConversationStatus = "draft"
 	ConversationStatusPending   ConversationStatus = "pending"
 	ConversationStatusRunning   ConversationStatus = "running"
 	ConversationStatusSuccess   ConversationStatus = "success"
 	ConversationStatusFailed    ConversationStatus = "failed"
 	ConversationStatusCancelled ConversationStatus = "cancelled"
+	// ConversationStatusAwaitingApproval holds the AI's uncommitted changes in the workspace until
+	// a user approves or rejects them
+	ConversationStatusAwaitingApproval ConversationStatus = "awaiting_approval"
 )
 
 type Task struct {
@@ -181,10 +302,23 @@ type Task struct {
 
 	Status         TaskStatus `gorm:"not null;index" json:"status"`
 	HasPullRequest bool       `gorm:"default:false" json:"has_pull_request"`
+	// BranchMergedAt is set once the work branch is known to be merged and its cleanup has run
+	BranchMergedAt *time.Time `json:"branch_merged_at"`
 
 	WorkspacePath string `gorm:"type:text" json:"workspace_path"`
 	SessionID     string `gorm:"default:''" json:"session_id"`
 
+	// ExecutionTimeoutSeconds overrides the global docker timeout when positive
+	ExecutionTimeoutSeconds int `gorm:"default:0" json:"execution_timeout_seconds"`
+
+	// CostBudgetUSD stops new conversations once the task's AI cost reaches it, 0 means no cap
+	CostBudgetUSD float64 `gorm:"type:decimal(10,2);not null;default:0" json:"cost_budget_usd"`
+	// MaxRuns stops new conversations once the task has that many, drafts excluded, 0 means no cap
+	MaxRuns int `gorm:"not null;default:0" json:"max_runs"`
+	// ParallelConversations runs each conversation on its own branch in a workspace cloned from
+	// the shared one, so several conversations of the task can run at once
+	ParallelConversations bool `gorm:"default:false" json:"parallel_conversations"`
+
 	ProjectID        uint            `gorm:"not null;index" json:"project_id"`
 	Project          *Project        `gorm:"foreignKey:ProjectID" json:"project"`
 	DevEnvironmentID *uint           `gorm:"index" json:"dev_environment_id"`
@@ -208,15 +342,30 @@ type TaskConversation struct {
 
 	Content string             `gorm:"type:longtext;not null" json:"content"`
 	Status  ConversationStatus `gorm:"not null;index" json:"status"`
+	// RetryContent is the replacement prompt of the latest retry, which runs it instead of
+	// Content. Empty when the retry reuses Content.
+	RetryContent string `gorm:"type:longtext" json:"retry_content"`
 
 	// ExecutionTime 执行时间，如果为空则立即执行
 	ExecutionTime *time.Time `gorm:"index" json:"execution_time"`
 
 	CommitHash string `gorm:"default:''" json:"commit_hash"`
+	// ChangesCommitted is false for finished conversations whose AI run left no file changes
+	ChangesCommitted bool `gorm:"default:false" json:"changes_committed"`
+
+	// SessionID is the AI tool session produced by this conversation, used to resume later turns
+	SessionID string `gorm:"default:''" json:"session_id"`
 
 	// EnvParams 环境参数，如model等参数的JSON存储
 	EnvParams string `gorm:"type:text;default:'{}'" json:"env_params"`
 
+	// Priority orders pending conversations in the scheduler, higher runs first
+	Priority int `gorm:"default:0;index" json:"priority"`
+
+	// IsolatedBranch runs the conversation on its own branch derived from the task's work branch
+	IsolatedBranch bool   `gorm:"default:false" json:"isolated_branch"`
+	WorkBranch     string `gorm:"default:''" json:"work_branch"`
+
 	CreatedBy string `gorm:"not null;index" json:"created_by"`
 }
 
@@ -230,13 +379,41 @@ type TaskExecutionLog struct {
 	Conversation   *TaskConversation `gorm:"foreignKey:ConversationID" json:"conversation"`
 
 	DockerCommand string `gorm:"type:text" json:"docker_command"`
-	ExecutionLogs string `gorm:"type:longtext" json:"execution_logs"`
-	ErrorMessage  string `gorm:"type:text" json:"error_message"`
+	// EffectivePrompt is the prompt as sent to the AI: the appended project and dev environment
+	// system prompts, then the conversation content after attachment tags were replaced with
+	// workspace paths
+	EffectivePrompt string `gorm:"type:text" json:"-"`
+	ExecutionLogs   string `gorm:"type:longtext" json:"execution_logs"`
+	// ExecutionLogsRef is the object storage key of logs moved out of the database after the
+	// conversation completed, ExecutionLogs is empty in the database then
+	ExecutionLogsRef string `gorm:"default:''" json:"-"`
+	ErrorMessage     string `gorm:"type:text" json:"error_message"`
+	ToolVersion      string `gorm:"default:''" json:"tool_version"`
+
+	// FailureCategory classifies the error of a failed conversation, empty otherwise
+	FailureCategory FailureCategory `gorm:"default:'';index" json:"failure_category"`
+
+	// Peak container usage sampled during the run, 0 when no sample was taken.
+	// PeakCPUPercent follows docker stats, where 100 is one full core.
+	PeakCPUPercent  float64 `gorm:"default:0" json:"peak_cpu_percent"`
+	PeakMemoryBytes int64   `gorm:"default:0" json:"peak_memory_bytes"`
 
 	StartedAt   *time.Time `json:"started_at"`
 	CompletedAt *time.Time `json:"completed_at"`
 }
 
+type FailureCategory string
+
+const (
+	FailureCategoryAuth         FailureCategory = "auth"
+	FailureCategoryImageMissing FailureCategory = "image_missing"
+	FailureCategoryOOM          FailureCategory = "oom"
+	FailureCategoryTimeout      FailureCategory = "timeout"
+	FailureCategoryNetwork      FailureCategory = "network"
+	FailureCategoryGit          FailureCategory = "git"
+	FailureCategoryUnknown      FailureCategory = "unknown"
+)
+
 type ResultType string
 
 const (
@@ -269,11 +446,22 @@ type TaskConversationResult struct {
 
 	Result string `gorm:"type:text;not null" json:"result"`
 
-	SessionID string `gorm:"not null;index" json:"session_id"`
+	SessionID   string `gorm:"not null;index" json:"session_id"`
+	ToolVersion string `gorm:"default:''" json:"tool_version"`
 
 	TotalCostUsd float64 `gorm:"type:decimal(10,6);not null;default:0" json:"total_cost_usd"`
 
 	Usage string `gorm:"type:text" json:"usage"`
+
+	// Token counts extracted from usage, 0 when the result JSON didn't report them
+	InputTokens  int64 `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens int64 `gorm:"not null;default:0" json:"output_tokens"`
+
+	// Timeline is a JSON array of the text, tool use and tool result events parsed from the stream output
+	Timeline string `gorm:"type:longtext" json:"timeline"`
+
+	// ChangesCommitted mirrors the conversation's flag, so clients see it without the conversation
+	ChangesCommitted bool `gorm:"-" json:"changes_committed"`
 }
 
 type ConfigFormType string
@@ -330,3 +518,90 @@ type TaskConversationAttachment struct {
 
 	CreatedBy string `gorm:"not null;index" json:"created_by"`
 }
+
+// WorkspaceSnapshot is a gzipped tar of a task workspace, including .git, taken so the
+// workspace can be rewound later
+type WorkspaceSnapshot struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	TaskID uint  `gorm:"not null;index" json:"task_id"`
+	Task   *Task `gorm:"foreignKey:TaskID" json:"-"`
+
+	// FileName is relative to the workspace snapshots directory
+	FileName   string `gorm:"not null" json:"-"`
+	SizeBytes  int64  `gorm:"not null;default:0" json:"size_bytes"`
+	Branch     string `gorm:"default:''" json:"branch"`
+	CommitHash string `gorm:"default:''" json:"commit_hash"`
+
+	CreatedBy string `gorm:"not null;index" json:"created_by"`
+}
+
+// Secret is a named value dev environment env vars reference as secret://<name>. The value is
+// stored encrypted with the configured encryption key and never returned by the API.
+type Secret struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Name is unique per owner, environments reference their owner's secrets by it
+	Name           string `gorm:"uniqueIndex:idx_secrets_owner_name,priority:2;size:191;not null" json:"name"`
+	Description    string `gorm:"type:text" json:"description"`
+	EncryptedValue string `gorm:"type:text;not null" json:"-"`
+
+	CreatedBy string `gorm:"not null;index;uniqueIndex:idx_secrets_owner_name,priority:1" json:"created_by"`
+}
+
+// TaskTemplate holds defaults a new task can inherit, the conversation template becomes the
+// requirement description when the task is created without one
+type TaskTemplate struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"not null;index" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+
+	ProjectID        *uint           `gorm:"index" json:"project_id"`
+	Project          *Project        `gorm:"foreignKey:ProjectID" json:"project"`
+	DevEnvironmentID *uint           `gorm:"index" json:"dev_environment_id"`
+	DevEnvironment   *DevEnvironment `gorm:"foreignKey:DevEnvironmentID" json:"dev_environment"`
+
+	StartBranch          string `gorm:"default:''" json:"start_branch"`
+	ConversationTemplate string `gorm:"type:text" json:"conversation_template"`
+
+	CreatedBy string `gorm:"not null;index" json:"created_by"`
+}
+
+// ScheduledTask creates a conversation on its task every time its cron expression fires, the
+// executor then runs it like any other pending conversation
+type ScheduledTask struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name string `gorm:"not null" json:"name"`
+	// CronExpression has five fields evaluated in UTC, or a descriptor such as @daily.
+	// A CRON_TZ= prefix selects another time zone.
+	CronExpression string `gorm:"not null" json:"cron_expression"`
+	Enabled        bool   `gorm:"not null;default:false;index" json:"enabled"`
+
+	TaskID uint  `gorm:"not null;index" json:"task_id"`
+	Task   *Task `gorm:"foreignKey:TaskID" json:"task"`
+	// Content is the conversation content, the conversation template of TemplateID is used
+	// when it is empty
+	Content    string        `gorm:"type:text" json:"content"`
+	TemplateID *uint         `gorm:"index" json:"template_id"`
+	Template   *TaskTemplate `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+
+	LastRunAt          *time.Time `json:"last_run_at"`
+	NextRunAt          *time.Time `gorm:"index" json:"next_run_at"`
+	LastConversationID *uint      `json:"last_conversation_id"`
+	LastError          string     `gorm:"type:text" json:"last_error"`
+
+	CreatedBy string `gorm:"not null;index" json:"created_by"`
+}
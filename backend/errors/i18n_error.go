@@ -36,6 +36,8 @@ var (
 	ErrInvalidFormat = &I18nError{Key: "validation.invalid_format"}
 	ErrTooLong       = &I18nError{Key: "validation.too_long"}
 
+	ErrUnsupportedLanguage = &I18nError{Key: "user.language_unsupported"}
+
 	ErrTaskTitleRequired                  = &I18nError{Key: "task.title_required"}
 	ErrTaskTitleTooLong                   = &I18nError{Key: "task.title_too_long"}
 	ErrStartBranchRequired                = &I18nError{Key: "task.start_branch_required"}
@@ -44,12 +46,51 @@ var (
 	ErrTaskNotFound                       = &I18nError{Key: "task.not_found"}
 	ErrNoGitCredential                    = &I18nError{Key: "task.no_git_credential"}
 	ErrProjectNotAssociatedWithCredential = &I18nError{Key: "task.project_not_associated_with_credential"}
-
-	ErrProjectNameExists      = &I18nError{Key: "project.name_exists"}
-	ErrIncompatibleCredential = &I18nError{Key: "project.incompatible_credential"}
-	ErrInvalidProtocol        = &I18nError{Key: "project.invalid_protocol"}
+	ErrTaskExecutionTimeoutInvalid        = &I18nError{Key: "task.execution_timeout_invalid"}
+	ErrTaskCostBudgetInvalid              = &I18nError{Key: "task.cost_budget_invalid"}
+	ErrTaskMaxRunsInvalid                 = &I18nError{Key: "task.max_runs_invalid"}
+	ErrTaskParallelConversationsBusy      = &I18nError{Key: "task.parallel_conversations_busy"}
+	ErrTaskDevEnvironmentRequired         = &I18nError{Key: "task.dev_environment_required"}
+	ErrTaskRequirementDescRequired        = &I18nError{Key: "task.requirement_desc_required"}
+	ErrTaskWorkBranchMissing              = &I18nError{Key: "task.work_branch_missing"}
+	ErrTaskBranchCleanupBusy              = &I18nError{Key: "task.branch_cleanup_busy"}
+	ErrTaskBranchCleanupUnpushed          = &I18nError{Key: "task.branch_cleanup_unpushed"}
+	ErrTaskBranchCleanupMoved             = &I18nError{Key: "task.branch_cleanup_moved"}
+
+	ErrTaskTemplateNotFound     = &I18nError{Key: "task_template.not_found"}
+	ErrTaskTemplateNameRequired = &I18nError{Key: "task_template.name_required"}
+	ErrTaskTemplateNameTooLong  = &I18nError{Key: "task_template.name_too_long"}
+
+	ErrScheduledTaskNotFound        = &I18nError{Key: "scheduled_task.not_found"}
+	ErrScheduledTaskNameRequired    = &I18nError{Key: "scheduled_task.name_required"}
+	ErrScheduledTaskNameTooLong     = &I18nError{Key: "scheduled_task.name_too_long"}
+	ErrScheduledTaskContentRequired = &I18nError{Key: "scheduled_task.content_required"}
+
+	ErrSecretNotFound              = &I18nError{Key: "secret.not_found"}
+	ErrSecretNameInvalid           = &I18nError{Key: "secret.name_invalid"}
+	ErrSecretNameExists            = &I18nError{Key: "secret.name_exists"}
+	ErrSecretValueRequired         = &I18nError{Key: "secret.value_required"}
+	ErrSecretEncryptionUnavailable = &I18nError{Key: "secret.encryption_unavailable"}
+	ErrSecretInUse                 = &I18nError{Key: "secret.in_use"}
+	ErrEnvironmentSecretNotFound   = &I18nError{Key: "dev_environment.secret_not_found"}
+
+	ErrContainerNameInvalid = &I18nError{Key: "container.name_invalid"}
+	ErrContainerInUse       = &I18nError{Key: "container.in_use"}
+
+	ErrProjectNameExists        = &I18nError{Key: "project.name_exists"}
+	ErrIncompatibleCredential   = &I18nError{Key: "project.incompatible_credential"}
+	ErrInvalidProtocol          = &I18nError{Key: "project.invalid_protocol"}
+	ErrRepoURLInvalid           = &I18nError{Key: "project.repo_url_invalid"}
+	ErrLogRetentionInvalid      = &I18nError{Key: "project.log_retention_invalid"}
+	ErrMaxConcurrentInvalid     = &I18nError{Key: "project.max_concurrent_tasks_invalid"}
+	ErrProjectCostBudgetInvalid = &I18nError{Key: "project.cost_budget_invalid"}
+	ErrCloneDepthInvalid        = &I18nError{Key: "project.clone_depth_invalid"}
+	ErrWebhookURLInvalid        = &I18nError{Key: "project.webhook_url_invalid"}
+	ErrRepositoryTooLarge       = &I18nError{Key: "project.repository_too_large"}
+	ErrRepositorySizeUnknown    = &I18nError{Key: "project.repository_size_unknown"}
 
 	ErrCredentialNameExists              = &I18nError{Key: "git_credential.name_exists"}
+	ErrCredentialNotFound                = &I18nError{Key: "git_credential.not_found"}
 	ErrCredentialUseFailed               = &I18nError{Key: "git_credential.use_failed"}
 	ErrInvalidCredentialType             = &I18nError{Key: "git_credential.invalid_type"}
 	ErrCredentialPasswordNotSet          = &I18nError{Key: "git_credential.password_not_set"}
@@ -60,25 +101,48 @@ var (
 	ErrCredentialPrivateKeyRequired      = &I18nError{Key: "git_credential.private_key_required"}
 	ErrCredentialInvalidPrivateKeyFormat = &I18nError{Key: "git_credential.invalid_private_key_format"}
 	ErrCredentialUnsupportedType         = &I18nError{Key: "git_credential.unsupported_credential_type"}
-
-	ErrEnvironmentCreateFailed           = &I18nError{Key: "dev_environment.create_failed"}
-	ErrDevEnvironmentNotFound            = &I18nError{Key: "dev_environment.not_found"}
-	ErrEnvironmentNameExists             = &I18nError{Key: "dev_environment.name_exists"}
-	ErrEnvironmentDockerImageRequired    = &I18nError{Key: "dev_environment.docker_image_required"}
-	ErrEnvironmentCPULimitInvalid        = &I18nError{Key: "dev_environment.cpu_limit_invalid"}
-	ErrEnvironmentMemoryLimitInvalid     = &I18nError{Key: "dev_environment.memory_limit_invalid"}
-	ErrEnvironmentNameRequired           = &I18nError{Key: "dev_environment.name_required"}
-	ErrEnvironmentImagesConfigFailed     = &I18nError{Key: "dev_environment.images_config_failed"}
-	ErrEnvironmentImagesConfigParseError = &I18nError{Key: "dev_environment.images_config_parse_error"}
-	ErrEnvironmentUnsupportedType        = &I18nError{Key: "dev_environment.unsupported_type"}
-	ErrEnvironmentVarKeyEmpty            = &I18nError{Key: "dev_environment.var_key_empty"}
-	ErrEnvironmentVarKeyInvalidChar      = &I18nError{Key: "dev_environment.var_key_invalid_char"}
-
-	ErrConversationGetFailed        = &I18nError{Key: "taskConversation.get_failed"}
-	ErrConversationCreateFailed     = &I18nError{Key: "taskConversation.create_failed"}
-	ErrConversationTaskCompleted    = &I18nError{Key: "taskConversation.task_completed"}
-	ErrConversationDeleteFailed     = &I18nError{Key: "taskConversation.delete_failed"}
-	ErrConversationDeleteLatestOnly = &I18nError{Key: "taskConversation.delete_latest_only"}
+	ErrCredentialProxyInvalid            = &I18nError{Key: "git_credential.proxy_invalid"}
+	ErrCredentialGitHubAppFieldsRequired = &I18nError{Key: "git_credential.github_app_fields_required"}
+
+	ErrEnvironmentCreateFailed             = &I18nError{Key: "dev_environment.create_failed"}
+	ErrDevEnvironmentNotFound              = &I18nError{Key: "dev_environment.not_found"}
+	ErrEnvironmentNameExists               = &I18nError{Key: "dev_environment.name_exists"}
+	ErrEnvironmentDockerImageRequired      = &I18nError{Key: "dev_environment.docker_image_required"}
+	ErrEnvironmentCPULimitInvalid          = &I18nError{Key: "dev_environment.cpu_limit_invalid"}
+	ErrEnvironmentMemoryLimitInvalid       = &I18nError{Key: "dev_environment.memory_limit_invalid"}
+	ErrEnvironmentNameRequired             = &I18nError{Key: "dev_environment.name_required"}
+	ErrEnvironmentImagesConfigFailed       = &I18nError{Key: "dev_environment.images_config_failed"}
+	ErrEnvironmentImagesConfigParseError   = &I18nError{Key: "dev_environment.images_config_parse_error"}
+	ErrEnvironmentUnsupportedType          = &I18nError{Key: "dev_environment.unsupported_type"}
+	ErrEnvironmentVarKeyEmpty              = &I18nError{Key: "dev_environment.var_key_empty"}
+	ErrEnvironmentVarKeyInvalidChar        = &I18nError{Key: "dev_environment.var_key_invalid_char"}
+	ErrEnvironmentNetworkModeInvalid       = &I18nError{Key: "dev_environment.network_mode_invalid"}
+	ErrEnvironmentGPUUnsupported           = &I18nError{Key: "dev_environment.gpu_unsupported"}
+	ErrEnvironmentGPUDeviceInvalid         = &I18nError{Key: "dev_environment.gpu_device_invalid"}
+	ErrEnvironmentUlimitInvalid            = &I18nError{Key: "dev_environment.ulimit_invalid"}
+	ErrEnvironmentEnvFileInvalid           = &I18nError{Key: "dev_environment.env_file_invalid"}
+	ErrEnvironmentPermissionModeInvalid    = &I18nError{Key: "dev_environment.permission_mode_invalid"}
+	ErrEnvironmentExtraDockerArgInvalid    = &I18nError{Key: "dev_environment.extra_docker_arg_invalid"}
+	ErrEnvironmentExtraDockerArgNotAllowed = &I18nError{Key: "dev_environment.extra_docker_arg_not_allowed"}
+	ErrEnvironmentRunAsUserInvalid         = &I18nError{Key: "dev_environment.run_as_user_invalid"}
+	ErrEnvironmentConcurrencyWeightInvalid = &I18nError{Key: "dev_environment.concurrency_weight_invalid"}
+	ErrEnvironmentTmpfsInvalid             = &I18nError{Key: "dev_environment.tmpfs_invalid"}
+	ErrEnvironmentAllowedToolsInvalid      = &I18nError{Key: "dev_environment.allowed_tools_invalid"}
+
+	ErrConversationGetFailed           = &I18nError{Key: "taskConversation.get_failed"}
+	ErrConversationNotFound            = &I18nError{Key: "taskConversation.not_found"}
+	ErrConversationCreateFailed        = &I18nError{Key: "taskConversation.create_failed"}
+	ErrConversationTaskCompleted       = &I18nError{Key: "taskConversation.task_completed"}
+	ErrConversationDeleteFailed        = &I18nError{Key: "taskConversation.delete_failed"}
+	ErrConversationDeleteLatestOnly    = &I18nError{Key: "taskConversation.delete_latest_only"}
+	ErrConversationNotDraft            = &I18nError{Key: "taskConversation.not_draft"}
+	ErrConversationDuplicate           = &I18nError{Key: "taskConversation.duplicate"}
+	ErrConversationDuplicateConflict   = &I18nError{Key: "taskConversation.duplicate_conflict"}
+	ErrConversationSearchQueryInvalid  = &I18nError{Key: "taskConversation.search_query_invalid"}
+	ErrConversationRunQuotaExceeded    = &I18nError{Key: "taskConversation.run_quota_exceeded"}
+	ErrConversationCostQuotaExceeded   = &I18nError{Key: "taskConversation.cost_quota_exceeded"}
+	ErrConversationNotAwaitingApproval = &I18nError{Key: "taskConversation.not_awaiting_approval"}
+	ErrConversationNoIsolatedBranch    = &I18nError{Key: "taskConversation.no_isolated_branch"}
 
 	ErrConversationResultCheckFailed = &I18nError{Key: "taskConversationResult.check_failed"}
 	ErrConversationResultExists      = &I18nError{Key: "taskConversationResult.already_exists"}
@@ -87,14 +151,24 @@ var (
 	ErrNoDevEnvironment   = &I18nError{Key: "task_execution.no_dev_environment"}
 	ErrUpdateStatusFailed = &I18nError{Key: "task_execution.update_status_failed"}
 
+	ErrExecutionLogOffsetInvalid  = &I18nError{Key: "task_execution_log.offset_invalid"}
+	ErrEffectivePromptNotRecorded = &I18nError{Key: "task_execution_log.effective_prompt_not_recorded"}
+
 	ErrProjectHasInProgressTasks = &I18nError{Key: "project.delete_has_in_progress_tasks"}
 	ErrCredentialUsedByProjects  = &I18nError{Key: "git_credential.delete_used_by_projects"}
 	ErrEnvironmentUsedByTasks    = &I18nError{Key: "dev_environment.delete_used_by_tasks"}
 
-	ErrSystemConfigKeyRequired      = &I18nError{Key: "system_config.key_required"}
-	ErrSystemConfigValueRequired    = &I18nError{Key: "system_config.value_required"}
-	ErrSystemConfigCategoryRequired = &I18nError{Key: "system_config.category_required"}
-	ErrSystemConfigInvalidKeyFormat = &I18nError{Key: "system_config.invalid_key_format"}
+	ErrSystemConfigKeyRequired          = &I18nError{Key: "system_config.key_required"}
+	ErrSystemConfigValueRequired        = &I18nError{Key: "system_config.value_required"}
+	ErrSystemConfigCategoryRequired     = &I18nError{Key: "system_config.category_required"}
+	ErrSystemConfigInvalidKeyFormat     = &I18nError{Key: "system_config.invalid_key_format"}
+	ErrSystemConfigInvalidDuration      = &I18nError{Key: "system_config.invalid_duration"}
+	ErrSystemConfigInvalidInt           = &I18nError{Key: "system_config.invalid_int"}
+	ErrSystemConfigIntTooSmall          = &I18nError{Key: "system_config.int_too_small"}
+	ErrSystemConfigInvalidBool          = &I18nError{Key: "system_config.invalid_bool"}
+	ErrSystemConfigInvalidJSON          = &I18nError{Key: "system_config.invalid_json"}
+	ErrSystemConfigInvalidOption        = &I18nError{Key: "system_config.invalid_option"}
+	ErrSystemConfigEncryptionKeyMissing = &I18nError{Key: "system_config.encryption_key_missing"}
 
 	ErrTaskIDsEmpty         = &I18nError{Key: "validation.required"}
 	ErrTooManyTasksForBatch = &I18nError{Key: "validation.too_many"}
@@ -102,4 +176,17 @@ var (
 	ErrFilePathEmpty      = &I18nError{Key: "validation.required"}
 	ErrWorkspacePathEmpty = &I18nError{Key: "task.workspace_path_empty"}
 	ErrNoCommitHash       = &I18nError{Key: "taskConversation.no_commit_hash"}
+
+	ErrConversationWorkspaceUnavailable = &I18nError{Key: "taskConversation.workspace_unavailable"}
+	ErrConversationCommitUnavailable    = &I18nError{Key: "taskConversation.commit_unavailable"}
+
+	ErrTaskWorkspaceUnavailable       = &I18nError{Key: "task.workspace_unavailable"}
+	ErrTaskWorkspaceBusy              = &I18nError{Key: "task.workspace_busy"}
+	ErrWorkspaceSnapshotNotFound      = &I18nError{Key: "task.workspace_snapshot_not_found"}
+	ErrWorkspaceSnapshotFailed        = &I18nError{Key: "task.workspace_snapshot_failed"}
+	ErrWorkspaceSnapshotTooLarge      = &I18nError{Key: "task.workspace_snapshot_too_large"}
+	ErrWorkspaceSnapshotRestoreFailed = &I18nError{Key: "task.workspace_snapshot_restore_failed"}
+
+	ErrTaskExportFormatInvalid     = &I18nError{Key: "task.export_format_invalid"}
+	ErrTaskImportSchemaUnsupported = &I18nError{Key: "task.import_schema_unsupported"}
 )
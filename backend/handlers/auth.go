@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -128,7 +129,7 @@ func (h *AuthHandlers) LogoutHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} object{username=string} "User information"
+// @Success 200 {object} object{user=string,authenticated=bool,preferred_language=string} "User information"
 // @Failure 500 {object} object{error=string} "Failed to get user information"
 // @Router /user/current [get]
 func (h *AuthHandlers) CurrentUserHandler(c *gin.Context) {
@@ -142,10 +143,60 @@ func (h *AuthHandlers) CurrentUserHandler(c *gin.Context) {
 		return
 	}
 
+	preferredLanguage, err := h.authService.GetPreferredLanguage(username.(string))
+	if err != nil {
+		utils.Warn("Failed to get preferred language of user", "username", username, "error", err)
+		preferredLanguage = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":               username,
+		"authenticated":      true,
+		"preferred_language": preferredLanguage,
+		"message":            i18n.T(lang, "user.authenticated"),
+	})
+}
+
+// UpdatePreferencesHandler updates the current user's preferences
+// @Summary Update current user preferences
+// @Description Set the language API messages use for the current user's requests that send no lang query, taking precedence over Accept-Language. An empty language removes the preference
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object{preferred_language=string} true "User preferences"
+// @Success 200 {object} object{message=string,preferred_language=string} "Preferences updated successfully"
+// @Failure 400 {object} object{error=string} "Invalid request or unsupported language"
+// @Failure 500 {object} object{error=string} "Failed to update preferences"
+// @Router /user/preferences [put]
+func (h *AuthHandlers) UpdatePreferencesHandler(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	var req struct {
+		PreferredLanguage string `json:"preferred_language"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error()),
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	if err := h.authService.SetPreferredLanguage(username.(string), req.PreferredLanguage); err != nil {
+		helper := i18n.NewHelper(lang)
+		if err == appErrors.ErrUnsupportedLanguage {
+			helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+			return
+		}
+		utils.Error("Failed to update user preferences", "username", username, "error", err)
+		helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"user":          username,
-		"authenticated": true,
-		"message":       i18n.T(lang, "user.authenticated"),
+		"message":            i18n.T(lang, "user.preferences_updated"),
+		"preferred_language": req.PreferredLanguage,
 	})
 }
 
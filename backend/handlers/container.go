@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/i18n"
+	"xsha-backend/middleware"
+	"xsha-backend/services"
+	"xsha-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContainerHandlers struct {
+	aiTaskExecutor services.AITaskExecutorService
+}
+
+func NewContainerHandlers(aiTaskExecutor services.AITaskExecutorService) *ContainerHandlers {
+	return &ContainerHandlers{
+		aiTaskExecutor: aiTaskExecutor,
+	}
+}
+
+// ListContainers lists conversation containers
+// @Summary List conversation containers
+// @Description List the xsha-task- containers known to the container runtime with their task and conversation IDs. Containers no running execution owns have tracked set to false.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{containers=[]services.ManagedContainer,total=int} "Conversation containers"
+// @Failure 500 {object} object{error=string} "Failed to list containers"
+// @Router /admin/containers [get]
+func (h *ContainerHandlers) ListContainers(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	containers, err := h.aiTaskExecutor.ListManagedContainers()
+	if err != nil {
+		utils.Error("Failed to list conversation containers", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "container.list_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"containers": containers,
+		"total":      len(containers),
+	})
+}
+
+// RemoveContainer stops and removes an orphaned conversation container
+// @Summary Remove conversation container
+// @Description Stop and remove a conversation container left behind by an unclean shutdown. Containers of running conversations are refused.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Container name" example(xsha-task-1-conv-2)
+// @Success 200 {object} object{message=string} "Container removed"
+// @Failure 400 {object} object{error=string} "Invalid container name"
+// @Failure 409 {object} object{error=string} "Container belongs to a running conversation"
+// @Failure 500 {object} object{error=string} "Failed to remove container"
+// @Router /admin/containers/{name} [delete]
+func (h *ContainerHandlers) RemoveContainer(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+	helper := i18n.NewHelper(lang)
+
+	if err := h.aiTaskExecutor.RemoveManagedContainer(c.Param("name")); err != nil {
+		if err == appErrors.ErrContainerNameInvalid {
+			helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err == appErrors.ErrContainerInUse {
+			helper.ErrorResponseFromError(c, http.StatusConflict, err)
+			return
+		}
+		utils.Error("Failed to remove conversation container", "container", c.Param("name"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "container.remove_success")})
+}
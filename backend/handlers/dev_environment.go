@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -29,7 +30,21 @@ type CreateEnvironmentRequest struct {
 	DockerImage  string            `json:"docker_image" binding:"required"`
 	CPULimit     float64           `json:"cpu_limit" binding:"min=0.1,max=16"`
 	MemoryLimit  int64             `json:"memory_limit" binding:"min=128,max=32768"`
-	EnvVars      map[string]string `json:"env_vars"`
+	NetworkMode  string            `json:"network_mode" example:"bridge"`
+	GPUEnabled   bool              `json:"gpu_enabled" example:"false"`
+	GPUDevice    string            `json:"gpu_device" example:"0,1"`
+	Ulimits      map[string]string `json:"ulimits"`
+	// A value of secret://<name> is resolved from the stored secret when the container starts
+	EnvVars map[string]string `json:"env_vars" example:"{\"ANTHROPIC_API_KEY\":\"secret://anthropic-key\"}"`
+
+	// ExtraDockerArgs are allowlisted docker run flags written as --flag or --flag=value
+	ExtraDockerArgs []string `json:"extra_docker_args" example:"--shm-size=1g,--tmpfs=/tmp"`
+
+	// Hardening options, the workspace at /app stays writable with a read-only root filesystem
+	ReadOnlyRootFS  bool     `json:"read_only_root_fs" example:"true"`
+	NoNewPrivileges bool     `json:"no_new_privileges" example:"true"`
+	RunAsUser       string   `json:"run_as_user" example:"1000:1000"`
+	TmpfsMounts     []string `json:"tmpfs_mounts" example:"/tmp:rw,size=256m"`
 }
 
 // @Description Update environment request
@@ -39,7 +54,31 @@ type UpdateEnvironmentRequest struct {
 	SystemPrompt string            `json:"system_prompt"`
 	CPULimit     float64           `json:"cpu_limit"`
 	MemoryLimit  int64             `json:"memory_limit"`
-	EnvVars      map[string]string `json:"env_vars"`
+	NetworkMode  string            `json:"network_mode" example:"bridge"`
+	GPUEnabled   *bool             `json:"gpu_enabled" example:"false"`
+	GPUDevice    *string           `json:"gpu_device" example:"0,1"`
+	Ulimits      map[string]string `json:"ulimits"`
+	// A value of secret://<name> is resolved from the stored secret when the container starts
+	EnvVars map[string]string `json:"env_vars" example:"{\"ANTHROPIC_API_KEY\":\"secret://anthropic-key\"}"`
+
+	// EnvFiles maps env var names to files under XSHA_ENV_FILES_DIR, read when the container starts
+	EnvFiles map[string]string `json:"env_files" example:"{\"GOOGLE_APPLICATION_CREDENTIALS_JSON\":\"gcp/service-account.json\"}"`
+
+	// PermissionMode is "skip" to run tools without approval, or "restricted" to only allow edits and AllowedTools
+	PermissionMode *string  `json:"permission_mode" example:"restricted"`
+	AllowedTools   []string `json:"allowed_tools" example:"Bash(git:*),Bash(npm test)"`
+
+	// ExtraDockerArgs are allowlisted docker run flags written as --flag or --flag=value
+	ExtraDockerArgs []string `json:"extra_docker_args" example:"--shm-size=1g,--tmpfs=/tmp"`
+
+	// Hardening options, the workspace at /app stays writable with a read-only root filesystem
+	ReadOnlyRootFS  *bool    `json:"read_only_root_fs" example:"true"`
+	NoNewPrivileges *bool    `json:"no_new_privileges" example:"true"`
+	RunAsUser       *string  `json:"run_as_user" example:"1000:1000"`
+	TmpfsMounts     []string `json:"tmpfs_mounts" example:"/tmp:rw,size=256m"`
+
+	// Share of the executor capacity a run uses, 0 derives it from the CPU and memory limits
+	ConcurrencyWeight *int `json:"concurrency_weight" example:"4"`
 }
 
 // CreateEnvironment creates a development environment
@@ -78,7 +117,8 @@ func (h *DevEnvironmentHandlers) CreateEnvironment(c *gin.Context) {
 
 	env, err := h.devEnvService.CreateEnvironment(
 		req.Name, req.Description, req.SystemPrompt, req.Type, req.DockerImage,
-		req.CPULimit, req.MemoryLimit, req.EnvVars, username.(string),
+		req.CPULimit, req.MemoryLimit, req.NetworkMode, req.GPUEnabled, req.GPUDevice, req.Ulimits, req.ExtraDockerArgs,
+		req.ReadOnlyRootFS, req.NoNewPrivileges, req.RunAsUser, req.TmpfsMounts, req.EnvVars, username.(string),
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -140,6 +180,10 @@ func (h *DevEnvironmentHandlers) GetEnvironment(c *gin.Context) {
 // @Param page_size query int false "Page size, default is 10"
 // @Param name query string false "Environment name filter"
 // @Param docker_image query string false "Docker image filter"
+// @Param min_cpu query number false "Minimum CPU limit in cores"
+// @Param max_cpu query number false "Maximum CPU limit in cores"
+// @Param min_memory query int false "Minimum memory limit in MB"
+// @Param max_memory query int false "Maximum memory limit in MB"
 // @Success 200 {object} object{environments=[]object,total=number} "Environment list"
 // @Router /environments [get]
 func (h *DevEnvironmentHandlers) ListEnvironments(c *gin.Context) {
@@ -167,7 +211,20 @@ func (h *DevEnvironmentHandlers) ListEnvironments(c *gin.Context) {
 		dockerImage = &di
 	}
 
-	environments, total, err := h.devEnvService.ListEnvironments(name, dockerImage, page, pageSize)
+	minCPU, invalidMinCPU := optionalFloatQuery(c, "min_cpu")
+	maxCPU, invalidMaxCPU := optionalFloatQuery(c, "max_cpu")
+	minMemory, invalidMinMemory := optionalIntQuery(c, "min_memory")
+	maxMemory, invalidMaxMemory := optionalIntQuery(c, "max_memory")
+	for _, param := range []string{invalidMinCPU, invalidMaxCPU, invalidMinMemory, invalidMaxMemory} {
+		if param != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": i18n.T(lang, "validation.invalid_format_with_details", param),
+			})
+			return
+		}
+	}
+
+	environments, total, err := h.devEnvService.ListEnvironments(name, dockerImage, minCPU, maxCPU, minMemory, maxMemory, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": i18n.T(lang, "dev_environment.list_failed"),
@@ -233,6 +290,45 @@ func (h *DevEnvironmentHandlers) UpdateEnvironment(c *gin.Context) {
 	if req.MemoryLimit > 0 {
 		updates["memory_limit"] = req.MemoryLimit
 	}
+	if req.NetworkMode != "" {
+		updates["network_mode"] = req.NetworkMode
+	}
+	if req.GPUEnabled != nil {
+		updates["gpu_enabled"] = *req.GPUEnabled
+	}
+	if req.GPUDevice != nil {
+		updates["gpu_device"] = *req.GPUDevice
+	}
+	if req.Ulimits != nil {
+		updates["ulimits"] = req.Ulimits
+	}
+	if req.EnvFiles != nil {
+		updates["env_files"] = req.EnvFiles
+	}
+	if req.PermissionMode != nil {
+		updates["permission_mode"] = *req.PermissionMode
+	}
+	if req.AllowedTools != nil {
+		updates["allowed_tools"] = req.AllowedTools
+	}
+	if req.ExtraDockerArgs != nil {
+		updates["extra_docker_args"] = req.ExtraDockerArgs
+	}
+	if req.ReadOnlyRootFS != nil {
+		updates["read_only_root_fs"] = *req.ReadOnlyRootFS
+	}
+	if req.NoNewPrivileges != nil {
+		updates["no_new_privileges"] = *req.NoNewPrivileges
+	}
+	if req.RunAsUser != nil {
+		updates["run_as_user"] = *req.RunAsUser
+	}
+	if req.TmpfsMounts != nil {
+		updates["tmpfs_mounts"] = req.TmpfsMounts
+	}
+	if req.ConcurrencyWeight != nil {
+		updates["concurrency_weight"] = *req.ConcurrencyWeight
+	}
 
 	err = h.devEnvService.UpdateEnvironment(uint(id), updates)
 	if err != nil {
@@ -424,3 +520,73 @@ func (h *DevEnvironmentHandlers) GetStats(c *gin.Context) {
 		"stats": stats,
 	})
 }
+
+// CompareEnvironments compares two development environments
+// @Summary Compare development environments
+// @Description Get a structured diff of two environments' settings, env var, env file and ulimit keys, to find duplicates
+// @Tags Development Environment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param a query int true "Baseline environment ID"
+// @Param b query int true "Environment ID to compare against the baseline"
+// @Success 200 {object} object{comparison=services.EnvironmentComparison} "Environment comparison"
+// @Failure 400 {object} object{error=string} "Invalid environment ID"
+// @Failure 404 {object} object{error=string} "Environment not found"
+// @Router /environments/compare [get]
+func (h *DevEnvironmentHandlers) CompareEnvironments(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	aID, errA := strconv.ParseUint(c.Query("a"), 10, 32)
+	bID, errB := strconv.ParseUint(c.Query("b"), 10, 32)
+	if errA != nil || errB != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "dev_environment.invalid_id"),
+		})
+		return
+	}
+
+	comparison, err := h.devEnvService.CompareEnvironments(uint(aID), uint(bID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == appErrors.ErrDevEnvironmentNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": i18n.MapErrorToI18nKey(err, lang),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comparison": comparison,
+	})
+}
+
+// optionalFloatQuery parses a non-negative number query parameter, nil when it is absent.
+// The parameter name is returned when its value is invalid.
+func optionalFloatQuery(c *gin.Context, param string) (*float64, string) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, ""
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil || parsed < 0 {
+		return nil, param
+	}
+	return &parsed, ""
+}
+
+// optionalIntQuery parses a non-negative integer query parameter, nil when it is absent.
+// The parameter name is returned when its value is invalid.
+func optionalIntQuery(c *gin.Context, param string) (*int64, string) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, ""
+	}
+	parsed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || parsed < 0 {
+		return nil, param
+	}
+	return &parsed, ""
+}
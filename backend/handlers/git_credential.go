@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -25,9 +26,11 @@ func NewGitCredentialHandlers(gitCredService services.GitCredentialService) *Git
 type CreateCredentialRequest struct {
 	Name        string            `json:"name" binding:"required" example:"My GitHub Credential"`
 	Description string            `json:"description" example:"Credential for GitHub projects"`
-	Type        string            `json:"type" binding:"required,oneof=password token ssh_key" example:"password"`
+	Type        string            `json:"type" binding:"required,oneof=password token ssh_key github_app" example:"password"`
 	Username    string            `json:"username" example:"myusername"`
 	SecretData  map[string]string `json:"secret_data" binding:"required" example:"{\"password\":\"mypassword\"}"`
+	// Proxy overrides the global git proxy for repositories accessed with this credential
+	Proxy services.GitCredentialProxy `json:"proxy"`
 }
 
 // @Description Request parameters for updating Git credentials
@@ -36,11 +39,13 @@ type UpdateCredentialRequest struct {
 	Description string            `json:"description" example:"Updated description"`
 	Username    string            `json:"username" example:"newusername"`
 	SecretData  map[string]string `json:"secret_data" example:"{\"password\":\"newpassword\"}"`
+	// Proxy replaces the proxy override when set
+	Proxy *services.GitCredentialProxy `json:"proxy"`
 }
 
 // CreateCredential creates a Git credential
 // @Summary Create Git credential
-// @Description Create a new Git credential, supporting password, token, and SSH key types
+// @Description Create a new Git credential, supporting password, token, SSH key, and GitHub App types (secret_data holds app_id, installation_id and private_key)
 // @Tags Git Credentials
 // @Accept json
 // @Produce json
@@ -71,7 +76,7 @@ func (h *GitCredentialHandlers) CreateCredential(c *gin.Context) {
 
 	credential, err := h.gitCredService.CreateCredential(
 		req.Name, req.Description, req.Type, req.Username,
-		req.SecretData, username.(string),
+		req.SecretData, req.Proxy, username.(string),
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -225,6 +230,9 @@ func (h *GitCredentialHandlers) UpdateCredential(c *gin.Context) {
 
 	updates["description"] = req.Description
 	updates["username"] = req.Username
+	if req.Proxy != nil {
+		updates["proxy"] = *req.Proxy
+	}
 
 	err = h.gitCredService.UpdateCredential(uint(id), updates, req.SecretData)
 	if err != nil {
@@ -239,6 +247,61 @@ func (h *GitCredentialHandlers) UpdateCredential(c *gin.Context) {
 	})
 }
 
+// @Description Request parameters for testing a Git credential
+type TestCredentialRequest struct {
+	RepoURL string `json:"repo_url" binding:"required" example:"https://github.com/user/repo.git"`
+}
+
+// TestCredential tests a Git credential against a repository
+// @Summary Test Git credential
+// @Description Check that a credential can access a repository by running git ls-remote with it, using the configured SSL verification and proxy settings
+// @Tags Git Credentials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Credential ID"
+// @Param request body TestCredentialRequest true "Repository to test against"
+// @Success 200 {object} object{message=string,result=object{can_access=bool,error_message=string,branches=[]string}} "Test result"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 404 {object} object{error=string} "Credential not found"
+// @Router /credentials/{id}/test [post]
+func (h *GitCredentialHandlers) TestCredential(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format"),
+		})
+		return
+	}
+
+	var req TestCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error()),
+		})
+		return
+	}
+
+	result, err := h.gitCredService.TestCredential(uint(id), req.RepoURL)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == appErrors.ErrCredentialNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": i18n.MapErrorToI18nKey(err, lang),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "common.success"),
+		"result":  result,
+	})
+}
+
 // DeleteCredential deletes a Git credential
 // @Summary Delete Git credential
 // @Description Delete a specified Git credential
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"sync"
+	"time"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
+	"xsha-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,3 +28,87 @@ func HealthHandler(c *gin.Context) {
 		"lang":    lang,
 	})
 }
+
+// dockerCheckCacheTTL keeps readiness probes from spawning a runtime process on every call
+const dockerCheckCacheTTL = 5 * time.Second
+
+// SchedulerStatus reports whether a background scheduler is running
+type SchedulerStatus interface {
+	IsRunning() bool
+	IsPaused() bool
+}
+
+type HealthHandlers struct {
+	aiTaskExecutor services.AITaskExecutorService
+	scheduler      SchedulerStatus
+
+	mu              sync.Mutex
+	dockerCheckedAt time.Time
+	dockerErr       error
+}
+
+func NewHealthHandlers(aiTaskExecutor services.AITaskExecutorService, scheduler SchedulerStatus) *HealthHandlers {
+	return &HealthHandlers{
+		aiTaskExecutor: aiTaskExecutor,
+		scheduler:      scheduler,
+	}
+}
+
+// ReadinessHandler reports whether the server can execute conversations
+// @Summary Readiness check
+// @Description Check container runtime availability and scheduler state
+// @Tags System
+// @Accept json
+// @Produce json
+// @Success 200 {object} object{status=string,message=string,checks=object} "Server is ready"
+// @Failure 503 {object} object{status=string,message=string,checks=object} "Server is not ready"
+// @Router /health/ready [get]
+func (h *HealthHandlers) ReadinessHandler(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+	ready := true
+
+	dockerCheck := gin.H{"status": "ok"}
+	if err := h.checkDocker(); err != nil {
+		ready = false
+		dockerCheck = gin.H{"status": "unavailable", "error": err.Error()}
+	}
+
+	schedulerCheck := gin.H{"status": "running", "paused": h.scheduler.IsPaused()}
+	if !h.scheduler.IsRunning() {
+		ready = false
+		schedulerCheck = gin.H{"status": "stopped"}
+	}
+
+	checks := gin.H{
+		"docker":    dockerCheck,
+		"scheduler": schedulerCheck,
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "not_ready",
+			"message": i18n.T(lang, "health.not_ready"),
+			"checks":  checks,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ready",
+		"message": i18n.T(lang, "health.ready"),
+		"checks":  checks,
+	})
+}
+
+func (h *HealthHandlers) checkDocker() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.dockerCheckedAt.IsZero() && time.Since(h.dockerCheckedAt) < dockerCheckCacheTTL {
+		return h.dockerErr
+	}
+
+	h.dockerErr = h.aiTaskExecutor.CheckDockerAvailability()
+	h.dockerCheckedAt = time.Now()
+	return h.dockerErr
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"xsha-backend/database"
@@ -31,6 +32,35 @@ type CreateProjectRequest struct {
 	RepoURL      string `json:"repo_url" binding:"required"`
 	Protocol     string `json:"protocol" binding:"required,oneof=https ssh"`
 	CredentialID *uint  `json:"credential_id"`
+	// Clone only recent history of the start branch, CloneDepth defaults to 1
+	ShallowClone bool `json:"shallow_clone" example:"false"`
+	CloneDepth   int  `json:"clone_depth" example:"1"`
+	// Initialize submodules recursively after cloning
+	RecurseSubmodules bool `json:"recurse_submodules" example:"false"`
+	// Commit identity and message template, empty values use the system defaults
+	CommitAuthorName      string `json:"commit_author_name" example:"XSHA AI"`
+	CommitAuthorEmail     string `json:"commit_author_email" example:"ai@xsha.dev"`
+	CommitMessageTemplate string `json:"commit_message_template" example:"{{.TaskTitle}}: conversation {{.ConversationID}}"`
+}
+
+// nullableInt tells an explicit JSON null apart from a missing field, so a null can reset an override
+type nullableInt struct {
+	Set   bool
+	Value *int
+}
+
+func (n *nullableInt) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	n.Value = &value
+	return nil
 }
 
 // @Description Update project request
@@ -40,6 +70,38 @@ type UpdateProjectRequest struct {
 	SystemPrompt string `json:"system_prompt" example:"Custom system prompt"`
 	RepoURL      string `json:"repo_url" example:"https://github.com/user/repo.git"`
 	CredentialID *uint  `json:"credential_id" example:"1"`
+
+	// null clears the override and falls back to the global retention, 0 keeps logs forever
+	ExecutionLogRetentionDays nullableInt `json:"execution_log_retention_days" swaggertype:"integer" example:"30"`
+	MaxConcurrentTasks        *int        `json:"max_concurrent_tasks" example:"2"`
+
+	ShallowClone *bool `json:"shallow_clone" example:"false"`
+	CloneDepth   *int  `json:"clone_depth" example:"1"`
+
+	RecurseSubmodules *bool `json:"recurse_submodules" example:"false"`
+
+	// Create task workspaces as worktrees of one shared clone instead of full clones
+	UseWorktrees *bool `json:"use_worktrees" example:"false"`
+
+	// Hold the AI's changes for approval before they are committed
+	RequireCommitApproval *bool `json:"require_commit_approval" example:"false"`
+
+	// Delete the remote work branch and remove the task workspace once the branch is merged
+	DeleteBranchAfterMerge    *bool `json:"delete_branch_after_merge" example:"false"`
+	RemoveWorkspaceAfterMerge *bool `json:"remove_workspace_after_merge" example:"false"`
+
+	CommitAuthorName      *string `json:"commit_author_name" example:"XSHA AI"`
+	CommitAuthorEmail     *string `json:"commit_author_email" example:"ai@xsha.dev"`
+	CommitMessageTemplate *string `json:"commit_message_template" example:"{{.TaskTitle}}: conversation {{.ConversationID}}"`
+
+	// Empty string clears the override and falls back to the global webhook
+	WebhookURL *string `json:"webhook_url" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+
+	// JSON object of allowlisted git config keys applied to task workspaces, empty string clears it
+	GitConfig *string `json:"git_config" example:"{\"core.autocrlf\":\"input\"}"`
+
+	// Cost cap in USD for the project's conversations, 0 removes it
+	CostBudgetUSD *float64 `json:"cost_budget_usd" example:"100"`
 }
 
 // CreateProject creates project
@@ -50,8 +112,8 @@ type UpdateProjectRequest struct {
 // @Produce json
 // @Security BearerAuth
 // @Param project body CreateProjectRequest true "Project information"
-// @Success 201 {object} object{id=number,message=string} "Project created successfully"
-// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Success 201 {object} object{message=string,project=object,size_warning=string,repository_size=object} "Project created successfully, with a size warning when the repository exceeds the clone size limit"
+// @Failure 400 {object} object{error=string} "Request parameter error, or repository too large to clone without shallow clone"
 // @Failure 500 {object} object{error=string} "Project creation failed"
 // @Router /projects [post]
 func (h *ProjectHandlers) CreateProject(c *gin.Context) {
@@ -73,9 +135,17 @@ func (h *ProjectHandlers) CreateProject(c *gin.Context) {
 		return
 	}
 
+	sizeCheck, err := h.projectService.CheckRepositorySize(req.RepoURL, req.CredentialID, req.ShallowClone)
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	project, err := h.projectService.CreateProject(
 		req.Name, req.Description, req.SystemPrompt, req.RepoURL, req.Protocol,
-		req.CredentialID, username.(string),
+		req.CredentialID, req.ShallowClone, req.CloneDepth, req.RecurseSubmodules,
+		req.CommitAuthorName, req.CommitAuthorEmail, req.CommitMessageTemplate, username.(string),
 	)
 	if err != nil {
 		helper := i18n.NewHelper(lang)
@@ -83,10 +153,15 @@ func (h *ProjectHandlers) CreateProject(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "project.create_success"),
 		"project": project,
-	})
+	}
+	if sizeCheck != nil {
+		response["size_warning"] = i18n.T(lang, "project.repository_size_warning")
+		response["repository_size"] = sizeCheck
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetProject gets single project
@@ -220,7 +295,7 @@ func (h *ProjectHandlers) ListProjects(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "Project ID"
 // @Param project body UpdateProjectRequest true "Project update information"
-// @Success 200 {object} object{message=string} "Project updated successfully"
+// @Success 200 {object} object{message=string,size_warning=string,repository_size=object} "Project updated successfully, with a size warning when a new repository exceeds the clone size limit"
 // @Failure 400 {object} object{error=string} "Request parameter error"
 // @Failure 404 {object} object{error=string} "Project not found"
 // @Router /projects/{id} [put]
@@ -258,6 +333,85 @@ func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
 
 	updates["credential_id"] = req.CredentialID
 
+	if req.ExecutionLogRetentionDays.Set {
+		updates["execution_log_retention_days"] = req.ExecutionLogRetentionDays.Value
+	}
+
+	if req.MaxConcurrentTasks != nil {
+		updates["max_concurrent_tasks"] = *req.MaxConcurrentTasks
+	}
+
+	if req.ShallowClone != nil {
+		updates["shallow_clone"] = *req.ShallowClone
+	}
+
+	if req.CloneDepth != nil {
+		updates["clone_depth"] = *req.CloneDepth
+	}
+
+	if req.RecurseSubmodules != nil {
+		updates["recurse_submodules"] = *req.RecurseSubmodules
+	}
+
+	if req.UseWorktrees != nil {
+		updates["use_worktrees"] = *req.UseWorktrees
+	}
+
+	if req.RequireCommitApproval != nil {
+		updates["require_commit_approval"] = *req.RequireCommitApproval
+	}
+
+	if req.DeleteBranchAfterMerge != nil {
+		updates["delete_branch_after_merge"] = *req.DeleteBranchAfterMerge
+	}
+
+	if req.RemoveWorkspaceAfterMerge != nil {
+		updates["remove_workspace_after_merge"] = *req.RemoveWorkspaceAfterMerge
+	}
+
+	if req.CommitAuthorName != nil {
+		updates["commit_author_name"] = *req.CommitAuthorName
+	}
+
+	if req.CommitAuthorEmail != nil {
+		updates["commit_author_email"] = *req.CommitAuthorEmail
+	}
+
+	if req.CommitMessageTemplate != nil {
+		updates["commit_message_template"] = *req.CommitMessageTemplate
+	}
+	if req.WebhookURL != nil {
+		updates["webhook_url"] = *req.WebhookURL
+	}
+	if req.GitConfig != nil {
+		updates["git_config"] = *req.GitConfig
+	}
+	if req.CostBudgetUSD != nil {
+		updates["cost_budget_usd"] = *req.CostBudgetUSD
+	}
+
+	// A new repository is checked against the clone size limit like on creation
+	var sizeCheck *services.RepositorySizeCheck
+	if req.RepoURL != "" {
+		project, err := h.projectService.GetProject(uint(id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "project.not_found")})
+			return
+		}
+		shallowClone := project.ShallowClone
+		if req.ShallowClone != nil {
+			shallowClone = *req.ShallowClone
+		}
+		if req.RepoURL != project.RepoURL {
+			sizeCheck, err = h.projectService.CheckRepositorySize(req.RepoURL, req.CredentialID, shallowClone)
+			if err != nil {
+				helper := i18n.NewHelper(lang)
+				helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+	}
+
 	err = h.projectService.UpdateProject(uint(id), updates)
 	if err != nil {
 		helper := i18n.NewHelper(lang)
@@ -265,9 +419,14 @@ func (h *ProjectHandlers) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "project.update_success"),
-	})
+	}
+	if sizeCheck != nil {
+		response["size_warning"] = i18n.T(lang, "project.repository_size_warning")
+		response["repository_size"] = sizeCheck
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DeleteProject deletes project
@@ -306,6 +465,49 @@ func (h *ProjectHandlers) DeleteProject(c *gin.Context) {
 	})
 }
 
+// PrewarmProject starts building the project's reference clone
+// @Summary Prewarm project workspaces
+// @Description Create or refresh a cached reference clone that new task workspaces clone from locally
+// @Tags Project
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Success 202 {object} object{message=string} "Prewarm started"
+// @Failure 400 {object} object{error=string} "Invalid project ID"
+// @Failure 404 {object} object{error=string} "Project not found"
+// @Router /projects/{id}/prewarm [post]
+func (h *ProjectHandlers) PrewarmProject(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format"),
+		})
+		return
+	}
+
+	if _, err := h.projectService.GetProject(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "project.not_found"),
+		})
+		return
+	}
+
+	// Cloning a large repository can outlast the request, so it runs in the background
+	go func(projectID uint) {
+		if err := h.projectService.PrewarmProject(projectID); err != nil {
+			utils.Error("Failed to prewarm project", "projectID", projectID, "error", err)
+		}
+	}(uint(id))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": i18n.T(lang, "project.prewarm_started"),
+	})
+}
+
 // GetCompatibleCredentials gets credential list compatible with protocol
 // @Summary Get compatible credentials
 // @Description Get Git credential list compatible with protocol type
@@ -354,10 +556,12 @@ type ParseRepositoryURLResponse struct {
 	Owner    string `json:"owner" example:"user"`
 	Repo     string `json:"repo" example:"repo"`
 	IsValid  bool   `json:"is_valid" example:"true"`
+	// NormalizedURL is the form the project stores, empty when the URL is invalid
+	NormalizedURL string `json:"normalized_url" example:"https://github.com/user/repo.git"`
 }
 
 // @Summary Parse repository URL
-// @Description Parse repository URL automatically detect protocol type and parse URL information
+// @Description Parse repository URL automatically detect protocol type and parse URL information, including the normalized URL a project would store
 // @Tags Project
 // @Accept json
 // @Produce json
@@ -385,6 +589,8 @@ func (h *ProjectHandlers) ParseRepositoryURL(c *gin.Context) {
 		Owner:    urlInfo.Owner,
 		Repo:     urlInfo.Repo,
 		IsValid:  urlInfo.IsValid,
+
+		NormalizedURL: urlInfo.NormalizedURL,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -493,3 +699,130 @@ func (h *ProjectHandlers) ValidateRepositoryAccess(c *gin.Context) {
 		"can_access": true,
 	})
 }
+
+// RevalidateAllProjects re-validates repository access for all projects
+// @Summary Re-validate repository access for all projects
+// @Description Run repository access validation for every project and report the projects that can no longer be accessed
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string,report=services.ProjectRevalidationReport} "Revalidation report"
+// @Failure 500 {object} object{error=string} "Failed to revalidate projects"
+// @Router /admin/projects/revalidate [post]
+func (h *ProjectHandlers) RevalidateAllProjects(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	report, err := h.projectService.RevalidateAllProjects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "project.revalidate_failed"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "project.revalidate_success"),
+		"report":  report,
+	})
+}
+
+// GetProjectBudget gets the project's AI cost budget usage
+// @Summary Get project cost budget
+// @Description Get the project's cost budget, the AI cost spent so far and what remains
+// @Tags Project
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} object{budget=services.ProjectBudget} "Project budget"
+// @Failure 400 {object} object{error=string} "Invalid project ID"
+// @Failure 404 {object} object{error=string} "Project not found"
+// @Router /projects/{id}/budget [get]
+func (h *ProjectHandlers) GetProjectBudget(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format"),
+		})
+		return
+	}
+
+	if _, err := h.projectService.GetProject(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "project.not_found"),
+		})
+		return
+	}
+
+	budget, err := h.projectService.GetProjectBudget(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "project.budget_get_failed"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"budget": budget,
+	})
+}
+
+type BudgetOverrideRequest struct {
+	Enabled *bool `json:"enabled" binding:"required" example:"true"`
+}
+
+// SetBudgetOverride lets a project's conversations run past its cost budget
+// @Summary Override project cost budget
+// @Description Allow or stop conversations of a project from running after its cost budget is spent
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Param request body BudgetOverrideRequest true "Override state"
+// @Success 200 {object} object{message=string} "Budget override updated"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 404 {object} object{error=string} "Project not found"
+// @Router /admin/projects/{id}/budget-override [put]
+func (h *ProjectHandlers) SetBudgetOverride(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format"),
+		})
+		return
+	}
+
+	var req BudgetOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error()),
+		})
+		return
+	}
+
+	if _, err := h.projectService.GetProject(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "project.not_found"),
+		})
+		return
+	}
+
+	if err := h.projectService.SetBudgetOverride(uint(id), *req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.MapErrorToI18nKey(err, lang),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "project.budget_override_updated"),
+	})
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"xsha-backend/i18n"
+	"xsha-backend/middleware"
+	"xsha-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledTaskHandlers struct {
+	scheduledTaskService services.ScheduledTaskService
+}
+
+func NewScheduledTaskHandlers(scheduledTaskService services.ScheduledTaskService) *ScheduledTaskHandlers {
+	return &ScheduledTaskHandlers{
+		scheduledTaskService: scheduledTaskService,
+	}
+}
+
+// @Description Create scheduled task request
+type CreateScheduledTaskRequest struct {
+	Name string `json:"name" binding:"required" example:"Nightly dependency update"`
+	// Five fields evaluated in UTC or a descriptor such as @daily, CRON_TZ= selects another time zone
+	CronExpression string `json:"cron_expression" binding:"required" example:"0 2 * * *"`
+	TaskID         uint   `json:"task_id" binding:"required" example:"1"`
+	// Conversation content, the template's conversation template is used when it is empty
+	Content    string `json:"content" example:"Update all dependencies to their latest compatible versions"`
+	TemplateID *uint  `json:"template_id" example:"1"`
+	Enabled    *bool  `json:"enabled" example:"true"`
+}
+
+// @Description Update scheduled task request
+type UpdateScheduledTaskRequest struct {
+	Name           *string `json:"name" example:"Nightly dependency update"`
+	CronExpression *string `json:"cron_expression" example:"0 2 * * *"`
+	Content        *string `json:"content" example:"Update all dependencies to their latest compatible versions"`
+	// 0 removes the template
+	TemplateID *uint `json:"template_id" example:"1"`
+	Enabled    *bool `json:"enabled" example:"false"`
+}
+
+// CreateScheduledTask creates a scheduled task
+// @Summary Create scheduled task
+// @Description Create a cron schedule that adds a conversation to a task each time it fires
+// @Tags Scheduled Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param schedule body CreateScheduledTaskRequest true "Scheduled task information"
+// @Success 201 {object} object{message=string,data=database.ScheduledTask} "Scheduled task created successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Router /scheduled-tasks [post]
+func (h *ScheduledTaskHandlers) CreateScheduledTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "auth.unauthorized"),
+		})
+		return
+	}
+
+	var req CreateScheduledTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	schedule, err := h.scheduledTaskService.CreateSchedule(req.Name, req.CronExpression, req.TaskID, req.TemplateID, req.Content, enabled, username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(lang, "scheduled_task.create_success"),
+		"data":    schedule,
+	})
+}
+
+// GetScheduledTask gets a scheduled task
+// @Summary Get scheduled task
+// @Description Get a scheduled task with its last and next run
+// @Tags Scheduled Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Scheduled task ID"
+// @Success 200 {object} object{data=database.ScheduledTask} "Scheduled task"
+// @Failure 400 {object} object{error=string} "Invalid scheduled task ID"
+// @Failure 404 {object} object{error=string} "Scheduled task not found"
+// @Router /scheduled-tasks/{id} [get]
+func (h *ScheduledTaskHandlers) GetScheduledTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	schedule, err := h.scheduledTaskService.GetSchedule(uint(id), username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": schedule})
+}
+
+// ListScheduledTasks lists scheduled tasks
+// @Summary List scheduled tasks
+// @Description List scheduled tasks with their last and next run
+// @Tags Scheduled Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)" default(1)
+// @Param page_size query int false "Number of items per page (default: 20)" default(20)
+// @Param task_id query int false "Filter by task ID"
+// @Param enabled query bool false "Filter by enabled state"
+// @Success 200 {object} object{data=object{schedules=[]database.ScheduledTask,total=int,page=int,page_size=int}} "Scheduled tasks"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 500 {object} object{error=string} "Internal server error"
+// @Router /scheduled-tasks [get]
+func (h *ScheduledTaskHandlers) ListScheduledTasks(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	var taskID *uint
+	if tid := c.Query("task_id"); tid != "" {
+		if id, err := strconv.ParseUint(tid, 10, 32); err == nil {
+			tidUint := uint(id)
+			taskID = &tidUint
+		}
+	}
+
+	var enabled *bool
+	if e := c.Query("enabled"); e != "" {
+		if parsed, err := strconv.ParseBool(e); err == nil {
+			enabled = &parsed
+		}
+	}
+
+	username, _ := c.Get("username")
+	schedules, total, err := h.scheduledTaskService.ListSchedules(username.(string), taskID, enabled, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"schedules": schedules,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// UpdateScheduledTask updates a scheduled task
+// @Summary Update scheduled task
+// @Description Update the fields of a scheduled task present in the request, the next run is recomputed
+// @Tags Scheduled Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Scheduled task ID"
+// @Param schedule body UpdateScheduledTaskRequest true "Scheduled task update information"
+// @Success 200 {object} object{message=string,data=database.ScheduledTask} "Scheduled task updated successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 404 {object} object{error=string} "Scheduled task not found"
+// @Router /scheduled-tasks/{id} [put]
+func (h *ScheduledTaskHandlers) UpdateScheduledTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	var req UpdateScheduledTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.CronExpression != nil {
+		updates["cron_expression"] = *req.CronExpression
+	}
+	if req.Content != nil {
+		updates["content"] = *req.Content
+	}
+	if req.TemplateID != nil {
+		if *req.TemplateID == 0 {
+			updates["template_id"] = (*uint)(nil)
+		} else {
+			updates["template_id"] = req.TemplateID
+		}
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	username, _ := c.Get("username")
+	schedule, err := h.scheduledTaskService.UpdateSchedule(uint(id), username.(string), updates)
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "scheduled_task.update_success"),
+		"data":    schedule,
+	})
+}
+
+// DeleteScheduledTask deletes a scheduled task
+// @Summary Delete scheduled task
+// @Description Delete a scheduled task, conversations it already created are kept
+// @Tags Scheduled Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Scheduled task ID"
+// @Success 200 {object} object{message=string} "Scheduled task deleted successfully"
+// @Failure 400 {object} object{error=string} "Invalid scheduled task ID"
+// @Failure 404 {object} object{error=string} "Scheduled task not found"
+// @Router /scheduled-tasks/{id} [delete]
+func (h *ScheduledTaskHandlers) DeleteScheduledTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	if err := h.scheduledTaskService.DeleteSchedule(uint(id), username.(string)); err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "scheduled_task.delete_success")})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"xsha-backend/i18n"
+	"xsha-backend/middleware"
+	"xsha-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerControl pauses and resumes the conversation scheduler
+type SchedulerControl interface {
+	IsRunning() bool
+	IsPaused() bool
+	Pause()
+	Resume()
+}
+
+type SchedulerHandlers struct {
+	scheduler      SchedulerControl
+	aiTaskExecutor services.AITaskExecutorService
+}
+
+func NewSchedulerHandlers(scheduler SchedulerControl, aiTaskExecutor services.AITaskExecutorService) *SchedulerHandlers {
+	return &SchedulerHandlers{
+		scheduler:      scheduler,
+		aiTaskExecutor: aiTaskExecutor,
+	}
+}
+
+// @Description Update scheduler state request
+type UpdateSchedulerStateRequest struct {
+	Paused *bool `json:"paused" binding:"required" example:"true"`
+}
+
+// GetState returns the state of the conversation scheduler
+// @Summary Get scheduler state
+// @Description Get whether the conversation scheduler is running and paused, along with the current execution status
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{running=bool,paused=bool,execution_status=object} "Scheduler state"
+// @Router /admin/scheduler/state [get]
+func (h *SchedulerHandlers) GetState(c *gin.Context) {
+	c.JSON(http.StatusOK, h.state())
+}
+
+// UpdateState pauses or resumes the conversation scheduler
+// @Summary Pause or resume scheduler
+// @Description Pause the conversation scheduler so no new conversations are started, or resume it. Running conversations are not affected.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateSchedulerStateRequest true "Desired scheduler state"
+// @Success 200 {object} object{message=string,running=bool,paused=bool,execution_status=object} "Scheduler state updated"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Router /admin/scheduler/state [post]
+func (h *SchedulerHandlers) UpdateState(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	var req UpdateSchedulerStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	messageKey := "scheduler.resumed"
+	if *req.Paused {
+		h.scheduler.Pause()
+		messageKey = "scheduler.paused"
+	} else {
+		h.scheduler.Resume()
+	}
+
+	response := h.state()
+	response["message"] = i18n.T(lang, messageKey)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *SchedulerHandlers) state() gin.H {
+	return gin.H{
+		"running":          h.scheduler.IsRunning(),
+		"paused":           h.scheduler.IsPaused(),
+		"execution_status": h.aiTaskExecutor.GetExecutionStatus(),
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/i18n"
+	"xsha-backend/middleware"
+	"xsha-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SecretHandlers struct {
+	secretService services.SecretService
+}
+
+func NewSecretHandlers(secretService services.SecretService) *SecretHandlers {
+	return &SecretHandlers{
+		secretService: secretService,
+	}
+}
+
+// @Description Create secret request
+type CreateSecretRequest struct {
+	// Referenced from dev environment env vars as secret://<name>
+	Name        string `json:"name" binding:"required" example:"anthropic-key"`
+	Description string `json:"description" example:"API key used by the claude-code environments"`
+	Value       string `json:"value" binding:"required" example:"sk-ant-..."`
+}
+
+// @Description Update secret request
+type UpdateSecretRequest struct {
+	Description *string `json:"description" example:"API key used by the claude-code environments"`
+	// Replaces the stored value when set
+	Value *string `json:"value" example:"sk-ant-..."`
+}
+
+// CreateSecret creates a secret
+// @Summary Create secret
+// @Description Store an encrypted value that dev environment env vars can reference as secret://<name>
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param secret body CreateSecretRequest true "Secret information"
+// @Success 201 {object} object{message=string,data=database.Secret} "Secret created successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Router /secrets [post]
+func (h *SecretHandlers) CreateSecret(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "auth.unauthorized"),
+		})
+		return
+	}
+
+	var req CreateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	secret, err := h.secretService.CreateSecret(req.Name, req.Description, req.Value, username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(lang, "secret.create_success"),
+		"data":    secret,
+	})
+}
+
+// GetSecret gets a secret
+// @Summary Get secret
+// @Description Get a secret of the current user by ID, the admin can get any secret. The value is never returned
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Secret ID"
+// @Success 200 {object} object{data=database.Secret} "Secret"
+// @Failure 400 {object} object{error=string} "Invalid secret ID"
+// @Failure 404 {object} object{error=string} "Secret not found"
+// @Router /secrets/{id} [get]
+func (h *SecretHandlers) GetSecret(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	secret, err := h.secretService.GetSecret(uint(id), username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": secret})
+}
+
+// ListSecrets lists secrets
+// @Summary List secrets
+// @Description List the current user's secrets with pagination and filtering, the admin lists every secret. Values are never returned
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)" default(1)
+// @Param page_size query int false "Number of items per page (default: 20)" default(20)
+// @Param name query string false "Filter by secret name (partial match)"
+// @Success 200 {object} object{data=object{secrets=[]database.Secret,total=int,page=int,page_size=int}} "Secrets"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 500 {object} object{error=string} "Internal server error"
+// @Router /secrets [get]
+func (h *SecretHandlers) ListSecrets(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	var name *string
+	if n := c.Query("name"); n != "" {
+		name = &n
+	}
+
+	username, _ := c.Get("username")
+	secrets, total, err := h.secretService.ListSecrets(username.(string), name, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"secrets":   secrets,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// UpdateSecret updates a secret
+// @Summary Update secret
+// @Description Update the description or replace the value of a secret, the name cannot change
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Secret ID"
+// @Param secret body UpdateSecretRequest true "Secret update information"
+// @Success 200 {object} object{message=string,data=database.Secret} "Secret updated successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 404 {object} object{error=string} "Secret not found"
+// @Router /secrets/{id} [put]
+func (h *SecretHandlers) UpdateSecret(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	var req UpdateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Value != nil {
+		updates["value"] = *req.Value
+	}
+
+	username, _ := c.Get("username")
+	secret, err := h.secretService.UpdateSecret(uint(id), username.(string), updates)
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "secret.update_success"),
+		"data":    secret,
+	})
+}
+
+// DeleteSecret deletes a secret
+// @Summary Delete secret
+// @Description Delete a secret that no dev environment env var references
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Secret ID"
+// @Success 200 {object} object{message=string} "Secret deleted successfully"
+// @Failure 400 {object} object{error=string} "Invalid secret ID or secret in use"
+// @Failure 404 {object} object{error=string} "Secret not found"
+// @Router /secrets/{id} [delete]
+func (h *SecretHandlers) DeleteSecret(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	if err := h.secretService.DeleteSecret(uint(id), username.(string)); err != nil {
+		status := http.StatusBadRequest
+		if err == appErrors.ErrSecretNotFound {
+			status = http.StatusNotFound
+		}
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "secret.delete_success")})
+}
@@ -53,6 +53,32 @@ func (h *SystemConfigHandlers) ListAllConfigs(c *gin.Context) {
 	})
 }
 
+// GetEffectiveConfigs returns the effective value of every known configuration
+// @Summary Get effective configurations
+// @Description Get every known configuration key with its effective value, default value and whether it is overridden
+// @Tags System Configuration
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{configs=[]services.EffectiveConfig} "Effective configurations"
+// @Router /settings/effective [get]
+func (h *SystemConfigHandlers) GetEffectiveConfigs(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	configs, err := h.configService.GetEffectiveConfigs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "system_config.list_failed"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "system_config.list_success"),
+		"configs": configs,
+	})
+}
+
 // BatchUpdateConfigs updates all system configurations
 // @Summary Batch update configurations
 // @Description Update multiple system configurations in a single request
@@ -84,9 +110,7 @@ func (h *SystemConfigHandlers) BatchUpdateConfigs(c *gin.Context) {
 	}
 
 	if err := h.configService.BatchUpdateConfigs(configItems); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": i18n.MapErrorToI18nKey(err, lang),
-		})
+		i18n.NewHelper(lang).ErrorResponseFromError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -18,27 +20,36 @@ type TaskHandlers struct {
 	taskService         services.TaskService
 	conversationService services.TaskConversationService
 	projectService      services.ProjectService
+	templateService     services.TaskTemplateService
 }
 
-func NewTaskHandlers(taskService services.TaskService, conversationService services.TaskConversationService, projectService services.ProjectService) *TaskHandlers {
+func NewTaskHandlers(taskService services.TaskService, conversationService services.TaskConversationService, projectService services.ProjectService, templateService services.TaskTemplateService) *TaskHandlers {
 	return &TaskHandlers{
 		taskService:         taskService,
 		conversationService: conversationService,
 		projectService:      projectService,
+		templateService:     templateService,
 	}
 }
 
 // @Description Create task request
 type CreateTaskRequest struct {
-	Title            string     `json:"title" binding:"required" example:"Fix user authentication bug"`
-	StartBranch      string     `json:"start_branch" binding:"required" example:"main"`
-	ProjectID        uint       `json:"project_id" binding:"required" example:"1"`
-	DevEnvironmentID *uint      `json:"dev_environment_id" binding:"required" example:"1"`
-	RequirementDesc  string     `json:"requirement_desc" binding:"required" example:"Fix the login validation issue"`
+	Title string `json:"title" binding:"required" example:"Fix user authentication bug"`
+	// Template whose defaults fill start_branch, project_id, dev_environment_id and
+	// requirement_desc when they are left empty
+	TemplateID       *uint      `json:"template_id" example:"1"`
+	StartBranch      string     `json:"start_branch" example:"main"`
+	ProjectID        uint       `json:"project_id" example:"1"`
+	DevEnvironmentID *uint      `json:"dev_environment_id" example:"1"`
+	RequirementDesc  string     `json:"requirement_desc" example:"Fix the login validation issue"`
 	IncludeBranches  bool       `json:"include_branches" example:"true"`
 	ExecutionTime    *time.Time `json:"execution_time" example:"2024-01-01T10:00:00Z"`
 	EnvParams        string     `json:"env_params" example:"{\"model\":\"sonnet\"}"`
 	AttachmentIDs    []uint     `json:"attachment_ids" example:"[1,2,3]"`
+	// Execution timeout in seconds, 0 uses the global docker timeout
+	ExecutionTimeoutSeconds int `json:"execution_timeout_seconds" example:"3600"`
+	// Scheduling priority of the initial conversation, higher runs first
+	Priority int `json:"priority" example:"0"`
 }
 
 // @Description Create task response
@@ -51,11 +62,21 @@ type CreateTaskResponse struct {
 // @Description Update task request
 type UpdateTaskRequest struct {
 	Title string `json:"title" binding:"required" example:"Updated task title"`
+	// Execution timeout in seconds, 0 uses the global docker timeout
+	ExecutionTimeoutSeconds *int `json:"execution_timeout_seconds" example:"3600"`
+	// Cost cap in USD for the task's conversations, 0 removes it
+	CostBudgetUSD *float64 `json:"cost_budget_usd" example:"10"`
+	// Maximum number of conversations the task may run, 0 removes the cap
+	MaxRuns *int `json:"max_runs" example:"20"`
+	// Run each conversation in its own workspace so several can run at once, only changeable
+	// while no conversation is pending or running
+	ParallelConversations *bool `json:"parallel_conversations" example:"false"`
 }
 
 // CreateTask creates a new task
 // @Summary Create task
-// @Description Create a new task with optional requirement description and branch fetching
+// @Description Create a new task with optional requirement description and branch fetching.
+// @Description Fields left empty are taken from the task template given by template_id.
 // @Tags Tasks
 // @Accept json
 // @Produce json
@@ -82,9 +103,27 @@ func (h *TaskHandlers) CreateTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.CreateTask(req.Title, req.StartBranch, req.ProjectID, req.DevEnvironmentID, username.(string))
+	helper := i18n.NewHelper(lang)
+	if req.TemplateID != nil {
+		template, err := h.templateService.GetTemplate(*req.TemplateID, username.(string))
+		if err != nil {
+			helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+			return
+		}
+		applyTaskTemplate(&req, template)
+	}
+
+	if req.DevEnvironmentID == nil {
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, appErrors.ErrTaskDevEnvironmentRequired)
+		return
+	}
+	if strings.TrimSpace(req.RequirementDesc) == "" {
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, appErrors.ErrTaskRequirementDescRequired)
+		return
+	}
+
+	task, err := h.taskService.CreateTask(req.Title, req.StartBranch, req.ProjectID, req.DevEnvironmentID, req.ExecutionTimeoutSeconds, username.(string))
 	if err != nil {
-		helper := i18n.NewHelper(lang)
 		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
 		return
 	}
@@ -99,6 +138,8 @@ func (h *TaskHandlers) CreateTask(c *gin.Context) {
 				req.ExecutionTime,
 				req.EnvParams,
 				req.AttachmentIDs,
+				req.Priority,
+				false,
 			)
 		} else {
 			_, err = h.conversationService.CreateConversationWithExecutionTime(
@@ -107,6 +148,8 @@ func (h *TaskHandlers) CreateTask(c *gin.Context) {
 				username.(string),
 				req.ExecutionTime,
 				req.EnvParams,
+				req.Priority,
+				false,
 			)
 		}
 		if err != nil {
@@ -142,15 +185,33 @@ func (h *TaskHandlers) CreateTask(c *gin.Context) {
 	})
 }
 
+// applyTaskTemplate fills the fields of a create task request left empty with the template defaults
+func applyTaskTemplate(req *CreateTaskRequest, template *database.TaskTemplate) {
+	if strings.TrimSpace(req.StartBranch) == "" {
+		req.StartBranch = template.StartBranch
+	}
+	if req.ProjectID == 0 && template.ProjectID != nil {
+		req.ProjectID = *template.ProjectID
+	}
+	if req.DevEnvironmentID == nil {
+		req.DevEnvironmentID = template.DevEnvironmentID
+	}
+	if strings.TrimSpace(req.RequirementDesc) == "" {
+		req.RequirementDesc = template.ConversationTemplate
+	}
+}
+
 // GetTask retrieves a specific task
 // @Summary Get task
-// @Description Get a task by ID
+// @Description Get a task by ID. Measuring the workspace walks all of its files, so its size is only reported when include_workspace_size is set
 // @Tags Tasks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Task ID"
-// @Success 200 {object} object{message=string,data=database.Task} "Task retrieved successfully"
+// @Param include_workspace_size query bool false "Report the disk usage of the task workspace"
+// @Param skip_git_objects query bool false "Leave .git/objects out of the workspace size"
+// @Success 200 {object} object{message=string,data=database.Task,workspace_size=int} "Task retrieved successfully, workspace_size is only set when requested"
 // @Failure 400 {object} object{error=string} "Invalid task ID"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 404 {object} object{error=string} "Task not found"
@@ -171,10 +232,21 @@ func (h *TaskHandlers) GetTask(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "task.get_success"),
 		"data":    task,
-	})
+	}
+
+	if c.Query("include_workspace_size") == "true" {
+		skipGitObjects := c.Query("skip_git_objects") == "true"
+		workspaceSize, err := h.taskService.GetTaskWorkspaceUsage(task, skipGitObjects)
+		if err != nil {
+			utils.Warn("Failed to get task workspace size", "taskID", task.ID, "error", err)
+		}
+		response["workspace_size"] = workspaceSize
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // ListTasks retrieves tasks with pagination and filtering
@@ -318,6 +390,18 @@ func (h *TaskHandlers) UpdateTask(c *gin.Context) {
 
 	updates := make(map[string]interface{})
 	updates["title"] = req.Title
+	if req.ExecutionTimeoutSeconds != nil {
+		updates["execution_timeout_seconds"] = *req.ExecutionTimeoutSeconds
+	}
+	if req.CostBudgetUSD != nil {
+		updates["cost_budget_usd"] = *req.CostBudgetUSD
+	}
+	if req.MaxRuns != nil {
+		updates["max_runs"] = *req.MaxRuns
+	}
+	if req.ParallelConversations != nil {
+		updates["parallel_conversations"] = *req.ParallelConversations
+	}
 
 	if err := h.taskService.UpdateTask(uint(id), updates); err != nil {
 		helper := i18n.NewHelper(lang)
@@ -496,7 +580,7 @@ func (h *TaskHandlers) BatchUpdateTaskStatus(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Task ID"
-// @Param include_content query bool false "Include file content in diff" default(false)
+// @Param include_content query bool false "Include file content in diff, capped per file by diff_max_bytes and in total by diff_total_max_bytes" default(false)
 // @Success 200 {object} object{data=object} "Git diff retrieved successfully"
 // @Failure 400 {object} object{error=string} "Invalid task ID or missing workspace"
 // @Failure 401 {object} object{error=string} "Authentication failed"
@@ -570,7 +654,9 @@ func (h *TaskHandlers) GetTaskGitDiff(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "Task ID"
 // @Param file_path query string true "File path to get diff for"
-// @Success 200 {object} object{data=object{file_path=string,diff_content=string}} "File diff retrieved successfully"
+// @Param hunk_offset query int false "Index of the first hunk to return"
+// @Param hunk_limit query int false "Maximum number of hunks to return, 0 returns all"
+// @Success 200 {object} object{data=utils.FileDiff} "File diff retrieved successfully, truncated when larger than diff_max_bytes"
 // @Failure 400 {object} object{error=string} "Invalid task ID or missing file path"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 403 {object} object{error=string} "No permission to access task"
@@ -605,7 +691,15 @@ func (h *TaskHandlers) GetTaskGitDiffFile(c *gin.Context) {
 		return
 	}
 
-	diffContent, err := h.taskService.GetTaskGitDiffFile(task, filePath)
+	hunkOffset, hunkLimit, ok := parseHunkPage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.hunk_page_invalid"),
+		})
+		return
+	}
+
+	diff, err := h.taskService.GetTaskGitDiffFile(task, filePath, hunkOffset, hunkLimit)
 	if err != nil {
 		utils.Error("Failed to get task file Git diff", "taskID", taskID, "filePath", filePath, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -615,24 +709,39 @@ func (h *TaskHandlers) GetTaskGitDiffFile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"file_path":    filePath,
-			"diff_content": diffContent,
-		},
+		"data": diff,
 	})
 }
 
-// PushTaskBranch pushes the task's work branch to the remote repository
+// parseHunkPage reads the optional hunk_offset and hunk_limit query parameters of file diff endpoints
+func parseHunkPage(c *gin.Context) (int, int, bool) {
+	values := [2]int{}
+	for i, key := range []string{"hunk_offset", "hunk_limit"} {
+		valueStr, ok := c.GetQuery(key)
+		if !ok {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value < 0 {
+			return 0, 0, false
+		}
+		values[i] = value
+	}
+	return values[0], values[1], true
+}
+
+// PushTaskBranch pushes the task's work branch, or the branch of one of its isolated
+// conversations, to the remote repository
 // @Summary Push task branch
-// @Description Push the task's work branch to the remote repository
+// @Description Push the task's work branch to the remote repository, or the branch a conversation ran on when conversation_id is given
 // @Tags Tasks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Task ID"
-// @Param request body object{force_push=bool} false "Push options"
+// @Param request body object{force_push=bool,conversation_id=int} false "Push options"
 // @Success 200 {object} object{message=string,data=object{output=string}} "Branch pushed successfully"
-// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 400 {object} object{error=string} "Invalid task ID or the conversation ran on no branch of its own"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 500 {object} object{error=string,details=string} "Failed to push branch"
 // @Router /tasks/{id}/push [post]
@@ -649,16 +758,27 @@ func (h *TaskHandlers) PushTaskBranch(c *gin.Context) {
 	}
 
 	var req struct {
-		ForcePush bool `json:"force_push"`
+		ForcePush      bool `json:"force_push"`
+		ConversationID uint `json:"conversation_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		req.ForcePush = false
+		req.ConversationID = 0
 	}
 
-	output, err := h.taskService.PushTaskBranch(uint(taskID), req.ForcePush)
+	var output string
+	if req.ConversationID != 0 {
+		output, err = h.taskService.PushConversationBranch(uint(taskID), req.ConversationID, req.ForcePush)
+	} else {
+		output, err = h.taskService.PushTaskBranch(uint(taskID), req.ForcePush)
+	}
 	if err != nil {
-		utils.Error("Failed to push task branch", "taskID", taskID, "error", err)
 		helper := i18n.NewHelper(lang)
+		if err == appErrors.ErrConversationNoIsolatedBranch {
+			helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+			return
+		}
+		utils.Error("Failed to push task branch", "taskID", taskID, "error", err)
 		helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -671,6 +791,248 @@ func (h *TaskHandlers) PushTaskBranch(c *gin.Context) {
 	})
 }
 
+// MarkTaskBranchMerged records that the task's work branch was merged outside of xsha
+// @Summary Mark task branch merged
+// @Description Record that the task's work branch was merged, deleting the remote branch and removing the task workspace when the project is configured to
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 200 {object} object{message=string,data=database.Task} "Task branch marked as merged"
+// @Failure 400 {object} object{error=string} "Invalid task ID or task has no work branch"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Failure 409 {object} object{error=string} "Task has pending or running conversations, unpushed commits, or unmerged remote commits"
+// @Failure 500 {object} object{error=string,details=string} "Failed to clean up merged branch"
+// @Router /tasks/{id}/branch/merged [post]
+func (h *TaskHandlers) MarkTaskBranchMerged(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	if _, err := h.taskService.GetTask(uint(taskID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "tasks.errors.not_found"),
+		})
+		return
+	}
+
+	task, err := h.taskService.MarkTaskBranchMerged(uint(taskID))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		switch err {
+		case appErrors.ErrTaskWorkBranchMissing:
+			helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		case appErrors.ErrTaskBranchCleanupBusy, appErrors.ErrTaskBranchCleanupUnpushed, appErrors.ErrTaskBranchCleanupMoved:
+			helper.ErrorResponseFromError(c, http.StatusConflict, err)
+		default:
+			utils.Error("Failed to clean up merged task branch", "taskID", taskID, "error", err)
+			helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "tasks.branch_merged_success"),
+		"data":    task,
+	})
+}
+
+// GetTaskPushStatus returns how many commits are waiting to be pushed
+// @Summary Get task push status
+// @Description Count the commits conversations made on the task's work branch that have not been pushed yet
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 200 {object} object{data=services.TaskPushStatus} "Push status retrieved successfully"
+// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Failure 500 {object} object{error=string} "Failed to get push status"
+// @Router /tasks/{id}/push-status [get]
+func (h *TaskHandlers) GetTaskPushStatus(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	if _, err := h.taskService.GetTask(uint(taskID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "tasks.errors.not_found"),
+		})
+		return
+	}
+
+	status, err := h.taskService.GetTaskPushStatus(uint(taskID))
+	if err != nil {
+		utils.Error("Failed to get task push status", "taskID", taskID, "error", err)
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// GetTaskBranchStatus returns how far the work branch is ahead of or behind its base
+// @Summary Get task branch status
+// @Description Count the commits the task's work branch is ahead of and behind its start branch, and whether it can be fast-forward merged
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 200 {object} object{data=services.TaskBranchStatus} "Branch status retrieved successfully"
+// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Failure 500 {object} object{error=string} "Failed to get branch status"
+// @Router /tasks/{id}/branch-status [get]
+func (h *TaskHandlers) GetTaskBranchStatus(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	if _, err := h.taskService.GetTask(uint(taskID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": i18n.T(lang, "tasks.errors.not_found"),
+		})
+		return
+	}
+
+	status, err := h.taskService.GetTaskBranchStatus(uint(taskID))
+	if err != nil {
+		utils.Error("Failed to get task branch status", "taskID", taskID, "error", err)
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// ExportTask downloads a task's conversation history
+// @Summary Export task
+// @Description Download a task with all its conversations, results, commit hashes and execution summaries as Markdown or JSON. Environment variable values are masked
+// @Tags Tasks
+// @Produce text/markdown
+// @Produce application/json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Param format query string false "Export format, markdown (default) or json"
+// @Success 200 {file} binary "Task export"
+// @Failure 400 {object} object{error=string} "Invalid task ID or format"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Failure 500 {object} object{error=string} "Failed to export task"
+// @Router /tasks/{id}/export [get]
+func (h *TaskHandlers) ExportTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.TaskExportFormatMarkdown)
+	content, err := h.conversationService.ExportTask(uint(taskID), format)
+	if err != nil {
+		switch err {
+		case appErrors.ErrTaskExportFormatInvalid:
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "task.export_format_invalid")})
+		case appErrors.ErrTaskNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task.not_found")})
+		default:
+			utils.Error("Failed to export task", "taskID", taskID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "task.export_failed")})
+		}
+		return
+	}
+
+	contentType, extension := "text/markdown; charset=utf-8", "md"
+	if format == services.TaskExportFormatJSON {
+		contentType, extension = "application/json; charset=utf-8", "json"
+	}
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"task-%d-export.%s\"", taskID, extension))
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// ImportTaskRequest holds a task export and where to import it
+type ImportTaskRequest struct {
+	// Project the task is created in
+	ProjectID uint `json:"project_id" binding:"required" example:"1"`
+	// Maps dev environment IDs of the exporting instance to local ones
+	DevEnvironmentMap map[uint]uint `json:"dev_environment_map"`
+	// JSON export produced by GET /tasks/{id}/export?format=json
+	Export services.TaskExport `json:"export"`
+}
+
+// ImportTask recreates a task from a JSON export
+// @Summary Import task
+// @Description Recreate a task and its conversation records from a JSON export under the current user. The task starts as todo on a new work branch, unfinished conversations are imported as cancelled, nothing is executed and no workspace is created
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param task body ImportTaskRequest true "Task export and target project"
+// @Success 201 {object} object{message=string,data=database.Task} "Task imported successfully"
+// @Failure 400 {object} object{error=string} "Invalid export or unknown project or dev environment"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Router /tasks/import [post]
+func (h *TaskHandlers) ImportTask(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "auth.unauthorized"),
+		})
+		return
+	}
+
+	var req ImportTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.conversationService.ImportTask(&req.Export, req.ProjectID, req.DevEnvironmentMap, username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(lang, "task.import_success"),
+		"data":    task,
+	})
+}
+
 // @Description Get kanban tasks response
 type GetKanbanTasksResponse struct {
 	Todo       []database.Task `json:"todo"`
@@ -722,3 +1084,147 @@ func (h *TaskHandlers) GetKanbanTasks(c *gin.Context) {
 		"data":    response,
 	})
 }
+
+// workspaceSnapshotErrorStatus maps workspace snapshot errors to HTTP status codes
+func workspaceSnapshotErrorStatus(err error) int {
+	switch err {
+	case appErrors.ErrTaskNotFound, appErrors.ErrWorkspaceSnapshotNotFound:
+		return http.StatusNotFound
+	case appErrors.ErrTaskWorkspaceBusy:
+		return http.StatusConflict
+	case appErrors.ErrTaskWorkspaceUnavailable:
+		return http.StatusGone
+	case appErrors.ErrWorkspaceSnapshotTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateWorkspaceSnapshot snapshots the task workspace
+// @Summary Create workspace snapshot
+// @Description Archive the task workspace, including uncommitted changes and git history, so it can be restored later
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 201 {object} object{message=string,data=database.WorkspaceSnapshot} "Snapshot created successfully"
+// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Failure 409 {object} object{error=string} "A conversation is pending or running"
+// @Failure 410 {object} object{error=string} "Workspace unavailable"
+// @Failure 413 {object} object{error=string} "Workspace exceeds the snapshot size limit"
+// @Failure 500 {object} object{error=string,details=string} "Failed to create snapshot"
+// @Router /tasks/{id}/workspace/snapshot [post]
+func (h *TaskHandlers) CreateWorkspaceSnapshot(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	createdBy, _ := username.(string)
+
+	snapshot, err := h.taskService.CreateWorkspaceSnapshot(uint(taskID), createdBy)
+	if err != nil {
+		utils.Error("Failed to create workspace snapshot", "taskID", taskID, "error", err)
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, workspaceSnapshotErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(lang, "task.workspace_snapshot_created"),
+		"data":    snapshot,
+	})
+}
+
+// ListWorkspaceSnapshots lists the snapshots of the task workspace
+// @Summary List workspace snapshots
+// @Description List the workspace snapshots of a task, newest first
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 200 {object} object{data=[]database.WorkspaceSnapshot} "Snapshots retrieved successfully"
+// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 500 {object} object{error=string} "Failed to list snapshots"
+// @Router /tasks/{id}/workspace/snapshots [get]
+func (h *TaskHandlers) ListWorkspaceSnapshots(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	snapshots, err := h.taskService.ListWorkspaceSnapshots(uint(taskID))
+	if err != nil {
+		utils.Error("Failed to list workspace snapshots", "taskID", taskID, "error", err)
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": snapshots,
+	})
+}
+
+// RestoreWorkspaceSnapshot restores the task workspace from a snapshot
+// @Summary Restore workspace snapshot
+// @Description Replace the task workspace with a snapshot taken of it, conversations are kept
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Param snapshot query int true "Snapshot ID"
+// @Success 200 {object} object{message=string,data=database.WorkspaceSnapshot} "Workspace restored successfully"
+// @Failure 400 {object} object{error=string} "Invalid task or snapshot ID"
+// @Failure 404 {object} object{error=string} "Task or snapshot not found"
+// @Failure 409 {object} object{error=string} "A conversation is pending or running"
+// @Failure 410 {object} object{error=string} "Workspace unavailable"
+// @Failure 500 {object} object{error=string,details=string} "Failed to restore snapshot"
+// @Router /tasks/{id}/workspace/restore [post]
+func (h *TaskHandlers) RestoreWorkspaceSnapshot(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+	snapshotID, err := strconv.ParseUint(c.Query("snapshot"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.invalid_id"),
+		})
+		return
+	}
+
+	snapshot, err := h.taskService.RestoreWorkspaceSnapshot(uint(taskID), uint(snapshotID))
+	if err != nil {
+		utils.Error("Failed to restore workspace snapshot", "taskID", taskID, "snapshotID", snapshotID, "error", err)
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, workspaceSnapshotErrorStatus(err), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "task.workspace_snapshot_restored"),
+		"data":    snapshot,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -19,12 +20,14 @@ import (
 type TaskConversationHandlers struct {
 	conversationService services.TaskConversationService
 	logStreamingService executor.LogStreamingService
+	aiTaskExecutor      services.AITaskExecutorService
 }
 
-func NewTaskConversationHandlers(conversationService services.TaskConversationService, logStreamingService executor.LogStreamingService) *TaskConversationHandlers {
+func NewTaskConversationHandlers(conversationService services.TaskConversationService, logStreamingService executor.LogStreamingService, aiTaskExecutor services.AITaskExecutorService) *TaskConversationHandlers {
 	return &TaskConversationHandlers{
 		conversationService: conversationService,
 		logStreamingService: logStreamingService,
+		aiTaskExecutor:      aiTaskExecutor,
 	}
 }
 
@@ -35,11 +38,26 @@ type CreateConversationRequest struct {
 	ExecutionTime *time.Time `json:"execution_time" example:"2024-01-01T10:00:00Z"`
 	EnvParams     string     `json:"env_params" example:"{\"model\":\"sonnet\"}"`
 	AttachmentIDs []uint     `json:"attachment_ids,omitempty" example:"[1,2]"`
+	Draft         bool       `json:"draft" example:"false"`
+	// Higher priority conversations are started first when execution slots are limited
+	Priority int `json:"priority" example:"0"`
+	// Run on a dedicated branch derived from the task's work branch
+	IsolatedBranch bool `json:"isolated_branch" example:"false"`
 }
 
 // @Description Update conversation request
 type UpdateConversationRequest struct {
-	Content string `json:"content" example:"Updated conversation content"`
+	Content       string     `json:"content" example:"Updated conversation content"`
+	ExecutionTime *time.Time `json:"execution_time" example:"2024-01-01T10:00:00Z"`
+	EnvParams     *string    `json:"env_params" example:"{\"model\":\"sonnet\"}"`
+}
+
+// @Description Estimate conversation cost request
+type EstimateCostRequest struct {
+	// Task whose system prompts and conversation history are counted, if set
+	TaskID    *uint  `json:"task_id" example:"1"`
+	Content   string `json:"content" binding:"required" example:"Please implement the user authentication feature"`
+	EnvParams string `json:"env_params" example:"{\"model\":\"sonnet\"}"`
 }
 
 // CreateConversation creates a new task conversation
@@ -50,9 +68,10 @@ type UpdateConversationRequest struct {
 // @Produce json
 // @Security BearerAuth
 // @Param conversation body CreateConversationRequest true "Conversation information"
-// @Success 201 {object} object{message=string,data=object} "Conversation created successfully"
+// @Success 201 {object} object{message=string,data=object,execution_status=object} "Conversation created successfully, with current running/max concurrency and pending queue length"
 // @Failure 400 {object} object{error=string} "Request parameter error"
 // @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 429 {object} object{error=string,retry_after_seconds=int} "Too many conversations created, retry after the Retry-After header"
 // @Router /conversations [post]
 func (h *TaskConversationHandlers) CreateConversation(c *gin.Context) {
 	lang := middleware.GetLangFromContext(c)
@@ -72,10 +91,12 @@ func (h *TaskConversationHandlers) CreateConversation(c *gin.Context) {
 	var conversation *database.TaskConversation
 	var err error
 
-	if len(req.AttachmentIDs) > 0 {
-		conversation, err = h.conversationService.CreateConversationWithExecutionTimeAndAttachments(req.TaskID, req.Content, username.(string), req.ExecutionTime, req.EnvParams, req.AttachmentIDs)
+	if req.Draft {
+		conversation, err = h.conversationService.CreateDraftConversation(req.TaskID, req.Content, username.(string), req.ExecutionTime, req.EnvParams, req.AttachmentIDs, req.Priority, req.IsolatedBranch)
+	} else if len(req.AttachmentIDs) > 0 {
+		conversation, err = h.conversationService.CreateConversationWithExecutionTimeAndAttachments(req.TaskID, req.Content, username.(string), req.ExecutionTime, req.EnvParams, req.AttachmentIDs, req.Priority, req.IsolatedBranch)
 	} else {
-		conversation, err = h.conversationService.CreateConversationWithExecutionTime(req.TaskID, req.Content, username.(string), req.ExecutionTime, req.EnvParams)
+		conversation, err = h.conversationService.CreateConversationWithExecutionTime(req.TaskID, req.Content, username.(string), req.ExecutionTime, req.EnvParams, req.Priority, req.IsolatedBranch)
 	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
@@ -83,8 +104,9 @@ func (h *TaskConversationHandlers) CreateConversation(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": i18n.T(lang, "taskConversation.create_success"),
-		"data":    conversation,
+		"message":          i18n.T(lang, "taskConversation.create_success"),
+		"data":             conversation,
+		"execution_status": h.aiTaskExecutor.GetExecutionStatus(),
 	})
 }
 
@@ -96,7 +118,7 @@ func (h *TaskConversationHandlers) CreateConversation(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Conversation ID"
-// @Success 200 {object} object{message=string,data=object} "Conversation retrieved successfully"
+// @Success 200 {object} object{message=string,notice=string,data=object} "Conversation retrieved successfully, notice is set when it succeeded without committing changes"
 // @Failure 400 {object} object{error=string} "Invalid conversation ID"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 404 {object} object{error=string} "Conversation not found"
@@ -117,10 +139,12 @@ func (h *TaskConversationHandlers) GetConversation(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "taskConversation.get_success"),
 		"data":    conversation,
-	})
+	}
+	addNoChangesNotice(response, lang, conversation)
+	c.JSON(http.StatusOK, response)
 }
 
 // GetConversationDetails retrieves a conversation with its result details
@@ -131,7 +155,7 @@ func (h *TaskConversationHandlers) GetConversation(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Conversation ID"
-// @Success 200 {object} object{message=string,data=object} "Conversation details retrieved successfully"
+// @Success 200 {object} object{message=string,notice=string,data=object} "Conversation details retrieved successfully, notice is set when it succeeded without committing changes"
 // @Failure 400 {object} object{error=string} "Invalid conversation ID"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 404 {object} object{error=string} "Conversation not found"
@@ -152,10 +176,14 @@ func (h *TaskConversationHandlers) GetConversationDetails(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "taskConversation.get_success"),
 		"data":    details,
-	})
+	}
+	if conversation, ok := details["conversation"].(*database.TaskConversation); ok {
+		addNoChangesNotice(response, lang, conversation)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // ListConversations lists conversations for a task
@@ -208,6 +236,61 @@ func (h *TaskConversationHandlers) ListConversations(c *gin.Context) {
 	})
 }
 
+// SearchConversations searches conversation content and execution logs
+// @Summary Search conversations
+// @Description Find conversations whose content, or optionally execution log, contains a string, with a snippet around the match and the task and project they belong to. Encrypted log content is not searched.
+// @Tags Task Conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Text to search for, 2 to 200 characters"
+// @Param project_id query int false "Only search this project"
+// @Param include_logs query bool false "Also search execution logs" default(false)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} object{data=object{results=[]repository.ConversationSearchHit,total=int,page=int,page_size=int}} "Search results"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 500 {object} object{error=string} "Internal server error"
+// @Router /conversations/search [get]
+func (h *TaskConversationHandlers) SearchConversations(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	var projectID *uint
+	if projectIDStr := c.Query("project_id"); projectIDStr != "" {
+		id, err := strconv.ParseUint(projectIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+			return
+		}
+		pid := uint(id)
+		projectID = &pid
+	}
+
+	includeLogs, _ := strconv.ParseBool(c.DefaultQuery("include_logs", "false"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	results, total, err := h.conversationService.SearchConversations(c.Query("q"), projectID, includeLogs, page, pageSize)
+	if err != nil {
+		if err == appErrors.ErrConversationSearchQueryInvalid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+			return
+		}
+		utils.Error("Failed to search conversations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"results":   results,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
 // UpdateConversation updates a conversation
 // @Summary Update task conversation
 // @Description Update a conversation's content
@@ -241,6 +324,12 @@ func (h *TaskConversationHandlers) UpdateConversation(c *gin.Context) {
 	if req.Content != "" {
 		updates["content"] = req.Content
 	}
+	if req.ExecutionTime != nil {
+		updates["execution_time"] = req.ExecutionTime
+	}
+	if req.EnvParams != nil {
+		updates["env_params"] = *req.EnvParams
+	}
 
 	if err := h.conversationService.UpdateConversation(uint(id), updates); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
@@ -281,6 +370,40 @@ func (h *TaskConversationHandlers) DeleteConversation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "taskConversation.update_success")})
 }
 
+// PromoteConversation promotes a draft conversation to pending
+// @Summary Promote draft conversation
+// @Description Queue a draft conversation for execution by changing its status to pending
+// @Tags Task Conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Success 200 {object} object{message=string,data=object} "Conversation promoted successfully"
+// @Failure 400 {object} object{error=string} "Conversation is not a draft or cannot be queued"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Router /conversations/{id}/promote [post]
+func (h *TaskConversationHandlers) PromoteConversation(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	conversation, err := h.conversationService.PromoteDraftConversation(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "taskConversation.promote_success"),
+		"data":    conversation,
+	})
+}
+
 // GetLatestConversation retrieves the latest conversation for a task
 // @Summary Get latest task conversation
 // @Description Get the most recent conversation for a specific task
@@ -289,7 +412,7 @@ func (h *TaskConversationHandlers) DeleteConversation(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param task_id query int true "Task ID"
-// @Success 200 {object} object{message=string,data=object} "Latest conversation retrieved successfully"
+// @Success 200 {object} object{message=string,notice=string,data=object} "Latest conversation retrieved successfully, notice is set when it succeeded without committing changes"
 // @Failure 400 {object} object{error=string} "Request parameter error"
 // @Failure 401 {object} object{error=string} "Authentication failed"
 // @Failure 404 {object} object{error=string} "Conversation not found"
@@ -315,10 +438,20 @@ func (h *TaskConversationHandlers) GetLatestConversation(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": i18n.T(lang, "taskConversation.get_success"),
 		"data":    conversation,
-	})
+	}
+	addNoChangesNotice(response, lang, conversation)
+	c.JSON(http.StatusOK, response)
+}
+
+// addNoChangesNotice tells clients that a succeeded conversation committed nothing because the
+// AI changed no files, so it is not mistaken for a commit
+func addNoChangesNotice(response gin.H, lang string, conversation *database.TaskConversation) {
+	if conversation.Status == database.ConversationStatusSuccess && !conversation.ChangesCommitted {
+		response["notice"] = i18n.T(lang, "taskConversation.no_changes_committed")
+	}
 }
 
 // GetConversationGitDiff retrieves Git diff for a conversation
@@ -329,10 +462,11 @@ func (h *TaskConversationHandlers) GetLatestConversation(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Conversation ID"
-// @Param include_content query bool false "Include file content in diff" default(false)
+// @Param include_content query bool false "Include file content in diff, capped per file by diff_max_bytes and in total by diff_total_max_bytes" default(false)
 // @Success 200 {object} object{data=object} "Git diff retrieved successfully"
 // @Failure 400 {object} object{error=string} "Invalid conversation ID"
 // @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 410 {object} object{error=string} "Workspace cleaned up or commit no longer available"
 // @Failure 500 {object} object{error=string} "Failed to get Git diff"
 // @Router /conversations/{id}/git-diff [get]
 func (h *TaskConversationHandlers) GetConversationGitDiff(c *gin.Context) {
@@ -352,6 +486,10 @@ func (h *TaskConversationHandlers) GetConversationGitDiff(c *gin.Context) {
 	diff, err := h.conversationService.GetConversationGitDiff(uint(conversationID), includeContent)
 	if err != nil {
 		utils.Error("Failed to get conversation Git diff", "conversationID", conversationID, "error", err)
+		if err == appErrors.ErrConversationWorkspaceUnavailable || err == appErrors.ErrConversationCommitUnavailable {
+			c.JSON(http.StatusGone, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": i18n.T(lang, "taskConversation.git_diff_failed"),
 		})
@@ -372,9 +510,12 @@ func (h *TaskConversationHandlers) GetConversationGitDiff(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "Conversation ID"
 // @Param file_path query string true "File path"
-// @Success 200 {object} object{data=object{file_path=string,diff_content=string}} "File Git diff retrieved successfully"
+// @Param hunk_offset query int false "Index of the first hunk to return"
+// @Param hunk_limit query int false "Maximum number of hunks to return, 0 returns all"
+// @Success 200 {object} object{data=utils.FileDiff} "File Git diff retrieved successfully, truncated when larger than diff_max_bytes"
 // @Failure 400 {object} object{error=string} "Request parameter error"
 // @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 410 {object} object{error=string} "Workspace cleaned up or commit no longer available"
 // @Failure 500 {object} object{error=string} "Failed to get file Git diff"
 // @Router /conversations/{id}/git-diff/file [get]
 func (h *TaskConversationHandlers) GetConversationGitDiffFile(c *gin.Context) {
@@ -397,9 +538,21 @@ func (h *TaskConversationHandlers) GetConversationGitDiffFile(c *gin.Context) {
 		return
 	}
 
-	diffContent, err := h.conversationService.GetConversationGitDiffFile(uint(conversationID), filePath)
+	hunkOffset, hunkLimit, ok := parseHunkPage(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": i18n.T(lang, "validation.hunk_page_invalid"),
+		})
+		return
+	}
+
+	diff, err := h.conversationService.GetConversationGitDiffFile(uint(conversationID), filePath, hunkOffset, hunkLimit)
 	if err != nil {
 		utils.Error("Failed to get conversation file Git diff", "conversationID", conversationID, "filePath", filePath, "error", err)
+		if err == appErrors.ErrConversationWorkspaceUnavailable || err == appErrors.ErrConversationCommitUnavailable {
+			c.JSON(http.StatusGone, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": i18n.T(lang, "taskConversation.git_diff_file_failed"),
 		})
@@ -407,10 +560,7 @@ func (h *TaskConversationHandlers) GetConversationGitDiffFile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"file_path":    filePath,
-			"diff_content": diffContent,
-		},
+		"data": diff,
 	})
 }
 
@@ -508,3 +658,157 @@ func (h *TaskConversationHandlers) StreamConversationLogs(c *gin.Context) {
 		}
 	}
 }
+
+// EstimateCost estimates the cost of running a conversation
+// @Summary Estimate conversation cost
+// @Description Estimate the token count and cost range of a prompt before running it, using the configured per-model rates. When a task is given, its system prompts and conversation history are included.
+// @Tags Task Conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body EstimateCostRequest true "Prompt to estimate"
+// @Success 200 {object} object{data=services.CostEstimate} "Cost estimate"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 404 {object} object{error=string} "Task not found"
+// @Router /conversations/estimate-cost [post]
+func (h *TaskConversationHandlers) EstimateCost(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	var req EstimateCostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	estimate, err := h.conversationService.EstimateCost(req.TaskID, req.Content, req.EnvParams)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == appErrors.ErrTaskNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": estimate})
+}
+
+// StreamTaskLogs streams the execution logs of all active conversations of a task
+// @Summary Stream task execution logs
+// @Description Multiplex the real-time logs of all pending and running conversations of a task over a single Server-Sent Events (SSE) connection. Every event carries the conversation ID it belongs to.
+// @Tags Tasks
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path int true "Task ID"
+// @Success 200 {string} string "Real-time log stream"
+// @Failure 400 {object} object{error=string} "Invalid task ID"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 500 {object} object{error=string} "Failed to stream logs"
+// @Router /tasks/{id}/logs/stream [get]
+func (h *TaskConversationHandlers) StreamTaskLogs(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_id")})
+		return
+	}
+
+	// Client disconnects cancel the request context, which stops every conversation stream
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	eventChan, conversationIDs, err := h.logStreamingService.StreamTaskLogs(ctx, uint(taskID))
+	if err != nil {
+		utils.Error("Failed to start task log streaming", "taskID", taskID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "taskConversation.log_stream_failed")})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	c.SSEvent("connected", gin.H{
+		"task_id":          taskID,
+		"conversation_ids": conversationIDs,
+		"timestamp":        time.Now().Unix(),
+	})
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			utils.Info("Task log streaming cancelled by client", "taskID", taskID)
+			return
+		case event, ok := <-eventChan:
+			if !ok {
+				c.SSEvent("finished", gin.H{
+					"task_id":   taskID,
+					"timestamp": time.Now().Unix(),
+				})
+				c.Writer.Flush()
+				return
+			}
+
+			switch event.Type {
+			case executor.TaskLogEventLog:
+				c.SSEvent("log", gin.H{
+					"conversation_id": event.ConversationID,
+					"line":            event.Line,
+					"timestamp":       time.Now().Unix(),
+				})
+			case executor.TaskLogEventFinished:
+				running, _ := h.logStreamingService.IsConversationRunning(event.ConversationID)
+				c.SSEvent("status", gin.H{
+					"conversation_id": event.ConversationID,
+					"running":         running,
+					"timestamp":       time.Now().Unix(),
+				})
+			case executor.TaskLogEventError:
+				utils.Error("Error during task log streaming", "taskID", taskID, "conversationID", event.ConversationID, "error", event.Err)
+				c.SSEvent("error", gin.H{
+					"conversation_id": event.ConversationID,
+					"message":         i18n.T(lang, "taskConversation.log_stream_failed"),
+					"timestamp":       time.Now().Unix(),
+				})
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// DownloadConversationBundle downloads all artifacts of a conversation as a zip bundle
+// @Summary Download conversation bundle
+// @Description Download a zip archive containing the execution log, parsed result, git patch and metadata of a conversation
+// @Tags Task Conversations
+// @Produce application/zip
+// @Security BearerAuth
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {file} binary "Conversation bundle"
+// @Failure 400 {object} object{error=string} "Invalid conversation ID"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 404 {object} object{error=string} "Conversation not found"
+// @Router /task-conversations/{conversationId}/bundle [get]
+func (h *TaskConversationHandlers) DownloadConversationBundle(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	bundle, err := h.conversationService.BuildConversationBundle(uint(conversationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "taskConversation.not_found")})
+		return
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"conversation-%d-bundle.zip\"", conversationID))
+	c.Data(http.StatusOK, "application/zip", bundle)
+}
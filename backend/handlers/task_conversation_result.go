@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
@@ -94,22 +95,52 @@ func (h *TaskConversationResultHandlers) GetResultByConversationID(c *gin.Contex
 }
 
 // ListResultsByTaskID lists results for a specific task
-// @Summary List results by task ID
-// @Description Get paginated list of conversation results for a specific task
+// @Summary List results by task ID or session ID
+// @Description Get paginated list of conversation results for a specific task, or a page of the results of an AI tool session across conversations and tasks with the session totals when session_id is given
 // @Tags Task Conversation Results
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param task_id query int true "Task ID"
+// @Param task_id query int false "Task ID, required without session_id"
+// @Param session_id query string false "Session ID, lists the results of the session instead of a task's"
 // @Param page query int false "Page number" default(1)
 // @Param page_size query int false "Page size (1-100)" default(10)
-// @Success 200 {object} object{message=string,data=object{results=[]object,total=int,page=int,page_size=int}} "Results retrieved successfully"
+// @Success 200 {object} object{message=string,data=object{results=[]object,total=int,page=int,page_size=int,summary=object}} "Results retrieved successfully, summary is only set for session queries"
 // @Failure 400 {object} object{error=string} "Request parameter error"
 // @Failure 500 {object} object{error=string} "Internal server error"
 // @Router /conversation-results [get]
 func (h *TaskConversationResultHandlers) ListResultsByTaskID(c *gin.Context) {
 	lang := middleware.GetLangFromContext(c)
 
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	if sessionID := strings.TrimSpace(c.Query("session_id")); sessionID != "" {
+		results, total, summary, err := h.resultService.ListResultsBySessionID(sessionID, page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": i18n.T(lang, "taskConversation.result_list_success"),
+			"data": gin.H{
+				"results":   results,
+				"total":     total,
+				"page":      page,
+				"page_size": pageSize,
+				"summary":   summary,
+			},
+		})
+		return
+	}
+
 	taskIDStr := c.Query("task_id")
 	if taskIDStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "task.id_required")})
@@ -122,15 +153,6 @@ func (h *TaskConversationResultHandlers) ListResultsByTaskID(c *gin.Context) {
 		return
 	}
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
-	}
-
 	results, total, err := h.resultService.ListResultsByTaskID(uint(taskID), page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
@@ -202,6 +224,59 @@ func (h *TaskConversationResultHandlers) ListResultsByProjectID(c *gin.Context)
 	})
 }
 
+// ListConversationsMissingResult lists succeeded conversations without a result record
+// @Summary List conversations missing results
+// @Description Get paginated list of succeeded conversations that have no result record, usually because their output could not be parsed
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param project_id query int false "Project ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size (1-100)" default(10)
+// @Success 200 {object} object{message=string,data=object{items=[]object,total=int,page=int,page_size=int}} "Conversations retrieved successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Router /admin/conversations/missing-results [get]
+func (h *TaskConversationResultHandlers) ListConversationsMissingResult(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	var projectID *uint
+	if projectIDStr := c.Query("project_id"); projectIDStr != "" {
+		id, err := strconv.ParseUint(projectIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+			return
+		}
+		value := uint(id)
+		projectID = &value
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	conversations, total, err := h.resultService.ListConversationsMissingResult(projectID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "common.success"),
+		"data": gin.H{
+			"items":     conversations,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
 // UpdateResult updates a conversation result
 // @Summary Update conversation result
 // @Description Update specific fields of a conversation result
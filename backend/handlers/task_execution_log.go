@@ -1,22 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
+	appErrors "xsha-backend/errors"
 	"xsha-backend/i18n"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
+	"xsha-backend/services/executor"
+	"xsha-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type TaskExecutionLogHandlers struct {
-	aiTaskExecutor services.AITaskExecutorService
+	aiTaskExecutor      services.AITaskExecutorService
+	logStreamingService executor.LogStreamingService
 }
 
-func NewTaskExecutionLogHandlers(aiTaskExecutor services.AITaskExecutorService) *TaskExecutionLogHandlers {
+func NewTaskExecutionLogHandlers(aiTaskExecutor services.AITaskExecutorService, logStreamingService executor.LogStreamingService) *TaskExecutionLogHandlers {
 	return &TaskExecutionLogHandlers{
-		aiTaskExecutor: aiTaskExecutor,
+		aiTaskExecutor:      aiTaskExecutor,
+		logStreamingService: logStreamingService,
 	}
 }
 
@@ -27,7 +35,9 @@ func NewTaskExecutionLogHandlers(aiTaskExecutor services.AITaskExecutorService)
 // @Accept json
 // @Produce json
 // @Param conversationId path int true "Conversation ID"
+// @Param offset query int false "Only return log content after this byte offset"
 // @Success 200 {object} database.TaskExecutionLog
+// @Success 200 {object} object{execution_logs=string,offset=int,next_offset=int} "Log content after the offset"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -43,6 +53,31 @@ func (h *TaskExecutionLogHandlers) GetExecutionLog(c *gin.Context) {
 		return
 	}
 
+	if offsetStr, ok := c.GetQuery("offset"); ok {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "task_execution_log.offset_invalid")})
+			return
+		}
+
+		content, nextOffset, err := h.aiTaskExecutor.GetLogTail(uint(conversationID), offset)
+		if err != nil {
+			if err == appErrors.ErrExecutionLogOffsetInvalid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "task_execution_log.offset_invalid")})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task_execution_log.not_found")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"execution_logs": content,
+			"offset":         offset,
+			"next_offset":    nextOffset,
+		})
+		return
+	}
+
 	log, err := h.aiTaskExecutor.GetExecutionLog(uint(conversationID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task_execution_log.not_found")})
@@ -52,6 +87,153 @@ func (h *TaskExecutionLogHandlers) GetExecutionLog(c *gin.Context) {
 	c.JSON(http.StatusOK, log)
 }
 
+// GetEffectivePrompt gets the prompt sent to the AI
+// @Summary Get conversation effective prompt
+// @Description Get the prompt as it was sent to the AI, including the appended project and dev environment system prompts, with attachment tags replaced by workspace paths
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {object} object{conversation_id=int,effective_prompt=string}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /task-conversations/{conversationId}/effective-prompt [get]
+func (h *TaskExecutionLogHandlers) GetEffectivePrompt(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	prompt, err := h.aiTaskExecutor.GetEffectivePrompt(uint(conversationID))
+	if err != nil {
+		if err == appErrors.ErrEffectivePromptNotRecorded {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task_execution_log.effective_prompt_not_recorded")})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task_execution_log.not_found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id":  conversationID,
+		"effective_prompt": prompt,
+	})
+}
+
+// StreamExecutionLog streams the execution log of a conversation
+// @Summary Stream task conversation execution log
+// @Description Stream the execution log of a conversation via Server-Sent Events (SSE). Already accumulated log content is sent first, followed by new output and a final status event
+// @Tags Task Execution Log
+// @Produce text/event-stream
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {string} string "Execution log stream"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /task-conversations/{conversationId}/execution-log/stream [get]
+func (h *TaskExecutionLogHandlers) StreamExecutionLog(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	// Client disconnects cancel the request context, which stops the stream
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	logChan, errChan, err := h.logStreamingService.StreamConversationLogs(ctx, uint(conversationID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task_execution_log.not_found")})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	c.SSEvent("connected", gin.H{
+		"conversation_id": conversationID,
+		"timestamp":       time.Now().Unix(),
+	})
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			utils.Info("Execution log stream closed by client", "conversationID", conversationID)
+			return
+		case logLine, ok := <-logChan:
+			if !ok {
+				running, _ := h.logStreamingService.IsConversationRunning(uint(conversationID))
+				c.SSEvent("status", gin.H{
+					"conversation_id": conversationID,
+					"running":         running,
+					"timestamp":       time.Now().Unix(),
+				})
+				c.Writer.Flush()
+				return
+			}
+
+			c.SSEvent("log", gin.H{
+				"line":      logLine,
+				"timestamp": time.Now().Unix(),
+			})
+			c.Writer.Flush()
+		case streamErr, ok := <-errChan:
+			if !ok {
+				continue
+			}
+
+			utils.Error("Execution log stream failed", "conversationID", conversationID, "error", streamErr)
+			c.SSEvent("error", gin.H{
+				"message":   i18n.T(lang, "task_execution_log.stream_failed"),
+				"timestamp": time.Now().Unix(),
+			})
+			c.Writer.Flush()
+			return
+		}
+	}
+}
+
+// PreviewCommand previews the docker command of a conversation
+// @Summary Preview task execution command
+// @Description Return the masked docker command a conversation would run, without executing it or touching the workspace
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {object} object{command=string}
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /task-conversations/{conversationId}/execution/preview [get]
+func (h *TaskExecutionLogHandlers) PreviewCommand(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	command, err := h.aiTaskExecutor.PreviewCommand(uint(conversationID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.MapErrorToI18nKey(err, lang)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"command": command})
+}
+
 // CancelExecution cancels task execution
 // @Summary Cancel task execution
 // @Description Cancel AI task that is executing or pending
@@ -86,13 +268,100 @@ func (h *TaskExecutionLogHandlers) CancelExecution(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "task_execution_log.cancel_success")})
 }
 
+// CancelProjectConversations cancels all active conversations of a project
+// @Summary Cancel all project conversations
+// @Description Cancel every pending or running conversation across all tasks of a project
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} object{message=string,cancelled_ids=[]int,failed_ids=[]int}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /projects/{id}/cancel-all-conversations [post]
+func (h *TaskExecutionLogHandlers) CancelProjectConversations(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	createdBy, _ := username.(string)
+
+	cancelled, failed, err := h.aiTaskExecutor.CancelProjectConversations(uint(projectID), createdBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "task_execution_log.cancel_all_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.T(lang, "task_execution_log.cancel_all_success"),
+		"cancelled_ids": cancelled,
+		"failed_ids":    failed,
+	})
+}
+
+// CancelTaskConversations cancels all active conversations of a task
+// @Summary Cancel all task conversations
+// @Description Cancel every pending or running conversation of a task
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} object{message=string,cancelled_count=int,skipped_count=int,cancelled_ids=[]int,skipped_ids=[]int}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tasks/{id}/conversations/cancel-all [post]
+func (h *TaskExecutionLogHandlers) CancelTaskConversations(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	createdBy, _ := username.(string)
+
+	cancelled, skipped, err := h.aiTaskExecutor.CancelTaskConversations(uint(taskID), createdBy)
+	if err != nil {
+		if err == appErrors.ErrTaskNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(lang, "task.not_found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "task_execution_log.cancel_task_all_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         i18n.T(lang, "task_execution_log.cancel_task_all_success"),
+		"cancelled_count": len(cancelled),
+		"skipped_count":   len(skipped),
+		"cancelled_ids":   cancelled,
+		"skipped_ids":     skipped,
+	})
+}
+
+type RetryExecutionRequest struct {
+	// Prompt for this attempt only, stored as retry_content, empty reuses the original prompt
+	Content string `json:"content" binding:"max=100000" example:"Fix the failing test, the fixture file moved to testdata/"`
+}
+
 // RetryExecution retries task execution
 // @Summary Retry task execution
-// @Description Retry failed or cancelled AI task
+// @Description Retry failed or cancelled AI task, optionally with a replacement prompt
 // @Tags Task Execution Log
 // @Accept json
 // @Produce json
 // @Param conversationId path int true "Conversation ID"
+// @Param request body RetryExecutionRequest false "Replacement prompt"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -109,13 +378,106 @@ func (h *TaskExecutionLogHandlers) RetryExecution(c *gin.Context) {
 		return
 	}
 
+	// The body is optional, an empty one retries with the original prompt
+	var req RetryExecutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
 	username, _ := c.Get("username")
 	createdBy, _ := username.(string)
 
-	if err := h.aiTaskExecutor.RetryExecution(uint(conversationID), createdBy); err != nil {
+	if err := h.aiTaskExecutor.RetryExecution(uint(conversationID), req.Content, createdBy); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "task_execution_log.retry_success")})
 }
+
+// ApproveConversation commits the changes of a conversation awaiting approval
+// @Summary Approve conversation changes
+// @Description Commit the uncommitted changes a conversation left for approval and mark it successful
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /task-conversations/{conversationId}/approve [post]
+func (h *TaskExecutionLogHandlers) ApproveConversation(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	approvedBy, _ := username.(string)
+
+	if err := h.aiTaskExecutor.ApproveConversation(uint(conversationID), approvedBy); err != nil {
+		helper := i18n.NewHelper(lang)
+		switch err {
+		case appErrors.ErrConversationNotAwaitingApproval:
+			helper.ErrorResponseFromError(c, http.StatusConflict, err)
+		case appErrors.ErrConversationNotFound:
+			helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		default:
+			helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "taskConversation.approve_success")})
+}
+
+// RejectConversation discards the changes of a conversation awaiting approval
+// @Summary Reject conversation changes
+// @Description Reset the workspace to discard the changes a conversation left for approval and cancel it
+// @Tags Task Execution Log
+// @Accept json
+// @Produce json
+// @Param conversationId path int true "Conversation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /task-conversations/{conversationId}/reject [post]
+func (h *TaskExecutionLogHandlers) RejectConversation(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	conversationIDStr := c.Param("conversationId")
+	conversationID, err := strconv.ParseUint(conversationIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	rejectedBy, _ := username.(string)
+
+	if err := h.aiTaskExecutor.RejectConversation(uint(conversationID), rejectedBy); err != nil {
+		helper := i18n.NewHelper(lang)
+		switch err {
+		case appErrors.ErrConversationNotAwaitingApproval:
+			helper.ErrorResponseFromError(c, http.StatusConflict, err)
+		case appErrors.ErrConversationNotFound:
+			helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		default:
+			helper.ErrorResponseFromError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "taskConversation.reject_success")})
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"xsha-backend/i18n"
+	"xsha-backend/middleware"
+	"xsha-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskTemplateHandlers struct {
+	templateService services.TaskTemplateService
+}
+
+func NewTaskTemplateHandlers(templateService services.TaskTemplateService) *TaskTemplateHandlers {
+	return &TaskTemplateHandlers{
+		templateService: templateService,
+	}
+}
+
+// @Description Create task template request
+type CreateTaskTemplateRequest struct {
+	Name             string `json:"name" binding:"required" example:"Bug fix on main"`
+	Description      string `json:"description" example:"Fix a reported bug starting from main"`
+	ProjectID        *uint  `json:"project_id" example:"1"`
+	DevEnvironmentID *uint  `json:"dev_environment_id" example:"1"`
+	StartBranch      string `json:"start_branch" example:"main"`
+	// Used as the requirement description of tasks created without one
+	ConversationTemplate string `json:"conversation_template" example:"Fix the following bug and add a regression test:"`
+}
+
+// @Description Update task template request
+type UpdateTaskTemplateRequest struct {
+	Name        *string `json:"name" example:"Bug fix on main"`
+	Description *string `json:"description" example:"Fix a reported bug starting from main"`
+	// 0 removes the default project
+	ProjectID *uint `json:"project_id" example:"1"`
+	// 0 removes the default development environment
+	DevEnvironmentID     *uint   `json:"dev_environment_id" example:"1"`
+	StartBranch          *string `json:"start_branch" example:"main"`
+	ConversationTemplate *string `json:"conversation_template" example:"Fix the following bug and add a regression test:"`
+}
+
+// CreateTemplate creates a task template
+// @Summary Create task template
+// @Description Create a template holding the defaults of new tasks
+// @Tags Task Templates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param template body CreateTaskTemplateRequest true "Task template information"
+// @Success 201 {object} object{message=string,data=database.TaskTemplate} "Task template created successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Router /task-templates [post]
+func (h *TaskTemplateHandlers) CreateTemplate(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	username, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": i18n.T(lang, "auth.unauthorized"),
+		})
+		return
+	}
+
+	var req CreateTaskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(req.Name, req.Description, req.ProjectID, req.DevEnvironmentID, req.StartBranch, req.ConversationTemplate, username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(lang, "task_template.create_success"),
+		"data":    template,
+	})
+}
+
+// GetTemplate gets a task template
+// @Summary Get task template
+// @Description Get a task template of the current user by ID
+// @Tags Task Templates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task template ID"
+// @Success 200 {object} object{data=database.TaskTemplate} "Task template"
+// @Failure 400 {object} object{error=string} "Invalid task template ID"
+// @Failure 404 {object} object{error=string} "Task template not found"
+// @Router /task-templates/{id} [get]
+func (h *TaskTemplateHandlers) GetTemplate(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	template, err := h.templateService.GetTemplate(uint(id), username.(string))
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": template})
+}
+
+// ListTemplates lists task templates
+// @Summary List task templates
+// @Description List the current user's task templates with pagination and filtering
+// @Tags Task Templates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)" default(1)
+// @Param page_size query int false "Number of items per page (default: 20)" default(20)
+// @Param name query string false "Filter by template name (partial match)"
+// @Param project_id query int false "Filter by default project ID"
+// @Success 200 {object} object{data=object{templates=[]database.TaskTemplate,total=int,page=int,page_size=int}} "Task templates"
+// @Failure 401 {object} object{error=string} "Authentication failed"
+// @Failure 500 {object} object{error=string} "Internal server error"
+// @Router /task-templates [get]
+func (h *TaskTemplateHandlers) ListTemplates(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	var name *string
+	if n := c.Query("name"); n != "" {
+		name = &n
+	}
+
+	var projectID *uint
+	if pid := c.Query("project_id"); pid != "" {
+		if id, err := strconv.ParseUint(pid, 10, 32); err == nil {
+			pidUint := uint(id)
+			projectID = &pidUint
+		}
+	}
+
+	username, _ := c.Get("username")
+	templates, total, err := h.templateService.ListTemplates(username.(string), name, projectID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(lang, "common.internal_error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"templates": templates,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// UpdateTemplate updates a task template
+// @Summary Update task template
+// @Description Update the fields of a task template that are present in the request
+// @Tags Task Templates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task template ID"
+// @Param template body UpdateTaskTemplateRequest true "Task template update information"
+// @Success 200 {object} object{message=string,data=database.TaskTemplate} "Task template updated successfully"
+// @Failure 400 {object} object{error=string} "Request parameter error"
+// @Failure 404 {object} object{error=string} "Task template not found"
+// @Router /task-templates/{id} [put]
+func (h *TaskTemplateHandlers) UpdateTemplate(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	var req UpdateTaskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "validation.invalid_format_with_details", err.Error())})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.ProjectID != nil {
+		if *req.ProjectID == 0 {
+			updates["project_id"] = (*uint)(nil)
+		} else {
+			updates["project_id"] = req.ProjectID
+		}
+	}
+	if req.DevEnvironmentID != nil {
+		if *req.DevEnvironmentID == 0 {
+			updates["dev_environment_id"] = (*uint)(nil)
+		} else {
+			updates["dev_environment_id"] = req.DevEnvironmentID
+		}
+	}
+	if req.StartBranch != nil {
+		updates["start_branch"] = *req.StartBranch
+	}
+	if req.ConversationTemplate != nil {
+		updates["conversation_template"] = *req.ConversationTemplate
+	}
+
+	username, _ := c.Get("username")
+	template, err := h.templateService.UpdateTemplate(uint(id), username.(string), updates)
+	if err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(lang, "task_template.update_success"),
+		"data":    template,
+	})
+}
+
+// DeleteTemplate deletes a task template
+// @Summary Delete task template
+// @Description Delete a task template, tasks created from it are not affected
+// @Tags Task Templates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Task template ID"
+// @Success 200 {object} object{message=string} "Task template deleted successfully"
+// @Failure 400 {object} object{error=string} "Invalid task template ID"
+// @Failure 404 {object} object{error=string} "Task template not found"
+// @Router /task-templates/{id} [delete]
+func (h *TaskTemplateHandlers) DeleteTemplate(c *gin.Context) {
+	lang := middleware.GetLangFromContext(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(lang, "common.invalid_id")})
+		return
+	}
+
+	username, _ := c.Get("username")
+	if err := h.templateService.DeleteTemplate(uint(id), username.(string)); err != nil {
+		helper := i18n.NewHelper(lang)
+		helper.ErrorResponseFromError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(lang, "task_template.delete_success")})
+}
@@ -13,8 +13,10 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"os"
+	"os/exec"
 	"os/signal"
 	"syscall"
 	"time"
@@ -48,28 +50,79 @@ func main() {
 	}
 	defer dbManager.Close()
 
+	shutdownTracing, err := utils.InitTracing(utils.TracingConfig{
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		ServiceName:  cfg.TracingServiceName,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		utils.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	shutdownEvents, err := utils.InitEventPublisher(utils.EventPublisherConfig{
+		Broker: cfg.EventBroker,
+		URL:    cfg.EventBrokerURL,
+		Topic:  cfg.EventTopic,
+	})
+	if err != nil {
+		utils.Error("Failed to initialize execution event publisher", "error", err)
+		os.Exit(1)
+	}
+
+	logCipher, err := utils.NewLogCipher(cfg.EncryptionKey)
+	if err != nil {
+		utils.Error("Failed to initialize execution log encryption", "error", err)
+		os.Exit(1)
+	}
+
+	secretCipher, err := utils.NewSecretCipher(cfg.EncryptionKey)
+	if err != nil {
+		utils.Error("Failed to initialize secret encryption", "error", err)
+		os.Exit(1)
+	}
+
+	logStore, err := utils.NewObjectStorage(utils.ObjectStorageConfig{
+		Endpoint:  cfg.LogStorageEndpoint,
+		Region:    cfg.LogStorageRegion,
+		Bucket:    cfg.LogStorageBucket,
+		AccessKey: cfg.LogStorageAccessKey,
+		SecretKey: cfg.LogStorageSecretKey,
+		Prefix:    cfg.LogStoragePrefix,
+	})
+	if err != nil {
+		utils.Error("Failed to initialize execution log storage", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize repositories
 	tokenRepo := repository.NewTokenBlacklistRepository(dbManager.GetDB())
 	loginLogRepo := repository.NewLoginLogRepository(dbManager.GetDB())
+	userPreferenceRepo := repository.NewUserPreferenceRepository(dbManager.GetDB())
 	adminOperationLogRepo := repository.NewAdminOperationLogRepository(dbManager.GetDB())
 	gitCredRepo := repository.NewGitCredentialRepository(dbManager.GetDB())
 	projectRepo := repository.NewProjectRepository(dbManager.GetDB())
 	devEnvRepo := repository.NewDevEnvironmentRepository(dbManager.GetDB())
 	taskRepo := repository.NewTaskRepository(dbManager.GetDB())
-	taskConvRepo := repository.NewTaskConversationRepository(dbManager.GetDB())
-	execLogRepo := repository.NewTaskExecutionLogRepository(dbManager.GetDB())
+	systemConfigRepo := repository.NewSystemConfigRepository(dbManager.GetDB())
+	taskConvRepo := repository.NewTaskConversationRepository(dbManager.GetDB(), logCipher, logStore)
+	execLogRepo := repository.NewTaskExecutionLogRepository(dbManager.GetDB(), logCipher, systemConfigRepo, logStore)
 	taskConvResultRepo := repository.NewTaskConversationResultRepository(dbManager.GetDB())
 	taskConvAttachmentRepo := repository.NewTaskConversationAttachmentRepository(dbManager.GetDB())
-	systemConfigRepo := repository.NewSystemConfigRepository(dbManager.GetDB())
+	workspaceSnapshotRepo := repository.NewWorkspaceSnapshotRepository(dbManager.GetDB())
+	taskTemplateRepo := repository.NewTaskTemplateRepository(dbManager.GetDB())
+	scheduledTaskRepo := repository.NewScheduledTaskRepository(dbManager.GetDB())
 	dashboardRepo := repository.NewDashboardRepository(dbManager.GetDB())
+	secretRepo := repository.NewSecretRepository(dbManager.GetDB())
 
 	// Initialize services
 	loginLogService := services.NewLoginLogService(loginLogRepo)
 	adminOperationLogService := services.NewAdminOperationLogService(adminOperationLogRepo)
-	authService := services.NewAuthService(tokenRepo, loginLogRepo, adminOperationLogService, systemConfigRepo, cfg)
-	gitCredService := services.NewGitCredentialService(gitCredRepo, projectRepo, cfg)
-	systemConfigService := services.NewSystemConfigService(systemConfigRepo)
+	authService := services.NewAuthService(tokenRepo, loginLogRepo, adminOperationLogService, systemConfigRepo, userPreferenceRepo, cfg)
+	systemConfigService := services.NewSystemConfigService(systemConfigRepo, cfg)
+	gitCredService := services.NewGitCredentialService(gitCredRepo, projectRepo, systemConfigService, cfg)
 	dashboardService := services.NewDashboardService(dashboardRepo)
+	secretService := services.NewSecretService(secretRepo, devEnvRepo, systemConfigService, secretCipher)
 
 	// Get git clone timeout from system config
 	gitCloneTimeout, err := systemConfigService.GetGitCloneTimeout()
@@ -80,12 +133,14 @@ func main() {
 
 	// Initialize workspace manager
 	workspaceManager := utils.NewWorkspaceManager(cfg.WorkspaceBaseDir, gitCloneTimeout)
-	devEnvService := services.NewDevEnvironmentService(devEnvRepo, taskRepo, systemConfigService, cfg)
-	projectService := services.NewProjectService(projectRepo, gitCredRepo, gitCredService, taskRepo, systemConfigService, cfg)
-	taskService := services.NewTaskService(taskRepo, projectRepo, devEnvRepo, taskConvRepo, execLogRepo, taskConvResultRepo, taskConvAttachmentRepo, workspaceManager, cfg, gitCredService, systemConfigService)
-	taskConvResultService := services.NewTaskConversationResultService(taskConvResultRepo, taskConvRepo, taskRepo, projectRepo)
+	devEnvService := services.NewDevEnvironmentService(devEnvRepo, taskRepo, secretRepo, systemConfigService, cfg)
+	projectService := services.NewProjectService(projectRepo, gitCredRepo, gitCredService, taskRepo, taskConvResultRepo, systemConfigService, workspaceManager, cfg)
+	taskService := services.NewTaskService(taskRepo, projectRepo, devEnvRepo, taskConvRepo, execLogRepo, taskConvResultRepo, taskConvAttachmentRepo, workspaceSnapshotRepo, workspaceManager, cfg, gitCredService, systemConfigService)
+	taskTemplateService := services.NewTaskTemplateService(taskTemplateRepo, projectRepo, devEnvRepo)
+	taskConvResultService := services.NewTaskConversationResultService(taskConvResultRepo, taskConvRepo, taskRepo, projectRepo, execLogRepo)
 	taskConvAttachmentService := services.NewTaskConversationAttachmentService(taskConvAttachmentRepo, cfg)
-	taskConvService := services.NewTaskConversationService(taskConvRepo, taskRepo, execLogRepo, taskConvResultRepo, taskService, taskConvAttachmentService, workspaceManager)
+	taskConvService := services.NewTaskConversationService(taskConvRepo, taskRepo, execLogRepo, taskConvResultRepo, taskService, taskConvAttachmentService, workspaceManager, systemConfigService, cfg)
+	scheduledTaskService := services.NewScheduledTaskService(scheduledTaskRepo, taskRepo, taskTemplateRepo, taskConvService, systemConfigService)
 
 	// Create shared execution manager
 	maxConcurrency := 5
@@ -95,12 +150,23 @@ func main() {
 	executionManager := executor.NewExecutionManager(maxConcurrency)
 
 	// Initialize services with shared execution manager
-	aiTaskExecutor := executor.NewAITaskExecutorServiceWithManager(taskConvRepo, taskRepo, execLogRepo, taskConvResultRepo, gitCredService, taskConvResultService, taskService, systemConfigService, taskConvAttachmentService, cfg, executionManager)
-	logStreamingService := executor.NewLogStreamingService(taskConvRepo, execLogRepo, executionManager)
+	aiTaskExecutor := executor.NewAITaskExecutorServiceWithManager(taskConvRepo, taskRepo, execLogRepo, taskConvResultRepo, gitCredService, taskConvResultService, taskService, systemConfigService, taskConvAttachmentService, secretService, authService, cfg, executionManager)
+	logStreamingService := executor.NewLogStreamingService(taskConvRepo, execLogRepo, executionManager, systemConfigService, cfg)
+	logRetentionService := services.NewExecutionLogRetentionService(execLogRepo, projectRepo, systemConfigService)
+	workspaceRetentionService := services.NewWorkspaceRetentionService(taskRepo, taskConvRepo, workspaceManager, systemConfigService)
 
 	// Initialize scheduler
-	taskProcessor := scheduler.NewTaskProcessor(aiTaskExecutor)
+	taskProcessor := scheduler.NewTaskProcessor(aiTaskExecutor, scheduledTaskService)
 	schedulerManager := scheduler.NewSchedulerManager(taskProcessor, cfg.SchedulerIntervalDuration)
+	aiTaskExecutor.SetSchedulerPauseState(schedulerManager)
+	logRetentionProcessor := scheduler.NewLogRetentionProcessor(logRetentionService)
+	logRetentionScheduler := scheduler.NewSchedulerManager(logRetentionProcessor, cfg.LogRetentionIntervalDuration)
+	referenceCacheProcessor := scheduler.NewReferenceCacheProcessor(projectService)
+	referenceCacheScheduler := scheduler.NewSchedulerManager(referenceCacheProcessor, cfg.ReferenceCacheRefreshIntervalDuration)
+	workspaceCleanupProcessor := scheduler.NewWorkspaceCleanupProcessor(workspaceRetentionService)
+	workspaceCleanupScheduler := scheduler.NewSchedulerManager(workspaceCleanupProcessor, cfg.WorkspaceCleanupIntervalDuration)
+	branchCleanupProcessor := scheduler.NewBranchCleanupProcessor(taskService)
+	branchCleanupScheduler := scheduler.NewSchedulerManager(branchCleanupProcessor, cfg.BranchCleanupIntervalDuration)
 
 	// Initialize handlers
 	authHandlers := handlers.NewAuthHandlers(authService, loginLogService)
@@ -108,13 +174,19 @@ func main() {
 	gitCredHandlers := handlers.NewGitCredentialHandlers(gitCredService)
 	projectHandlers := handlers.NewProjectHandlers(projectService)
 	devEnvHandlers := handlers.NewDevEnvironmentHandlers(devEnvService)
-	taskHandlers := handlers.NewTaskHandlers(taskService, taskConvService, projectService)
-	taskConvHandlers := handlers.NewTaskConversationHandlers(taskConvService, logStreamingService)
+	taskHandlers := handlers.NewTaskHandlers(taskService, taskConvService, projectService, taskTemplateService)
+	taskTemplateHandlers := handlers.NewTaskTemplateHandlers(taskTemplateService)
+	scheduledTaskHandlers := handlers.NewScheduledTaskHandlers(scheduledTaskService)
+	secretHandlers := handlers.NewSecretHandlers(secretService)
+	taskConvHandlers := handlers.NewTaskConversationHandlers(taskConvService, logStreamingService, aiTaskExecutor)
 	taskConvResultHandlers := handlers.NewTaskConversationResultHandlers(taskConvResultService)
-	taskExecLogHandlers := handlers.NewTaskExecutionLogHandlers(aiTaskExecutor)
+	taskExecLogHandlers := handlers.NewTaskExecutionLogHandlers(aiTaskExecutor, logStreamingService)
 	taskConvAttachmentHandlers := handlers.NewTaskConversationAttachmentHandlers(taskConvAttachmentService)
 	systemConfigHandlers := handlers.NewSystemConfigHandlers(systemConfigService)
 	dashboardHandlers := handlers.NewDashboardHandlers(dashboardService)
+	healthHandlers := handlers.NewHealthHandlers(aiTaskExecutor, schedulerManager)
+	schedulerHandlers := handlers.NewSchedulerHandlers(schedulerManager, aiTaskExecutor)
+	containerHandlers := handlers.NewContainerHandlers(aiTaskExecutor)
 
 	// Set gin mode
 	if cfg.Environment == "production" {
@@ -130,6 +202,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Verify the configured container runtime is installed
+	containerRuntime, err := systemConfigService.GetContainerRuntime()
+	if err != nil {
+		utils.Error("Failed to get container runtime from system config", "error", err)
+		os.Exit(1)
+	}
+	if _, err := exec.LookPath(containerRuntime); err != nil {
+		utils.Error("Configured container runtime not found in PATH", "runtime", containerRuntime, "error", err)
+		os.Exit(1)
+	}
+	utils.Info("Container runtime available", "runtime", containerRuntime)
+
 	// Create attachment storage directory
 	if err := os.MkdirAll(cfg.AttachmentsDir, 0755); err != nil {
 		utils.Error("Failed to create attachment storage directory", "directory", cfg.AttachmentsDir, "error", err)
@@ -151,8 +235,32 @@ func main() {
 	}
 	utils.Info("Dev sessions directory initialized", "directory", cfg.DevSessionsDir)
 
+	// Create workspace snapshots directory
+	if err := os.MkdirAll(cfg.WorkspaceSnapshotsDir, 0755); err != nil {
+		utils.Error("Failed to create workspace snapshots directory", "directory", cfg.WorkspaceSnapshotsDir, "error", err)
+		os.Exit(1)
+	}
+	utils.Info("Workspace snapshots directory initialized", "directory", cfg.WorkspaceSnapshotsDir)
+
 	// Setup routes - Pass all handler instances including static files
-	routes.SetupRoutes(r, cfg, authService, authHandlers, gitCredHandlers, projectHandlers, adminOperationLogHandlers, devEnvHandlers, taskHandlers, taskConvHandlers, taskConvResultHandlers, taskExecLogHandlers, taskConvAttachmentHandlers, systemConfigHandlers, dashboardHandlers, &StaticFiles)
+	routes.SetupRoutes(r, cfg, authService, systemConfigService, authHandlers, gitCredHandlers, projectHandlers, adminOperationLogHandlers, devEnvHandlers, taskHandlers, taskTemplateHandlers, scheduledTaskHandlers, secretHandlers, taskConvHandlers, taskConvResultHandlers, taskExecLogHandlers, taskConvAttachmentHandlers, systemConfigHandlers, dashboardHandlers, healthHandlers, schedulerHandlers, containerHandlers, &StaticFiles)
+
+	// Remove conversation containers left running by an unclean shutdown, no execution owns
+	// them before the scheduler starts. They go first so none still writes to a workspace that
+	// reconciling interrupted conversations cleans up.
+	if removed, err := aiTaskExecutor.ReconcileOrphanedContainers(); err != nil {
+		utils.Warn("Failed to reconcile orphaned containers", "error", err)
+	} else if removed > 0 {
+		utils.Info("Removed orphaned conversation containers", "count", removed)
+	}
+
+	// Fail conversations whose execution was lost with the previous process, before the
+	// scheduler could pick up their tasks again
+	if interrupted, err := aiTaskExecutor.ReconcileInterruptedConversations(); err != nil {
+		utils.Warn("Failed to reconcile interrupted conversations", "error", err)
+	} else if interrupted > 0 {
+		utils.Info("Marked interrupted conversations as failed", "count", interrupted)
+	}
 
 	// Start scheduler
 	if err := schedulerManager.Start(); err != nil {
@@ -160,6 +268,30 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Start execution log retention scheduler
+	if err := logRetentionScheduler.Start(); err != nil {
+		utils.Error("Failed to start log retention scheduler", "error", err)
+		os.Exit(1)
+	}
+
+	// Start project reference cache refresh scheduler
+	if err := referenceCacheScheduler.Start(); err != nil {
+		utils.Error("Failed to start reference cache scheduler", "error", err)
+		os.Exit(1)
+	}
+
+	// Start stale workspace cleanup scheduler
+	if err := workspaceCleanupScheduler.Start(); err != nil {
+		utils.Error("Failed to start workspace cleanup scheduler", "error", err)
+		os.Exit(1)
+	}
+
+	// Start merged branch cleanup scheduler
+	if err := branchCleanupScheduler.Start(); err != nil {
+		utils.Error("Failed to start branch cleanup scheduler", "error", err)
+		os.Exit(1)
+	}
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -173,6 +305,40 @@ func main() {
 			utils.Error("Failed to stop scheduler", "error", err)
 		}
 
+		// Stop execution log retention scheduler
+		if err := logRetentionScheduler.Stop(); err != nil {
+			utils.Error("Failed to stop log retention scheduler", "error", err)
+		}
+
+		// Stop project reference cache refresh scheduler
+		if err := referenceCacheScheduler.Stop(); err != nil {
+			utils.Error("Failed to stop reference cache scheduler", "error", err)
+		}
+
+		// Stop stale workspace cleanup scheduler
+		if err := workspaceCleanupScheduler.Stop(); err != nil {
+			utils.Error("Failed to stop workspace cleanup scheduler", "error", err)
+		}
+
+		// Stop merged branch cleanup scheduler
+		if err := branchCleanupScheduler.Stop(); err != nil {
+			utils.Error("Failed to stop branch cleanup scheduler", "error", err)
+		}
+
+		// Flush pending trace spans
+		tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdownTracing(tracingCtx); err != nil {
+			utils.Error("Failed to flush trace spans", "error", err)
+		}
+		cancelTracing()
+
+		// Publish queued execution events
+		eventsCtx, cancelEvents := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdownEvents(eventsCtx); err != nil {
+			utils.Error("Failed to close execution event publisher", "error", err)
+		}
+		cancelEvents()
+
 		// Sync logger before exit
 		if err := utils.Sync(); err != nil {
 			utils.Error("Failed to sync logger", "error", err)
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"xsha-backend/config"
 	"xsha-backend/i18n"
@@ -62,6 +63,30 @@ func AuthMiddlewareWithService(authService services.AuthService, cfg *config.Con
 		}
 
 		c.Set("username", claims.Username)
+		applyPreferredLanguage(c, authService, claims.Username)
+		c.Next()
+	}
+}
+
+// MetricsAuthMiddleware requires the configured metrics bearer token, the endpoint is open
+// when no token is configured
+func MetricsAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.MetricsToken == "" {
+			c.Next()
+			return
+		}
+
+		token, err := utils.ExtractTokenFromAuthHeader(c.GetHeader("Authorization"))
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.MetricsToken)) != 1 {
+			lang := GetLangFromContext(c)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(lang, "auth.unauthorized"),
+			})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
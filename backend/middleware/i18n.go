@@ -3,33 +3,39 @@ package middleware
 import (
 	"strings"
 	"xsha-backend/i18n"
+	"xsha-backend/services"
+	"xsha-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func I18nMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		lang := detectLanguage(c)
+		lang, explicit := detectLanguage(c)
 		c.Set("lang", lang)
+		c.Set("lang_explicit", explicit)
 		c.Next()
 	}
 }
 
-func detectLanguage(c *gin.Context) string {
+// detectLanguage returns the language named by the lang query or the Accept-Language header,
+// and whether the lang query named it. The header is only the browser default, so a stored
+// preference still overrides it.
+func detectLanguage(c *gin.Context) (string, bool) {
 	if lang := c.Query("lang"); lang != "" {
 		if isValidLanguage(lang) {
-			return lang
+			return lang, true
 		}
 	}
 
 	if acceptLang := c.GetHeader("Accept-Language"); acceptLang != "" {
 		lang := parseAcceptLanguage(acceptLang)
 		if isValidLanguage(lang) {
-			return lang
+			return lang, false
 		}
 	}
 
-	return "en-US"
+	return "en-US", false
 }
 
 func parseAcceptLanguage(acceptLang string) string {
@@ -63,6 +69,22 @@ func isValidLanguage(lang string) bool {
 	return false
 }
 
+// applyPreferredLanguage switches requests without a lang query to the user's preferred language
+func applyPreferredLanguage(c *gin.Context, authService services.AuthService, username string) {
+	if explicit, _ := c.Get("lang_explicit"); explicit == true {
+		return
+	}
+
+	preferred, err := authService.GetPreferredLanguage(username)
+	if err != nil {
+		utils.Warn("Failed to get preferred language of user", "username", username, "error", err)
+		return
+	}
+	if preferred != "" && isValidLanguage(preferred) {
+		c.Set("lang", preferred)
+	}
+}
+
 func GetLangFromContext(c *gin.Context) string {
 	if lang, exists := c.Get("lang"); exists {
 		if langStr, ok := lang.(string); ok {
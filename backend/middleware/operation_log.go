@@ -130,17 +130,20 @@ func determineOperationInfo(method, path, id string) (operation, resource, resou
 
 func getResourceDisplayName(resource string) string {
 	displayNames := map[string]string{
-		"admin":          "admin",
-		"auth":           "auth",
-		"credentials":    "credentials",
-		"projects":       "projects",
-		"tasks":          "tasks",
-		"conversations":  "conversations",
-		"environments":   "environments",
-		"operation-logs": "operation-logs",
-		"login-logs":     "login-logs",
-		"logs":           "logs",
-		"user":           "user",
+		"admin":           "admin",
+		"auth":            "auth",
+		"credentials":     "credentials",
+		"projects":        "projects",
+		"tasks":           "tasks",
+		"task-templates":  "task-templates",
+		"scheduled-tasks": "scheduled-tasks",
+		"secrets":         "secrets",
+		"conversations":   "conversations",
+		"environments":    "environments",
+		"operation-logs":  "operation-logs",
+		"login-logs":      "login-logs",
+		"logs":            "logs",
+		"user":            "user",
 	}
 
 	if displayName, exists := displayNames[resource]; exists {
@@ -168,6 +171,15 @@ func normalizeResourceName(path, resource string) string {
 	if strings.Contains(path, "/projects") {
 		return "projects"
 	}
+	if strings.Contains(path, "/scheduled-tasks") {
+		return "scheduled-tasks"
+	}
+	if strings.Contains(path, "/secrets") {
+		return "secrets"
+	}
+	if strings.Contains(path, "/task-templates") {
+		return "task-templates"
+	}
 	if strings.Contains(path, "/tasks") {
 		return "tasks"
 	}
@@ -2,10 +2,13 @@ package middleware
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 	"xsha-backend/i18n"
+	"xsha-backend/services"
 	"xsha-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -136,3 +139,128 @@ func LoginRateLimitMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RateLimitStore counts requests per key in fixed windows. Limits are passed on
+// every call so they can follow configuration changes; a shared backend such as
+// Redis can implement it when several instances serve the API.
+type RateLimitStore interface {
+	// Allow records a request for key and reports whether it is within limit,
+	// along with the time left until the current window resets
+	Allow(key string, limit int, window time.Duration) (bool, time.Duration)
+}
+
+// memoryRateLimitEntry keeps the window the entry was counted with, so cleanup keeps it
+// for as long as that window lasts however long it is
+type memoryRateLimitEntry struct {
+	RateLimitEntry
+	Window time.Duration
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryRateLimitEntry
+}
+
+// NewMemoryRateLimitStore creates a RateLimitStore kept in process memory
+func NewMemoryRateLimitStore() RateLimitStore {
+	store := &memoryRateLimitStore{
+		entries: make(map[string]*memoryRateLimitEntry),
+	}
+
+	go store.cleanup()
+
+	return store
+}
+
+func (s *memoryRateLimitStore) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utils.Now()
+	entry, exists := s.entries[key]
+	if !exists || now.Sub(entry.FirstTime) >= window {
+		s.entries[key] = &memoryRateLimitEntry{
+			RateLimitEntry: RateLimitEntry{
+				Count:     1,
+				FirstTime: now,
+				LastTime:  now,
+			},
+			Window: window,
+		}
+		return true, window
+	}
+
+	entry.LastTime = now
+	entry.Window = window
+	remaining := window - now.Sub(entry.FirstTime)
+
+	if entry.Count >= limit {
+		return false, remaining
+	}
+
+	entry.Count++
+	return true, remaining
+}
+
+func (s *memoryRateLimitStore) cleanup() {
+	ticker := time.NewTicker(time.Minute * 5)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := utils.Now()
+		for key, entry := range s.entries {
+			if now.Sub(entry.FirstTime) >= entry.Window {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ConversationRateLimitMiddleware limits conversation creation per username
+// according to the conversation_rate_limit system configs
+func ConversationRateLimitMiddleware(configService services.SystemConfigService, store RateLimitStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limitConfig, err := configService.GetConversationRateLimitConfig()
+		if err != nil {
+			utils.Error("Failed to get conversation rate limit config, skipping the limit", "error", err)
+			c.Next()
+			return
+		}
+		if limitConfig.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		username, _ := c.Get("username")
+		user, _ := username.(string)
+		if user == "" {
+			c.Next()
+			return
+		}
+		if limitConfig.ExemptAdmin && limitConfig.AdminUser != "" && user == limitConfig.AdminUser {
+			c.Next()
+			return
+		}
+
+		allowed, remainingTime := store.Allow("conversation:"+user, limitConfig.Limit, limitConfig.Window)
+		if !allowed {
+			lang := GetLangFromContext(c)
+			retryAfter := int(math.Ceil(remainingTime.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":               i18n.T(lang, "taskConversation.rate_limit"),
+				"retry_after_seconds": retryAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+// conversationSnippetContext is how many characters before a match a snippet starts,
+// conversationSnippetLength how many it holds in total
+const (
+	conversationSnippetContext = 80
+	conversationSnippetLength  = 240
+)
+
+// Conversation search match locations
+const (
+	ConversationMatchContent      = "content"
+	ConversationMatchExecutionLog = "execution_log"
+)
+
+// ConversationSearchParams filters a conversation search, ProjectID nil searches all projects
+type ConversationSearchParams struct {
+	Query       string
+	ProjectID   *uint
+	IncludeLogs bool
+	Page        int
+	PageSize    int
+}
+
+// ConversationSearchHit is a conversation matching a search, with a snippet around the first
+// match and the task and project it belongs to
+type ConversationSearchHit struct {
+	ConversationID uint                        `json:"conversation_id"`
+	Status         database.ConversationStatus `json:"status"`
+	CreatedAt      time.Time                   `json:"created_at"`
+	CreatedBy      string                      `json:"created_by"`
+	TaskID         uint                        `json:"task_id"`
+	TaskTitle      string                      `json:"task_title"`
+	ProjectID      uint                        `json:"project_id"`
+	ProjectName    string                      `json:"project_name"`
+	MatchedIn      string                      `json:"matched_in"`
+	Snippet        string                      `json:"snippet"`
+}
+
+// conversationSearchBackend runs conversation searches. likeConversationSearch works on any
+// database; an FTS5 or MySQL FULLTEXT backed implementation can replace it without changing
+// the repository interface.
+type conversationSearchBackend interface {
+	Search(params ConversationSearchParams) ([]ConversationSearchHit, int64, error)
+}
+
+// likeConversationSearch matches with case-insensitive LIKE. Encrypted execution log segments
+// are stored as ciphertext and never match.
+type likeConversationSearch struct {
+	db *gorm.DB
+}
+
+func (s *likeConversationSearch) Search(params ConversationSearchParams) ([]ConversationSearchHit, int64, error) {
+	term := strings.ToLower(params.Query)
+	pattern := "%" + escapeLikePattern(term) + "%"
+
+	query := s.db.Table("task_conversations AS c").
+		Joins("JOIN tasks AS t ON t.id = c.task_id AND t.deleted_at IS NULL").
+		Joins("JOIN projects AS p ON p.id = t.project_id AND p.deleted_at IS NULL").
+		Where("c.deleted_at IS NULL")
+	if params.IncludeLogs {
+		query = query.
+			Joins("LEFT JOIN task_execution_logs AS l ON l.conversation_id = c.id AND l.deleted_at IS NULL").
+			Where("(LOWER(c.content) LIKE ? ESCAPE '!' OR LOWER(l.execution_logs) LIKE ? ESCAPE '!')", pattern, pattern)
+	} else {
+		query = query.Where("LOWER(c.content) LIKE ? ESCAPE '!'", pattern)
+	}
+	if params.ProjectID != nil {
+		query = query.Where("t.project_id = ?", *params.ProjectID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	contentMatch := "LOWER(c.content) LIKE ? ESCAPE '!'"
+	contentSnippet := snippetExpression("c.content")
+	selectSQL := "c.id AS conversation_id, c.status, c.created_at, c.created_by, " +
+		"t.id AS task_id, t.title AS task_title, p.id AS project_id, p.name AS project_name, "
+	args := []interface{}{}
+	if params.IncludeLogs {
+		selectSQL += "CASE WHEN " + contentMatch + " THEN '" + ConversationMatchContent + "' ELSE '" + ConversationMatchExecutionLog + "' END AS matched_in, " +
+			"CASE WHEN " + contentMatch + " THEN " + contentSnippet + " ELSE " + snippetExpression("l.execution_logs") + " END AS snippet"
+		args = append(args, pattern, pattern, term, term, term, term)
+	} else {
+		selectSQL += "'" + ConversationMatchContent + "' AS matched_in, " + contentSnippet + " AS snippet"
+		args = append(args, term, term)
+	}
+
+	var hits []ConversationSearchHit
+	offset := (params.Page - 1) * params.PageSize
+	err := query.Select(selectSQL, args...).
+		Order("c.created_at DESC").
+		Offset(offset).Limit(params.PageSize).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hits, total, nil
+}
+
+// snippetExpression selects the text around the first case-insensitive match in column,
+// it takes the lowercased search term twice. INSTR, SUBSTR and LOWER work on SQLite and MySQL.
+func snippetExpression(column string) string {
+	position := "INSTR(LOWER(" + column + "), ?)"
+	return "SUBSTR(" + column + ", CASE WHEN " + position + " > " + strconv.Itoa(conversationSnippetContext) +
+		" THEN " + position + " - " + strconv.Itoa(conversationSnippetContext) + " ELSE 1 END, " + strconv.Itoa(conversationSnippetLength) + ")"
+}
+
+// escapeLikePattern escapes LIKE wildcards with "!", a backslash escape is not portable since
+// MySQL treats it as a string escape too
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+	return replacer.Replace(value)
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"encoding/json"
 	"xsha-backend/database"
 
 	"gorm.io/gorm"
@@ -36,7 +37,7 @@ func (r *devEnvironmentRepository) GetByName(name string) (*database.DevEnvironm
 	return &env, nil
 }
 
-func (r *devEnvironmentRepository) List(name *string, dockerImage *string, page, pageSize int) ([]database.DevEnvironment, int64, error) {
+func (r *devEnvironmentRepository) List(name *string, dockerImage *string, minCPU, maxCPU *float64, minMemory, maxMemory *int64, page, pageSize int) ([]database.DevEnvironment, int64, error) {
 	var environments []database.DevEnvironment
 	var total int64
 
@@ -50,6 +51,22 @@ func (r *devEnvironmentRepository) List(name *string, dockerImage *string, page,
 		query = query.Where("docker_image LIKE ?", "%"+*dockerImage+"%")
 	}
 
+	if minCPU != nil {
+		query = query.Where("cpu_limit >= ?", *minCPU)
+	}
+
+	if maxCPU != nil {
+		query = query.Where("cpu_limit <= ?", *maxCPU)
+	}
+
+	if minMemory != nil {
+		query = query.Where("memory_limit >= ?", *minMemory)
+	}
+
+	if maxMemory != nil {
+		query = query.Where("memory_limit <= ?", *maxMemory)
+	}
+
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -70,6 +87,35 @@ func (r *devEnvironmentRepository) Delete(id uint) error {
 	return r.db.Where("id = ?", id).Delete(&database.DevEnvironment{}).Error
 }
 
+func (r *devEnvironmentRepository) ListByEnvVarValue(value, createdBy string) ([]database.DevEnvironment, error) {
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []database.DevEnvironment
+	if err := scopeOwner(r.db, createdBy).Where("env_vars LIKE ?", "%"+string(quoted)+"%").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	// LIKE treats _ and % as wildcards and matches keys too, so confirm on the parsed vars
+	var environments []database.DevEnvironment
+	for _, env := range candidates {
+		var envVars map[string]string
+		if err := json.Unmarshal([]byte(env.EnvVars), &envVars); err != nil {
+			continue
+		}
+		for _, v := range envVars {
+			if v == value {
+				environments = append(environments, env)
+				break
+			}
+		}
+	}
+
+	return environments, nil
+}
+
 func (r *devEnvironmentRepository) GetStats() (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
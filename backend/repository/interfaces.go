@@ -17,6 +17,12 @@ type LoginLogRepository interface {
 	CleanOld(days int) error
 }
 
+type UserPreferenceRepository interface {
+	// GetPreferredLanguage returns an empty string for users without a preference
+	GetPreferredLanguage(username string) (string, error)
+	SetPreferredLanguage(username, language string) error
+}
+
 type GitCredentialRepository interface {
 	Create(credential *database.GitCredential) error
 	GetByID(id uint) (*database.GitCredential, error)
@@ -34,7 +40,10 @@ type ProjectRepository interface {
 	Update(project *database.Project) error
 	Delete(id uint) error
 
+	ListAll() ([]database.Project, error)
 	UpdateLastUsed(id uint) error
+	UpdateReferenceCacheUpdatedAt(id uint, updatedAt time.Time) error
+	UpdateBudgetOverride(id uint, enabled bool) error
 	GetByCredentialID(credentialID uint) ([]database.Project, error)
 	GetTaskCounts(projectIDs []uint) (map[uint]int64, error)
 }
@@ -56,20 +65,30 @@ type DevEnvironmentRepository interface {
 	Create(env *database.DevEnvironment) error
 	GetByID(id uint) (*database.DevEnvironment, error)
 	GetByName(name string) (*database.DevEnvironment, error)
-	List(name *string, dockerImage *string, page, pageSize int) ([]database.DevEnvironment, int64, error)
+	List(name *string, dockerImage *string, minCPU, maxCPU *float64, minMemory, maxMemory *int64, page, pageSize int) ([]database.DevEnvironment, int64, error)
 	Update(env *database.DevEnvironment) error
 	Delete(id uint) error
 	GetStats() (map[string]interface{}, error)
+	// ListByEnvVarValue returns the environments of createdBy with an env var set to exactly
+	// value, an empty createdBy searches every environment
+	ListByEnvVarValue(value, createdBy string) ([]database.DevEnvironment, error)
 }
 
 type TaskRepository interface {
 	Create(task *database.Task) error
+	// CreateImported creates a task with its imported conversation history in one transaction
+	CreateImported(task *database.Task, conversations []ImportedConversation) error
 	GetByID(id uint) (*database.Task, error)
 	List(projectID *uint, statuses []database.TaskStatus, title *string, branch *string, devEnvID *uint, sortBy, sortDirection string, page, pageSize int) ([]database.Task, int64, error)
 	Update(task *database.Task) error
 	Delete(id uint) error
 
 	ListByProject(projectID uint) ([]database.Task, error)
+	ListWithWorkspace() ([]database.Task, error)
+	ClearWorkspacePath(id uint) error
+	// ListForBranchCleanup returns the unmerged tasks with a work branch whose project cleans up after merge
+	ListForBranchCleanup() ([]database.Task, error)
+	MarkBranchMerged(id uint, mergedAt time.Time, clearWorkspace bool) error
 	GetConversationCounts(taskIDs []uint) (map[uint]int64, error)
 	GetLatestExecutionTimes(taskIDs []uint) (map[uint]*time.Time, error)
 }
@@ -86,19 +105,52 @@ type TaskConversationRepository interface {
 	GetLatestByTask(taskID uint) (*database.TaskConversation, error)
 
 	ListByStatus(status database.ConversationStatus) ([]database.TaskConversation, error)
+	CountByStatus(status database.ConversationStatus) (int64, error)
 	GetPendingConversationsWithDetails() ([]database.TaskConversation, error)
+	GetPendingConversationsOrderedByPriority() ([]database.TaskConversation, error)
 	HasPendingOrRunningConversations(taskID uint) (bool, error)
+	Search(params ConversationSearchParams) ([]ConversationSearchHit, int64, error)
+	CountRunsByTask(taskID, excludeID uint, excludedStatuses ...database.ConversationStatus) (int64, error)
+	FindPendingOrRunningByContent(taskID uint, content string) (*database.TaskConversation, error)
+	// CreateUnlessDuplicate creates the conversation unless a pending or running one with the same
+	// content exists on the task, which is returned instead
+	CreateUnlessDuplicate(conversation *database.TaskConversation) (*database.TaskConversation, error)
+	ListActiveByProject(projectID uint) ([]database.TaskConversation, error)
+	ListActiveByTask(taskID uint) ([]database.TaskConversation, error)
+	ListSucceededWithoutResult(projectID *uint, page, pageSize int) ([]database.TaskConversation, int64, error)
 	UpdateCommitHash(id uint, commitHash string) error
+	UpdateChangesCommitted(id uint, committed bool) error
+	UpdateWorkBranch(id uint, workBranch string) error
+	UpdateSessionID(id uint, sessionID string) error
+	// TransitionStatus moves a conversation from one status to another, reporting false when it
+	// was no longer in the from status
+	TransitionStatus(id uint, from, to database.ConversationStatus) (bool, error)
+	GetPreviousSessionID(taskID, beforeConversationID uint) (string, error)
 }
 
 type TaskExecutionLogRepository interface {
 	Create(log *database.TaskExecutionLog) error
 	GetByID(id uint) (*database.TaskExecutionLog, error)
 	GetByConversationID(conversationID uint) (*database.TaskExecutionLog, error)
+	GetLogTail(conversationID uint, offset int) (string, int, error)
 	Update(log *database.TaskExecutionLog) error
 	AppendLog(id uint, logContent string) error
 	UpdateMetadata(id uint, updates map[string]interface{}) error
 	DeleteByConversationID(conversationID uint) error
+	OffloadLogs(id uint) error
+	DeleteCompletedBeforeByProject(projectID uint, before time.Time) (int64, error)
+	CountFailureCategoriesByProject(projectID uint) (map[database.FailureCategory]int64, error)
+}
+
+// SessionResultTotals sums the results of every conversation that took part in a session
+type SessionResultTotals struct {
+	ResultCount       int64
+	ErrorCount        int64
+	TotalCostUsd      float64
+	TotalDurationMs   int64
+	TotalNumTurns     int64
+	TotalInputTokens  int64
+	TotalOutputTokens int64
 }
 
 type TaskConversationResultRepository interface {
@@ -110,10 +162,14 @@ type TaskConversationResultRepository interface {
 
 	ListByTaskID(taskID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error)
 	ListByProjectID(projectID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error)
+	ListBySessionID(sessionID string, page, pageSize int) ([]database.TaskConversationResult, int64, error)
+	GetSessionTotals(sessionID string) (*SessionResultTotals, error)
 
 	GetSuccessRate(taskID uint) (float64, error)
 	GetTotalCost(taskID uint) (float64, error)
+	GetTotalCostByProject(projectID uint) (float64, error)
 	GetAverageDuration(taskID uint) (float64, error)
+	GetTotalTokens(taskID uint) (int64, int64, error)
 
 	ExistsByConversationID(conversationID uint) (bool, error)
 	DeleteByConversationID(conversationID uint) error
@@ -145,3 +201,43 @@ type TaskConversationAttachmentRepository interface {
 	Delete(id uint) error
 	DeleteByConversationID(conversationID uint) error
 }
+
+type WorkspaceSnapshotRepository interface {
+	Create(snapshot *database.WorkspaceSnapshot) error
+	GetByID(id uint) (*database.WorkspaceSnapshot, error)
+	ListByTask(taskID uint) ([]database.WorkspaceSnapshot, error)
+	Delete(id uint) error
+	DeleteByTask(taskID uint) error
+}
+
+// TaskTemplateRepository reads and writes templates of their creator only
+type TaskTemplateRepository interface {
+	Create(template *database.TaskTemplate) error
+	GetByID(id uint, createdBy string) (*database.TaskTemplate, error)
+	List(createdBy string, name *string, projectID *uint, page, pageSize int) ([]database.TaskTemplate, int64, error)
+	Update(template *database.TaskTemplate) error
+	Delete(id uint, createdBy string) error
+}
+
+// ScheduledTaskRepository reads schedules of their creator only, an empty createdBy reads every schedule
+type ScheduledTaskRepository interface {
+	Create(schedule *database.ScheduledTask) error
+	GetByID(id uint, createdBy string) (*database.ScheduledTask, error)
+	List(createdBy string, taskID *uint, enabled *bool, page, pageSize int) ([]database.ScheduledTask, int64, error)
+	Update(schedule *database.ScheduledTask) error
+	Delete(id uint) error
+
+	// ListDue returns the enabled schedules whose next run is at or before now
+	ListDue(now time.Time) ([]database.ScheduledTask, error)
+	UpdateRunState(id uint, updates map[string]interface{}) error
+}
+
+// SecretRepository reads secrets of their creator only, an empty createdBy reads every secret
+type SecretRepository interface {
+	Create(secret *database.Secret) error
+	GetByID(id uint, createdBy string) (*database.Secret, error)
+	GetByName(name, createdBy string) (*database.Secret, error)
+	List(createdBy string, name *string, page, pageSize int) ([]database.Secret, int64, error)
+	Update(secret *database.Secret) error
+	Delete(id uint) error
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"time"
 	"xsha-backend/database"
 	"xsha-backend/utils"
 
@@ -98,6 +99,12 @@ func (r *projectRepository) Delete(id uint) error {
 	return r.db.Where("id = ?", id).Delete(&database.Project{}).Error
 }
 
+func (r *projectRepository) ListAll() ([]database.Project, error) {
+	var projects []database.Project
+	err := r.db.Preload("Credential").Order("id ASC").Find(&projects).Error
+	return projects, err
+}
+
 func (r *projectRepository) UpdateLastUsed(id uint) error {
 	now := utils.Now()
 	return r.db.Model(&database.Project{}).
@@ -105,6 +112,18 @@ func (r *projectRepository) UpdateLastUsed(id uint) error {
 		Update("last_used", now).Error
 }
 
+func (r *projectRepository) UpdateReferenceCacheUpdatedAt(id uint, updatedAt time.Time) error {
+	return r.db.Model(&database.Project{}).
+		Where("id = ?", id).
+		Update("reference_cache_updated_at", updatedAt).Error
+}
+
+func (r *projectRepository) UpdateBudgetOverride(id uint, enabled bool) error {
+	return r.db.Model(&database.Project{}).
+		Where("id = ?", id).
+		Update("budget_override", enabled).Error
+}
+
 func (r *projectRepository) GetByCredentialID(credentialID uint) ([]database.Project, error) {
 	var projects []database.Project
 	err := r.db.Where("credential_id = ?", credentialID).Find(&projects).Error
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+type scheduledTaskRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledTaskRepository(db *gorm.DB) ScheduledTaskRepository {
+	return &scheduledTaskRepository{db: db}
+}
+
+func (r *scheduledTaskRepository) Create(schedule *database.ScheduledTask) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *scheduledTaskRepository) GetByID(id uint, createdBy string) (*database.ScheduledTask, error) {
+	var schedule database.ScheduledTask
+	err := scopeOwner(r.db, createdBy).Preload("Task").Preload("Template").Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduledTaskRepository) List(createdBy string, taskID *uint, enabled *bool, page, pageSize int) ([]database.ScheduledTask, int64, error) {
+	var schedules []database.ScheduledTask
+	var total int64
+
+	query := scopeOwner(r.db.Model(&database.ScheduledTask{}), createdBy)
+
+	if taskID != nil {
+		query = query.Where("task_id = ?", *taskID)
+	}
+
+	if enabled != nil {
+		query = query.Where("enabled = ?", *enabled)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Task").Preload("Template").
+		Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&schedules).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return schedules, total, nil
+}
+
+func (r *scheduledTaskRepository) Update(schedule *database.ScheduledTask) error {
+	return r.db.Omit("Task", "Template").Save(schedule).Error
+}
+
+func (r *scheduledTaskRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&database.ScheduledTask{}).Error
+}
+
+func (r *scheduledTaskRepository) ListDue(now time.Time) ([]database.ScheduledTask, error) {
+	var schedules []database.ScheduledTask
+	err := r.db.Preload("Template").
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduledTaskRepository) UpdateRunState(id uint, updates map[string]interface{}) error {
+	return r.db.Model(&database.ScheduledTask{}).Where("id = ?", id).Updates(updates).Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+type secretRepository struct {
+	db *gorm.DB
+}
+
+func NewSecretRepository(db *gorm.DB) SecretRepository {
+	return &secretRepository{db: db}
+}
+
+func (r *secretRepository) Create(secret *database.Secret) error {
+	return r.db.Create(secret).Error
+}
+
+func (r *secretRepository) GetByID(id uint, createdBy string) (*database.Secret, error) {
+	var secret database.Secret
+	err := scopeOwner(r.db, createdBy).Where("id = ?", id).First(&secret).Error
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (r *secretRepository) GetByName(name, createdBy string) (*database.Secret, error) {
+	var secret database.Secret
+	err := scopeOwner(r.db, createdBy).Where("name = ?", name).Order("id ASC").First(&secret).Error
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (r *secretRepository) List(createdBy string, name *string, page, pageSize int) ([]database.Secret, int64, error) {
+	var secrets []database.Secret
+	var total int64
+
+	query := scopeOwner(r.db.Model(&database.Secret{}), createdBy)
+
+	if name != nil && *name != "" {
+		query = query.Where("name LIKE ?", "%"+*name+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("name ASC").Offset(offset).Limit(pageSize).Find(&secrets).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return secrets, total, nil
+}
+
+func (r *secretRepository) Update(secret *database.Secret) error {
+	return r.db.Save(secret).Error
+}
+
+func (r *secretRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&database.Secret{}).Error
+}
+
+// scopeOwner limits the query to rows created by createdBy, an empty createdBy is unscoped
+func scopeOwner(db *gorm.DB, createdBy string) *gorm.DB {
+	if createdBy == "" {
+		return db
+	}
+	return db.Where("created_by = ?", createdBy)
+}
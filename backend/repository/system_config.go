@@ -2,7 +2,9 @@ package repository
 
 import (
 	"encoding/json"
+	"strings"
 	"xsha-backend/database"
+	"xsha-backend/utils"
 
 	"gorm.io/gorm"
 )
@@ -97,7 +99,48 @@ func (r *systemConfigRepository) SetValueWithCategoryAndSort(key, value, descrip
 	return r.Update(config)
 }
 
-func (r *systemConfigRepository) InitializeDefaultConfigs() error {
+// ConfigValueType describes how a configuration value must be parsed
+type ConfigValueType string
+
+const (
+	ConfigValueTypeString   ConfigValueType = "string"
+	ConfigValueTypeDuration ConfigValueType = "duration"
+	ConfigValueTypeInt      ConfigValueType = "int"
+	ConfigValueTypeBool     ConfigValueType = "bool"
+	ConfigValueTypeJSON     ConfigValueType = "json"
+	ConfigValueTypeEnum     ConfigValueType = "enum"
+)
+
+// DefaultSystemConfig describes a known configuration key and its default value
+type DefaultSystemConfig struct {
+	Key         string
+	Value       string
+	Description string
+	Category    string
+	FormType    string
+	SortOrder   int
+	ValueType   ConfigValueType
+	MinValue    int      // Lower bound for int values
+	Options     []string // Allowed values for enum values
+}
+
+// defaultStderrErrorPatterns match stderr lines that describe a real failure rather than a warning
+var defaultStderrErrorPatterns = []string{
+	`\berror\b`,
+	`\bfatal\b`,
+	`\bpanic\b`,
+	`exception`,
+	`permission denied`,
+	`no such file or directory`,
+	`not found`,
+	`unable to`,
+	`\bfailed\b`,
+	`\bkilled\b`,
+	`timed? ?out`,
+}
+
+// DefaultSystemConfigs returns every known configuration key with its default value
+func DefaultSystemConfigs() []DefaultSystemConfig {
 	defaultDevEnvImages := []map[string]interface{}{
 		{
 			"image": "ghcr.io/xshalabs/dev-image-registry/claude-code:node18-1.0.67",
@@ -121,103 +164,420 @@ func (r *systemConfigRepository) InitializeDefaultConfigs() error {
 		},
 	}
 
-	devEnvImagesJSON, err := json.Marshal(defaultDevEnvImages)
-	if err != nil {
-		return err
+	devEnvImagesJSON, _ := json.Marshal(defaultDevEnvImages)
+
+	// USD per million tokens, "default" applies to conversations without an explicit model
+	defaultModelPricing := map[string]map[string]float64{
+		"default": {"input_per_mtok": 3, "output_per_mtok": 15},
+		"sonnet":  {"input_per_mtok": 3, "output_per_mtok": 15},
+		"opus":    {"input_per_mtok": 15, "output_per_mtok": 75},
+		"haiku":   {"input_per_mtok": 0.8, "output_per_mtok": 4},
 	}
 
-	defaultConfigs := []struct {
-		key         string
-		value       string
-		description string
-		category    string
-		formType    string
-		sortOrder   int
-	}{
-		{
-			key:         "admin_user",
-			value:       "xshauser",
-			description: "Administrator username for system login",
-			category:    "auth",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   10,
-		},
-		{
-			key:         "admin_password",
-			value:       "xshapass",
-			description: "Administrator password for system login",
-			category:    "auth",
-			formType:    string(database.ConfigFormTypePassword),
-			sortOrder:   20,
-		},
-		{
-			key:         "dev_environment_images",
-			value:       string(devEnvImagesJSON),
-			description: "Development environment image configuration, defines available Docker images and their corresponding environment images",
-			category:    "dev_environment",
-			formType:    string(database.ConfigFormTypeTextarea),
-			sortOrder:   30,
-		},
-		{
-			key:         "git_proxy_enabled",
-			value:       "false",
-			description: "Enable or disable HTTP proxy for Git operations",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeSwitch),
-			sortOrder:   40,
-		},
-		{
-			key:         "git_proxy_http",
-			value:       "",
-			description: "HTTP proxy URL for Git operations (e.g., http://proxy.example.com:8080)",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   50,
-		},
-		{
-			key:         "git_proxy_https",
-			value:       "",
-			description: "HTTPS proxy URL for Git operations (e.g., https://proxy.example.com:8080)",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   60,
-		},
-		{
-			key:         "git_proxy_no_proxy",
-			value:       "",
-			description: "Comma-separated list of domains to bypass proxy (e.g., localhost,127.0.0.1,.local)",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   70,
-		},
-		{
-			key:         "git_clone_timeout",
-			value:       "5m",
-			description: "Timeout for Git clone operations (e.g., 5m, 300s)",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   80,
-		},
-		{
-			key:         "git_ssl_verify",
-			value:       "false",
-			description: "Enable or disable SSL verification for Git operations",
-			category:    "git",
-			formType:    string(database.ConfigFormTypeSwitch),
-			sortOrder:   90,
-		},
-		{
-			key:         "docker_timeout",
-			value:       "120m",
-			description: "Timeout for Docker execution operations (e.g., 120m, 7200s)",
-			category:    "docker",
-			formType:    string(database.ConfigFormTypeInput),
-			sortOrder:   100,
+	modelPricingJSON, _ := json.Marshal(defaultModelPricing)
+
+	return []DefaultSystemConfig{
+		{
+			Key:         "admin_user",
+			Value:       "xshauser",
+			Description: "Administrator username for system login",
+			Category:    "auth",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   10,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "admin_password",
+			Value:       "xshapass",
+			Description: "Administrator password for system login",
+			Category:    "auth",
+			FormType:    string(database.ConfigFormTypePassword),
+			SortOrder:   20,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "dev_environment_images",
+			Value:       string(devEnvImagesJSON),
+			Description: "Development environment image configuration, defines available Docker images and their corresponding environment images",
+			Category:    "dev_environment",
+			FormType:    string(database.ConfigFormTypeTextarea),
+			SortOrder:   30,
+			ValueType:   ConfigValueTypeJSON,
+		},
+		{
+			Key:         "git_proxy_enabled",
+			Value:       "false",
+			Description: "Enable or disable HTTP proxy for Git operations",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   40,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "git_proxy_http",
+			Value:       "",
+			Description: "HTTP proxy URL for Git operations (e.g., http://proxy.example.com:8080)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   50,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "git_proxy_https",
+			Value:       "",
+			Description: "HTTPS proxy URL for Git operations (e.g., https://proxy.example.com:8080)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   60,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "git_proxy_no_proxy",
+			Value:       "",
+			Description: "Comma-separated list of domains to bypass proxy (e.g., localhost,127.0.0.1,.local)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   70,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "git_clone_timeout",
+			Value:       "5m",
+			Description: "Timeout for Git clone operations (e.g., 5m, 300s)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   80,
+			ValueType:   ConfigValueTypeDuration,
+		},
+		{
+			Key:         "git_clone_max_attempts",
+			Value:       "3",
+			Description: "Maximum attempts for Git clone when it fails with a transient network error",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   82,
+			ValueType:   ConfigValueTypeInt,
+			MinValue:    1,
+		},
+		{
+			Key:         "git_clone_retry_base_delay",
+			Value:       "2s",
+			Description: "Initial delay between Git clone retries, doubled after each attempt (e.g., 2s)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   84,
+			ValueType:   ConfigValueTypeDuration,
+		},
+		{
+			Key:         "git_ssl_verify",
+			Value:       "false",
+			Description: "Enable or disable SSL verification for Git operations",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   90,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "git_fail_on_pull_error",
+			Value:       "false",
+			Description: "Fail the conversation when pulling the latest base branch fails, instead of continuing on the local copy",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   92,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "git_unshallow_before_push",
+			Value:       "false",
+			Description: "Fetch the full history of shallow workspaces before every push, instead of only when the remote rejects a push for missing history",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   93,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "git_max_concurrent_operations",
+			Value:       "4",
+			Description: "Maximum number of concurrent Git network operations for batch jobs",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   95,
+			ValueType:   ConfigValueTypeInt,
+			MinValue:    1,
+		},
+		{
+			Key:         "git_commit_author_name",
+			Value:       "XSHA AI",
+			Description: "Default author name of commits made for conversations",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   86,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "git_commit_author_email",
+			Value:       "ai@xsha.dev",
+			Description: "Default author email of commits made for conversations",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   87,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "git_commit_message_template",
+			Value:       utils.DefaultCommitMessageTemplate,
+			Description: "Default commit message template, supports {{.TaskTitle}}, {{.TaskID}}, {{.ConversationID}}, {{.ConversationContent}}, {{.ProjectName}} and {{.WorkBranch}}",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeTextarea),
+			SortOrder:   88,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "workspace_dirty_policy",
+			Value:       "reset",
+			Description: "What to do with uncommitted changes left in a reused task workspace before a run (reset or keep)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSelect),
+			SortOrder:   89,
+			ValueType:   ConfigValueTypeEnum,
+			Options:     []string{"reset", "keep"},
+		},
+		{
+			Key:         "no_changes_policy",
+			Value:       "succeed",
+			Description: "How a conversation whose AI run changed no files finishes (succeed without a commit, or fail)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSelect),
+			SortOrder:   91,
+			ValueType:   ConfigValueTypeEnum,
+			Options:     []string{"succeed", "fail"},
+		},
+		{
+			Key:         "custom_ca_certificate",
+			Value:       "",
+			Description: "PEM encoded CA certificate trusted by Git and the AI tool, e.g. for a TLS-intercepting proxy",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeTextarea),
+			SortOrder:   97,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "diff_max_bytes",
+			Value:       "1048576",
+			Description: "Maximum size in bytes of a single file diff returned to the UI, larger diffs are truncated (0 disables the limit)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   98,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "diff_total_max_bytes",
+			Value:       "5242880",
+			Description: "Maximum size in bytes of all file diffs returned together when a whole diff includes content, files past it are returned without content (0 disables the limit)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   98,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "git_clone_size_limit_mb",
+			Value:       "0",
+			Description: "Repository size in MB above which cloning is flagged before it starts, measured through the provider API when a token is available (0 disables the check)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   99,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "git_clone_size_policy",
+			Value:       "warn",
+			Description: "What to do when a repository exceeds the clone size limit (warn, or refuse unless the project uses shallow clones)",
+			Category:    "git",
+			FormType:    string(database.ConfigFormTypeSelect),
+			SortOrder:   99,
+			ValueType:   ConfigValueTypeEnum,
+			Options:     []string{"warn", "refuse"},
+		},
+		{
+			Key:         "docker_timeout",
+			Value:       "120m",
+			Description: "Timeout for Docker execution operations (e.g., 120m, 7200s)",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   100,
+			ValueType:   ConfigValueTypeDuration,
+		},
+		{
+			Key:         "docker_stop_timeout",
+			Value:       "10s",
+			Description: "Grace period containers get to exit after a stop before they are killed and removed (e.g., 10s, 2m)",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   101,
+			ValueType:   ConfigValueTypeDuration,
+		},
+		{
+			Key:         "container_runtime",
+			Value:       "docker",
+			Description: "Container runtime binary used to run tasks (docker or podman)",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   105,
+			ValueType:   ConfigValueTypeEnum,
+			Options:     []string{"docker", "podman"},
+		},
+		{
+			Key:         "docker_registry_url",
+			Value:       "",
+			Description: "Private registry to log in to before running dev environment images (e.g., ghcr.io)",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   106,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "docker_registry_username",
+			Value:       "",
+			Description: "Username for the private registry",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   107,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "docker_registry_password",
+			Value:       "",
+			Description: "Password or access token for the private registry",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypePassword),
+			SortOrder:   108,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "redacted_env_var_keys",
+			Value:       "",
+			Description: "Comma-separated environment variable keys whose names are hidden as *** in logged commands",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   109,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "stderr_error_patterns",
+			Value:       strings.Join(defaultStderrErrorPatterns, "\n"),
+			Description: "Case-insensitive regular expressions, one per line. When a task command fails, only stderr lines matching one of them are used as the error message; empty uses all stderr",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeTextarea),
+			SortOrder:   112,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "docker_allow_privileged_args",
+			Value:       "false",
+			Description: "Allow dev environments to use extra docker run flags that weaken isolation, such as --privileged, --volume, --cap-add or --network",
+			Category:    "docker",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   113,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "execution_log_retention_days",
+			Value:       "0",
+			Description: "Number of days to keep task execution logs, 0 keeps them forever (projects may override)",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   110,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "workspace_retention_days",
+			Value:       "0",
+			Description: "Number of days after the last activity of a finished task before its workspace is removed, 0 keeps workspaces forever",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   115,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "model_pricing",
+			Value:       string(modelPricingJSON),
+			Description: "Per-model token rates in USD per million tokens, used to estimate conversation cost before running",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeTextarea),
+			SortOrder:   120,
+			ValueType:   ConfigValueTypeJSON,
+		},
+		{
+			Key:         "notification_webhook_url",
+			Value:       "",
+			Description: "Webhook URL (e.g., Slack or Teams) notified when a task conversation finishes, projects may override",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeInput),
+			SortOrder:   125,
+			ValueType:   ConfigValueTypeString,
+		},
+		{
+			Key:         "encrypt_execution_logs",
+			Value:       "false",
+			Description: "Encrypt newly written execution log content at rest, requires XSHA_ENCRYPTION_KEY (existing logs stay readable)",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   130,
+			ValueType:   ConfigValueTypeBool,
+		},
+		{
+			Key:         "duplicate_conversation_policy",
+			Value:       "off",
+			Description: "What to do when a conversation is created with the same content as a pending or running one on the task (off, reject or link to the existing one)",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeSelect),
+			SortOrder:   135,
+			ValueType:   ConfigValueTypeEnum,
+			Options:     []string{"off", "reject", "link"},
+		},
+		{
+			Key:         "conversation_rate_limit",
+			Value:       "0",
+			Description: "Maximum number of conversations a user may create per rate limit window, 0 disables the limit",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   140,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "conversation_rate_limit_window_seconds",
+			Value:       "60",
+			Description: "Length in seconds of the conversation creation rate limit window",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeNumber),
+			SortOrder:   141,
+			ValueType:   ConfigValueTypeInt,
+		},
+		{
+			Key:         "conversation_rate_limit_exempt_admin",
+			Value:       "true",
+			Description: "Exempt the administrator account from the conversation creation rate limit",
+			Category:    "general",
+			FormType:    string(database.ConfigFormTypeSwitch),
+			SortOrder:   142,
+			ValueType:   ConfigValueTypeBool,
 		},
 	}
+}
+
+// GetDefaultSystemConfig returns the definition of a known configuration key
+func GetDefaultSystemConfig(key string) (DefaultSystemConfig, bool) {
+	for _, config := range DefaultSystemConfigs() {
+		if config.Key == key {
+			return config, true
+		}
+	}
+	return DefaultSystemConfig{}, false
+}
 
-	for _, config := range defaultConfigs {
-		existingConfig, err := r.GetByKey(config.key)
+func (r *systemConfigRepository) InitializeDefaultConfigs() error {
+	for _, config := range DefaultSystemConfigs() {
+		existingConfig, err := r.GetByKey(config.Key)
 		if err != nil && err != gorm.ErrRecordNotFound {
 			return err
 		}
@@ -227,13 +587,13 @@ func (r *systemConfigRepository) InitializeDefaultConfigs() error {
 		}
 
 		if err := r.SetValueWithCategoryAndSort(
-			config.key,
-			config.value,
-			config.description,
-			config.category,
-			config.formType,
+			config.Key,
+			config.Value,
+			config.Description,
+			config.Category,
+			config.FormType,
 			true,
-			config.sortOrder,
+			config.SortOrder,
 		); err != nil {
 			return err
 		}
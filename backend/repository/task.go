@@ -5,6 +5,7 @@ import (
 	"xsha-backend/database"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type taskRepository struct {
@@ -19,6 +20,41 @@ func (r *taskRepository) Create(task *database.Task) error {
 	return r.db.Create(task).Error
 }
 
+// ImportedConversation is a conversation with the result and execution log it is imported with,
+// Result and ExecutionLog may be nil
+type ImportedConversation struct {
+	Conversation *database.TaskConversation
+	Result       *database.TaskConversationResult
+	ExecutionLog *database.TaskExecutionLog
+}
+
+func (r *taskRepository) CreateImported(task *database.Task, conversations []ImportedConversation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(task).Error; err != nil {
+			return err
+		}
+		for _, item := range conversations {
+			item.Conversation.TaskID = task.ID
+			if err := tx.Omit(clause.Associations).Create(item.Conversation).Error; err != nil {
+				return err
+			}
+			if item.Result != nil {
+				item.Result.ConversationID = item.Conversation.ID
+				if err := tx.Omit(clause.Associations).Create(item.Result).Error; err != nil {
+					return err
+				}
+			}
+			if item.ExecutionLog != nil {
+				item.ExecutionLog.ConversationID = item.Conversation.ID
+				if err := tx.Omit(clause.Associations).Create(item.ExecutionLog).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
 func (r *taskRepository) GetByID(id uint) (*database.Task, error) {
 	var task database.Task
 	err := r.db.Preload("Project").Preload("DevEnvironment").Preload("Conversations").
@@ -120,6 +156,35 @@ func (r *taskRepository) ListByProject(projectID uint) ([]database.Task, error)
 	return tasks, err
 }
 
+func (r *taskRepository) ListWithWorkspace() ([]database.Task, error) {
+	var tasks []database.Task
+	err := r.db.Where("workspace_path <> ?", "").Order("id ASC").Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *taskRepository) ClearWorkspacePath(id uint) error {
+	return r.db.Model(&database.Task{}).Where("id = ?", id).Update("workspace_path", "").Error
+}
+
+func (r *taskRepository) ListForBranchCleanup() ([]database.Task, error) {
+	var tasks []database.Task
+	err := r.db.Preload("Project").
+		Joins("JOIN projects ON projects.id = tasks.project_id AND projects.deleted_at IS NULL").
+		Where("projects.delete_branch_after_merge = ? OR projects.remove_workspace_after_merge = ?", true, true).
+		Where("tasks.work_branch <> ? AND tasks.branch_merged_at IS NULL AND tasks.status <> ?", "", database.TaskStatusCancelled).
+		Order("tasks.id ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *taskRepository) MarkBranchMerged(id uint, mergedAt time.Time, clearWorkspace bool) error {
+	updates := map[string]interface{}{"branch_merged_at": mergedAt}
+	if clearWorkspace {
+		updates["workspace_path"] = ""
+	}
+	return r.db.Model(&database.Task{}).Where("id = ?", id).Updates(updates).Error
+}
+
 func (r *taskRepository) GetConversationCounts(taskIDs []uint) (map[uint]int64, error) {
 	if len(taskIDs) == 0 {
 		return make(map[uint]int64), nil
@@ -8,11 +8,21 @@ import (
 )
 
 type taskConversationRepository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	logCipher *utils.LogCipher
+	logStore  *utils.ObjectStorage
+	search    conversationSearchBackend
 }
 
-func NewTaskConversationRepository(db *gorm.DB) TaskConversationRepository {
-	return &taskConversationRepository{db: db}
+// NewTaskConversationRepository creates the repository, logCipher decrypts execution logs loaded
+// with a conversation and logStore fetches the ones offloaded to object storage
+func NewTaskConversationRepository(db *gorm.DB, logCipher *utils.LogCipher, logStore *utils.ObjectStorage) TaskConversationRepository {
+	return &taskConversationRepository{db: db, logCipher: logCipher, logStore: logStore, search: &likeConversationSearch{db: db}}
+}
+
+// Search finds conversations whose content, or optionally execution log, contains the query
+func (r *taskConversationRepository) Search(params ConversationSearchParams) ([]ConversationSearchHit, int64, error) {
+	return r.search.Search(params)
 }
 
 func (r *taskConversationRepository) Create(conversation *database.TaskConversation) error {
@@ -56,6 +66,7 @@ func (r *taskConversationRepository) GetWithResult(id uint) (*database.TaskConve
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, nil, nil, err
 	}
+	executionLog.ExecutionLogs = r.logCipher.Decrypt(loadOffloadedLogs(r.logStore, executionLog.ExecutionLogsRef, executionLog.ExecutionLogs))
 
 	// Return conversation with result and execution log (both can be nil if not found)
 	var resultPtr *database.TaskConversationResult
@@ -123,6 +134,16 @@ func (r *taskConversationRepository) ListByStatus(status database.ConversationSt
 }
 
 func (r *taskConversationRepository) GetPendingConversationsWithDetails() ([]database.TaskConversation, error) {
+	return r.getReadyPendingConversations("created_at ASC")
+}
+
+// GetPendingConversationsOrderedByPriority returns ready pending conversations with the
+// highest priority first; equal priorities keep FIFO order
+func (r *taskConversationRepository) GetPendingConversationsOrderedByPriority() ([]database.TaskConversation, error) {
+	return r.getReadyPendingConversations("priority DESC, created_at ASC, id ASC")
+}
+
+func (r *taskConversationRepository) getReadyPendingConversations(order string) ([]database.TaskConversation, error) {
 	var conversations []database.TaskConversation
 	now := utils.Now()
 
@@ -139,7 +160,7 @@ func (r *taskConversationRepository) GetPendingConversationsWithDetails() ([]dat
 		Preload("Task.DevEnvironment").
 		Where("status = ? AND (execution_time IS NULL OR execution_time <= ?)",
 			database.ConversationStatusPending, now).
-		Order("created_at ASC").
+		Order(order).
 		Find(&conversations).Error
 
 	if err == nil {
@@ -162,6 +183,71 @@ func (r *taskConversationRepository) GetPendingConversationsWithDetails() ([]dat
 	return conversations, err
 }
 
+// ListActiveByProject returns pending and running conversations across all tasks of a project
+func (r *taskConversationRepository) ListActiveByProject(projectID uint) ([]database.TaskConversation, error) {
+	var conversations []database.TaskConversation
+	err := r.db.Joins("JOIN tasks ON tasks.id = task_conversations.task_id AND tasks.deleted_at IS NULL").
+		Where("tasks.project_id = ? AND task_conversations.status IN (?)",
+			projectID, []database.ConversationStatus{
+				database.ConversationStatusPending,
+				database.ConversationStatusRunning,
+			}).
+		Order("task_conversations.id ASC").
+		Find(&conversations).Error
+	return conversations, err
+}
+
+func (r *taskConversationRepository) ListActiveByTask(taskID uint) ([]database.TaskConversation, error) {
+	var conversations []database.TaskConversation
+	err := r.db.Where("task_id = ? AND status IN (?)",
+		taskID, []database.ConversationStatus{
+			database.ConversationStatusPending,
+			database.ConversationStatusRunning,
+		}).
+		Order("id ASC").
+		Find(&conversations).Error
+	return conversations, err
+}
+
+// ListSucceededWithoutResult lists succeeded conversations that have no result record,
+// optionally limited to one project
+func (r *taskConversationRepository) ListSucceededWithoutResult(projectID *uint, page, pageSize int) ([]database.TaskConversation, int64, error) {
+	var conversations []database.TaskConversation
+	var total int64
+
+	query := r.db.Model(&database.TaskConversation{}).
+		Joins("JOIN tasks ON tasks.id = task_conversations.task_id AND tasks.deleted_at IS NULL").
+		Joins("LEFT JOIN task_conversation_results ON task_conversation_results.conversation_id = task_conversations.id AND task_conversation_results.deleted_at IS NULL").
+		Where("task_conversations.status = ? AND task_conversation_results.id IS NULL", database.ConversationStatusSuccess)
+	if projectID != nil {
+		query = query.Where("tasks.project_id = ?", *projectID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Task").
+		Order("task_conversations.id DESC").
+		Offset(offset).Limit(pageSize).
+		Find(&conversations).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return conversations, total, nil
+}
+
+func (r *taskConversationRepository) CountByStatus(status database.ConversationStatus) (int64, error) {
+	var count int64
+	err := r.db.Model(&database.TaskConversation{}).
+		Where("status = ?", status).
+		Count(&count).Error
+	return count, err
+}
+
+// HasPendingOrRunningConversations also counts conversations awaiting approval, their changes
+// are still uncommitted in the workspace
 func (r *taskConversationRepository) HasPendingOrRunningConversations(taskID uint) (bool, error) {
 	var count int64
 	err := r.db.Model(&database.TaskConversation{}).
@@ -169,6 +255,7 @@ func (r *taskConversationRepository) HasPendingOrRunningConversations(taskID uin
 			taskID, []database.ConversationStatus{
 				database.ConversationStatusPending,
 				database.ConversationStatusRunning,
+				database.ConversationStatusAwaitingApproval,
 			}).
 		Count(&count).Error
 	if err != nil {
@@ -177,8 +264,111 @@ func (r *taskConversationRepository) HasPendingOrRunningConversations(taskID uin
 	return count > 0, nil
 }
 
+// CountRunsByTask counts the task's conversations other than excludeID whose status is not
+// one of excludedStatuses
+func (r *taskConversationRepository) CountRunsByTask(taskID, excludeID uint, excludedStatuses ...database.ConversationStatus) (int64, error) {
+	var count int64
+	query := r.db.Model(&database.TaskConversation{}).Where("task_id = ? AND id <> ?", taskID, excludeID)
+	if len(excludedStatuses) > 0 {
+		query = query.Where("status NOT IN (?)", excludedStatuses)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// FindPendingOrRunningByContent returns the oldest pending or running conversation on the task
+// with exactly the given content, or nil when there is none
+func (r *taskConversationRepository) FindPendingOrRunningByContent(taskID uint, content string) (*database.TaskConversation, error) {
+	return findPendingOrRunningByContent(r.db, taskID, content)
+}
+
+// CreateUnlessDuplicate checks for the duplicate and inserts in one transaction. The task row is
+// written first, which locks it, so concurrent creates on the same task are serialized and
+// cannot both miss each other.
+func (r *taskConversationRepository) CreateUnlessDuplicate(conversation *database.TaskConversation) (*database.TaskConversation, error) {
+	var existing *database.TaskConversation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.Task{}).Where("id = ?", conversation.TaskID).
+			UpdateColumn("id", gorm.Expr("id")).Error; err != nil {
+			return err
+		}
+
+		var err error
+		existing, err = findPendingOrRunningByContent(tx, conversation.TaskID, conversation.Content)
+		if err != nil || existing != nil {
+			return err
+		}
+		return tx.Create(conversation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func findPendingOrRunningByContent(db *gorm.DB, taskID uint, content string) (*database.TaskConversation, error) {
+	var conversations []database.TaskConversation
+	err := db.Where("task_id = ? AND content = ? AND status IN (?)",
+		taskID, content, []database.ConversationStatus{
+			database.ConversationStatusPending,
+			database.ConversationStatusRunning,
+		}).
+		Order("id ASC").
+		Limit(1).
+		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, nil
+	}
+	return &conversations[0], nil
+}
+
+func (r *taskConversationRepository) UpdateSessionID(id uint, sessionID string) error {
+	return r.db.Model(&database.TaskConversation{}).
+		Where("id = ?", id).
+		Update("session_id", sessionID).Error
+}
+
+// GetPreviousSessionID returns the session ID of the latest earlier conversation of the task, or "" if none exists
+func (r *taskConversationRepository) GetPreviousSessionID(taskID, beforeConversationID uint) (string, error) {
+	var conversation database.TaskConversation
+	err := r.db.Select("session_id").
+		Where("task_id = ? AND id < ? AND session_id <> ''", taskID, beforeConversationID).
+		Order("id DESC").
+		First(&conversation).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return conversation.SessionID, nil
+}
+
+func (r *taskConversationRepository) UpdateWorkBranch(id uint, workBranch string) error {
+	return r.db.Model(&database.TaskConversation{}).
+		Where("id = ?", id).
+		Update("work_branch", workBranch).Error
+}
+
 func (r *taskConversationRepository) UpdateCommitHash(id uint, commitHash string) error {
 	return r.db.Model(&database.TaskConversation{}).
 		Where("id = ?", id).
 		Update("commit_hash", commitHash).Error
 }
+
+func (r *taskConversationRepository) UpdateChangesCommitted(id uint, committed bool) error {
+	return r.db.Model(&database.TaskConversation{}).Where("id = ?", id).Update("changes_committed", committed).Error
+}
+
+func (r *taskConversationRepository) TransitionStatus(id uint, from, to database.ConversationStatus) (bool, error) {
+	result := r.db.Model(&database.TaskConversation{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
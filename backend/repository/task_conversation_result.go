@@ -103,6 +103,48 @@ func (r *taskConversationResultRepository) ListByProjectID(projectID uint, page,
 	return results, total, nil
 }
 
+// ListBySessionID lists a page of the results of an AI tool session in the order they were produced
+func (r *taskConversationResultRepository) ListBySessionID(sessionID string, page, pageSize int) ([]database.TaskConversationResult, int64, error) {
+	var results []database.TaskConversationResult
+	var total int64
+
+	query := r.db.Model(&database.TaskConversationResult{}).
+		Where("session_id = ?", sessionID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Conversation").
+		Preload("Conversation.Task").
+		Order("created_at ASC").Offset(offset).Limit(pageSize).
+		Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// GetSessionTotals sums the results of an AI tool session
+func (r *taskConversationResultRepository) GetSessionTotals(sessionID string) (*SessionResultTotals, error) {
+	var totals SessionResultTotals
+	err := r.db.Model(&database.TaskConversationResult{}).
+		Where("session_id = ?", sessionID).
+		Select("COUNT(*) AS result_count, "+
+			"COALESCE(SUM(CASE WHEN is_error = ? THEN 1 ELSE 0 END), 0) AS error_count, "+
+			"COALESCE(SUM(total_cost_usd), 0) AS total_cost_usd, "+
+			"COALESCE(SUM(duration_ms), 0) AS total_duration_ms, "+
+			"COALESCE(SUM(num_turns), 0) AS total_num_turns, "+
+			"COALESCE(SUM(input_tokens), 0) AS total_input_tokens, "+
+			"COALESCE(SUM(output_tokens), 0) AS total_output_tokens", true).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
 func (r *taskConversationResultRepository) GetSuccessRate(taskID uint) (float64, error) {
 	var totalCount, successCount int64
 
@@ -144,6 +186,22 @@ func (r *taskConversationResultRepository) GetTotalCost(taskID uint) (float64, e
 	return totalCost, err
 }
 
+func (r *taskConversationResultRepository) GetTotalCostByProject(projectID uint) (float64, error) {
+	var totalCost float64
+
+	subQuery := r.db.Model(&database.TaskConversation{}).
+		Select("task_conversations.id").
+		Joins("JOIN tasks ON tasks.id = task_conversations.task_id").
+		Where("tasks.project_id = ?", projectID)
+
+	err := r.db.Model(&database.TaskConversationResult{}).
+		Where("conversation_id IN (?)", subQuery).
+		Select("COALESCE(SUM(total_cost_usd), 0)").
+		Scan(&totalCost).Error
+
+	return totalCost, err
+}
+
 func (r *taskConversationResultRepository) GetAverageDuration(taskID uint) (float64, error) {
 	var avgDuration float64
 
@@ -159,6 +217,25 @@ func (r *taskConversationResultRepository) GetAverageDuration(taskID uint) (floa
 	return avgDuration, err
 }
 
+// GetTotalTokens returns the summed input and output tokens of the task's results
+func (r *taskConversationResultRepository) GetTotalTokens(taskID uint) (int64, int64, error) {
+	var totals struct {
+		InputTokens  int64
+		OutputTokens int64
+	}
+
+	subQuery := r.db.Model(&database.TaskConversation{}).
+		Select("id").
+		Where("task_id = ?", taskID)
+
+	err := r.db.Model(&database.TaskConversationResult{}).
+		Where("conversation_id IN (?)", subQuery).
+		Select("COALESCE(SUM(input_tokens), 0) AS input_tokens, COALESCE(SUM(output_tokens), 0) AS output_tokens").
+		Scan(&totals).Error
+
+	return totals.InputTokens, totals.OutputTokens, err
+}
+
 func (r *taskConversationResultRepository) ExistsByConversationID(conversationID uint) (bool, error) {
 	var count int64
 	err := r.db.Model(&database.TaskConversationResult{}).
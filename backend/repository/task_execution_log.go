@@ -1,21 +1,43 @@
 package repository
 
 import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
 	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/utils"
 
 	"gorm.io/gorm"
 )
 
 type taskExecutionLogRepository struct {
-	db *gorm.DB
+	db         *gorm.DB
+	logCipher  *utils.LogCipher
+	configRepo SystemConfigRepository
+	logStore   *utils.ObjectStorage
+
+	// encryptByLog holds whether each running execution's log is encrypted, resolved once so
+	// a config change never mixes plaintext and encrypted segments within one log
+	encryptByLog sync.Map
 }
 
-func NewTaskExecutionLogRepository(db *gorm.DB) TaskExecutionLogRepository {
-	return &taskExecutionLogRepository{db: db}
+// NewTaskExecutionLogRepository creates the repository. Appended log content is encrypted
+// when logCipher is set and the encrypt_execution_logs system config was enabled when the
+// execution started. Completed logs can be moved to logStore, reads fetch them back
+// transparently.
+func NewTaskExecutionLogRepository(db *gorm.DB, logCipher *utils.LogCipher, configRepo SystemConfigRepository, logStore *utils.ObjectStorage) TaskExecutionLogRepository {
+	return &taskExecutionLogRepository{db: db, logCipher: logCipher, configRepo: configRepo, logStore: logStore}
 }
 
 func (r *taskExecutionLogRepository) Create(log *database.TaskExecutionLog) error {
-	return r.db.Create(log).Error
+	if err := r.db.Create(log).Error; err != nil {
+		return err
+	}
+	r.encryptByLog.Store(log.ID, r.shouldEncrypt())
+	return nil
 }
 
 func (r *taskExecutionLogRepository) GetByID(id uint) (*database.TaskExecutionLog, error) {
@@ -24,6 +46,7 @@ func (r *taskExecutionLogRepository) GetByID(id uint) (*database.TaskExecutionLo
 	if err != nil {
 		return nil, err
 	}
+	log.ExecutionLogs = r.logCipher.Decrypt(loadOffloadedLogs(r.logStore, log.ExecutionLogsRef, log.ExecutionLogs))
 	return &log, nil
 }
 
@@ -33,25 +56,99 @@ func (r *taskExecutionLogRepository) GetByConversationID(conversationID uint) (*
 	if err != nil {
 		return nil, err
 	}
+	log.ExecutionLogs = r.logCipher.Decrypt(loadOffloadedLogs(r.logStore, log.ExecutionLogsRef, log.ExecutionLogs))
 	return &log, nil
 }
 
+// GetLogTail returns the log content after the given byte offset and the total log length
+func (r *taskExecutionLogRepository) GetLogTail(conversationID uint, offset int) (string, int, error) {
+	var logs, ref string
+	err := r.db.Model(&database.TaskExecutionLog{}).
+		Where("conversation_id = ?", conversationID).
+		Select("COALESCE(execution_logs, ''), COALESCE(execution_logs_ref, '')").
+		Limit(1).
+		Row().
+		Scan(&logs, &ref)
+	if err == sql.ErrNoRows {
+		return "", 0, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	logs = r.logCipher.Decrypt(loadOffloadedLogs(r.logStore, ref, logs))
+
+	if offset < 0 || offset > len(logs) {
+		return "", len(logs), appErrors.ErrExecutionLogOffsetInvalid
+	}
+
+	return logs[offset:], len(logs), nil
+}
+
 func (r *taskExecutionLogRepository) Update(log *database.TaskExecutionLog) error {
 	return r.db.Save(log).Error
 }
 
 func (r *taskExecutionLogRepository) AppendLog(id uint, logContent string) error {
+	if r.encryptLog(id) {
+		segment, err := r.logCipher.EncryptSegment(logContent)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt execution log: %v", err)
+		}
+		logContent = segment
+	}
+
 	return r.db.Model(&database.TaskExecutionLog{}).
 		Where("id = ?", id).
 		Update("execution_logs", gorm.Expr("COALESCE(execution_logs, '') || ?", logContent)).Error
 }
 
+// encryptLog returns whether the log's content is encrypted, resolving it for logs created
+// before a restart
+func (r *taskExecutionLogRepository) encryptLog(id uint) bool {
+	if encrypt, ok := r.encryptByLog.Load(id); ok {
+		return encrypt.(bool)
+	}
+	encrypt, _ := r.encryptByLog.LoadOrStore(id, r.shouldEncrypt())
+	return encrypt.(bool)
+}
+
+// shouldEncrypt reports whether new logs are encrypted. Enabling the flag without
+// XSHA_ENCRYPTION_KEY keeps storing plaintext.
+func (r *taskExecutionLogRepository) shouldEncrypt() bool {
+	if r.configRepo == nil {
+		return false
+	}
+
+	value, err := r.configRepo.GetValue("encrypt_execution_logs")
+	if err != nil {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil || !enabled {
+		return false
+	}
+
+	if r.logCipher == nil {
+		utils.Warn("Execution log encryption is enabled but XSHA_ENCRYPTION_KEY is not set, storing logs in plaintext")
+		return false
+	}
+	return true
+}
+
 func (r *taskExecutionLogRepository) UpdateMetadata(id uint, updates map[string]interface{}) error {
 	allowedFields := map[string]bool{
 		"error_message":  true,
 		"started_at":     true,
 		"completed_at":   true,
 		"docker_command": true,
+		"tool_version":   true,
+
+		"effective_prompt": true,
+
+		"failure_category": true,
+
+		"peak_cpu_percent":  true,
+		"peak_memory_bytes": true,
 	}
 
 	filteredUpdates := make(map[string]interface{})
@@ -71,5 +168,141 @@ func (r *taskExecutionLogRepository) UpdateMetadata(id uint, updates map[string]
 }
 
 func (r *taskExecutionLogRepository) DeleteByConversationID(conversationID uint) error {
-	return r.db.Where("conversation_id = ?", conversationID).Delete(&database.TaskExecutionLog{}).Error
+	refs, err := r.offloadedRefs(r.db.Where("conversation_id = ?", conversationID))
+	if err != nil {
+		return err
+	}
+	if err := r.db.Where("conversation_id = ?", conversationID).Delete(&database.TaskExecutionLog{}).Error; err != nil {
+		return err
+	}
+	r.deleteOffloadedLogs(refs)
+	return nil
+}
+
+// OffloadLogs moves the log content of a completed execution to object storage and keeps only
+// the object key, it does nothing when object storage is not configured. The content is
+// uploaded as stored, so encrypted segments stay encrypted.
+func (r *taskExecutionLogRepository) OffloadLogs(id uint) error {
+	// The execution completed, later appends resolve the encryption again
+	r.encryptByLog.Delete(id)
+
+	if r.logStore == nil {
+		return nil
+	}
+
+	var log database.TaskExecutionLog
+	err := r.db.Select("id", "conversation_id", "execution_logs", "execution_logs_ref").First(&log, id).Error
+	if err != nil {
+		return err
+	}
+	if log.ExecutionLogsRef != "" || log.ExecutionLogs == "" {
+		return nil
+	}
+
+	key := r.logStore.Key(fmt.Sprintf("conversation-%d/log-%d.log", log.ConversationID, log.ID))
+	if err := r.logStore.Put(key, []byte(log.ExecutionLogs)); err != nil {
+		return err
+	}
+
+	// Only swap when nothing was appended while uploading, otherwise keep the logs in the database
+	result := r.db.Model(&database.TaskExecutionLog{}).
+		Where("id = ? AND execution_logs = ?", id, log.ExecutionLogs).
+		Updates(map[string]interface{}{"execution_logs": "", "execution_logs_ref": key})
+	if result.Error != nil || result.RowsAffected == 0 {
+		r.deleteOffloadedLogs([]string{key})
+		if result.Error != nil {
+			return result.Error
+		}
+		return fmt.Errorf("execution log %d changed while offloading", id)
+	}
+	return nil
+}
+
+// offloadedRefs returns the object keys of offloaded logs matched by query
+func (r *taskExecutionLogRepository) offloadedRefs(query *gorm.DB) ([]string, error) {
+	var refs []string
+	if r.logStore == nil {
+		return refs, nil
+	}
+	err := query.Model(&database.TaskExecutionLog{}).
+		Where("execution_logs_ref <> ''").
+		Pluck("execution_logs_ref", &refs).Error
+	return refs, err
+}
+
+// deleteOffloadedLogs removes objects of deleted logs, failures only leave an orphaned object
+func (r *taskExecutionLogRepository) deleteOffloadedLogs(refs []string) {
+	for _, ref := range refs {
+		if err := r.logStore.Delete(ref); err != nil {
+			utils.Warn("Failed to delete offloaded execution log", "ref", ref, "error", err)
+		}
+	}
+}
+
+// loadOffloadedLogs returns the stored log content, fetched from object storage when the log
+// was offloaded. A placeholder line stands in when the object cannot be fetched.
+func loadOffloadedLogs(logStore *utils.ObjectStorage, ref, logs string) string {
+	if ref == "" {
+		return logs
+	}
+	if logStore == nil {
+		utils.Warn("Execution log was offloaded but object storage is not configured", "ref", ref)
+		return fmt.Sprintf("[execution log is stored in object storage, which is not configured: %s]\n", ref)
+	}
+
+	content, err := logStore.Get(ref)
+	if err != nil {
+		utils.Error("Failed to fetch offloaded execution log", "ref", ref, "error", err)
+		return fmt.Sprintf("[execution log could not be fetched from object storage: %s]\n", ref)
+	}
+	return string(content)
+}
+
+// CountFailureCategoriesByProject counts the classified failures of a project's conversations per category
+func (r *taskExecutionLogRepository) CountFailureCategoriesByProject(projectID uint) (map[database.FailureCategory]int64, error) {
+	var rows []struct {
+		FailureCategory database.FailureCategory
+		Count           int64
+	}
+
+	err := r.db.Model(&database.TaskExecutionLog{}).
+		Select("task_execution_logs.failure_category, COUNT(*) AS count").
+		Joins("JOIN task_conversations ON task_conversations.id = task_execution_logs.conversation_id AND task_conversations.deleted_at IS NULL").
+		Joins("JOIN tasks ON tasks.id = task_conversations.task_id AND tasks.deleted_at IS NULL").
+		Where("tasks.project_id = ? AND task_execution_logs.failure_category <> ''", projectID).
+		Group("task_execution_logs.failure_category").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[database.FailureCategory]int64, len(rows))
+	for _, row := range rows {
+		counts[row.FailureCategory] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *taskExecutionLogRepository) DeleteCompletedBeforeByProject(projectID uint, before time.Time) (int64, error) {
+	conversationIDs := r.db.Table("task_conversations").
+		Select("task_conversations.id").
+		Joins("JOIN tasks ON tasks.id = task_conversations.task_id").
+		Where("tasks.project_id = ?", projectID)
+
+	refs, err := r.offloadedRefs(r.db.Unscoped().
+		Where("conversation_id IN (?)", conversationIDs).
+		Where("completed_at IS NOT NULL AND completed_at < ?", before))
+	if err != nil {
+		return 0, err
+	}
+
+	result := r.db.Unscoped().
+		Where("conversation_id IN (?)", conversationIDs).
+		Where("completed_at IS NOT NULL AND completed_at < ?", before).
+		Delete(&database.TaskExecutionLog{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	r.deleteOffloadedLogs(refs)
+	return result.RowsAffected, nil
 }
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+type taskTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskTemplateRepository(db *gorm.DB) TaskTemplateRepository {
+	return &taskTemplateRepository{db: db}
+}
+
+func (r *taskTemplateRepository) Create(template *database.TaskTemplate) error {
+	return r.db.Create(template).Error
+}
+
+func (r *taskTemplateRepository) GetByID(id uint, createdBy string) (*database.TaskTemplate, error) {
+	var template database.TaskTemplate
+	err := r.db.Preload("Project").Preload("DevEnvironment").
+		Where("id = ? AND created_by = ?", id, createdBy).
+		First(&template).Error
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *taskTemplateRepository) List(createdBy string, name *string, projectID *uint, page, pageSize int) ([]database.TaskTemplate, int64, error) {
+	var templates []database.TaskTemplate
+	var total int64
+
+	query := r.db.Model(&database.TaskTemplate{}).Where("created_by = ?", createdBy)
+
+	if name != nil && *name != "" {
+		query = query.Where("name LIKE ?", "%"+*name+"%")
+	}
+
+	if projectID != nil {
+		query = query.Where("project_id = ?", *projectID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Project").Preload("DevEnvironment").
+		Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&templates).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return templates, total, nil
+}
+
+func (r *taskTemplateRepository) Update(template *database.TaskTemplate) error {
+	return r.db.Save(template).Error
+}
+
+func (r *taskTemplateRepository) Delete(id uint, createdBy string) error {
+	return r.db.Where("id = ? AND created_by = ?", id, createdBy).Delete(&database.TaskTemplate{}).Error
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+type userPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewUserPreferenceRepository(db *gorm.DB) UserPreferenceRepository {
+	return &userPreferenceRepository{db: db}
+}
+
+func (r *userPreferenceRepository) GetPreferredLanguage(username string) (string, error) {
+	var preference database.UserPreference
+	err := r.db.Where("username = ?", username).First(&preference).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return preference.PreferredLanguage, nil
+}
+
+func (r *userPreferenceRepository) SetPreferredLanguage(username, language string) error {
+	var preference database.UserPreference
+	if err := r.db.Where(database.UserPreference{Username: username}).FirstOrCreate(&preference).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&preference).Update("preferred_language", language).Error
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"xsha-backend/database"
+
+	"gorm.io/gorm"
+)
+
+type workspaceSnapshotRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceSnapshotRepository(db *gorm.DB) WorkspaceSnapshotRepository {
+	return &workspaceSnapshotRepository{db: db}
+}
+
+func (r *workspaceSnapshotRepository) Create(snapshot *database.WorkspaceSnapshot) error {
+	return r.db.Create(snapshot).Error
+}
+
+func (r *workspaceSnapshotRepository) GetByID(id uint) (*database.WorkspaceSnapshot, error) {
+	var snapshot database.WorkspaceSnapshot
+	err := r.db.First(&snapshot, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (r *workspaceSnapshotRepository) ListByTask(taskID uint) ([]database.WorkspaceSnapshot, error) {
+	var snapshots []database.WorkspaceSnapshot
+	err := r.db.Where("task_id = ?", taskID).Order("created_at DESC").Find(&snapshots).Error
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (r *workspaceSnapshotRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&database.WorkspaceSnapshot{}).Error
+}
+
+func (r *workspaceSnapshotRepository) DeleteByTask(taskID uint) error {
+	return r.db.Where("task_id = ?", taskID).Delete(&database.WorkspaceSnapshot{}).Error
+}
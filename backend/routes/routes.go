@@ -10,13 +10,14 @@ import (
 	"xsha-backend/handlers"
 	"xsha-backend/middleware"
 	"xsha-backend/services"
+	"xsha-backend/utils"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthService, authHandlers *handlers.AuthHandlers, gitCredHandlers *handlers.GitCredentialHandlers, projectHandlers *handlers.ProjectHandlers, operationLogHandlers *handlers.AdminOperationLogHandlers, devEnvHandlers *handlers.DevEnvironmentHandlers, taskHandlers *handlers.TaskHandlers, taskConvHandlers *handlers.TaskConversationHandlers, taskConvResultHandlers *handlers.TaskConversationResultHandlers, taskExecLogHandlers *handlers.TaskExecutionLogHandlers, attachmentHandlers *handlers.TaskConversationAttachmentHandlers, systemConfigHandlers *handlers.SystemConfigHandlers, dashboardHandlers *handlers.DashboardHandlers, staticFiles *embed.FS) {
+func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthService, systemConfigService services.SystemConfigService, authHandlers *handlers.AuthHandlers, gitCredHandlers *handlers.GitCredentialHandlers, projectHandlers *handlers.ProjectHandlers, operationLogHandlers *handlers.AdminOperationLogHandlers, devEnvHandlers *handlers.DevEnvironmentHandlers, taskHandlers *handlers.TaskHandlers, taskTemplateHandlers *handlers.TaskTemplateHandlers, scheduledTaskHandlers *handlers.ScheduledTaskHandlers, secretHandlers *handlers.SecretHandlers, taskConvHandlers *handlers.TaskConversationHandlers, taskConvResultHandlers *handlers.TaskConversationResultHandlers, taskExecLogHandlers *handlers.TaskExecutionLogHandlers, attachmentHandlers *handlers.TaskConversationAttachmentHandlers, systemConfigHandlers *handlers.SystemConfigHandlers, dashboardHandlers *handlers.DashboardHandlers, healthHandlers *handlers.HealthHandlers, schedulerHandlers *handlers.SchedulerHandlers, containerHandlers *handlers.ContainerHandlers, staticFiles *embed.FS) {
 	r.Use(middleware.I18nMiddleware())
 	r.Use(middleware.ErrorHandlerMiddleware())
 
@@ -25,6 +26,8 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	r.GET("/health", handlers.HealthHandler)
+	r.GET("/health/ready", healthHandlers.ReadinessHandler)
+	r.GET("/metrics", middleware.MetricsAuthMiddleware(cfg), gin.WrapH(utils.MetricsHandler()))
 
 	auth := r.Group("/api/v1/auth")
 	{
@@ -38,6 +41,7 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 
 	{
 		api.GET("/user/current", authHandlers.CurrentUserHandler)
+		api.PUT("/user/preferences", authHandlers.UpdatePreferencesHandler)
 		api.POST("/auth/logout", authHandlers.LogoutHandler)
 
 		admin := api.Group("/admin")
@@ -47,6 +51,17 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 			admin.GET("/operation-logs", operationLogHandlers.GetOperationLogs)
 			admin.GET("/operation-logs/:id", operationLogHandlers.GetOperationLog)
 			admin.GET("/operation-stats", operationLogHandlers.GetOperationStats)
+
+			admin.POST("/projects/revalidate", projectHandlers.RevalidateAllProjects)
+			admin.PUT("/projects/:id/budget-override", projectHandlers.SetBudgetOverride)
+
+			admin.GET("/conversations/missing-results", taskConvResultHandlers.ListConversationsMissingResult)
+
+			admin.GET("/scheduler/state", schedulerHandlers.GetState)
+			admin.POST("/scheduler/state", schedulerHandlers.UpdateState)
+
+			admin.GET("/containers", containerHandlers.ListContainers)
+			admin.DELETE("/containers/:name", containerHandlers.RemoveContainer)
 		}
 
 		gitCreds := api.Group("/credentials")
@@ -56,6 +71,7 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 			gitCreds.GET("/:id", gitCredHandlers.GetCredential)
 			gitCreds.PUT("/:id", gitCredHandlers.UpdateCredential)
 			gitCreds.DELETE("/:id", gitCredHandlers.DeleteCredential)
+			gitCreds.POST("/:id/test", gitCredHandlers.TestCredential)
 		}
 
 		projects := api.Group("/projects")
@@ -70,6 +86,9 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 			projects.PUT("/:id", projectHandlers.UpdateProject)
 			projects.DELETE("/:id", projectHandlers.DeleteProject)
 			projects.GET("/:id/kanban", taskHandlers.GetKanbanTasks)
+			projects.POST("/:id/cancel-all-conversations", taskExecLogHandlers.CancelProjectConversations)
+			projects.POST("/:id/prewarm", projectHandlers.PrewarmProject)
+			projects.GET("/:id/budget", projectHandlers.GetProjectBudget)
 		}
 
 		tasks := api.Group("/tasks")
@@ -84,17 +103,30 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 			tasks.GET("/:id/git-diff", taskHandlers.GetTaskGitDiff)
 			tasks.GET("/:id/git-diff/file", taskHandlers.GetTaskGitDiffFile)
 			tasks.POST("/:id/push", taskHandlers.PushTaskBranch)
+			tasks.GET("/:id/push-status", taskHandlers.GetTaskPushStatus)
+			tasks.POST("/:id/branch/merged", taskHandlers.MarkTaskBranchMerged)
+			tasks.GET("/:id/branch-status", taskHandlers.GetTaskBranchStatus)
+			tasks.GET("/:id/export", taskHandlers.ExportTask)
+			tasks.POST("/import", taskHandlers.ImportTask)
+			tasks.POST("/:id/workspace/snapshot", taskHandlers.CreateWorkspaceSnapshot)
+			tasks.GET("/:id/workspace/snapshots", taskHandlers.ListWorkspaceSnapshots)
+			tasks.POST("/:id/workspace/restore", taskHandlers.RestoreWorkspaceSnapshot)
+			tasks.POST("/:id/conversations/cancel-all", taskExecLogHandlers.CancelTaskConversations)
+			tasks.GET("/:id/logs/stream", taskConvHandlers.StreamTaskLogs)
 		}
 
 		conversations := api.Group("/conversations")
 		{
-			conversations.POST("", taskConvHandlers.CreateConversation)
+			conversations.POST("", middleware.ConversationRateLimitMiddleware(systemConfigService, middleware.NewMemoryRateLimitStore()), taskConvHandlers.CreateConversation)
 			conversations.GET("", taskConvHandlers.ListConversations)
 			conversations.GET("/latest", taskConvHandlers.GetLatestConversation)
+			conversations.GET("/search", taskConvHandlers.SearchConversations)
+			conversations.POST("/estimate-cost", taskConvHandlers.EstimateCost)
 			conversations.GET("/:id", taskConvHandlers.GetConversation)
 			conversations.GET("/:id/details", taskConvHandlers.GetConversationDetails)
 			conversations.PUT("/:id", taskConvHandlers.UpdateConversation)
 			conversations.DELETE("/:id", taskConvHandlers.DeleteConversation)
+			conversations.POST("/:id/promote", taskConvHandlers.PromoteConversation)
 			conversations.GET("/:id/git-diff", taskConvHandlers.GetConversationGitDiff)
 			conversations.GET("/:id/git-diff/file", taskConvHandlers.GetConversationGitDiffFile)
 			conversations.GET("/:id/logs/stream", taskConvHandlers.StreamConversationLogs)
@@ -128,8 +160,41 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 		}
 
 		api.GET("/task-conversations/:conversationId/execution-log", taskExecLogHandlers.GetExecutionLog)
+		api.GET("/task-conversations/:conversationId/execution-log/stream", taskExecLogHandlers.StreamExecutionLog)
+		api.GET("/task-conversations/:conversationId/effective-prompt", taskExecLogHandlers.GetEffectivePrompt)
+		api.GET("/task-conversations/:conversationId/execution/preview", taskExecLogHandlers.PreviewCommand)
 		api.POST("/task-conversations/:conversationId/execution/cancel", taskExecLogHandlers.CancelExecution)
 		api.POST("/task-conversations/:conversationId/execution/retry", taskExecLogHandlers.RetryExecution)
+		api.POST("/task-conversations/:conversationId/approve", taskExecLogHandlers.ApproveConversation)
+		api.POST("/task-conversations/:conversationId/reject", taskExecLogHandlers.RejectConversation)
+		api.GET("/task-conversations/:conversationId/bundle", taskConvHandlers.DownloadConversationBundle)
+
+		taskTemplates := api.Group("/task-templates")
+		{
+			taskTemplates.POST("", taskTemplateHandlers.CreateTemplate)
+			taskTemplates.GET("", taskTemplateHandlers.ListTemplates)
+			taskTemplates.GET("/:id", taskTemplateHandlers.GetTemplate)
+			taskTemplates.PUT("/:id", taskTemplateHandlers.UpdateTemplate)
+			taskTemplates.DELETE("/:id", taskTemplateHandlers.DeleteTemplate)
+		}
+
+		scheduledTasks := api.Group("/scheduled-tasks")
+		{
+			scheduledTasks.POST("", scheduledTaskHandlers.CreateScheduledTask)
+			scheduledTasks.GET("", scheduledTaskHandlers.ListScheduledTasks)
+			scheduledTasks.GET("/:id", scheduledTaskHandlers.GetScheduledTask)
+			scheduledTasks.PUT("/:id", scheduledTaskHandlers.UpdateScheduledTask)
+			scheduledTasks.DELETE("/:id", scheduledTaskHandlers.DeleteScheduledTask)
+		}
+
+		secrets := api.Group("/secrets")
+		{
+			secrets.POST("", secretHandlers.CreateSecret)
+			secrets.GET("", secretHandlers.ListSecrets)
+			secrets.GET("/:id", secretHandlers.GetSecret)
+			secrets.PUT("/:id", secretHandlers.UpdateSecret)
+			secrets.DELETE("/:id", secretHandlers.DeleteSecret)
+		}
 
 		devEnvs := api.Group("/environments")
 		{
@@ -137,6 +202,7 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 			devEnvs.GET("", devEnvHandlers.ListEnvironments)
 			devEnvs.GET("/available-images", devEnvHandlers.GetAvailableImages)
 			devEnvs.GET("/stats", devEnvHandlers.GetStats)
+			devEnvs.GET("/compare", devEnvHandlers.CompareEnvironments)
 			devEnvs.GET("/:id", devEnvHandlers.GetEnvironment)
 			devEnvs.PUT("/:id", devEnvHandlers.UpdateEnvironment)
 			devEnvs.DELETE("/:id", devEnvHandlers.DeleteEnvironment)
@@ -147,6 +213,7 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authService services.AuthSer
 		systemConfigs := api.Group("/settings")
 		{
 			systemConfigs.GET("", systemConfigHandlers.ListAllConfigs)
+			systemConfigs.GET("/effective", systemConfigHandlers.GetEffectiveConfigs)
 			systemConfigs.PUT("", systemConfigHandlers.BatchUpdateConfigs)
 		}
 
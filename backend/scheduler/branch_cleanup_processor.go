@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"xsha-backend/services"
+	"xsha-backend/utils"
+)
+
+type branchCleanupProcessor struct {
+	taskService services.TaskService
+}
+
+func NewBranchCleanupProcessor(taskService services.TaskService) TaskProcessor {
+	return &branchCleanupProcessor{
+		taskService: taskService,
+	}
+}
+
+func (p *branchCleanupProcessor) ProcessTasks() error {
+	cleaned, err := p.taskService.CleanupMergedBranches()
+	if err != nil {
+		utils.Error("Merged branch cleanup failed", "error", err)
+		return err
+	}
+
+	if cleaned > 0 {
+		utils.Info("Merged branch cleanup completed", "cleaned", cleaned)
+	}
+	return nil
+}
@@ -4,6 +4,10 @@ type Scheduler interface {
 	Start() error
 	Stop() error
 	IsRunning() bool
+	// Pause keeps the scheduler running but skips processing on each tick until Resume
+	Pause()
+	Resume()
+	IsPaused() bool
 }
 
 type TaskProcessor interface {
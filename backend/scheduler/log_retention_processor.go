@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"xsha-backend/services"
+	"xsha-backend/utils"
+)
+
+type logRetentionProcessor struct {
+	retentionService services.ExecutionLogRetentionService
+}
+
+func NewLogRetentionProcessor(retentionService services.ExecutionLogRetentionService) TaskProcessor {
+	return &logRetentionProcessor{
+		retentionService: retentionService,
+	}
+}
+
+func (p *logRetentionProcessor) ProcessTasks() error {
+	deleted, err := p.retentionService.CleanupExpiredLogs()
+	if err != nil {
+		utils.Error("Execution log retention failed", "error", err)
+		return err
+	}
+
+	utils.Info("Execution log retention completed", "deleted", deleted)
+	return nil
+}
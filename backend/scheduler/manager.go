@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 	"xsha-backend/utils"
 )
@@ -14,6 +15,9 @@ type schedulerManager struct {
 	running   bool
 	mu        sync.RWMutex
 	interval  time.Duration
+
+	// paused is read by run without mu, Stop holds mu while waiting for run to return
+	paused atomic.Bool
 }
 
 func NewSchedulerManager(processor TaskProcessor, interval time.Duration) Scheduler {
@@ -69,16 +73,37 @@ func (s *schedulerManager) IsRunning() bool {
 	return s.running
 }
 
+func (s *schedulerManager) Pause() {
+	if s.paused.CompareAndSwap(false, true) {
+		utils.Info("Scheduler paused")
+	}
+}
+
+func (s *schedulerManager) Resume() {
+	if s.paused.CompareAndSwap(true, false) {
+		utils.Info("Scheduler resumed")
+	}
+}
+
+func (s *schedulerManager) IsPaused() bool {
+	return s.paused.Load()
+}
+
 func (s *schedulerManager) run() {
 	defer s.wg.Done()
 
-	if err := s.processor.ProcessTasks(); err != nil {
-		utils.Error("Initial task processing failed", "error", err)
+	if !s.IsPaused() {
+		if err := s.processor.ProcessTasks(); err != nil {
+			utils.Error("Initial task processing failed", "error", err)
+		}
 	}
 
 	for {
 		select {
 		case <-s.ticker.C:
+			if s.IsPaused() {
+				continue
+			}
 			if err := s.processor.ProcessTasks(); err != nil {
 				utils.Error("Scheduled task processing failed", "error", err)
 			}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"xsha-backend/services"
+	"xsha-backend/utils"
+)
+
+type referenceCacheProcessor struct {
+	projectService services.ProjectService
+}
+
+func NewReferenceCacheProcessor(projectService services.ProjectService) TaskProcessor {
+	return &referenceCacheProcessor{
+		projectService: projectService,
+	}
+}
+
+func (p *referenceCacheProcessor) ProcessTasks() error {
+	refreshed, err := p.projectService.RefreshReferenceCaches()
+	if err != nil {
+		utils.Error("Reference cache refresh failed", "error", err)
+		return err
+	}
+
+	utils.Info("Reference cache refresh completed", "refreshed", refreshed)
+	return nil
+}
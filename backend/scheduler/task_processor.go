@@ -6,23 +6,34 @@ import (
 )
 
 type taskProcessor struct {
-	aiTaskExecutor services.AITaskExecutorService
+	aiTaskExecutor       services.AITaskExecutorService
+	scheduledTaskService services.ScheduledTaskService
 }
 
-func NewTaskProcessor(aiTaskExecutor services.AITaskExecutorService) TaskProcessor {
+func NewTaskProcessor(aiTaskExecutor services.AITaskExecutorService, scheduledTaskService services.ScheduledTaskService) TaskProcessor {
 	return &taskProcessor{
-		aiTaskExecutor: aiTaskExecutor,
+		aiTaskExecutor:       aiTaskExecutor,
+		scheduledTaskService: scheduledTaskService,
 	}
 }
 
 func (p *taskProcessor) ProcessTasks() error {
 	utils.Info("Starting to process pending task conversations...")
 
+	// Conversations created by due schedules are picked up by the processing below
+	if created, err := p.scheduledTaskService.RunDueSchedules(); err != nil {
+		utils.Error("Scheduled task processing failed", "error", err)
+	} else if created > 0 {
+		utils.Info("Scheduled tasks created conversations", "count", created)
+	}
+
 	if err := p.aiTaskExecutor.ProcessPendingConversations(); err != nil {
+		utils.RecordSchedulerRun(true)
 		utils.Error("Task processing failed", "error", err)
 		return err
 	}
 
+	utils.RecordSchedulerRun(false)
 	utils.Info("Task processing completed")
 	return nil
 }
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"xsha-backend/services"
+	"xsha-backend/utils"
+)
+
+type workspaceCleanupProcessor struct {
+	retentionService services.WorkspaceRetentionService
+}
+
+func NewWorkspaceCleanupProcessor(retentionService services.WorkspaceRetentionService) TaskProcessor {
+	return &workspaceCleanupProcessor{
+		retentionService: retentionService,
+	}
+}
+
+func (p *workspaceCleanupProcessor) ProcessTasks() error {
+	removed, reclaimedBytes, err := p.retentionService.CleanupStaleWorkspaces()
+	if err != nil {
+		utils.Error("Stale workspace cleanup failed", "error", err)
+		return err
+	}
+
+	utils.Info("Stale workspace cleanup completed", "removed", removed, "reclaimedBytes", reclaimedBytes)
+	return nil
+}
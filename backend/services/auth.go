@@ -1,7 +1,10 @@
 package services
 
 import (
+	"sync"
 	"xsha-backend/config"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/i18n"
 	"xsha-backend/repository"
 	"xsha-backend/utils"
 )
@@ -11,15 +14,21 @@ type authService struct {
 	loginLogRepo        repository.LoginLogRepository
 	operationLogService AdminOperationLogService
 	systemConfigRepo    repository.SystemConfigRepository
+	userPreferenceRepo  repository.UserPreferenceRepository
 	config              *config.Config
+
+	// preferredLanguages caches the stored preference by username, it is read on every
+	// authenticated request and by the executor
+	preferredLanguages sync.Map
 }
 
-func NewAuthService(tokenRepo repository.TokenBlacklistRepository, loginLogRepo repository.LoginLogRepository, operationLogService AdminOperationLogService, systemConfigRepo repository.SystemConfigRepository, cfg *config.Config) AuthService {
+func NewAuthService(tokenRepo repository.TokenBlacklistRepository, loginLogRepo repository.LoginLogRepository, operationLogService AdminOperationLogService, systemConfigRepo repository.SystemConfigRepository, userPreferenceRepo repository.UserPreferenceRepository, cfg *config.Config) AuthService {
 	return &authService{
 		tokenRepo:           tokenRepo,
 		loginLogRepo:        loginLogRepo,
 		operationLogService: operationLogService,
 		systemConfigRepo:    systemConfigRepo,
+		userPreferenceRepo:  userPreferenceRepo,
 		config:              cfg,
 	}
 }
@@ -168,3 +177,39 @@ func (s *authService) IsTokenBlacklisted(token string) (bool, error) {
 func (s *authService) CleanExpiredTokens() error {
 	return s.tokenRepo.CleanExpired()
 }
+
+func (s *authService) GetPreferredLanguage(username string) (string, error) {
+	if cached, ok := s.preferredLanguages.Load(username); ok {
+		return cached.(string), nil
+	}
+
+	language, err := s.userPreferenceRepo.GetPreferredLanguage(username)
+	if err != nil {
+		return "", err
+	}
+	s.preferredLanguages.Store(username, language)
+	return language, nil
+}
+
+// SetPreferredLanguage stores the language used for the user's requests without a lang query,
+// an empty language removes the preference
+func (s *authService) SetPreferredLanguage(username, language string) error {
+	if language != "" {
+		supported := false
+		for _, lang := range i18n.GetInstance().GetSupportedLanguages() {
+			if lang == language {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return appErrors.ErrUnsupportedLanguage
+		}
+	}
+
+	if err := s.userPreferenceRepo.SetPreferredLanguage(username, language); err != nil {
+		return err
+	}
+	s.preferredLanguages.Store(username, language)
+	return nil
+}
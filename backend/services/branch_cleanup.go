@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/utils"
+)
+
+// MarkTaskBranchMerged records that the task's work branch has been merged, for repositories
+// whose provider cannot be polled, and runs the cleanup configured on the task's project
+func (s *taskService) MarkTaskBranchMerged(id uint) (*database.Task, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.WorkBranch == "" {
+		return nil, appErrors.ErrTaskWorkBranchMissing
+	}
+
+	if task.Project == nil {
+		return nil, appErrors.NewI18nError("task.project_info_incomplete")
+	}
+
+	// The merge was not seen on the provider, so there is no merged head to check the remote against
+	if err := s.cleanupMergedBranch(task, ""); err != nil {
+		return nil, err
+	}
+
+	return s.GetTask(id)
+}
+
+// CleanupMergedBranches asks the providers of projects with cleanup after merge enabled whether
+// task work branches were merged, and cleans up the merged ones. Tasks whose cleanup fails are
+// retried on the next run. It returns the number of tasks cleaned up.
+func (s *taskService) CleanupMergedBranches() (int, error) {
+	tasks, err := s.repo.ListForBranchCleanup()
+	if err != nil {
+		return 0, err
+	}
+
+	gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+	if err != nil {
+		utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+		gitSSLVerify = false
+	}
+
+	cleaned := 0
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Project == nil {
+			continue
+		}
+
+		credential, cred, err := s.projectGitCredential(task.Project)
+		if err != nil {
+			utils.Warn("Failed to get credential for merge detection", "taskID", task.ID, "error", err)
+			continue
+		}
+
+		proxyConfig, err := s.getGitProxyConfig(cred)
+		if err != nil {
+			utils.Warn("Failed to get proxy config for merge detection, using no proxy", "error", err)
+			proxyConfig = nil
+		}
+
+		mergedHead, err := utils.MergedBranchHead(task.Project.RepoURL, task.WorkBranch, credential, gitSSLVerify, proxyConfig)
+		if err != nil {
+			if !errors.Is(err, utils.ErrMergeDetectionUnavailable) {
+				utils.Warn("Failed to check whether task branch was merged", "taskID", task.ID, "branch", task.WorkBranch, "error", err)
+			}
+			continue
+		}
+		if mergedHead == "" {
+			continue
+		}
+
+		if err := s.cleanupMergedBranch(task, mergedHead); err != nil {
+			utils.Warn("Failed to clean up merged task branch", "taskID", task.ID, "branch", task.WorkBranch, "error", err)
+			continue
+		}
+		cleaned++
+	}
+
+	return cleaned, nil
+}
+
+// cleanupMergedBranch deletes the remote work branch and removes the task workspace as configured
+// on the project, then records the merge. The workspace is kept while conversations may use it
+// or it has commits that were never pushed. With mergedHead set, the remote branch is only
+// deleted while it still points at the merged commit.
+func (s *taskService) cleanupMergedBranch(task *database.Task, mergedHead string) error {
+	project := task.Project
+	removeWorkspace := project.RemoveWorkspaceAfterMerge && task.WorkspacePath != ""
+
+	if removeWorkspace {
+		active, err := s.taskConversationRepo.HasPendingOrRunningConversations(task.ID)
+		if err != nil {
+			return err
+		}
+		if active {
+			return appErrors.ErrTaskBranchCleanupBusy
+		}
+
+		if s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+			unpushed, _, err := s.workspaceManager.CountUnpushedCommits(task.WorkspacePath, task.WorkBranch, task.StartBranch)
+			if err != nil {
+				return err
+			}
+			if unpushed > 0 {
+				return appErrors.ErrTaskBranchCleanupUnpushed
+			}
+		}
+	}
+
+	if project.DeleteBranchAfterMerge {
+		credential, cred, err := s.projectGitCredential(project)
+		if err != nil {
+			return err
+		}
+
+		proxyConfig, err := s.getGitProxyConfig(cred)
+		if err != nil {
+			utils.Warn("Failed to get proxy config for branch deletion, using no proxy", "error", err)
+			proxyConfig = nil
+		}
+
+		gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+		if err != nil {
+			utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+			gitSSLVerify = false
+		}
+
+		if err := s.workspaceManager.DeleteRemoteBranch(project.RepoURL, task.WorkBranch, mergedHead, credential, gitSSLVerify, proxyConfig); err != nil {
+			if errors.Is(err, utils.ErrRemoteBranchMoved) {
+				return appErrors.ErrTaskBranchCleanupMoved
+			}
+			return err
+		}
+	}
+
+	if removeWorkspace {
+		if err := s.workspaceManager.CleanupTaskWorkspace(task.WorkspacePath); err != nil {
+			return err
+		}
+		if err := s.workspaceManager.CleanupConversationWorkspaces(task.ID); err != nil {
+			utils.Warn("Failed to remove conversation workspaces of merged task", "taskID", task.ID, "error", err)
+		}
+	}
+
+	if err := s.repo.MarkBranchMerged(task.ID, utils.Now(), removeWorkspace); err != nil {
+		return err
+	}
+
+	utils.Info("Cleaned up merged task branch", "taskID", task.ID, "branch", task.WorkBranch,
+		"deletedRemoteBranch", project.DeleteBranchAfterMerge, "removedWorkspace", removeWorkspace)
+	return nil
+}
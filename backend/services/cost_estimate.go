@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/utils"
+)
+
+// The output of an agent run is hard to predict, so the estimate assumes it falls between a
+// quarter of and the full input size, with floors for very short prompts
+const (
+	estimateOutputMinRatio  = 0.25
+	estimateOutputMaxRatio  = 1.0
+	estimateOutputMinTokens = 256
+	estimateOutputMaxTokens = 4096
+)
+
+// EstimateCost gives a rough token count and cost range for running content as a new
+// conversation. When taskID is set, the task's system prompts and conversation history are
+// counted too, since resumed sessions send them along with the prompt.
+func (s *taskConversationService) EstimateCost(taskID *uint, content, envParams string) (*CostEstimate, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, appErrors.ErrRequired
+	}
+
+	pricingByModel, err := s.systemConfigService.GetModelPricing()
+	if err != nil {
+		return nil, err
+	}
+
+	model := modelFromEnvParams(envParams)
+	pricing, ok := pricingByModel[model]
+	if !ok {
+		pricing = pricingByModel["default"]
+	}
+
+	estimate := &CostEstimate{
+		Model:       model,
+		InputTokens: estimateTokens(content),
+		Pricing:     pricing,
+	}
+
+	if taskID != nil {
+		task, err := s.taskRepo.GetByID(*taskID)
+		if err != nil {
+			return nil, appErrors.ErrTaskNotFound
+		}
+
+		if task.Project != nil {
+			estimate.InputTokens += estimateTokens(task.Project.SystemPrompt)
+		}
+		if task.DevEnvironment != nil {
+			estimate.InputTokens += estimateTokens(task.DevEnvironment.SystemPrompt)
+		}
+
+		conversations, err := s.repo.ListByTask(task.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var totalCost float64
+		var costCount int
+		for _, conv := range conversations {
+			estimate.HistoryTokens += estimateTokens(conv.Content)
+
+			result, err := s.resultRepo.GetByConversationID(conv.ID)
+			if err != nil {
+				continue
+			}
+			estimate.HistoryTokens += estimateTokens(result.Result)
+			if result.TotalCostUsd > 0 {
+				totalCost += result.TotalCostUsd
+				costCount++
+			}
+		}
+
+		if costCount > 0 {
+			average := totalCost / float64(costCount)
+			estimate.HistoricalAverageCostUsd = &average
+		}
+	}
+
+	totalInput := estimate.InputTokens + estimate.HistoryTokens
+	estimate.OutputTokensMin = max(int(float64(totalInput)*estimateOutputMinRatio), estimateOutputMinTokens)
+	estimate.OutputTokensMax = max(int(float64(totalInput)*estimateOutputMaxRatio), estimateOutputMaxTokens)
+	estimate.CostMinUsd = tokenCost(totalInput, estimate.OutputTokensMin, pricing)
+	estimate.CostMaxUsd = tokenCost(totalInput, estimate.OutputTokensMax, pricing)
+
+	return estimate, nil
+}
+
+// estimateTokens approximates the token count of text at roughly four characters per token
+func estimateTokens(text string) int {
+	chars := len([]rune(strings.TrimSpace(text)))
+	return (chars + 3) / 4
+}
+
+func tokenCost(inputTokens, outputTokens int, pricing ModelPricing) float64 {
+	cost := float64(inputTokens)*pricing.InputPerMTok/1e6 + float64(outputTokens)*pricing.OutputPerMTok/1e6
+	return math.Round(cost*1e6) / 1e6
+}
+
+// modelFromEnvParams returns the model requested in a conversation's env params, "default" if none
+func modelFromEnvParams(envParams string) string {
+	if envParams == "" || envParams == "{}" {
+		return "default"
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(envParams), &params); err != nil {
+		utils.Warn("Failed to parse env params for cost estimate", "error", err)
+		return "default"
+	}
+
+	if model, ok := params["model"].(string); ok && strings.TrimSpace(model) != "" {
+		return strings.TrimSpace(model)
+	}
+	return "default"
+}
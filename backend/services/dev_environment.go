@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"xsha-backend/config"
 	"xsha-backend/database"
@@ -16,27 +20,65 @@ import (
 type devEnvironmentService struct {
 	repo          repository.DevEnvironmentRepository
 	taskRepo      repository.TaskRepository
+	secretRepo    repository.SecretRepository
 	configService SystemConfigService
 	config        *config.Config
 }
 
-func NewDevEnvironmentService(repo repository.DevEnvironmentRepository, taskRepo repository.TaskRepository, configService SystemConfigService, cfg *config.Config) DevEnvironmentService {
+func NewDevEnvironmentService(repo repository.DevEnvironmentRepository, taskRepo repository.TaskRepository, secretRepo repository.SecretRepository, configService SystemConfigService, cfg *config.Config) DevEnvironmentService {
 	return &devEnvironmentService{
 		repo:          repo,
 		taskRepo:      taskRepo,
+		secretRepo:    secretRepo,
 		configService: configService,
 		config:        cfg,
 	}
 }
 
-func (s *devEnvironmentService) CreateEnvironment(name, description, systemPrompt, envType, dockerImage string, cpuLimit float64, memoryLimit int64, envVars map[string]string, createdBy string) (*database.DevEnvironment, error) {
+func (s *devEnvironmentService) CreateEnvironment(name, description, systemPrompt, envType, dockerImage string, cpuLimit float64, memoryLimit int64, networkMode string, gpuEnabled bool, gpuDevice string, ulimits map[string]string, extraDockerArgs []string, readOnlyRootFS, noNewPrivileges bool, runAsUser string, tmpfsMounts []string, envVars map[string]string, createdBy string) (*database.DevEnvironment, error) {
 	if err := s.validateEnvironmentData(name, envType, cpuLimit, memoryLimit); err != nil {
 		return nil, err
 	}
 
+	if networkMode == "" {
+		networkMode = database.NetworkModeBridge
+	}
+	if err := s.ValidateNetworkMode(networkMode); err != nil {
+		return nil, err
+	}
+
+	if gpuEnabled {
+		if err := s.ValidateGPUSupport(); err != nil {
+			return nil, err
+		}
+	}
+	gpuDevice = strings.TrimSpace(gpuDevice)
+	if err := validateGPUDevice(gpuDevice); err != nil {
+		return nil, err
+	}
+
 	if err := s.ValidateEnvVars(envVars); err != nil {
 		return nil, err
 	}
+	if err := s.validateSecretReferences(envVars, createdBy); err != nil {
+		return nil, err
+	}
+
+	if err := s.ValidateUlimits(ulimits); err != nil {
+		return nil, err
+	}
+
+	if err := s.ValidateExtraDockerArgs(extraDockerArgs); err != nil {
+		return nil, err
+	}
+
+	runAsUser = strings.TrimSpace(runAsUser)
+	if err := s.ValidateRunAsUser(runAsUser); err != nil {
+		return nil, err
+	}
+	if err := s.ValidateTmpfsMounts(tmpfsMounts); err != nil {
+		return nil, err
+	}
 
 	if existing, _ := s.repo.GetByName(name); existing != nil {
 		return nil, appErrors.ErrEnvironmentNameExists
@@ -51,6 +93,29 @@ func (s *devEnvironmentService) CreateEnvironment(name, description, systemPromp
 		return nil, fmt.Errorf("failed to serialize environment variables: %v", err)
 	}
 
+	ulimitsJSON, err := json.Marshal(ulimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize ulimits: %v", err)
+	}
+
+	extraDockerArgsJSON := ""
+	if len(extraDockerArgs) > 0 {
+		data, err := json.Marshal(extraDockerArgs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize extra docker args: %v", err)
+		}
+		extraDockerArgsJSON = string(data)
+	}
+
+	tmpfsMountsJSON := ""
+	if len(tmpfsMounts) > 0 {
+		data, err := json.Marshal(tmpfsMounts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize tmpfs mounts: %v", err)
+		}
+		tmpfsMountsJSON = string(data)
+	}
+
 	// Generate session directory
 	sessionDir, err := s.generateSessionDir()
 	if err != nil {
@@ -65,9 +130,19 @@ func (s *devEnvironmentService) CreateEnvironment(name, description, systemPromp
 		DockerImage:  dockerImage,
 		CPULimit:     cpuLimit,
 		MemoryLimit:  memoryLimit,
+		NetworkMode:  networkMode,
+		GPUEnabled:   gpuEnabled,
+		GPUDevice:    gpuDevice,
 		EnvVars:      string(envVarsJSON),
+		Ulimits:      string(ulimitsJSON),
 		SessionDir:   sessionDir,
 		CreatedBy:    createdBy,
+
+		ExtraDockerArgs: extraDockerArgsJSON,
+		ReadOnlyRootFS:  readOnlyRootFS,
+		NoNewPrivileges: noNewPrivileges,
+		RunAsUser:       runAsUser,
+		TmpfsMounts:     tmpfsMountsJSON,
 	}
 
 	if err := s.repo.Create(env); err != nil {
@@ -81,8 +156,8 @@ func (s *devEnvironmentService) GetEnvironment(id uint) (*database.DevEnvironmen
 	return s.repo.GetByID(id)
 }
 
-func (s *devEnvironmentService) ListEnvironments(name *string, dockerImage *string, page, pageSize int) ([]database.DevEnvironment, int64, error) {
-	return s.repo.List(name, dockerImage, page, pageSize)
+func (s *devEnvironmentService) ListEnvironments(name *string, dockerImage *string, minCPU, maxCPU *float64, minMemory, maxMemory *int64, page, pageSize int) ([]database.DevEnvironment, int64, error) {
+	return s.repo.List(name, dockerImage, minCPU, maxCPU, minMemory, maxMemory, page, pageSize)
 }
 
 func (s *devEnvironmentService) UpdateEnvironment(id uint, updates map[string]interface{}) error {
@@ -106,6 +181,154 @@ func (s *devEnvironmentService) UpdateEnvironment(id uint, updates map[string]in
 	if memoryLimit, ok := updates["memory_limit"]; ok {
 		env.MemoryLimit = memoryLimit.(int64)
 	}
+	if networkMode, ok := updates["network_mode"]; ok {
+		mode, ok := networkMode.(string)
+		if !ok {
+			return fmt.Errorf("invalid network_mode type")
+		}
+		env.NetworkMode = mode
+		if err := s.ValidateNetworkMode(env.NetworkMode); err != nil {
+			return err
+		}
+	}
+
+	if gpuDevice, ok := updates["gpu_device"]; ok {
+		device, ok := gpuDevice.(string)
+		if !ok {
+			return fmt.Errorf("invalid gpu_device type")
+		}
+		env.GPUDevice = strings.TrimSpace(device)
+		if err := validateGPUDevice(env.GPUDevice); err != nil {
+			return err
+		}
+	}
+	if gpuEnabled, ok := updates["gpu_enabled"]; ok {
+		enabled, ok := gpuEnabled.(bool)
+		if !ok {
+			return fmt.Errorf("invalid gpu_enabled type")
+		}
+		if enabled && !env.GPUEnabled {
+			if err := s.ValidateGPUSupport(); err != nil {
+				return err
+			}
+		}
+		env.GPUEnabled = enabled
+	}
+	if ulimits, ok := updates["ulimits"]; ok {
+		ulimitsMap, ok := ulimits.(map[string]string)
+		if !ok {
+			return fmt.Errorf("invalid ulimits type")
+		}
+		if err := s.ValidateUlimits(ulimitsMap); err != nil {
+			return err
+		}
+		ulimitsJSON, err := json.Marshal(ulimitsMap)
+		if err != nil {
+			return fmt.Errorf("failed to serialize ulimits: %v", err)
+		}
+		env.Ulimits = string(ulimitsJSON)
+	}
+	if envFiles, ok := updates["env_files"]; ok {
+		envFilesMap, ok := envFiles.(map[string]string)
+		if !ok {
+			return fmt.Errorf("invalid env_files type")
+		}
+		if err := s.ValidateEnvFiles(envFilesMap); err != nil {
+			return err
+		}
+		envFilesJSON, err := json.Marshal(envFilesMap)
+		if err != nil {
+			return fmt.Errorf("failed to serialize env files: %v", err)
+		}
+		env.EnvFiles = string(envFilesJSON)
+	}
+	if permissionMode, ok := updates["permission_mode"]; ok {
+		mode, ok := permissionMode.(string)
+		if !ok {
+			return fmt.Errorf("invalid permission_mode type")
+		}
+		if err := s.ValidatePermissionMode(mode); err != nil {
+			return err
+		}
+		env.PermissionMode = mode
+	}
+	if allowedTools, ok := updates["allowed_tools"]; ok {
+		tools, ok := allowedTools.([]string)
+		if !ok {
+			return fmt.Errorf("invalid allowed_tools type")
+		}
+		if err := s.ValidateAllowedTools(tools); err != nil {
+			return err
+		}
+		allowedToolsJSON, err := json.Marshal(tools)
+		if err != nil {
+			return fmt.Errorf("failed to serialize allowed tools: %v", err)
+		}
+		env.AllowedTools = string(allowedToolsJSON)
+	}
+	if extraDockerArgs, ok := updates["extra_docker_args"]; ok {
+		args, ok := extraDockerArgs.([]string)
+		if !ok {
+			return fmt.Errorf("invalid extra_docker_args type")
+		}
+		if err := s.ValidateExtraDockerArgs(args); err != nil {
+			return err
+		}
+		extraDockerArgsJSON, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("failed to serialize extra docker args: %v", err)
+		}
+		env.ExtraDockerArgs = string(extraDockerArgsJSON)
+	}
+	if readOnlyRootFS, ok := updates["read_only_root_fs"]; ok {
+		enabled, ok := readOnlyRootFS.(bool)
+		if !ok {
+			return fmt.Errorf("invalid read_only_root_fs type")
+		}
+		env.ReadOnlyRootFS = enabled
+	}
+	if noNewPrivileges, ok := updates["no_new_privileges"]; ok {
+		enabled, ok := noNewPrivileges.(bool)
+		if !ok {
+			return fmt.Errorf("invalid no_new_privileges type")
+		}
+		env.NoNewPrivileges = enabled
+	}
+	if runAsUser, ok := updates["run_as_user"]; ok {
+		value, ok := runAsUser.(string)
+		if !ok {
+			return fmt.Errorf("invalid run_as_user type")
+		}
+		user := strings.TrimSpace(value)
+		if err := s.ValidateRunAsUser(user); err != nil {
+			return err
+		}
+		env.RunAsUser = user
+	}
+	if concurrencyWeight, ok := updates["concurrency_weight"]; ok {
+		weight, ok := concurrencyWeight.(int)
+		if !ok {
+			return fmt.Errorf("invalid concurrency_weight type")
+		}
+		if err := s.ValidateConcurrencyWeight(weight); err != nil {
+			return err
+		}
+		env.ConcurrencyWeight = weight
+	}
+	if tmpfsMounts, ok := updates["tmpfs_mounts"]; ok {
+		mounts, ok := tmpfsMounts.([]string)
+		if !ok {
+			return fmt.Errorf("invalid tmpfs_mounts type")
+		}
+		if err := s.ValidateTmpfsMounts(mounts); err != nil {
+			return err
+		}
+		tmpfsMountsJSON, err := json.Marshal(mounts)
+		if err != nil {
+			return fmt.Errorf("failed to serialize tmpfs mounts: %v", err)
+		}
+		env.TmpfsMounts = string(tmpfsMountsJSON)
+	}
 
 	if err := s.ValidateResourceLimits(env.CPULimit, env.MemoryLimit); err != nil {
 		return err
@@ -142,15 +365,174 @@ func (s *devEnvironmentService) DeleteEnvironment(id uint) error {
 	return s.repo.Delete(id)
 }
 
+// gpuDevicePattern matches docker --gpus device lists such as 0,1 or GPU-<uuid>
+var gpuDevicePattern = regexp.MustCompile(`^[0-9A-Za-z,:-]+$`)
+
+// validateGPUDevice checks the GPU device list, empty selects all GPUs
+func validateGPUDevice(device string) error {
+	if device != "" && !gpuDevicePattern.MatchString(device) {
+		return appErrors.ErrEnvironmentGPUDeviceInvalid
+	}
+	return nil
+}
+
 func (s *devEnvironmentService) ValidateEnvVars(envVars map[string]string) error {
-	for key, value := range envVars {
+	for key := range envVars {
 		if strings.TrimSpace(key) == "" {
 			return appErrors.ErrEnvironmentVarKeyEmpty
 		}
-		if strings.Contains(key, "=") {
+		if !envVarNamePattern.MatchString(key) {
 			return appErrors.ErrEnvironmentVarKeyInvalidChar
 		}
-		_ = value
+	}
+	return nil
+}
+
+// validateSecretReferences checks every secret://<name> value names a secret the environment's
+// owner may use, their own or any secret when the owner is the admin
+func (s *devEnvironmentService) validateSecretReferences(envVars map[string]string, owner string) error {
+	for _, value := range envVars {
+		name, ok := utils.ParseSecretReference(value)
+		if !ok {
+			continue
+		}
+		if secret, _ := findOwnerSecret(s.secretRepo, s.configService, name, owner); secret == nil {
+			return appErrors.NewI18nError(appErrors.ErrEnvironmentSecretNotFound.Key, name)
+		}
+	}
+	return nil
+}
+
+// envVarNamePattern matches a portable env var name, names reach the sh -c command line as -e KEY
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvFiles checks each entry maps a valid env var name to a path inside the env files directory
+func (s *devEnvironmentService) ValidateEnvFiles(envFiles map[string]string) error {
+	for key, path := range envFiles {
+		if !envVarNamePattern.MatchString(key) {
+			return appErrors.ErrEnvironmentEnvFileInvalid
+		}
+		if err := utils.ValidateEnvFilePath(path); err != nil {
+			return appErrors.NewI18nError(appErrors.ErrEnvironmentEnvFileInvalid.Key, err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *devEnvironmentService) ValidatePermissionMode(mode string) error {
+	switch mode {
+	case database.PermissionModeSkip, database.PermissionModeRestricted:
+		return nil
+	default:
+		return appErrors.ErrEnvironmentPermissionModeInvalid
+	}
+}
+
+// ValidateAllowedTools checks each tool pattern is non-empty and can be passed as one
+// comma separated --allowedTools value
+func (s *devEnvironmentService) ValidateAllowedTools(tools []string) error {
+	for _, tool := range tools {
+		if strings.TrimSpace(tool) == "" || strings.ContainsAny(tool, ",\n\r") {
+			return appErrors.ErrEnvironmentAllowedToolsInvalid
+		}
+	}
+	return nil
+}
+
+// ValidateExtraDockerArgs checks each argument is an allowlisted docker run flag, privileged
+// flags additionally require the docker_allow_privileged_args setting
+func (s *devEnvironmentService) ValidateExtraDockerArgs(args []string) error {
+	for _, arg := range args {
+		_, privileged, err := utils.ParseExtraDockerArg(arg)
+		if err != nil {
+			return appErrors.NewI18nError(appErrors.ErrEnvironmentExtraDockerArgInvalid.Key, err.Error())
+		}
+		if !privileged {
+			continue
+		}
+
+		allowed, err := s.configService.GetDockerAllowPrivilegedArgs()
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return appErrors.NewI18nError(appErrors.ErrEnvironmentExtraDockerArgNotAllowed.Key, arg)
+		}
+	}
+	return nil
+}
+
+var (
+	runAsUserPattern  = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+	tmpfsMountPattern = regexp.MustCompile(`^/[A-Za-z0-9_./-]*(:[A-Za-z0-9_=,.-]+)?$`)
+)
+
+// ValidateRunAsUser checks the user is empty or a numeric "uid" or "uid:gid", names are not
+// accepted since they may not exist in the image
+func (s *devEnvironmentService) ValidateRunAsUser(user string) error {
+	if user != "" && !runAsUserPattern.MatchString(user) {
+		return appErrors.ErrEnvironmentRunAsUserInvalid
+	}
+	return nil
+}
+
+// maxConcurrencyWeight bounds explicit environment weights
+const maxConcurrencyWeight = 100
+
+// ValidateConcurrencyWeight checks an explicit weight, 0 derives it from the resource limits
+func (s *devEnvironmentService) ValidateConcurrencyWeight(weight int) error {
+	if weight < 0 || weight > maxConcurrencyWeight {
+		return appErrors.ErrEnvironmentConcurrencyWeightInvalid
+	}
+	return nil
+}
+
+// ValidateTmpfsMounts checks each mount is an absolute container path with optional
+// docker tmpfs options, e.g. /tmp:rw,size=256m. Paths are cleaned first, so neither the root
+// nor the workspace at /app or anything below it can be shadowed through "/app/" or "/tmp/..".
+func (s *devEnvironmentService) ValidateTmpfsMounts(mounts []string) error {
+	for _, mount := range mounts {
+		mountPath, _, _ := strings.Cut(mount, ":")
+		cleaned := path.Clean(mountPath)
+		if !tmpfsMountPattern.MatchString(mount) || cleaned == "/" || cleaned == "/app" || strings.HasPrefix(cleaned, "/app/") {
+			return appErrors.ErrEnvironmentTmpfsInvalid
+		}
+	}
+	return nil
+}
+
+// supportedUlimits are the limit names accepted by docker run --ulimit
+var supportedUlimits = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true,
+	"memlock": true, "msgqueue": true, "nice": true, "nofile": true, "nproc": true,
+	"rss": true, "rtprio": true, "rttime": true, "sigpending": true, "stack": true,
+}
+
+// ValidateUlimits checks each entry is a known limit with a "soft" or "soft:hard" value,
+// where -1 means unlimited
+func (s *devEnvironmentService) ValidateUlimits(ulimits map[string]string) error {
+	for name, value := range ulimits {
+		if !supportedUlimits[name] {
+			return appErrors.ErrEnvironmentUlimitInvalid
+		}
+
+		parts := strings.Split(value, ":")
+		if len(parts) > 2 {
+			return appErrors.ErrEnvironmentUlimitInvalid
+		}
+
+		var limits []int64
+		for _, part := range parts {
+			limit, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || limit < -1 {
+				return appErrors.ErrEnvironmentUlimitInvalid
+			}
+			limits = append(limits, limit)
+		}
+
+		if len(limits) == 2 && limits[1] != -1 && (limits[0] == -1 || limits[0] > limits[1]) {
+			return appErrors.ErrEnvironmentUlimitInvalid
+		}
 	}
 	return nil
 }
@@ -184,6 +566,9 @@ func (s *devEnvironmentService) UpdateEnvironmentVars(id uint, envVars map[strin
 	if err := s.ValidateEnvVars(envVars); err != nil {
 		return err
 	}
+	if err := s.validateSecretReferences(envVars, env.CreatedBy); err != nil {
+		return err
+	}
 
 	envVarsJSON, err := json.Marshal(envVars)
 	if err != nil {
@@ -204,6 +589,35 @@ func (s *devEnvironmentService) ValidateResourceLimits(cpuLimit float64, memoryL
 	return nil
 }
 
+func (s *devEnvironmentService) ValidateNetworkMode(networkMode string) error {
+	switch networkMode {
+	case database.NetworkModeBridge, database.NetworkModeNone, database.NetworkModeHost:
+		return nil
+	default:
+		return appErrors.ErrEnvironmentNetworkModeInvalid
+	}
+}
+
+// ValidateGPUSupport checks that the container runtime on this host exposes a GPU runtime
+func (s *devEnvironmentService) ValidateGPUSupport() error {
+	runtime, err := s.configService.GetContainerRuntime()
+	if err != nil {
+		runtime = "docker"
+	}
+
+	output, err := exec.Command(runtime, "info").Output()
+	if err != nil {
+		utils.Warn("Failed to query container runtime info for GPU support", "runtime", runtime, "error", err)
+		return appErrors.ErrEnvironmentGPUUnsupported
+	}
+
+	if !strings.Contains(strings.ToLower(string(output)), "nvidia") {
+		return appErrors.ErrEnvironmentGPUUnsupported
+	}
+
+	return nil
+}
+
 func (s *devEnvironmentService) validateEnvironmentData(name, envType string, cpuLimit float64, memoryLimit int64) error {
 	if strings.TrimSpace(name) == "" {
 		return appErrors.ErrEnvironmentNameRequired
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+
+	"gorm.io/gorm"
+)
+
+// EnvironmentFieldDiff is a setting whose value differs between the two environments
+type EnvironmentFieldDiff struct {
+	Field string      `json:"field"`
+	A     interface{} `json:"a"`
+	B     interface{} `json:"b"`
+}
+
+// EnvironmentMapDiff lists keys only present in B (added), only in A (removed), or in both
+// with different values (changed). Values are left out since env vars often hold secrets.
+type EnvironmentMapDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+func (d EnvironmentMapDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// EnvironmentComparison is the structured diff of two environments, A being the baseline
+type EnvironmentComparison struct {
+	AID       uint                   `json:"a_id"`
+	AName     string                 `json:"a_name"`
+	BID       uint                   `json:"b_id"`
+	BName     string                 `json:"b_name"`
+	Identical bool                   `json:"identical"`
+	Fields    []EnvironmentFieldDiff `json:"fields"`
+	EnvVars   EnvironmentMapDiff     `json:"env_vars"`
+	EnvFiles  EnvironmentMapDiff     `json:"env_files"`
+	Ulimits   EnvironmentMapDiff     `json:"ulimits"`
+}
+
+// CompareEnvironments diffs the runtime settings of two environments. Name, description and
+// the session directory are ignored, they always differ and do not affect how tasks run.
+func (s *devEnvironmentService) CompareEnvironments(aID, bID uint) (*EnvironmentComparison, error) {
+	a, err := s.getEnvironmentForCompare(aID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.getEnvironmentForCompare(bID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &EnvironmentComparison{
+		AID:    a.ID,
+		AName:  a.Name,
+		BID:    b.ID,
+		BName:  b.Name,
+		Fields: []EnvironmentFieldDiff{},
+	}
+
+	fields := []EnvironmentFieldDiff{
+		{Field: "type", A: a.Type, B: b.Type},
+		{Field: "docker_image", A: a.DockerImage, B: b.DockerImage},
+		{Field: "cpu_limit", A: a.CPULimit, B: b.CPULimit},
+		{Field: "memory_limit", A: a.MemoryLimit, B: b.MemoryLimit},
+		{Field: "network_mode", A: a.NetworkMode, B: b.NetworkMode},
+		{Field: "gpu_enabled", A: a.GPUEnabled, B: b.GPUEnabled},
+		{Field: "gpu_device", A: a.GPUDevice, B: b.GPUDevice},
+		{Field: "concurrency_weight", A: a.ConcurrencyWeight, B: b.ConcurrencyWeight},
+		{Field: "system_prompt", A: a.SystemPrompt, B: b.SystemPrompt},
+		{Field: "permission_mode", A: effectivePermissionMode(a), B: effectivePermissionMode(b)},
+		{Field: "read_only_root_fs", A: a.ReadOnlyRootFS, B: b.ReadOnlyRootFS},
+		{Field: "no_new_privileges", A: a.NoNewPrivileges, B: b.NoNewPrivileges},
+		{Field: "run_as_user", A: a.RunAsUser, B: b.RunAsUser},
+	}
+	for _, field := range fields {
+		if field.A != field.B {
+			comparison.Fields = append(comparison.Fields, field)
+		}
+	}
+
+	listFields := []struct {
+		field, name string
+		a, b        string
+	}{
+		{field: "allowed_tools", name: "allowed tools", a: a.AllowedTools, b: b.AllowedTools},
+		{field: "tmpfs_mounts", name: "tmpfs mounts", a: a.TmpfsMounts, b: b.TmpfsMounts},
+		{field: "extra_docker_args", name: "extra docker args", a: a.ExtraDockerArgs, b: b.ExtraDockerArgs},
+	}
+	for _, listField := range listFields {
+		aList, err := parseStringList(listField.a, listField.name)
+		if err != nil {
+			return nil, err
+		}
+		bList, err := parseStringList(listField.b, listField.name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(aList, bList) {
+			comparison.Fields = append(comparison.Fields, EnvironmentFieldDiff{Field: listField.field, A: aList, B: bList})
+		}
+	}
+
+	if comparison.EnvVars, err = diffEnvironmentMaps(a.EnvVars, b.EnvVars, "environment variables"); err != nil {
+		return nil, err
+	}
+	if comparison.EnvFiles, err = diffEnvironmentMaps(a.EnvFiles, b.EnvFiles, "env files"); err != nil {
+		return nil, err
+	}
+	if comparison.Ulimits, err = diffEnvironmentMaps(a.Ulimits, b.Ulimits, "ulimits"); err != nil {
+		return nil, err
+	}
+
+	comparison.Identical = len(comparison.Fields) == 0 && comparison.EnvVars.empty() &&
+		comparison.EnvFiles.empty() && comparison.Ulimits.empty()
+
+	return comparison, nil
+}
+
+func (s *devEnvironmentService) getEnvironmentForCompare(id uint) (*database.DevEnvironment, error) {
+	env, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrDevEnvironmentNotFound
+		}
+		return nil, err
+	}
+	return env, nil
+}
+
+// effectivePermissionMode treats environments created before permission modes existed as skip
+func effectivePermissionMode(env *database.DevEnvironment) string {
+	if env.PermissionMode == "" {
+		return database.PermissionModeSkip
+	}
+	return env.PermissionMode
+}
+
+func parseStringList(value, name string) ([]string, error) {
+	list := []string{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &list); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+	}
+	return list, nil
+}
+
+// diffEnvironmentMaps compares two JSON encoded string maps by key
+func diffEnvironmentMaps(aJSON, bJSON, name string) (EnvironmentMapDiff, error) {
+	diff := EnvironmentMapDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+
+	var a, b map[string]string
+	if aJSON != "" {
+		if err := json.Unmarshal([]byte(aJSON), &a); err != nil {
+			return diff, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+	}
+	if bJSON != "" {
+		if err := json.Unmarshal([]byte(bJSON), &b); err != nil {
+			return diff, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+	}
+
+	for key, aValue := range a {
+		bValue, ok := b[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, key)
+		} else if aValue != bValue {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"xsha-backend/repository"
+	"xsha-backend/utils"
+)
+
+type executionLogRetentionService struct {
+	execLogRepo         repository.TaskExecutionLogRepository
+	projectRepo         repository.ProjectRepository
+	systemConfigService SystemConfigService
+}
+
+func NewExecutionLogRetentionService(execLogRepo repository.TaskExecutionLogRepository, projectRepo repository.ProjectRepository, systemConfigService SystemConfigService) ExecutionLogRetentionService {
+	return &executionLogRetentionService{
+		execLogRepo:         execLogRepo,
+		projectRepo:         projectRepo,
+		systemConfigService: systemConfigService,
+	}
+}
+
+// CleanupExpiredLogs deletes execution logs older than the retention period,
+// using the project override when set and the global default otherwise
+func (s *executionLogRetentionService) CleanupExpiredLogs() (int64, error) {
+	defaultDays, err := s.systemConfigService.GetExecutionLogRetentionDays()
+	if err != nil {
+		return 0, err
+	}
+
+	projects, err := s.projectRepo.ListAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var totalDeleted int64
+	for _, project := range projects {
+		retentionDays := defaultDays
+		if project.ExecutionLogRetentionDays != nil {
+			retentionDays = *project.ExecutionLogRetentionDays
+		}
+		if retentionDays <= 0 {
+			continue
+		}
+
+		cutoff := utils.Now().AddDate(0, 0, -retentionDays)
+		deleted, err := s.execLogRepo.DeleteCompletedBeforeByProject(project.ID, cutoff)
+		if err != nil {
+			utils.Error("Failed to clean up expired execution logs", "projectID", project.ID, "retentionDays", retentionDays, "error", err)
+			continue
+		}
+
+		if deleted > 0 {
+			utils.Info("Cleaned up expired execution logs", "projectID", project.ID, "retentionDays", retentionDays, "deleted", deleted)
+		}
+		totalDeleted += deleted
+	}
+
+	return totalDeleted, nil
+}
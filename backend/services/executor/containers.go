@@ -0,0 +1,56 @@
+package executor
+
+import (
+	appErrors "xsha-backend/errors"
+	"xsha-backend/services"
+	"xsha-backend/utils"
+)
+
+// ListManagedContainers lists the conversation containers, marking the ones a running
+// execution of this process owns
+func (s *aiTaskExecutorService) ListManagedContainers() ([]services.ManagedContainer, error) {
+	containers, err := s.dockerExecutor.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range containers {
+		containers[i].Tracked = s.executionManager.IsRunning(containers[i].ConversationID)
+	}
+	return containers, nil
+}
+
+// RemoveManagedContainer stops and removes an orphaned conversation container. Containers of
+// running executions are refused, cancelling the conversation removes them cleanly.
+func (s *aiTaskExecutorService) RemoveManagedContainer(name string) error {
+	_, conversationID, ok := parseContainerName(name)
+	if !ok {
+		return appErrors.ErrContainerNameInvalid
+	}
+	if s.executionManager.IsRunning(conversationID) {
+		return appErrors.ErrContainerInUse
+	}
+
+	return s.dockerExecutor.StopAndRemoveContainer(name)
+}
+
+func (s *aiTaskExecutorService) ReconcileOrphanedContainers() (int, error) {
+	containers, err := s.ListManagedContainers()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, container := range containers {
+		if container.Tracked {
+			continue
+		}
+		if err := s.dockerExecutor.StopAndRemoveContainer(container.Name); err != nil {
+			utils.Error("Failed to remove orphaned container", "container", container.Name, "conversationId", container.ConversationID, "error", err)
+			continue
+		}
+		utils.Info("Removed orphaned container", "container", container.Name, "conversationId", container.ConversationID)
+		removed++
+	}
+	return removed, nil
+}
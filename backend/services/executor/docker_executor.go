@@ -1,20 +1,30 @@
 package executor
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 	"xsha-backend/config"
 	"xsha-backend/database"
+	"xsha-backend/i18n"
+	"xsha-backend/repository"
 	"xsha-backend/services"
 	"xsha-backend/utils"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BatchLogAppender provides batched log appending to reduce database operations
@@ -112,31 +122,73 @@ func (bla *BatchLogAppender) Close() {
 type dockerExecutor struct {
 	config        *config.Config
 	logAppender   LogAppender
+	execLogRepo   repository.TaskExecutionLogRepository
 	configService services.SystemConfigService
+	secretService services.SecretService
+
+	runtimeMu sync.Mutex
+	runtime   string
 }
 
-func NewDockerExecutor(cfg *config.Config, logAppender LogAppender, configService services.SystemConfigService) DockerExecutor {
+func NewDockerExecutor(cfg *config.Config, logAppender LogAppender, execLogRepo repository.TaskExecutionLogRepository, configService services.SystemConfigService, secretService services.SecretService) DockerExecutor {
+	runtime, err := configService.GetContainerRuntime()
+	if err != nil {
+		utils.Warn("Failed to get container runtime from system config, using default docker", "error", err)
+		runtime = "docker"
+	}
+
 	return &dockerExecutor{
 		config:        cfg,
 		logAppender:   logAppender,
+		execLogRepo:   execLogRepo,
 		configService: configService,
+		secretService: secretService,
+		runtime:       runtime,
 	}
 }
 
+// containerRuntime returns the configured runtime binary, re-reading the system config so a
+// change takes effect without a restart. The last working runtime is kept when the config
+// can't be read or the new binary isn't in PATH
+func (d *dockerExecutor) containerRuntime() string {
+	d.runtimeMu.Lock()
+	defer d.runtimeMu.Unlock()
+
+	runtime, err := d.configService.GetContainerRuntime()
+	if err != nil {
+		utils.Warn("Failed to get container runtime from system config, keeping current runtime", "runtime", d.runtime, "error", err)
+		return d.runtime
+	}
+	if runtime == d.runtime {
+		return d.runtime
+	}
+	if _, err := exec.LookPath(runtime); err != nil {
+		utils.Error("Configured container runtime not found in PATH, keeping current runtime", "runtime", runtime, "current", d.runtime, "error", err)
+		return d.runtime
+	}
+
+	utils.Info("Container runtime changed", "from", d.runtime, "to", runtime)
+	d.runtime = runtime
+	return d.runtime
+}
+
 func (d *dockerExecutor) CheckAvailability() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "docker", "version")
+	runtime := d.containerRuntime()
+	cmd := exec.CommandContext(ctx, runtime, "version")
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker command unavailable or docker daemon not running: %v", err)
+		return fmt.Errorf("%s command unavailable or daemon not running: %v", runtime, err)
 	}
 
 	return nil
 }
 
+// escapeShellArg single quotes arg for the sh -c command line, inside single quotes the shell
+// expands nothing, so only embedded single quotes need escaping
 func (d *dockerExecutor) escapeShellArg(arg string) string {
-	return strconv.Quote(arg)
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
 }
 
 type buildDockerCommandOptions struct {
@@ -155,7 +207,7 @@ func (d *dockerExecutor) buildDockerCommandCore(conv *database.TaskConversation,
 
 	isInContainer := utils.IsRunningInContainer()
 
-	cmd := []string{"docker", "run", "--rm"}
+	cmd := []string{d.containerRuntime(), "run", "--rm"}
 
 	if opts.includeStdinFlag {
 		cmd = append(cmd, "-i")
@@ -174,6 +226,15 @@ func (d *dockerExecutor) buildDockerCommandCore(conv *database.TaskConversation,
 		// workspacePath is now relative, need to convert to absolute for volume mounting
 		absoluteWorkspacePath := filepath.Join(d.config.WorkspaceBaseDir, workspacePath)
 		cmd = append(cmd, fmt.Sprintf("-v %s:/app", absoluteWorkspacePath))
+		// A worktree only links to its project clone, which has to be reachable from /app too. The
+		// clone is shared by every task of the project, so only this worktree's own admin
+		// directory is writable.
+		if clonePath, mountPath := utils.WorktreeCloneMount(absoluteWorkspacePath, "/app"); clonePath != "" {
+			cmd = append(cmd, fmt.Sprintf("-v %s:%s:ro", clonePath, mountPath))
+			if adminPath, adminMountPath := utils.WorktreeAdminMount(absoluteWorkspacePath, "/app"); adminPath != "" {
+				cmd = append(cmd, fmt.Sprintf("-v %s:%s", adminPath, adminMountPath))
+			}
+		}
 		if devEnv.SessionDir != "" {
 			// SessionDir is now also relative, convert to absolute for volume mounting
 			absoluteSessionDir := filepath.Join(d.config.DevSessionsDir, devEnv.SessionDir)
@@ -188,23 +249,305 @@ func (d *dockerExecutor) buildDockerCommandCore(conv *database.TaskConversation,
 	if devEnv.MemoryLimit > 0 {
 		cmd = append(cmd, fmt.Sprintf("--memory=%dm", devEnv.MemoryLimit))
 	}
+	if devEnv.NetworkMode != "" {
+		cmd = append(cmd, fmt.Sprintf("--network=%s", devEnv.NetworkMode))
+	}
+	if devEnv.GPUEnabled {
+		if devEnv.GPUDevice != "" {
+			cmd = append(cmd, fmt.Sprintf("--gpus %s", d.escapeShellArg("device="+devEnv.GPUDevice)))
+		} else {
+			cmd = append(cmd, "--gpus all")
+		}
+	}
+
+	for _, ulimit := range buildUlimitArgs(devEnv) {
+		cmd = append(cmd, fmt.Sprintf("--ulimit %s", d.escapeShellArg(ulimit)))
+	}
+
+	cmd = append(cmd, d.buildHardeningArgs(devEnv)...)
+	cmd = append(cmd, d.buildExtraDockerArgs(devEnv)...)
+
+	for _, label := range d.buildContainerLabels(conv) {
+		cmd = append(cmd, fmt.Sprintf("--label %s", d.escapeShellArg(label)))
+	}
+
+	var redactedKeys map[string]bool
+	if opts.maskEnvVars {
+		redactedKeys = d.redactedEnvVarKeys()
+	}
 
 	for key, value := range envVars {
+		// Secret-referenced values are resolved into the client process environment like env
+		// files, so the decrypted value stays off the command line
+		if _, ok := utils.ParseSecretReference(value); ok {
+			if opts.maskEnvVars && redactedKeys[key] {
+				cmd = append(cmd, "-e ***")
+				continue
+			}
+			cmd = append(cmd, fmt.Sprintf("-e %s", d.escapeShellArg(key)))
+			continue
+		}
 		if opts.maskEnvVars {
+			if redactedKeys[key] {
+				cmd = append(cmd, "-e ***=***")
+				continue
+			}
 			value = utils.MaskSensitiveValue(value)
 		}
-		cmd = append(cmd, fmt.Sprintf("-e %s=%s", key, value))
+		cmd = append(cmd, fmt.Sprintf("-e %s", d.escapeShellArg(key+"="+value)))
 	}
 
+	// File-sourced values reach docker through the client process environment, so they never
+	// appear on the command line or in the logged command
+	for _, key := range envFileKeys(devEnv) {
+		if opts.maskEnvVars && redactedKeys[key] {
+			cmd = append(cmd, "-e ***")
+			continue
+		}
+		cmd = append(cmd, fmt.Sprintf("-e %s", d.escapeShellArg(key)))
+	}
+
+	cmd = append(cmd, d.buildCACertArgs(isInContainer)...)
+
 	imageName := devEnv.DockerImage
 	aiCommand := d.buildAICommand(devEnv.Type, conv.Content, isInContainer, conv.Task, devEnv, conv)
 
-	cmd = append(cmd, imageName)
+	cmd = append(cmd, d.escapeShellArg(imageName))
 	cmd = append(cmd, aiCommand...)
 
 	return strings.Join(cmd, " ")
 }
 
+// buildCACertArgs makes the custom CA certificate available in the container and points
+// Node.js (the AI tool) and git at it
+func (d *dockerExecutor) buildCACertArgs(isInContainer bool) []string {
+	caCertFile, err := d.configService.GetCustomCACertFile()
+	if err != nil {
+		utils.Warn("Failed to prepare custom CA certificate for container", "error", err)
+		return nil
+	}
+	if caCertFile == "" {
+		return nil
+	}
+
+	var args []string
+	containerPath := "/etc/xsha/ca.crt"
+	if isInContainer {
+		// The workspace volume is mounted at /app and already contains the certificate
+		containerPath = "/app/" + utils.CACertDirName + "/ca.crt"
+	} else {
+		args = append(args, fmt.Sprintf("-v %s:%s:ro", caCertFile, containerPath))
+	}
+
+	return append(args,
+		fmt.Sprintf("-e NODE_EXTRA_CA_CERTS=%s", containerPath),
+		fmt.Sprintf("-e GIT_SSL_CAINFO=%s", containerPath),
+	)
+}
+
+// parseEnvFiles returns the dev environment's env var name to file path map
+func parseEnvFiles(devEnv *database.DevEnvironment) map[string]string {
+	envFiles := make(map[string]string)
+	if devEnv.EnvFiles != "" {
+		if err := json.Unmarshal([]byte(devEnv.EnvFiles), &envFiles); err != nil {
+			utils.Warn("Failed to parse dev environment env files", "devEnvironmentID", devEnv.ID, "error", err)
+		}
+	}
+	return envFiles
+}
+
+func envFileKeys(devEnv *database.DevEnvironment) []string {
+	envFiles := parseEnvFiles(devEnv)
+	keys := make([]string, 0, len(envFiles))
+	for key := range envFiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readEnvFileValues reads file-sourced env vars as KEY=value entries for the docker client process
+func (d *dockerExecutor) readEnvFileValues(devEnv *database.DevEnvironment) ([]string, error) {
+	envFiles := parseEnvFiles(devEnv)
+	values := make([]string, 0, len(envFiles))
+	for _, key := range envFileKeys(devEnv) {
+		value, err := utils.ReadEnvFile(d.config.EnvFilesDir, envFiles[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load env var %s from file: %v", key, err)
+		}
+		values = append(values, fmt.Sprintf("%s=%s", key, value))
+	}
+	return values, nil
+}
+
+// resolveSecretEnvValues decrypts the secrets referenced by env vars as KEY=value entries for
+// the docker client process
+func (d *dockerExecutor) resolveSecretEnvValues(devEnv *database.DevEnvironment) ([]string, error) {
+	envVars := make(map[string]string)
+	if devEnv.EnvVars != "" {
+		json.Unmarshal([]byte(devEnv.EnvVars), &envVars)
+	}
+
+	keys := make([]string, 0, len(envVars))
+	for key, value := range envVars {
+		if _, ok := utils.ParseSecretReference(value); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, _ := utils.ParseSecretReference(envVars[key])
+		value, err := d.secretService.ResolveSecret(name, devEnv.CreatedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret %s for env var %s: %v", name, key, err)
+		}
+		values = append(values, fmt.Sprintf("%s=%s", key, value))
+	}
+	return values, nil
+}
+
+// redactedEnvVarKeys returns env var keys whose names must not appear in logged commands
+func (d *dockerExecutor) redactedEnvVarKeys() map[string]bool {
+	keys, err := d.configService.GetRedactedEnvVarKeys()
+	if err != nil {
+		utils.Warn("Failed to get redacted env var keys", "error", err)
+		return nil
+	}
+
+	redacted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		redacted[key] = true
+	}
+	return redacted
+}
+
+// RedactCommand hides redacted env var keys in a previously logged command, so commands
+// stored before a key was added to the redaction list are covered too
+func (d *dockerExecutor) RedactCommand(command string) string {
+	keys, err := d.configService.GetRedactedEnvVarKeys()
+	if err != nil {
+		utils.Warn("Failed to get redacted env var keys", "error", err)
+		return command
+	}
+	return utils.RedactDockerEnvArgs(command, keys)
+}
+
+// buildUlimitArgs returns the environment's ulimits as sorted name=value pairs
+func buildUlimitArgs(devEnv *database.DevEnvironment) []string {
+	if devEnv.Ulimits == "" {
+		return nil
+	}
+
+	var ulimits map[string]string
+	if err := json.Unmarshal([]byte(devEnv.Ulimits), &ulimits); err != nil {
+		utils.Warn("Failed to parse environment ulimits", "envID", devEnv.ID, "error", err)
+		return nil
+	}
+
+	args := make([]string, 0, len(ulimits))
+	for name, value := range ulimits {
+		args = append(args, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(args)
+	return args
+}
+
+// buildHardeningArgs returns the read-only, user and no-new-privileges flags. Volumes mounted at
+// /app and the session directory are unaffected by --read-only, so tasks can still write there.
+func (d *dockerExecutor) buildHardeningArgs(devEnv *database.DevEnvironment) []string {
+	var args []string
+
+	var mounts []string
+	if devEnv.TmpfsMounts != "" {
+		if err := json.Unmarshal([]byte(devEnv.TmpfsMounts), &mounts); err != nil {
+			utils.Warn("Failed to parse environment tmpfs mounts", "envID", devEnv.ID, "error", err)
+		}
+	}
+	if devEnv.ReadOnlyRootFS {
+		args = append(args, "--read-only")
+		if len(mounts) == 0 {
+			mounts = []string{"/tmp"}
+		}
+	}
+	for _, mount := range mounts {
+		args = append(args, fmt.Sprintf("--tmpfs %s", d.escapeShellArg(mount)))
+	}
+
+	if devEnv.RunAsUser != "" {
+		args = append(args, fmt.Sprintf("--user %s", d.escapeShellArg(devEnv.RunAsUser)))
+	}
+	if devEnv.NoNewPrivileges {
+		args = append(args, "--security-opt no-new-privileges")
+	}
+
+	return args
+}
+
+// buildExtraDockerArgs returns the environment's extra docker run flags. They are checked again
+// here so privileged flags stop being applied once docker_allow_privileged_args is turned off.
+func (d *dockerExecutor) buildExtraDockerArgs(devEnv *database.DevEnvironment) []string {
+	if devEnv.ExtraDockerArgs == "" {
+		return nil
+	}
+
+	var extraArgs []string
+	if err := json.Unmarshal([]byte(devEnv.ExtraDockerArgs), &extraArgs); err != nil {
+		utils.Warn("Failed to parse environment extra docker args", "envID", devEnv.ID, "error", err)
+		return nil
+	}
+
+	var allowPrivileged *bool
+	args := make([]string, 0, len(extraArgs))
+	for _, arg := range extraArgs {
+		_, privileged, err := utils.ParseExtraDockerArg(arg)
+		if err != nil {
+			utils.Warn("Skipping invalid extra docker arg", "envID", devEnv.ID, "error", err)
+			continue
+		}
+		if privileged {
+			if allowPrivileged == nil {
+				allowed, err := d.configService.GetDockerAllowPrivilegedArgs()
+				if err != nil {
+					utils.Warn("Failed to get docker allow privileged args", "error", err)
+				}
+				allowPrivileged = &allowed
+			}
+			if !*allowPrivileged {
+				utils.Warn("Skipping privileged extra docker arg, docker_allow_privileged_args is disabled", "envID", devEnv.ID, "arg", arg)
+				continue
+			}
+		}
+		args = append(args, d.escapeShellArg(arg))
+	}
+	return args
+}
+
+// buildContainerLabels returns labels that let host-level tooling attribute containers to xsha entities
+func (d *dockerExecutor) buildContainerLabels(conv *database.TaskConversation) []string {
+	labels := []string{
+		fmt.Sprintf("xsha.task_id=%d", conv.TaskID),
+		fmt.Sprintf("xsha.conversation_id=%d", conv.ID),
+		fmt.Sprintf("xsha.user=%s", sanitizeLabelValue(conv.CreatedBy)),
+	}
+	if conv.Task != nil && conv.Task.Project != nil {
+		labels = append(labels, fmt.Sprintf("xsha.project=%s", sanitizeLabelValue(conv.Task.Project.Name)))
+	}
+	return labels
+}
+
+// sanitizeLabelValue replaces control characters, newlines included, in a user supplied label
+// value so it can neither break the command line nor forge lines in tooling reading the labels
+func sanitizeLabelValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
 func (d *dockerExecutor) buildAICommand(envType, content string, isInContainer bool, task *database.Task, devEnv *database.DevEnvironment, conv *database.TaskConversation) []string {
 	var baseCommand []string
 
@@ -214,12 +557,12 @@ func (d *dockerExecutor) buildAICommand(envType, content string, isInContainer b
 			"claude",
 			"-p",
 			"--output-format=stream-json",
-			"--dangerously-skip-permissions",
 			"--verbose",
 		}
+		claudeCommand = append(claudeCommand, d.permissionArgs(devEnv)...)
 
 		if task.SessionID != "" {
-			claudeCommand = append(claudeCommand, "-r", task.SessionID)
+			claudeCommand = append(claudeCommand, "-r", d.escapeShellArg(task.SessionID))
 		}
 
 		// Parse env_params to check for model parameter
@@ -228,20 +571,14 @@ func (d *dockerExecutor) buildAICommand(envType, content string, isInContainer b
 			if err := json.Unmarshal([]byte(conv.EnvParams), &envParams); err == nil {
 				if model, exists := envParams["model"]; exists {
 					if modelStr, ok := model.(string); ok && modelStr != "default" {
-						claudeCommand = append(claudeCommand, "--model", modelStr)
+						claudeCommand = append(claudeCommand, "--model", d.escapeShellArg(modelStr))
 					}
 				}
 			}
 		}
 
-		// Add system prompt if project has one
-		if task.Project != nil && task.Project.SystemPrompt != "" {
-			claudeCommand = append(claudeCommand, "--append-system-prompt", d.escapeShellArg(task.Project.SystemPrompt))
-		}
-
-		// Add system prompt if dev environment has one
-		if devEnv != nil && devEnv.SystemPrompt != "" {
-			claudeCommand = append(claudeCommand, "--append-system-prompt", d.escapeShellArg(devEnv.SystemPrompt))
+		for _, systemPrompt := range appendedSystemPrompts(envType, task, devEnv) {
+			claudeCommand = append(claudeCommand, "--append-system-prompt", d.escapeShellArg(systemPrompt))
 		}
 
 		claudeCommand = append(claudeCommand, d.escapeShellArg(content))
@@ -253,139 +590,144 @@ func (d *dockerExecutor) buildAICommand(envType, content string, isInContainer b
 
 		claudeCommandStr := strings.Join(claudeCommand, " ")
 		baseCommand = append(baseCommand, "--command", d.escapeShellArg(claudeCommandStr))
-	case "opencode", "gemini-cli":
-		baseCommand = []string{d.escapeShellArg(content)}
+	case "opencode":
+		// The result parser reads the JSON events, the default output is plain text
+		baseCommand = []string{"--format", "json", d.escapeShellArg(content)}
+	case "gemini-cli":
+		baseCommand = []string{"--output-format", "json", d.escapeShellArg(content)}
 	}
 
 	return baseCommand
 }
 
-func (d *dockerExecutor) BuildCommandForLog(conv *database.TaskConversation, workspacePath string) string {
-	return d.buildDockerCommandCore(conv, workspacePath, buildDockerCommandOptions{
-		containerName:    "",
-		maskEnvVars:      true,
-		includeStdinFlag: false,
-	})
-}
-
-func (d *dockerExecutor) ExecuteWithContext(ctx context.Context, dockerCmd string, execLogID uint) error {
-	if err := d.CheckAvailability(); err != nil {
-		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ Docker unavailable: %v\n", err))
-		return fmt.Errorf("docker unavailable: %v", err)
+// permissionArgs returns the claude permission flags for the environment. Environments without
+// a mode keep skipping permission prompts, restricted ones accept edits and only run the
+// allowed tools, any other tool request is denied since nobody can approve it.
+func (d *dockerExecutor) permissionArgs(devEnv *database.DevEnvironment) []string {
+	if devEnv == nil || devEnv.PermissionMode != database.PermissionModeRestricted {
+		return []string{"--dangerously-skip-permissions"}
 	}
 
-	d.logAppender.AppendLog(execLogID, "✅ Docker availability check passed\n")
-
-	timeout, err := d.configService.GetDockerTimeout()
-	if err != nil {
-		utils.Warn("Failed to get Docker timeout from system config, using default 120 minutes", "error", err)
-		timeout = 120 * time.Minute
+	args := []string{"--permission-mode", "acceptEdits"}
+	if devEnv.AllowedTools != "" {
+		var tools []string
+		if err := json.Unmarshal([]byte(devEnv.AllowedTools), &tools); err != nil {
+			utils.Warn("Failed to parse allowed tools", "envID", devEnv.ID, "error", err)
+		} else if len(tools) > 0 {
+			// One comma separated value, the variadic form would consume the prompt
+			args = append(args, "--allowedTools", d.escapeShellArg(strings.Join(tools, ",")))
+		}
 	}
+	return args
+}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "sh", "-c", dockerCmd)
+// appendedSystemPrompts returns the project and dev environment system prompts, in the order
+// they are appended to the AI tool's own. Only claude-code takes them.
+func appendedSystemPrompts(envType string, task *database.Task, devEnv *database.DevEnvironment) []string {
+	if envType != "claude-code" {
+		return nil
+	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
+	var prompts []string
+	if task != nil && task.Project != nil && task.Project.SystemPrompt != "" {
+		prompts = append(prompts, task.Project.SystemPrompt)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return err
+	if devEnv != nil && devEnv.SystemPrompt != "" {
+		prompts = append(prompts, devEnv.SystemPrompt)
 	}
+	return prompts
+}
 
-	if err := cmd.Start(); err != nil {
-		return err
+// BuildEffectivePrompt returns everything the AI command passes as prompt for the conversation,
+// the appended system prompts followed by the conversation content
+func (d *dockerExecutor) BuildEffectivePrompt(conv *database.TaskConversation) string {
+	var devEnv *database.DevEnvironment
+	envType := ""
+	if conv.Task != nil && conv.Task.DevEnvironment != nil {
+		devEnv = conv.Task.DevEnvironment
+		envType = devEnv.Type
 	}
 
-	var stderrLines []string
-	var mu sync.Mutex
-
-	// Create batch log appenders for better performance with large logs
-	stdoutBatcher := NewBatchLogAppender(execLogID, d.logAppender)
-	stderrBatcher := NewBatchLogAppender(execLogID, d.logAppender)
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		defer stdoutBatcher.Close()
-		d.readPipeWithBatcher(stdout, stdoutBatcher, "STDOUT")
-	}()
+	systemPrompts := appendedSystemPrompts(envType, conv.Task, devEnv)
+	if len(systemPrompts) == 0 {
+		return conv.Content
+	}
 
-	go func() {
-		defer wg.Done()
-		defer stderrBatcher.Close()
-		d.readPipeWithErrorCaptureAndBatcher(stderr, stderrBatcher, "STDERR", &stderrLines, &mu)
-	}()
+	var prompt strings.Builder
+	for _, systemPrompt := range systemPrompts {
+		prompt.WriteString("[Appended system prompt]\n")
+		prompt.WriteString(systemPrompt)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString("[Prompt]\n")
+	prompt.WriteString(conv.Content)
+	return prompt.String()
+}
 
-	err = cmd.Wait()
+func (d *dockerExecutor) BuildCommandForLog(conv *database.TaskConversation, workspacePath string) string {
+	return d.buildDockerCommandCore(conv, workspacePath, buildDockerCommandOptions{
+		containerName:    "",
+		maskEnvVars:      true,
+		includeStdinFlag: false,
+	})
+}
 
-	// Wait for all log processing to complete before returning
-	wg.Wait()
-	if err != nil && len(stderrLines) > 0 {
-		mu.Lock()
-		errorLines := make([]string, len(stderrLines))
-		copy(errorLines, stderrLines)
-		mu.Unlock()
+// filterStderrErrorLines keeps the stderr lines that match the configured error patterns, so
+// warnings a tool prints on stderr are not reported as the cause of a failure. Without
+// patterns every line is kept.
+func (d *dockerExecutor) filterStderrErrorLines(stderrLines []string) []string {
+	patterns, err := d.configService.GetStderrErrorPatterns()
+	if err != nil {
+		utils.Warn("Failed to get stderr error patterns, using all stderr lines", "error", err)
+		patterns = nil
+	}
 
-		if len(errorLines) > 0 {
-			errorMsg := strings.Join(errorLines, "\n")
-			if len(errorMsg) > 1000 {
-				errorMsg = errorMsg[:1000] + "..."
+	var errorLines []string
+	for _, line := range stderrLines {
+		if len(patterns) == 0 {
+			errorLines = append(errorLines, line)
+			continue
+		}
+		for _, pattern := range patterns {
+			if pattern.MatchString(line) {
+				errorLines = append(errorLines, line)
+				break
 			}
-			return fmt.Errorf("%s", errorMsg)
 		}
 	}
-	return err
+	return errorLines
 }
 
-func (d *dockerExecutor) readPipeWithBatcher(pipe interface{}, batcher *BatchLogAppender, prefix string) {
-	scanner := bufio.NewScanner(pipe.(interface{ Read([]byte) (int, error) }))
-	// Set larger buffer to handle large log outputs (1MB buffer)
-	const maxCapacity = 1024 * 1024 // 1MB
-	buf := make([]byte, 0, 64*1024) // Start with 64KB
-	scanner.Buffer(buf, maxCapacity)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
+func (d *dockerExecutor) readPipeWithBatcher(pipe io.Reader, batcher *BatchLogAppender, prefix string) {
+	maxLineBytes := d.config.LogLineMaxBytes
+	err := readLogLines(pipe, maxLineBytes, func(line string, originalLength int) bool {
 		// Protect against extremely large log lines
-		if len(line) > maxCapacity {
-			line = line[:maxCapacity-3] + "..."
-			utils.Warn("Truncated extremely large log line", "prefix", prefix, "original_length", len(scanner.Text()))
+		if originalLength > maxLineBytes {
+			utils.Warn("Truncated extremely large log line", "prefix", prefix, "original_length", originalLength)
 		}
+		line = normalizeLogLine(line)
 
 		logLine := fmt.Sprintf("[%s] %s: %s\n", utils.Now().Format("15:04:05"), prefix, line)
 		batcher.AppendLog(logLine)
-	}
+		return true
+	})
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
+	// Check for reader errors
+	if err != nil {
 		errorLine := fmt.Sprintf("[%s] %s: ERROR - Scanner failed: %v\n", utils.Now().Format("15:04:05"), prefix, err)
 		batcher.AppendLog(errorLine)
 		utils.Error("Log scanner failed", "prefix", prefix, "error", err)
 	}
 }
 
-func (d *dockerExecutor) readPipeWithErrorCaptureAndBatcher(pipe interface{}, batcher *BatchLogAppender, prefix string, errorLines *[]string, mu *sync.Mutex) {
-	scanner := bufio.NewScanner(pipe.(interface{ Read([]byte) (int, error) }))
-	// Set larger buffer to handle large log outputs (1MB buffer)
-	const maxCapacity = 1024 * 1024 // 1MB
-	buf := make([]byte, 0, 64*1024) // Start with 64KB
-	scanner.Buffer(buf, maxCapacity)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
+func (d *dockerExecutor) readPipeWithErrorCaptureAndBatcher(pipe io.Reader, batcher *BatchLogAppender, prefix string, errorLines *[]string, mu *sync.Mutex) {
+	maxLineBytes := d.config.LogLineMaxBytes
+	err := readLogLines(pipe, maxLineBytes, func(line string, originalLength int) bool {
 		// Protect against extremely large log lines
-		if len(line) > maxCapacity {
-			line = line[:maxCapacity-3] + "..."
-			utils.Warn("Truncated extremely large log line", "prefix", prefix, "original_length", len(scanner.Text()))
+		if originalLength > maxLineBytes {
+			utils.Warn("Truncated extremely large log line", "prefix", prefix, "original_length", originalLength)
 		}
+		line = normalizeLogLine(line)
 
 		logLine := fmt.Sprintf("[%s] %s: %s\n", utils.Now().Format("15:04:05"), prefix, line)
 		batcher.AppendLog(logLine)
@@ -395,10 +737,11 @@ func (d *dockerExecutor) readPipeWithErrorCaptureAndBatcher(pipe interface{}, ba
 			*errorLines = append(*errorLines, line)
 			mu.Unlock()
 		}
-	}
+		return true
+	})
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
+	// Check for reader errors
+	if err != nil {
 		errorLine := fmt.Sprintf("[%s] %s: ERROR - Scanner failed: %v\n", utils.Now().Format("15:04:05"), prefix, err)
 		batcher.AppendLog(errorLine)
 		utils.Error("Log scanner failed", "prefix", prefix, "error", err)
@@ -412,9 +755,95 @@ func (d *dockerExecutor) readPipeWithErrorCaptureAndBatcher(pipe interface{}, ba
 	}
 }
 
+// normalizeLogLine makes a scanned line safe to persist and stream. Carriage returns are
+// treated like a terminal would (the last overwrite wins) and invalid UTF-8 bytes are
+// hex-escaped so the stored log is always valid text.
+func normalizeLogLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if idx := strings.LastIndex(line, "\r"); idx >= 0 {
+		line = line[idx+1:]
+	}
+
+	if utf8.ValidString(line) {
+		return line
+	}
+
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, "\\x%02x", line[i])
+		} else {
+			b.WriteString(line[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// containerNamePrefix starts the name of every container run for a conversation
+const containerNamePrefix = "xsha-task-"
+
+var containerNameRegex = regexp.MustCompile(`^xsha-task-(\d+)-conv-(\d+)$`)
+
 // generateContainerName creates a unique container name for the conversation
 func (d *dockerExecutor) generateContainerName(conv *database.TaskConversation) string {
-	return fmt.Sprintf("xsha-task-%d-conv-%d", conv.TaskID, conv.ID)
+	return fmt.Sprintf("%s%d-conv-%d", containerNamePrefix, conv.TaskID, conv.ID)
+}
+
+// parseContainerName returns the task and conversation IDs of a conversation container name
+func parseContainerName(name string) (uint, uint, bool) {
+	matches := containerNameRegex.FindStringSubmatch(name)
+	if len(matches) != 3 {
+		return 0, 0, false
+	}
+	taskID, err := strconv.ParseUint(matches[1], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	conversationID, err := strconv.ParseUint(matches[2], 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(taskID), uint(conversationID), true
+}
+
+// ListContainers lists the conversation containers known to the runtime, stopped ones included
+func (d *dockerExecutor) ListContainers() ([]services.ManagedContainer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, d.containerRuntime(), "ps", "-a",
+		"--filter", "name="+containerNamePrefix,
+		"--format", "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Status}}\t{{.CreatedAt}}")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	containers := []services.ManagedContainer{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		// The name filter matches substrings, only keep names this executor generated
+		taskID, conversationID, ok := parseContainerName(fields[1])
+		if !ok {
+			continue
+		}
+		containers = append(containers, services.ManagedContainer{
+			ID:             fields[0],
+			Name:           fields[1],
+			Image:          fields[2],
+			Status:         fields[3],
+			CreatedAt:      fields[4],
+			TaskID:         taskID,
+			ConversationID: conversationID,
+		})
+	}
+	return containers, nil
 }
 
 // BuildCommandWithContainerName builds the docker command with a specific container name
@@ -427,30 +856,138 @@ func (d *dockerExecutor) BuildCommandWithContainerName(conv *database.TaskConver
 	})
 }
 
+// loginRegistry logs in to the configured private registry. The password is passed
+// on stdin so it never appears in the process list or the execution log.
+func (d *dockerExecutor) loginRegistry(ctx context.Context, execLogID uint) error {
+	registryConfig, err := d.configService.GetDockerRegistryConfig()
+	if err != nil {
+		utils.Warn("Failed to get registry config, skipping registry login", "error", err)
+		return nil
+	}
+	if !registryConfig.Enabled() {
+		return nil
+	}
+
+	d.logAppender.AppendLog(execLogID, fmt.Sprintf("🔐 Logging in to registry %s as %s\n", registryConfig.URL, registryConfig.Username))
+
+	cmd := exec.CommandContext(ctx, d.containerRuntime(), "login", registryConfig.URL, "-u", registryConfig.Username, "--password-stdin")
+	cmd.Stdin = strings.NewReader(registryConfig.Password)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		detail := strings.ReplaceAll(strings.TrimSpace(string(output)), registryConfig.Password, "****")
+		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ %s: %s\n", i18n.T("en-US", "docker.registry_login_failed", registryConfig.URL), detail))
+		return fmt.Errorf("registry login failed for %s: %s", registryConfig.URL, detail)
+	}
+
+	d.logAppender.AppendLog(execLogID, "✅ Registry login succeeded\n")
+	return nil
+}
+
+// pullImageIfMissing pulls the image before the container starts so pull progress shows up
+// in the execution log. The pull is bound to ctx, so cancelling the conversation aborts it.
+func (d *dockerExecutor) pullImageIfMissing(ctx context.Context, image string, execLogID uint) error {
+	if image == "" {
+		return nil
+	}
+
+	runtime := d.containerRuntime()
+	if err := exec.CommandContext(ctx, runtime, "image", "inspect", image).Run(); err == nil {
+		d.logAppender.AppendLog(execLogID, fmt.Sprintf("✅ Image %s already present locally\n", image))
+		return nil
+	}
+
+	d.logAppender.AppendLog(execLogID, fmt.Sprintf("📥 Pulling image: %s\n", image))
+
+	cmd := exec.CommandContext(ctx, runtime, "pull", image)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start image pull: %v", err)
+	}
+
+	batcher := NewBatchLogAppender(execLogID, d.logAppender)
+	d.readPipeWithBatcher(stdout, batcher, "PULL")
+	batcher.Close()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		detail := strings.TrimSpace(stderr.String())
+		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ Failed to pull image %s: %s\n", image, detail))
+		return fmt.Errorf("failed to pull image %s: %s", image, detail)
+	}
+
+	d.logAppender.AppendLog(execLogID, fmt.Sprintf("✅ Image %s pulled\n", image))
+	return nil
+}
+
+// resolveExecutionTimeout prefers the task-level timeout over the global docker timeout
+func (d *dockerExecutor) resolveExecutionTimeout(conv *database.TaskConversation) time.Duration {
+	if conv.Task != nil && conv.Task.ExecutionTimeoutSeconds > 0 {
+		return time.Duration(conv.Task.ExecutionTimeoutSeconds) * time.Second
+	}
+
+	timeout, err := d.configService.GetDockerTimeout()
+	if err != nil {
+		utils.Warn("Failed to get Docker timeout from system config, using default 120 minutes", "error", err)
+		return 120 * time.Minute
+	}
+	return timeout
+}
+
 // ExecuteWithContainerTracking executes docker command with container tracking for proper cleanup
 func (d *dockerExecutor) ExecuteWithContainerTracking(ctx context.Context, conv *database.TaskConversation, workspacePath string, execLogID uint) (string, error) {
 	if err := d.CheckAvailability(); err != nil {
 		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ Docker unavailable: %v\n", err))
+		utils.RecordDockerUnavailable()
 		return "", fmt.Errorf("docker unavailable: %v", err)
 	}
 
 	d.logAppender.AppendLog(execLogID, "✅ Docker availability check passed\n")
 
-	timeout, err := d.configService.GetDockerTimeout()
-	if err != nil {
-		utils.Warn("Failed to get Docker timeout from system config, using default 120 minutes", "error", err)
-		timeout = 120 * time.Minute
+	if err := d.loginRegistry(ctx, execLogID); err != nil {
+		return "", err
 	}
 
+	timeout := d.resolveExecutionTimeout(conv)
+
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	pullCtx, pullSpan := utils.StartSpan(ctx, "docker.pull", attribute.String("xsha.docker.image", conv.Task.DevEnvironment.DockerImage))
+	err := d.pullImageIfMissing(pullCtx, conv.Task.DevEnvironment.DockerImage, execLogID)
+	utils.EndSpan(pullSpan, err)
+	if err != nil {
+		return "", err
+	}
+
 	containerName := d.generateContainerName(conv)
 	dockerCmd := d.BuildCommandWithContainerName(conv, workspacePath)
 
+	envFileValues, err := d.readEnvFileValues(conv.Task.DevEnvironment)
+	if err != nil {
+		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ %v\n", err))
+		return "", err
+	}
+	secretValues, err := d.resolveSecretEnvValues(conv.Task.DevEnvironment)
+	if err != nil {
+		d.logAppender.AppendLog(execLogID, fmt.Sprintf("❌ %v\n", err))
+		return "", err
+	}
+	envFileValues = append(envFileValues, secretValues...)
+
 	d.logAppender.AppendLog(execLogID, fmt.Sprintf("🐳 Starting container: %s\n", containerName))
 
 	cmd := exec.CommandContext(ctx, "sh", "-c", dockerCmd)
+	if len(envFileValues) > 0 {
+		cmd.Env = append(os.Environ(), envFileValues...)
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -461,10 +998,18 @@ func (d *dockerExecutor) ExecuteWithContainerTracking(ctx context.Context, conv
 		return "", err
 	}
 
+	_, runSpan := utils.StartSpan(ctx, "docker.run", attribute.String("xsha.docker.container", containerName))
 	if err := cmd.Start(); err != nil {
+		utils.EndSpan(runSpan, err)
 		return "", err
 	}
 
+	stopMonitor := make(chan struct{})
+	peakCh := make(chan containerResourcePeak, 1)
+	go func() {
+		peakCh <- d.monitorContainerResources(ctx, containerName, stopMonitor)
+	}()
+
 	var stderrLines []string
 	var mu sync.Mutex
 
@@ -491,6 +1036,10 @@ func (d *dockerExecutor) ExecuteWithContainerTracking(ctx context.Context, conv
 
 	// Wait for all log processing to complete before updating status
 	wg.Wait()
+	utils.EndSpan(runSpan, err)
+
+	close(stopMonitor)
+	d.recordResourcePeak(execLogID, <-peakCh)
 
 	// If context was cancelled, ensure container cleanup
 	select {
@@ -507,8 +1056,7 @@ func (d *dockerExecutor) ExecuteWithContainerTracking(ctx context.Context, conv
 
 	if err != nil && len(stderrLines) > 0 {
 		mu.Lock()
-		errorLines := make([]string, len(stderrLines))
-		copy(errorLines, stderrLines)
+		errorLines := d.filterStderrErrorLines(stderrLines)
 		mu.Unlock()
 
 		if len(errorLines) > 0 {
@@ -522,13 +1070,40 @@ func (d *dockerExecutor) ExecuteWithContainerTracking(ctx context.Context, conv
 	return containerName, err
 }
 
+// recordResourcePeak stores the peak usage on the execution log, nothing is recorded when no
+// sample succeeded, e.g. for runs shorter than the sampling interval
+func (d *dockerExecutor) recordResourcePeak(execLogID uint, peak containerResourcePeak) {
+	if peak.Samples == 0 {
+		return
+	}
+
+	d.logAppender.AppendLog(execLogID, fmt.Sprintf("📊 Peak resource usage: CPU %.1f%%, memory %s\n", peak.CPUPercent, formatMemorySize(peak.MemoryBytes)))
+
+	updates := map[string]interface{}{
+		"peak_cpu_percent":  peak.CPUPercent,
+		"peak_memory_bytes": peak.MemoryBytes,
+	}
+	if err := d.execLogRepo.UpdateMetadata(execLogID, updates); err != nil {
+		utils.Error("Failed to record container resource usage", "execLogID", execLogID, "error", err)
+	}
+}
+
 // StopAndRemoveContainer stops and removes a Docker container by name or ID
 func (d *dockerExecutor) StopAndRemoveContainer(containerID string) error {
-	// First try to stop the container gracefully
-	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	stopTimeout, err := d.configService.GetDockerStopTimeout()
+	if err != nil {
+		utils.Warn("Failed to get docker stop timeout, using default 10 seconds", "error", err)
+		stopTimeout = 10 * time.Second
+	}
+
+	// First try to stop the container gracefully, the runtime kills it once the grace period
+	// ends, the extra time lets the stop command return before its context expires
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout+10*time.Second)
 	defer stopCancel()
 
-	stopCmd := exec.CommandContext(stopCtx, "docker", "stop", containerID)
+	runtime := d.containerRuntime()
+	graceSeconds := int(math.Ceil(stopTimeout.Seconds()))
+	stopCmd := exec.CommandContext(stopCtx, runtime, "stop", "-t", strconv.Itoa(graceSeconds), containerID)
 	if err := stopCmd.Run(); err != nil {
 		utils.Warn("Failed to stop container gracefully, will try force removal", "container", containerID, "error", err)
 	}
@@ -537,10 +1112,10 @@ func (d *dockerExecutor) StopAndRemoveContainer(containerID string) error {
 	removeCtx, removeCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer removeCancel()
 
-	removeCmd := exec.CommandContext(removeCtx, "docker", "rm", "-f", containerID)
+	removeCmd := exec.CommandContext(removeCtx, runtime, "rm", "-f", containerID)
 	if err := removeCmd.Run(); err != nil {
 		// Check if container doesn't exist (which is fine)
-		if strings.Contains(err.Error(), "No such container") {
+		if strings.Contains(err.Error(), "No such container") || strings.Contains(err.Error(), "no such container") {
 			utils.Info("Container already removed or doesn't exist", "container", containerID)
 			return nil
 		}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+	"xsha-backend/config"
+)
+
+type recordingLogAppender struct {
+	mu   sync.Mutex
+	logs strings.Builder
+}
+
+func (r *recordingLogAppender) AppendLog(execLogID uint, content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs.WriteString(content)
+}
+
+func TestNormalizeLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain ascii", in: "building image", want: "building image"},
+		{name: "valid utf-8", in: "构建完成 ✓", want: "构建完成 ✓"},
+		{name: "crlf line ending", in: "done\r", want: "done"},
+		{name: "progress overwrite", in: "10%\r50%\r100%", want: "100%"},
+		{name: "latin-1 byte", in: "caf\xe9", want: `caf\xe9`},
+		{name: "mixed utf-8 and latin-1", in: "résumé caf\xe9 构建", want: `résumé caf\xe9 构建`},
+		{name: "truncated multibyte rune", in: "ok \xe6\x9e", want: `ok \xe6\x9e`},
+		{name: "invalid bytes after overwrite", in: "\xff\xfe\rfin\xff\r", want: `fin\xff`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeLogLine(tt.in)
+			if got != tt.want {
+				t.Errorf("normalizeLogLine(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("normalizeLogLine(%q) returned invalid UTF-8 %q", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestReadPipeWithBatcherMixedEncodings(t *testing.T) {
+	appender := &recordingLogAppender{}
+	d := &dockerExecutor{config: &config.Config{LogLineMaxBytes: 1024}}
+
+	input := "utf-8 行\nlatin-1 caf\xe9\r\nprogress 1%\rprogress 100%\nbroken \xe6\x9e\n"
+	batcher := NewBatchLogAppender(1, appender)
+	d.readPipeWithBatcher(strings.NewReader(input), batcher, "STDOUT")
+	batcher.Close()
+
+	logs := appender.logs.String()
+	if !utf8.ValidString(logs) {
+		t.Fatalf("appended logs are not valid UTF-8: %q", logs)
+	}
+	for _, want := range []string{
+		"STDOUT: utf-8 行\n",
+		`STDOUT: latin-1 caf\xe9` + "\n",
+		"STDOUT: progress 100%\n",
+		`STDOUT: broken \xe6\x9e` + "\n",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("appended logs missing %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "progress 1%") {
+		t.Errorf("appended logs kept overwritten progress output:\n%s", logs)
+	}
+}
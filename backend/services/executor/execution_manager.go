@@ -2,19 +2,43 @@ package executor
 
 import (
 	"context"
+	"math"
 	"sync"
+	"xsha-backend/database"
 )
 
+// memoryPerWeightUnitMB is the memory limit that counts as much as one CPU when deriving an
+// environment's weight
+const memoryPerWeightUnitMB = 2048
+
+// maxCapacitySkips is how many scheduler ticks a pending conversation may be passed over for
+// lack of capacity before the conversations behind it are held back until it fits, so a
+// steady stream of light executions cannot starve a heavy one
+const maxCapacitySkips = 3
+
 type ExecutionInfo struct {
 	CancelFunc  context.CancelFunc
 	ContainerID string
+	ProjectID   uint
+	Weight      int
 }
 
+// ExecutionManager tracks running conversations. maxConcurrency is a capacity budget in weight
+// units, each execution uses the weight of its environment, so a light environment counts as 1
+// and heavier ones take more of the budget.
 type ExecutionManager struct {
 	runningConversations map[uint]*ExecutionInfo
 	maxConcurrency       int
 	currentCount         int
-	mu                   sync.RWMutex
+	currentWeight        int
+	// projectCounts tracks running conversations per project for per-project limits
+	projectCounts map[uint]int
+	// waitQueue holds pending conversations skipped because of the concurrency limit,
+	// kept in the order they were returned by the pending query
+	waitQueue []uint
+	// capacitySkips counts the ticks each pending conversation was passed over for lack of capacity
+	capacitySkips map[uint]int
+	mu            sync.RWMutex
 }
 
 func NewExecutionManager(maxConcurrency int) *ExecutionManager {
@@ -24,28 +48,65 @@ func NewExecutionManager(maxConcurrency int) *ExecutionManager {
 	return &ExecutionManager{
 		runningConversations: make(map[uint]*ExecutionInfo),
 		maxConcurrency:       maxConcurrency,
+		projectCounts:        make(map[uint]int),
+		capacitySkips:        make(map[uint]int),
 	}
 }
 
-func (em *ExecutionManager) CanExecute() bool {
+// HasCapacity reports whether any of the capacity budget is left
+func (em *ExecutionManager) HasCapacity() bool {
 	em.mu.RLock()
 	defer em.mu.RUnlock()
-	return em.currentCount < em.maxConcurrency
+	return em.currentWeight < em.maxConcurrency
 }
 
-func (em *ExecutionManager) AddExecution(conversationID uint, cancelFunc context.CancelFunc) bool {
+// HasCapacityFor reports whether an execution of the given weight fits the budget next to the
+// running executions plus reserved weight that was dispatched but is not registered yet
+func (em *ExecutionManager) HasCapacityFor(weight, reserved int) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.fitsLocked(em.currentWeight+reserved, weight)
+}
+
+// fitsLocked allows an execution heavier than the whole budget when nothing else uses it,
+// otherwise it could never start
+func (em *ExecutionManager) fitsLocked(usedWeight, weight int) bool {
+	return usedWeight == 0 || usedWeight+weight <= em.maxConcurrency
+}
+
+// CanExecute checks both the capacity budget and the project's limit. A projectLimit
+// of 0 or less means the project is only bound by the budget.
+func (em *ExecutionManager) CanExecute(projectID uint, projectLimit, weight int) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.canExecuteLocked(projectID, projectLimit, weight)
+}
+
+func (em *ExecutionManager) canExecuteLocked(projectID uint, projectLimit, weight int) bool {
+	if !em.fitsLocked(em.currentWeight, weight) {
+		return false
+	}
+	return projectLimit <= 0 || em.projectCounts[projectID] < projectLimit
+}
+
+func (em *ExecutionManager) AddExecution(conversationID, projectID uint, projectLimit, weight int, cancelFunc context.CancelFunc) bool {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	if em.currentCount >= em.maxConcurrency {
+	if !em.canExecuteLocked(projectID, projectLimit, weight) {
 		return false
 	}
 
 	em.runningConversations[conversationID] = &ExecutionInfo{
 		CancelFunc:  cancelFunc,
 		ContainerID: "", // Will be set later
+		ProjectID:   projectID,
+		Weight:      weight,
 	}
 	em.currentCount++
+	em.currentWeight += weight
+	em.projectCounts[projectID]++
+	delete(em.capacitySkips, conversationID)
 	return true
 }
 
@@ -53,12 +114,27 @@ func (em *ExecutionManager) RemoveExecution(conversationID uint) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	if _, exists := em.runningConversations[conversationID]; exists {
+	if execInfo, exists := em.runningConversations[conversationID]; exists {
 		delete(em.runningConversations, conversationID)
 		em.currentCount--
+		em.currentWeight -= execInfo.Weight
+		em.releaseProjectLocked(execInfo.ProjectID)
 	}
 }
 
+func (em *ExecutionManager) releaseProjectLocked(projectID uint) {
+	em.projectCounts[projectID]--
+	if em.projectCounts[projectID] <= 0 {
+		delete(em.projectCounts, projectID)
+	}
+}
+
+func (em *ExecutionManager) GetProjectRunningCount(projectID uint) int {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.projectCounts[projectID]
+}
+
 func (em *ExecutionManager) SetContainerID(conversationID uint, containerID string) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
@@ -87,6 +163,8 @@ func (em *ExecutionManager) CancelExecution(conversationID uint) (context.Cancel
 		containerID := execInfo.ContainerID
 		delete(em.runningConversations, conversationID)
 		em.currentCount--
+		em.currentWeight -= execInfo.Weight
+		em.releaseProjectLocked(execInfo.ProjectID)
 		return cancelFunc, containerID
 	}
 	return nil, ""
@@ -98,9 +176,97 @@ func (em *ExecutionManager) GetRunningCount() int {
 	return em.currentCount
 }
 
+// GetRunningWeight returns how much of the capacity budget running executions use
+func (em *ExecutionManager) GetRunningWeight() int {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.currentWeight
+}
+
 func (em *ExecutionManager) IsRunning(conversationID uint) bool {
 	em.mu.RLock()
 	defer em.mu.RUnlock()
 	_, exists := em.runningConversations[conversationID]
 	return exists
 }
+
+// SetWaitQueue replaces the wait queue with the given conversation IDs
+func (em *ExecutionManager) SetWaitQueue(conversationIDs []uint) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.waitQueue = append([]uint(nil), conversationIDs...)
+}
+
+// DequeueWaiting pops the next queued conversation ID
+func (em *ExecutionManager) DequeueWaiting() (uint, bool) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if len(em.waitQueue) == 0 {
+		return 0, false
+	}
+
+	conversationID := em.waitQueue[0]
+	em.waitQueue = em.waitQueue[1:]
+	return conversationID, true
+}
+
+// RecordCapacitySkip counts a tick the conversation was passed over for lack of capacity and
+// reports whether it is starved, the conversations behind it should then wait for it
+func (em *ExecutionManager) RecordCapacitySkip(conversationID uint) bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.capacitySkips[conversationID]++
+	return em.capacitySkips[conversationID] >= maxCapacitySkips
+}
+
+// IsStarved reports whether the conversation was passed over for lack of capacity often
+// enough that the conversations behind it wait for it
+func (em *ExecutionManager) IsStarved(conversationID uint) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.capacitySkips[conversationID] >= maxCapacitySkips
+}
+
+// RetainCapacitySkips forgets the skip counts of conversations that are no longer pending
+func (em *ExecutionManager) RetainCapacitySkips(pendingIDs []uint) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	pending := make(map[uint]bool, len(pendingIDs))
+	for _, id := range pendingIDs {
+		pending[id] = true
+	}
+	for id := range em.capacitySkips {
+		if !pending[id] {
+			delete(em.capacitySkips, id)
+		}
+	}
+}
+
+func (em *ExecutionManager) GetWaitQueueLength() int {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return len(em.waitQueue)
+}
+
+// EnvironmentWeight returns how much of the capacity budget an execution in the environment
+// uses: its explicit weight, otherwise one unit per CPU or per 2 GB of memory, whichever is
+// larger. Conversations without an environment weigh 1.
+func EnvironmentWeight(devEnv *database.DevEnvironment) int {
+	if devEnv == nil {
+		return 1
+	}
+	if devEnv.ConcurrencyWeight > 0 {
+		return devEnv.ConcurrencyWeight
+	}
+
+	weight := int(math.Ceil(devEnv.CPULimit))
+	if memoryWeight := int(math.Ceil(float64(devEnv.MemoryLimit) / memoryPerWeightUnitMB)); memoryWeight > weight {
+		weight = memoryWeight
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	return weight
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"regexp"
+	"xsha-backend/database"
+)
+
+// failureLogTailBytes limits how much of the end of the execution log is inspected
+const failureLogTailBytes = 4096
+
+// failureRules are checked in order, so more specific causes come before generic ones,
+// e.g. "pull access denied" is a missing image rather than an auth failure
+var failureRules = []struct {
+	category database.FailureCategory
+	pattern  *regexp.Regexp
+}{
+	{database.FailureCategoryImageMissing, regexp.MustCompile(`(?i)unable to find image|no such image|pull access denied|manifest unknown|manifest for .* not found|image not found`)},
+	{database.FailureCategoryAuth, regexp.MustCompile(`(?i)authentication failed|permission denied \(publickey|could not read username|invalid api key|invalid bearer token|unauthorized|\b401\b|\b403\b|oauth token has expired`)},
+	{database.FailureCategoryOOM, regexp.MustCompile(`(?i)out of memory|oomkilled|cannot allocate memory|heap out of memory|exit status 137`)},
+	{database.FailureCategoryNetwork, regexp.MustCompile(`(?i)could not resolve host|connection refused|connection reset|network is unreachable|no route to host|tls handshake|i/o timeout|temporary failure in name resolution|econnreset|enotfound`)},
+	{database.FailureCategoryTimeout, regexp.MustCompile(`(?i)context deadline exceeded|timed out|timeout`)},
+	{database.FailureCategoryGit, regexp.MustCompile(`(?i)failed to clone|failed to push|work branch|failed to commit|not a git repository|merge conflict|detached head`)},
+}
+
+// ClassifyFailure maps the error message and the end of the execution log of a failed
+// conversation to a failure category
+func ClassifyFailure(errorMessage, executionLogs string) database.FailureCategory {
+	if len(executionLogs) > failureLogTailBytes {
+		executionLogs = executionLogs[len(executionLogs)-failureLogTailBytes:]
+	}
+
+	// The error message is the most precise signal, so it is matched on its own first
+	for _, text := range []string{errorMessage, executionLogs} {
+		if text == "" {
+			continue
+		}
+		for _, rule := range failureRules {
+			if rule.pattern.MatchString(text) {
+				return rule.category
+			}
+		}
+	}
+
+	return database.FailureCategoryUnknown
+}
@@ -3,25 +3,30 @@ package executor
 import (
 	"context"
 	"xsha-backend/database"
+	"xsha-backend/services"
 )
 
 type DockerExecutor interface {
 	CheckAvailability() error
 	BuildCommandForLog(conv *database.TaskConversation, workspacePath string) string
-	ExecuteWithContext(ctx context.Context, dockerCmd string, execLogID uint) error
+	BuildEffectivePrompt(conv *database.TaskConversation) string
+	RedactCommand(command string) string
 	ExecuteWithContainerTracking(ctx context.Context, conv *database.TaskConversation, workspacePath string, execLogID uint) (string, error)
 	StopAndRemoveContainer(containerID string) error
+	ListContainers() ([]services.ManagedContainer, error)
 }
 
 type ResultParser interface {
 	ParseAndCreate(conv *database.TaskConversation, execLog *database.TaskExecutionLog)
-	ParseFromLogs(executionLogs string) (map[string]interface{}, error)
+	ParseFromLogs(envType, executionLogs string) (map[string]interface{}, error)
+	ParseToolVersion(executionLogs string) string
+	ParseTimeline(executionLogs string) []TimelineEvent
 }
 
 type WorkspaceCleaner interface {
 	CleanupOnFailure(taskID uint, workspacePath string) error
 	CleanupOnCancel(taskID uint, workspacePath string) error
-	CleanupBeforeExecution(taskID uint, workspacePath string) error
+	CleanupBeforeExecution(taskID uint, workspacePath string, keepChanges bool) error
 }
 
 type ConversationStateManager interface {
@@ -29,6 +34,7 @@ type ConversationStateManager interface {
 	Rollback(conv *database.TaskConversation, errorMessage string)
 	RollbackToState(conv *database.TaskConversation, execLog *database.TaskExecutionLog,
 		status database.ConversationStatus, errorMessage string)
+	FailInterrupted(conv *database.TaskConversation, errorMessage string) bool
 }
 
 type LogAppender interface {
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"bufio"
+	"io"
+)
+
+// defaultMaxLogLineBytes is used when no positive limit is configured
+const defaultMaxLogLineBytes = 10 * 1024 * 1024
+
+// readLogLines reads newline-delimited output of any length. Lines longer than
+// maxLineBytes are truncated instead of aborting the read, so the pipe is always
+// drained and later lines are not lost. handle receives the (possibly truncated)
+// line and its original length; returning false stops reading.
+func readLogLines(reader io.Reader, maxLineBytes int, handle func(line string, originalLength int) bool) error {
+	if maxLineBytes <= 0 {
+		maxLineBytes = defaultMaxLogLineBytes
+	}
+
+	br := bufio.NewReaderSize(reader, 64*1024)
+	var buf []byte
+	originalLength := 0
+
+	flush := func() bool {
+		line := string(buf)
+		if originalLength > maxLineBytes {
+			line += "..."
+		}
+		length := originalLength
+		buf = buf[:0]
+		originalLength = 0
+		return handle(line, length)
+	}
+
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			if len(buf) > 0 || originalLength > 0 {
+				flush()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		originalLength += len(chunk)
+		if remaining := maxLineBytes - len(buf); remaining > 0 {
+			if len(chunk) > remaining {
+				chunk = chunk[:remaining]
+			}
+			buf = append(buf, chunk...)
+		}
+
+		if isPrefix {
+			continue
+		}
+
+		if !flush() {
+			return nil
+		}
+	}
+}
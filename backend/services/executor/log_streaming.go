@@ -1,15 +1,17 @@
 package executor
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
+	"xsha-backend/config"
 	"xsha-backend/database"
 	"xsha-backend/repository"
+	"xsha-backend/services"
 	"xsha-backend/utils"
 )
 
@@ -24,23 +26,49 @@ type LogStreamingService interface {
 
 	// IsConversationRunning checks if a conversation is currently running
 	IsConversationRunning(conversationID uint) (bool, error)
+
+	// StreamTaskLogs multiplexes the log streams of all pending and running conversations
+	// of a task into one channel. It also returns the IDs of the streamed conversations.
+	StreamTaskLogs(ctx context.Context, taskID uint) (<-chan TaskLogEvent, []uint, error)
+}
+
+// TaskLogEvent types emitted by StreamTaskLogs
+const (
+	TaskLogEventLog      = "log"
+	TaskLogEventFinished = "finished"
+	TaskLogEventError    = "error"
+)
+
+// TaskLogEvent is a single event of a multiplexed task log stream, tagged with the
+// conversation it belongs to
+type TaskLogEvent struct {
+	ConversationID uint
+	Type           string
+	Line           string
+	Err            error
 }
 
 type logStreamingService struct {
 	conversationRepo repository.TaskConversationRepository
 	execLogRepo      repository.TaskExecutionLogRepository
 	execManager      *ExecutionManager
+	configService    services.SystemConfigService
+	config           *config.Config
 }
 
 func NewLogStreamingService(
 	conversationRepo repository.TaskConversationRepository,
 	execLogRepo repository.TaskExecutionLogRepository,
 	execManager *ExecutionManager,
+	configService services.SystemConfigService,
+	cfg *config.Config,
 ) LogStreamingService {
 	return &logStreamingService{
 		conversationRepo: conversationRepo,
 		execLogRepo:      execLogRepo,
 		execManager:      execManager,
+		configService:    configService,
+		config:           cfg,
 	}
 }
 
@@ -125,9 +153,85 @@ func (s *logStreamingService) StreamConversationLogs(ctx context.Context, conver
 	return logChan, errChan, nil
 }
 
+func (s *logStreamingService) StreamTaskLogs(ctx context.Context, taskID uint) (<-chan TaskLogEvent, []uint, error) {
+	conversations, err := s.conversationRepo.ListByTask(taskID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list task conversations: %v", err)
+	}
+
+	conversationIDs := []uint{}
+	for _, conv := range conversations {
+		if conv.Status == database.ConversationStatusPending || conv.Status == database.ConversationStatusRunning {
+			conversationIDs = append(conversationIDs, conv.ID)
+		}
+	}
+
+	eventChan := make(chan TaskLogEvent, 100)
+	var wg sync.WaitGroup
+
+	send := func(event TaskLogEvent) bool {
+		select {
+		case eventChan <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for _, conversationID := range conversationIDs {
+		logChan, errChan, err := s.StreamConversationLogs(ctx, conversationID)
+		if err != nil {
+			utils.Warn("Skipping conversation in task log stream", "taskID", taskID, "conversationID", conversationID, "error", err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(conversationID uint, logChan <-chan string, errChan <-chan error) {
+			defer wg.Done()
+
+			for logChan != nil || errChan != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case line, ok := <-logChan:
+					if !ok {
+						logChan = nil
+						continue
+					}
+					if !send(TaskLogEvent{ConversationID: conversationID, Type: TaskLogEventLog, Line: line}) {
+						return
+					}
+				case streamErr, ok := <-errChan:
+					if !ok {
+						errChan = nil
+						continue
+					}
+					send(TaskLogEvent{ConversationID: conversationID, Type: TaskLogEventError, Err: streamErr})
+					return
+				}
+			}
+
+			send(TaskLogEvent{ConversationID: conversationID, Type: TaskLogEventFinished})
+		}(conversationID, logChan, errChan)
+	}
+
+	go func() {
+		wg.Wait()
+		close(eventChan)
+	}()
+
+	return eventChan, conversationIDs, nil
+}
+
 func (s *logStreamingService) streamContainerLogs(ctx context.Context, containerID string, logChan chan<- string) error {
-	// Use docker logs with follow flag to get real-time logs
-	cmd := exec.CommandContext(ctx, "docker", "logs", "-f", "--timestamps", containerID)
+	runtime, err := s.configService.GetContainerRuntime()
+	if err != nil {
+		utils.Warn("Failed to get container runtime from system config, using default docker", "error", err)
+		runtime = "docker"
+	}
+
+	// Use container logs with follow flag to get real-time logs
+	cmd := exec.CommandContext(ctx, runtime, "logs", "-f", "--timestamps", containerID)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -164,30 +268,24 @@ func (s *logStreamingService) streamContainerLogs(ctx context.Context, container
 }
 
 func (s *logStreamingService) readLogStream(ctx context.Context, reader io.Reader, prefix string, logChan chan<- string) {
-	scanner := bufio.NewScanner(reader)
-	// Set larger buffer to handle large log outputs (1MB buffer)
-	const maxCapacity = 1024 * 1024 // 1MB
-	buf := make([]byte, 0, 64*1024) // Start with 64KB
-	scanner.Buffer(buf, maxCapacity)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
+	maxLineBytes := s.config.LogLineMaxBytes
+	err := readLogLines(reader, maxLineBytes, func(line string, originalLength int) bool {
 		// Protect against extremely large log lines
-		if len(line) > maxCapacity {
-			line = line[:maxCapacity-3] + "..."
-			utils.Warn("Truncated extremely large log line in streaming", "prefix", prefix, "original_length", len(scanner.Text()))
+		if originalLength > maxLineBytes {
+			utils.Warn("Truncated extremely large log line in streaming", "prefix", prefix, "original_length", originalLength)
 		}
+		line = normalizeLogLine(line)
 
 		select {
 		case <-ctx.Done():
-			return
+			return false
 		case logChan <- line:
+			return true
 		}
-	}
+	})
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
+	// Check for reader errors
+	if err != nil && ctx.Err() == nil {
 		utils.Error("Log streaming scanner failed", "prefix", prefix, "error", err)
 		select {
 		case <-ctx.Done():
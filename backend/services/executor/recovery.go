@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"context"
+	"xsha-backend/database"
+	"xsha-backend/utils"
+)
+
+// interruptedByRestartMessage is recorded on conversations whose execution was lost with the
+// process that ran it
+const interruptedByRestartMessage = "execution interrupted by restart"
+
+// ReconcileInterruptedConversations fails the conversations left running by a previous process.
+// Their execution goroutines are gone, so nothing would ever finish them. It must run before the
+// scheduler starts, while no execution of this process exists yet.
+func (s *aiTaskExecutorService) ReconcileInterruptedConversations() (int, error) {
+	conversations, err := s.taskConvRepo.ListByStatus(database.ConversationStatusRunning)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, running := range conversations {
+		if s.executionManager.IsRunning(running.ID) {
+			continue
+		}
+
+		conv, err := s.taskConvRepo.GetByID(running.ID)
+		if err != nil {
+			utils.Error("Failed to load interrupted conversation", "conversationId", running.ID, "error", err)
+			continue
+		}
+
+		if !s.stateManager.FailInterrupted(conv, interruptedByRestartMessage) {
+			continue
+		}
+
+		s.cleanupInterruptedWorkspace(conv)
+
+		event := newExecutionEvent(context.Background(), conv, utils.ExecutionEventCompleted)
+		event.Status = string(database.ConversationStatusFailed)
+		event.ErrorMessage = interruptedByRestartMessage
+		utils.PublishExecutionEvent(event)
+
+		utils.Warn("Marked conversation interrupted by restart as failed", "conversationId", conv.ID, "taskId", conv.TaskID)
+		failed++
+	}
+
+	return failed, nil
+}
+
+// cleanupInterruptedWorkspace removes what the interrupted execution left in its workspace, the
+// way a failed execution is cleaned up
+func (s *aiTaskExecutorService) cleanupInterruptedWorkspace(conv *database.TaskConversation) {
+	if conv.Task == nil {
+		return
+	}
+
+	if conv.Task.ParallelConversations {
+		workspacePath := utils.ConversationWorkspaceName(conv.Task.ID, conv.ID)
+		if err := s.workspaceManager.CleanupTaskWorkspace(workspacePath); err != nil {
+			utils.Error("Failed to remove workspace of interrupted conversation", "conversationId", conv.ID, "workspace", workspacePath, "error", err)
+		}
+		return
+	}
+
+	if conv.Task.WorkspacePath != "" {
+		if err := s.workspaceCleaner.CleanupOnFailure(conv.Task.ID, conv.Task.WorkspacePath); err != nil {
+			utils.Error("Failed to clean up workspace of interrupted conversation", "taskId", conv.Task.ID, "workspace", conv.Task.WorkspacePath, "error", err)
+		}
+	}
+}
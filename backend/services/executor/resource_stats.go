@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resourceStatsInterval is how often a running container's resource usage is sampled
+const resourceStatsInterval = 5 * time.Second
+
+// containerResourcePeak is the highest usage sampled from a container
+type containerResourcePeak struct {
+	CPUPercent  float64
+	MemoryBytes int64
+	Samples     int
+}
+
+// memoryUnits covers the binary units docker prints and the SI units podman prints
+var memoryUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// monitorContainerResources samples the container until stop is closed and returns the peak
+// usage. Sampling fails while the container is starting or after it exits, those samples are skipped.
+func (d *dockerExecutor) monitorContainerResources(ctx context.Context, containerName string, stop <-chan struct{}) containerResourcePeak {
+	var peak containerResourcePeak
+
+	ticker := time.NewTicker(resourceStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return peak
+		case <-ctx.Done():
+			return peak
+		case <-ticker.C:
+			cpuPercent, memoryBytes, err := d.sampleContainerResources(ctx, containerName)
+			if err != nil {
+				continue
+			}
+			peak.Samples++
+			if cpuPercent > peak.CPUPercent {
+				peak.CPUPercent = cpuPercent
+			}
+			if memoryBytes > peak.MemoryBytes {
+				peak.MemoryBytes = memoryBytes
+			}
+		}
+	}
+}
+
+func (d *dockerExecutor) sampleContainerResources(ctx context.Context, containerName string) (float64, int64, error) {
+	statsCtx, cancel := context.WithTimeout(ctx, resourceStatsInterval)
+	defer cancel()
+
+	output, err := exec.CommandContext(statsCtx, d.containerRuntime(), "stats", "--no-stream", "--format", "{{.CPUPerc}}|{{.MemUsage}}", containerName).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseContainerStats(strings.TrimSpace(string(output)))
+}
+
+// parseContainerStats parses a "12.5%|256MiB / 2GiB" stats line into CPU percent and memory bytes
+func parseContainerStats(line string) (float64, int64, error) {
+	cpuPart, memPart, ok := strings.Cut(line, "|")
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected stats output: %q", line)
+	}
+
+	cpuPercent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(cpuPart), "%"), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid CPU usage %q: %v", cpuPart, err)
+	}
+
+	usage, _, _ := strings.Cut(memPart, "/")
+	memoryBytes, err := parseMemorySize(strings.TrimSpace(usage))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cpuPercent, memoryBytes, nil
+}
+
+func parseMemorySize(value string) (int64, error) {
+	for _, unit := range memoryUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid memory usage %q: %v", value, err)
+		}
+		return int64(number * unit.multiplier), nil
+	}
+	return 0, fmt.Errorf("invalid memory usage %q", value)
+}
+
+// formatMemorySize renders bytes in MiB, the unit dev environment memory limits use
+func formatMemorySize(bytes int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+}
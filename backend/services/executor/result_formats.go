@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"xsha-backend/database"
+)
+
+// resultLogParser extracts the result of a run from its execution logs in the form
+// CreateResult expects, nil when the logs hold no result. A missing session_id is filled in
+// per conversation by ParseAndCreate.
+type resultLogParser func(executionLogs string) map[string]interface{}
+
+// resultLogParsers maps dev environment types to the parser of their tool's output
+var resultLogParsers = map[string]resultLogParser{
+	"claude-code": parseClaudeCodeResult,
+	"opencode":    parseOpencodeResult,
+	"gemini-cli":  parseGeminiCLIResult,
+}
+
+// stdoutLogLineRegex captures the payload of a stdout line, gemini-cli pretty prints its JSON
+// output over several lines so it has to be reassembled from the payloads
+var stdoutLogLineRegex = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\]\s*STDOUT:\s?(.*)$`)
+
+// opencodeEvent is one line of `opencode run --format json` output
+type opencodeEvent struct {
+	Type      string  `json:"type"`
+	Timestamp float64 `json:"timestamp"`
+	SessionID string  `json:"sessionID"`
+	Part      struct {
+		Text   string  `json:"text"`
+		Cost   float64 `json:"cost"`
+		Tokens struct {
+			Input  float64 `json:"input"`
+			Output float64 `json:"output"`
+		} `json:"tokens"`
+	} `json:"part"`
+	Error *struct {
+		Name string `json:"name"`
+		Data struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"error"`
+}
+
+// parseOpencodeResult totals the step_finish events of an opencode run, the last text part
+// is the result and an error event marks the run failed
+func parseOpencodeResult(executionLogs string) map[string]interface{} {
+	var sessionID, lastText, errorMessage string
+	var cost, inputTokens, outputTokens, firstTimestamp, lastTimestamp float64
+	steps := 0
+	found := false
+
+	for _, line := range strings.Split(executionLogs, "\n") {
+		jsonStr := extractJSONFromLogLine(line)
+		if jsonStr == "" {
+			continue
+		}
+
+		var event opencodeEvent
+		if err := json.Unmarshal([]byte(jsonStr), &event); err != nil || event.Type == "" || event.SessionID == "" {
+			continue
+		}
+		found = true
+		sessionID = event.SessionID
+		if event.Timestamp > 0 {
+			if firstTimestamp == 0 {
+				firstTimestamp = event.Timestamp
+			}
+			lastTimestamp = event.Timestamp
+		}
+
+		switch event.Type {
+		case "text":
+			if strings.TrimSpace(event.Part.Text) != "" {
+				lastText = event.Part.Text
+			}
+		case "step_finish":
+			steps++
+			cost += event.Part.Cost
+			inputTokens += event.Part.Tokens.Input
+			outputTokens += event.Part.Tokens.Output
+		case "error":
+			errorMessage = "opencode run failed"
+			if event.Error != nil {
+				if event.Error.Data.Message != "" {
+					errorMessage = event.Error.Data.Message
+				} else if event.Error.Name != "" {
+					errorMessage = event.Error.Name
+				}
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	isError := errorMessage != ""
+	result := lastText
+	if isError {
+		result = errorMessage
+	}
+	if result == "" {
+		return nil
+	}
+
+	return buildResultData(isError, result, sessionID, map[string]interface{}{
+		"duration_ms":    lastTimestamp - firstTimestamp,
+		"num_turns":      float64(steps),
+		"total_cost_usd": cost,
+		"usage": map[string]interface{}{
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+		},
+	})
+}
+
+// geminiCLIOutput is the JSON document `gemini --output-format json` prints when it exits
+type geminiCLIOutput struct {
+	SessionID string  `json:"session_id"`
+	Response  *string `json:"response"`
+	Stats     struct {
+		Models map[string]struct {
+			API struct {
+				TotalRequests  float64 `json:"totalRequests"`
+				TotalLatencyMs float64 `json:"totalLatencyMs"`
+			} `json:"api"`
+			Tokens struct {
+				Prompt     float64 `json:"prompt"`
+				Candidates float64 `json:"candidates"`
+			} `json:"tokens"`
+		} `json:"models"`
+	} `json:"stats"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// parseGeminiCLIResult decodes the last JSON document gemini-cli printed to stdout, summing
+// the request and token stats of every model it used
+func parseGeminiCLIResult(executionLogs string) map[string]interface{} {
+	var payloads []string
+	for _, line := range strings.Split(executionLogs, "\n") {
+		if matches := stdoutLogLineRegex.FindStringSubmatch(strings.TrimRight(line, "\r")); len(matches) == 2 {
+			payloads = append(payloads, matches[1])
+		}
+	}
+
+	for start := len(payloads) - 1; start >= 0; start-- {
+		if !strings.HasPrefix(strings.TrimSpace(payloads[start]), "{") {
+			continue
+		}
+
+		var output geminiCLIOutput
+		decoder := json.NewDecoder(strings.NewReader(strings.Join(payloads[start:], "\n")))
+		if err := decoder.Decode(&output); err != nil || (output.Response == nil && output.Error == nil) {
+			continue
+		}
+
+		var requests, latencyMs, inputTokens, outputTokens float64
+		for _, model := range output.Stats.Models {
+			requests += model.API.TotalRequests
+			latencyMs += model.API.TotalLatencyMs
+			inputTokens += model.Tokens.Prompt
+			outputTokens += model.Tokens.Candidates
+		}
+
+		isError := output.Error != nil
+		result := ""
+		if output.Response != nil {
+			result = *output.Response
+		}
+		if isError && output.Error.Message != "" {
+			result = output.Error.Message
+		}
+		if result == "" {
+			return nil
+		}
+
+		return buildResultData(isError, result, output.SessionID, map[string]interface{}{
+			"duration_api_ms": latencyMs,
+			"num_turns":       requests,
+			"usage": map[string]interface{}{
+				"input_tokens":  inputTokens,
+				"output_tokens": outputTokens,
+			},
+		})
+	}
+
+	return nil
+}
+
+// buildResultData shapes a parsed run like the claude-code result event CreateResult reads
+func buildResultData(isError bool, result, sessionID string, fields map[string]interface{}) map[string]interface{} {
+	subtype := database.ResultSubtypeSuccess
+	if isError {
+		subtype = database.ResultSubtypeError
+	}
+
+	data := map[string]interface{}{
+		"type":       string(database.ResultTypeResult),
+		"subtype":    string(subtype),
+		"is_error":   isError,
+		"result":     result,
+		"session_id": sessionID,
+	}
+	for key, value := range fields {
+		data[key] = value
+	}
+	return data
+}
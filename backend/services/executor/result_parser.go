@@ -11,29 +11,37 @@ import (
 )
 
 type resultParser struct {
+	taskConvRepo          repository.TaskConversationRepository
 	taskConvResultRepo    repository.TaskConversationResultRepository
 	taskConvResultService services.TaskConversationResultService
 	taskService           services.TaskService
-	logLineJSONRegex      *regexp.Regexp
 }
 
+// logLineJSONRegex matches a JSON object logged as one line, after the optional timestamp
+// and stream prefix the docker executor adds
+var logLineJSONRegex = regexp.MustCompile(`^(?:\[\d{2}:\d{2}:\d{2}\]\s*)?(?:\w+:\s*)?(\{.*\})\s*$`)
+
 func NewResultParser(
+	taskConvRepo repository.TaskConversationRepository,
 	taskConvResultRepo repository.TaskConversationResultRepository,
 	taskConvResultService services.TaskConversationResultService,
 	taskService services.TaskService,
 ) ResultParser {
-	logLineJSONRegex := regexp.MustCompile(`^(?:\[\d{2}:\d{2}:\d{2}\]\s*)?(?:\w+:\s*)?(\{.*\})\s*$`)
-
 	return &resultParser{
+		taskConvRepo:          taskConvRepo,
 		taskConvResultRepo:    taskConvResultRepo,
 		taskConvResultService: taskConvResultService,
 		taskService:           taskService,
-		logLineJSONRegex:      logLineJSONRegex,
 	}
 }
 
 func (r *resultParser) ParseAndCreate(conv *database.TaskConversation, execLog *database.TaskExecutionLog) {
-	resultData, err := r.ParseFromLogs(execLog.ExecutionLogs)
+	envType := ""
+	if conv.Task != nil && conv.Task.DevEnvironment != nil {
+		envType = conv.Task.DevEnvironment.Type
+	}
+
+	resultData, err := r.ParseFromLogs(envType, execLog.ExecutionLogs)
 	if err != nil {
 		utils.Warn("Failed to parse execution result from logs",
 			"conversation_id", conv.ID,
@@ -49,6 +57,11 @@ func (r *resultParser) ParseAndCreate(conv *database.TaskConversation, execLog *
 		return
 	}
 
+	if execLog.ToolVersion != "" {
+		resultData["tool_version"] = execLog.ToolVersion
+	}
+	resultData["timeline"] = r.ParseTimeline(execLog.ExecutionLogs)
+
 	exists, err := r.taskConvResultRepo.ExistsByConversationID(conv.ID)
 	if err != nil {
 		utils.Error("Failed to check existing task conversation result",
@@ -71,9 +84,20 @@ func (r *resultParser) ParseAndCreate(conv *database.TaskConversation, execLog *
 		return
 	}
 
+	// The result text may hold code or secrets from the workspace, so only its size is logged
 	utils.Info("Successfully created task conversation result",
 		"conversation_id", conv.ID,
-		"result_data", resultData)
+		"result_id", result.ID,
+		"result_bytes", len(result.Result))
+
+	if result.SessionID != "" {
+		if err := r.taskConvRepo.UpdateSessionID(conv.ID, result.SessionID); err != nil {
+			utils.Error("Failed to update conversation session ID",
+				"conversation_id", conv.ID,
+				"session_id", result.SessionID,
+				"error", err)
+		}
+	}
 
 	// Update task session_id if result has a session_id
 	if result.SessionID != "" && conv.Task != nil {
@@ -91,11 +115,22 @@ func (r *resultParser) ParseAndCreate(conv *database.TaskConversation, execLog *
 	}
 }
 
-func (r *resultParser) ParseFromLogs(executionLogs string) (map[string]interface{}, error) {
+// ParseFromLogs extracts the result of a run with the parser registered for the dev
+// environment type, unknown types use the claude-code parser
+func (r *resultParser) ParseFromLogs(envType, executionLogs string) (map[string]interface{}, error) {
 	if executionLogs == "" {
 		return nil, nil
 	}
 
+	parser, ok := resultLogParsers[envType]
+	if !ok {
+		parser = parseClaudeCodeResult
+	}
+	return parser(executionLogs), nil
+}
+
+// parseClaudeCodeResult returns the last stream-json result event of a claude-code run
+func parseClaudeCodeResult(executionLogs string) map[string]interface{} {
 	lines := strings.Split(executionLogs, "\n")
 
 	for i := len(lines) - 1; i >= 0; i-- {
@@ -104,7 +139,7 @@ func (r *resultParser) ParseFromLogs(executionLogs string) (map[string]interface
 			continue
 		}
 
-		jsonStr := r.extractJSONFromLogLine(line)
+		jsonStr := extractJSONFromLogLine(line)
 		if jsonStr == "" {
 			continue
 		}
@@ -117,23 +152,51 @@ func (r *resultParser) ParseFromLogs(executionLogs string) (map[string]interface
 		if typeVal, ok := result["type"].(string); ok && typeVal == "result" {
 			if _, hasSubtype := result["subtype"]; hasSubtype {
 				if _, hasIsError := result["is_error"]; hasIsError {
-					if r.validateResultData(result) {
-						utils.Info("Found result JSON in execution logs",
+					if validateResultData(result) {
+						utils.Debug("Found result JSON in execution logs",
 							"line_index", i,
 							"result_type", typeVal,
-							"json_extract", jsonStr[:100]+"...")
-						return result, nil
+							"json_bytes", len(jsonStr))
+						return result
 					}
 				}
 			}
 		}
 	}
 
-	return nil, nil
+	return nil
+}
+
+// ParseToolVersion extracts the AI tool version from the stream-json init event
+func (r *resultParser) ParseToolVersion(executionLogs string) string {
+	for _, line := range strings.Split(executionLogs, "\n") {
+		jsonStr := extractJSONFromLogLine(line)
+		if jsonStr == "" {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(jsonStr), &event); err != nil {
+			continue
+		}
+
+		if event["type"] != "system" || event["subtype"] != "init" {
+			continue
+		}
+
+		for _, key := range []string{"claude_code_version", "version"} {
+			if version, ok := event[key].(string); ok && version != "" {
+				return version
+			}
+		}
+		return ""
+	}
+
+	return ""
 }
 
-func (r *resultParser) extractJSONFromLogLine(line string) string {
-	matches := r.logLineJSONRegex.FindStringSubmatch(strings.TrimSpace(line))
+func extractJSONFromLogLine(line string) string {
+	matches := logLineJSONRegex.FindStringSubmatch(strings.TrimSpace(line))
 	if len(matches) >= 2 {
 		return matches[1]
 	}
@@ -146,7 +209,7 @@ func (r *resultParser) extractJSONFromLogLine(line string) string {
 	return ""
 }
 
-func (r *resultParser) validateResultData(data map[string]interface{}) bool {
+func validateResultData(data map[string]interface{}) bool {
 	requiredFields := []string{"type", "subtype", "is_error", "session_id"}
 	for _, field := range requiredFields {
 		if _, exists := data[field]; !exists {
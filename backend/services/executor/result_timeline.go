@@ -0,0 +1,184 @@
+package executor
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// Timeline event types
+const (
+	TimelineEventSystem     = "system"
+	TimelineEventText       = "text"
+	TimelineEventThinking   = "thinking"
+	TimelineEventToolUse    = "tool_use"
+	TimelineEventToolResult = "tool_result"
+	TimelineEventResult     = "result"
+)
+
+// timelineFieldMaxBytes caps text and tool input stored per event, file writes and tool
+// output can be arbitrarily large
+const timelineFieldMaxBytes = 4000
+
+// TimelineEvent is one step of what the AI did during a conversation
+type TimelineEvent struct {
+	Sequence  int    `json:"sequence"`
+	Type      string `json:"type"`
+	Role      string `json:"role,omitempty"`
+	Subtype   string `json:"subtype,omitempty"`
+	Text      string `json:"text,omitempty"`
+	ToolName  string `json:"tool_name,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Input     string `json:"input,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// streamEvent covers the stream-json event shapes, both content blocks nested in
+// assistant/user messages and top-level text and tool_use events
+type streamEvent struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Message *struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+	streamContentBlock
+	Result string `json:"result"`
+}
+
+type streamContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// ParseTimeline scans the execution log for stream-json events and returns them in order.
+// Lines that are not JSON, or JSON that is malformed or truncated, are skipped.
+func (r *resultParser) ParseTimeline(executionLogs string) []TimelineEvent {
+	timeline := []TimelineEvent{}
+
+	for _, line := range strings.Split(executionLogs, "\n") {
+		jsonStr := extractJSONFromLogLine(line)
+		if jsonStr == "" {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(jsonStr), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "system":
+			timeline = appendTimelineEvent(timeline, TimelineEvent{Type: TimelineEventSystem, Subtype: event.Subtype})
+		case "assistant", "user":
+			if event.Message == nil {
+				continue
+			}
+			for _, block := range parseContentBlocks(event.Message.Content) {
+				if item, ok := timelineEventFromBlock(block); ok {
+					item.Role = event.Type
+					timeline = appendTimelineEvent(timeline, item)
+				}
+			}
+		case "text", "tool_use", "tool_result", "thinking":
+			// The event's own type field shadows the embedded block's
+			block := event.streamContentBlock
+			block.Type = event.Type
+			if item, ok := timelineEventFromBlock(block); ok {
+				timeline = appendTimelineEvent(timeline, item)
+			}
+		case "result":
+			item := TimelineEvent{Type: TimelineEventResult, Subtype: event.Subtype, IsError: event.IsError}
+			item.Text, item.Truncated = truncateTimelineField(event.Result)
+			timeline = appendTimelineEvent(timeline, item)
+		}
+	}
+
+	return timeline
+}
+
+func appendTimelineEvent(timeline []TimelineEvent, event TimelineEvent) []TimelineEvent {
+	event.Sequence = len(timeline) + 1
+	return append(timeline, event)
+}
+
+// parseContentBlocks decodes message content, which is either a plain string or a list of blocks
+func parseContentBlocks(content json.RawMessage) []streamContentBlock {
+	if len(content) == 0 {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return []streamContentBlock{{Type: "text", Text: text}}
+	}
+
+	var blocks []streamContentBlock
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return nil
+	}
+	return blocks
+}
+
+func timelineEventFromBlock(block streamContentBlock) (TimelineEvent, bool) {
+	var event TimelineEvent
+	var truncated bool
+
+	switch block.Type {
+	case "text":
+		if strings.TrimSpace(block.Text) == "" {
+			return event, false
+		}
+		event.Type = TimelineEventText
+		event.Text, truncated = truncateTimelineField(block.Text)
+	case "thinking":
+		event.Type = TimelineEventThinking
+		event.Text, truncated = truncateTimelineField(block.Thinking)
+	case "tool_use":
+		event.Type = TimelineEventToolUse
+		event.ToolName = block.Name
+		event.ToolUseID = block.ID
+		event.Input, truncated = truncateTimelineField(string(block.Input))
+	case "tool_result":
+		event.Type = TimelineEventToolResult
+		event.ToolUseID = block.ToolUseID
+		event.IsError = block.IsError
+		event.Text, truncated = truncateTimelineField(toolResultText(block.Content))
+	default:
+		return event, false
+	}
+
+	event.Truncated = truncated
+	return event, true
+}
+
+// toolResultText flattens tool result content, a string or a list of text blocks
+func toolResultText(content json.RawMessage) string {
+	var parts []string
+	for _, block := range parseContentBlocks(content) {
+		if block.Type == "text" && block.Text != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func truncateTimelineField(value string) (string, bool) {
+	if len(value) <= timelineFieldMaxBytes {
+		return value, false
+	}
+
+	cut := timelineFieldMaxBytes
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut], true
+}
@@ -2,14 +2,22 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 	"xsha-backend/config"
 	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/i18n"
 	"xsha-backend/repository"
 	"xsha-backend/services"
 	"xsha-backend/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"gorm.io/gorm"
 )
 
 type aiTaskExecutorService struct {
@@ -23,6 +31,7 @@ type aiTaskExecutorService struct {
 	taskService           services.TaskService
 	systemConfigService   services.SystemConfigService
 	attachmentService     services.TaskConversationAttachmentService
+	authService           services.AuthService
 
 	executionManager *ExecutionManager
 	dockerExecutor   DockerExecutor
@@ -32,6 +41,13 @@ type aiTaskExecutorService struct {
 
 	workspaceManager *utils.WorkspaceManager
 	config           *config.Config
+
+	// dispatchMu serializes scheduler ticks and wait queue draining so a
+	// conversation is never dispatched twice
+	dispatchMu sync.Mutex
+
+	// schedulerPauseState stops queued conversations from starting while the scheduler is paused
+	schedulerPauseState services.SchedulerPauseState
 }
 
 func NewAITaskExecutorService(
@@ -44,12 +60,14 @@ func NewAITaskExecutorService(
 	taskService services.TaskService,
 	systemConfigService services.SystemConfigService,
 	attachmentService services.TaskConversationAttachmentService,
+	secretService services.SecretService,
+	authService services.AuthService,
 	cfg *config.Config,
 ) services.AITaskExecutorService {
 	return NewAITaskExecutorServiceWithManager(
 		taskConvRepo, taskRepo, execLogRepo, taskConvResultRepo,
 		gitCredService, taskConvResultService, taskService, systemConfigService,
-		attachmentService, cfg, nil,
+		attachmentService, secretService, authService, cfg, nil,
 	)
 }
 
@@ -63,6 +81,8 @@ func NewAITaskExecutorServiceWithManager(
 	taskService services.TaskService,
 	systemConfigService services.SystemConfigService,
 	attachmentService services.TaskConversationAttachmentService,
+	secretService services.SecretService,
+	authService services.AuthService,
 	cfg *config.Config,
 	executionManager *ExecutionManager,
 ) services.AITaskExecutorService {
@@ -85,8 +105,9 @@ func NewAITaskExecutorServiceWithManager(
 		}
 		executionManager = NewExecutionManager(maxConcurrency)
 	}
-	dockerExecutor := NewDockerExecutor(cfg, logAppender, systemConfigService)
-	resultParser := NewResultParser(taskConvResultRepo, taskConvResultService, taskService)
+	utils.RegisterExecutionGauges(executionManager.GetRunningCount, func() int { return executionManager.maxConcurrency })
+	dockerExecutor := NewDockerExecutor(cfg, logAppender, execLogRepo, systemConfigService, secretService)
+	resultParser := NewResultParser(taskConvRepo, taskConvResultRepo, taskConvResultService, taskService)
 	workspaceCleaner := NewWorkspaceCleaner(workspaceManager)
 	stateManager := NewConversationStateManager(taskConvRepo, execLogRepo)
 
@@ -100,6 +121,7 @@ func NewAITaskExecutorServiceWithManager(
 		taskService:           taskService,
 		systemConfigService:   systemConfigService,
 		attachmentService:     attachmentService,
+		authService:           authService,
 		executionManager:      executionManager,
 		dockerExecutor:        dockerExecutor,
 		resultParser:          resultParser,
@@ -111,7 +133,10 @@ func NewAITaskExecutorServiceWithManager(
 }
 
 func (s *aiTaskExecutorService) ProcessPendingConversations() error {
-	conversations, err := s.taskConvRepo.GetPendingConversationsWithDetails()
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	conversations, err := s.taskConvRepo.GetPendingConversationsOrderedByPriority()
 	if err != nil {
 		return fmt.Errorf("failed to get pending conversations: %v", err)
 	}
@@ -119,16 +144,50 @@ func (s *aiTaskExecutorService) ProcessPendingConversations() error {
 	utils.Info("Found pending conversations to process",
 		"count", len(conversations),
 		"running", s.executionManager.GetRunningCount(),
+		"runningWeight", s.executionManager.GetRunningWeight(),
 		"maxConcurrency", s.executionManager.maxConcurrency)
 
 	var wg sync.WaitGroup
 	processedCount := 0
 	skippedCount := 0
+	var waiting []uint
+	// dispatchedByProject and dispatchedWeight count conversations started in this tick that
+	// are not yet registered with the execution manager
+	dispatchedByProject := make(map[uint]int)
+	dispatchedWeight := 0
+	// holdBack is set once a starved conversation does not fit, the conversations after it
+	// are not started so the capacity freed next is kept for it
+	holdBack := false
+
+	pendingIDs := make([]uint, 0, len(conversations))
+	for _, conv := range conversations {
+		pendingIDs = append(pendingIDs, conv.ID)
+	}
+	s.executionManager.RetainCapacitySkips(pendingIDs)
 
 	for _, conv := range conversations {
-		if !s.executionManager.CanExecute() {
+		weight := conversationWeight(&conv)
+		if holdBack {
+			skippedCount++
+			waiting = append(waiting, conv.ID)
+			continue
+		}
+		if !s.executionManager.HasCapacityFor(weight, dispatchedWeight) {
 			skippedCount++
-			utils.Warn("Reached maximum concurrency limit, skipping conversation", "conversationId", conv.ID)
+			waiting = append(waiting, conv.ID)
+			if s.executionManager.RecordCapacitySkip(conv.ID) {
+				holdBack = true
+				utils.Warn("Conversation waited too long for capacity, holding back the conversations after it", "conversationId", conv.ID, "weight", weight)
+			} else {
+				utils.Warn("Reached maximum concurrency limit, skipping conversation", "conversationId", conv.ID, "weight", weight)
+			}
+			continue
+		}
+
+		projectID, projectLimit := projectConcurrency(&conv)
+		if projectLimit > 0 && s.executionManager.GetProjectRunningCount(projectID)+dispatchedByProject[projectID] >= projectLimit {
+			skippedCount++
+			utils.Warn("Reached project concurrency limit, skipping conversation", "conversationId", conv.ID, "projectId", projectID, "limit", projectLimit)
 			continue
 		}
 
@@ -140,6 +199,8 @@ func (s *aiTaskExecutorService) ProcessPendingConversations() error {
 
 		wg.Add(1)
 		processedCount++
+		dispatchedByProject[projectID]++
+		dispatchedWeight += weight
 
 		go func(conversation database.TaskConversation) {
 			defer wg.Done()
@@ -151,12 +212,232 @@ func (s *aiTaskExecutorService) ProcessPendingConversations() error {
 
 	wg.Wait()
 
-	utils.Info("Batch conversation processing completed", "processed", processedCount, "skipped", skippedCount)
+	if s.config.ExecutionQueueEnabled {
+		s.executionManager.SetWaitQueue(waiting)
+	}
+	utils.SetSchedulerQueueDepth(len(conversations), len(waiting))
+
+	utils.Info("Batch conversation processing completed", "processed", processedCount, "skipped", skippedCount, "queued", len(waiting))
 	return nil
 }
 
+// dispatchQueuedConversations starts queued conversations as soon as execution slots
+// free up, instead of waiting for the next scheduler tick
+func (s *aiTaskExecutorService) dispatchQueuedConversations() {
+	if !s.config.ExecutionQueueEnabled || s.isSchedulerPaused() {
+		return
+	}
+
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	for s.executionManager.HasCapacity() {
+		conversationID, ok := s.executionManager.DequeueWaiting()
+		if !ok {
+			return
+		}
+
+		if s.executionManager.IsRunning(conversationID) {
+			continue
+		}
+
+		conv, err := s.taskConvRepo.GetByID(conversationID)
+		if err != nil {
+			utils.Warn("Queued conversation no longer available", "conversationId", conversationID, "error", err)
+			continue
+		}
+		if conv.Status != database.ConversationStatusPending {
+			continue
+		}
+		if projectID, projectLimit := projectConcurrency(conv); !s.executionManager.CanExecute(projectID, projectLimit, conversationWeight(conv)) {
+			// Left pending; the next scheduler tick picks it up again. A starved conversation keeps
+			// the freed capacity, so the ones queued after it are not started ahead of it.
+			if s.executionManager.IsStarved(conversationID) {
+				return
+			}
+			continue
+		}
+
+		utils.Info("Starting queued conversation", "conversationId", conversationID)
+		if err := s.processConversation(conv); err != nil {
+			utils.Error("Failed to process queued conversation", "conversationId", conversationID, "error", err)
+		}
+	}
+}
+
+// projectConcurrency returns the project ID and per-project concurrency limit of a conversation
+func projectConcurrency(conv *database.TaskConversation) (uint, int) {
+	if conv.Task == nil {
+		return 0, 0
+	}
+	if conv.Task.Project == nil {
+		return conv.Task.ProjectID, 0
+	}
+	return conv.Task.ProjectID, conv.Task.Project.MaxConcurrentTasks
+}
+
+// conversationWeight returns how much of the capacity budget the conversation's environment uses
+func conversationWeight(conv *database.TaskConversation) int {
+	if conv.Task == nil {
+		return 1
+	}
+	return EnvironmentWeight(conv.Task.DevEnvironment)
+}
+
 func (s *aiTaskExecutorService) GetExecutionLog(conversationID uint) (*database.TaskExecutionLog, error) {
-	return s.execLogRepo.GetByConversationID(conversationID)
+	execLog, err := s.execLogRepo.GetByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	execLog.DockerCommand = s.dockerExecutor.RedactCommand(execLog.DockerCommand)
+	return execLog, nil
+}
+
+// GetEffectivePrompt returns the prompt recorded when the conversation was executed
+func (s *aiTaskExecutorService) GetEffectivePrompt(conversationID uint) (string, error) {
+	execLog, err := s.execLogRepo.GetByConversationID(conversationID)
+	if err != nil {
+		return "", err
+	}
+	if execLog.EffectivePrompt == "" {
+		return "", appErrors.ErrEffectivePromptNotRecorded
+	}
+	return execLog.EffectivePrompt, nil
+}
+
+func (s *aiTaskExecutorService) GetLogTail(conversationID uint, offset int) (string, int, error) {
+	return s.execLogRepo.GetLogTail(conversationID, offset)
+}
+
+// PreviewCommand returns the masked docker command a conversation would run, without
+// creating an execution log or touching the workspace
+func (s *aiTaskExecutorService) PreviewCommand(conversationID uint) (string, error) {
+	conv, err := s.taskConvRepo.GetByID(conversationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get conversation info: %v", err)
+	}
+	if conv.Task == nil || conv.Task.Project == nil {
+		return "", fmt.Errorf("task or project information is missing")
+	}
+	if conv.Task.DevEnvironment == nil {
+		return "", appErrors.ErrNoDevEnvironment
+	}
+
+	workspacePath := conv.Task.WorkspacePath
+	if conv.Task.ParallelConversations {
+		workspacePath = utils.ConversationWorkspaceName(conv.Task.ID, conv.ID)
+	} else if workspacePath == "" {
+		// Mirrors the name GetOrCreateTaskWorkspace would generate
+		workspacePath = fmt.Sprintf("task-%d-%d", conv.Task.ID, utils.Now().Unix())
+	}
+
+	previewConv := *conv
+	previewConv.Content = attemptContent(conv)
+	s.applyPreviousSession(&previewConv)
+
+	return s.dockerExecutor.BuildCommandForLog(&previewConv, workspacePath), nil
+}
+
+// applyPreviousSession points the conversation's task at the previous conversation's
+// session so the AI tool resumes it. The original task object is left untouched.
+func (s *aiTaskExecutorService) applyPreviousSession(conv *database.TaskConversation) {
+	previousSessionID, err := s.taskConvRepo.GetPreviousSessionID(conv.TaskID, conv.ID)
+	if err != nil {
+		utils.Warn("Failed to look up previous session ID, using task session", "conversationId", conv.ID, "error", err)
+		return
+	}
+	if previousSessionID == "" {
+		return
+	}
+
+	task := *conv.Task
+	task.SessionID = previousSessionID
+	conv.Task = &task
+}
+
+// attemptContent returns the prompt the current attempt runs, the retry's replacement prompt
+// when one was given
+func attemptContent(conv *database.TaskConversation) string {
+	if conv.RetryContent != "" {
+		return conv.RetryContent
+	}
+	return conv.Content
+}
+
+// CancelProjectConversations cancels every pending or running conversation of a project
+// and returns the IDs that were cancelled and the IDs that failed to cancel
+func (s *aiTaskExecutorService) CancelProjectConversations(projectID uint, createdBy string) ([]uint, []uint, error) {
+	cancelled, failed, err := s.cancelActiveConversations(func() ([]database.TaskConversation, error) {
+		return s.taskConvRepo.ListActiveByProject(projectID)
+	}, createdBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	utils.Info("Cancelled project conversations", "projectID", projectID, "cancelled", len(cancelled), "failed", len(failed), "createdBy", createdBy)
+	return cancelled, failed, nil
+}
+
+// CancelTaskConversations cancels every pending or running conversation of a task and returns
+// the IDs that were cancelled and the IDs that were skipped
+func (s *aiTaskExecutorService) CancelTaskConversations(taskID uint, createdBy string) ([]uint, []uint, error) {
+	if _, err := s.taskRepo.GetByID(taskID); err != nil {
+		return nil, nil, appErrors.ErrTaskNotFound
+	}
+
+	cancelled, skipped, err := s.cancelActiveConversations(func() ([]database.TaskConversation, error) {
+		return s.taskConvRepo.ListActiveByTask(taskID)
+	}, createdBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	utils.Info("Cancelled task conversations", "taskID", taskID, "cancelled", len(cancelled), "skipped", len(skipped), "createdBy", createdBy)
+	return cancelled, skipped, nil
+}
+
+// cancelActiveConversations cancels the conversations listActive returns. Pending ones are
+// cancelled while holding dispatchMu so the scheduler cannot start one halfway through.
+// Running ones are only collected under the lock and stopped after it is released, since
+// each container stop can take the whole stop timeout and would hold up dispatching for
+// every other task.
+func (s *aiTaskExecutorService) cancelActiveConversations(listActive func() ([]database.TaskConversation, error), createdBy string) ([]uint, []uint, error) {
+	cancelled := []uint{}
+	skipped := []uint{}
+	var running []uint
+
+	s.dispatchMu.Lock()
+	conversations, err := listActive()
+	if err != nil {
+		s.dispatchMu.Unlock()
+		return nil, nil, fmt.Errorf("failed to list active conversations: %v", err)
+	}
+	for _, conv := range conversations {
+		if conv.Status == database.ConversationStatusRunning {
+			running = append(running, conv.ID)
+			continue
+		}
+		if err := s.CancelExecution(conv.ID, createdBy); err != nil {
+			utils.Warn("Skipped cancelling conversation", "conversationID", conv.ID, "error", err)
+			skipped = append(skipped, conv.ID)
+			continue
+		}
+		cancelled = append(cancelled, conv.ID)
+	}
+	s.dispatchMu.Unlock()
+
+	// A running conversation that finished meanwhile is no longer cancellable and is skipped
+	for _, conversationID := range running {
+		if err := s.CancelExecution(conversationID, createdBy); err != nil {
+			utils.Warn("Skipped cancelling conversation", "conversationID", conversationID, "error", err)
+			skipped = append(skipped, conversationID)
+			continue
+		}
+		cancelled = append(cancelled, conversationID)
+	}
+
+	return cancelled, skipped, nil
 }
 
 func (s *aiTaskExecutorService) CancelExecution(conversationID uint, createdBy string) error {
@@ -209,7 +490,9 @@ func (s *aiTaskExecutorService) CancelExecution(conversationID uint, createdBy s
 			"conversation_id", conversationID)
 	}
 
-	if conv.Task != nil && conv.Task.WorkspacePath != "" {
+	// Parallel conversations never touch the shared workspace, their own one is removed when
+	// the execution stops
+	if conv.Task != nil && conv.Task.WorkspacePath != "" && !conv.Task.ParallelConversations {
 		if cleanupErr := s.workspaceCleaner.CleanupOnCancel(conv.Task.ID, conv.Task.WorkspacePath); cleanupErr != nil {
 			utils.Error("Failed to cleanup workspace during cancellation", "task_id", conv.Task.ID, "workspace", conv.Task.WorkspacePath, "error", cleanupErr)
 		}
@@ -218,7 +501,26 @@ func (s *aiTaskExecutorService) CancelExecution(conversationID uint, createdBy s
 	return nil
 }
 
-func (s *aiTaskExecutorService) RetryExecution(conversationID uint, createdBy string) error {
+// reloadDevEnvironment replaces the conversation's dev environment with the one its task uses
+// now, so changes to the image, limits or env vars made after the conversation was queued or
+// first run apply to this run
+func (s *aiTaskExecutorService) reloadDevEnvironment(conv *database.TaskConversation) error {
+	current, err := s.taskConvRepo.GetByID(conv.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload development environment: %v", err)
+	}
+	if current.Task == nil || current.Task.DevEnvironment == nil {
+		return fmt.Errorf("task has no development environment configured, cannot execute")
+	}
+
+	conv.Task.DevEnvironmentID = current.Task.DevEnvironmentID
+	conv.Task.DevEnvironment = current.Task.DevEnvironment
+	return nil
+}
+
+// RetryExecution reruns a failed or cancelled conversation. A non-empty content replaces the
+// conversation's prompt for the retry and stays on the conversation afterwards.
+func (s *aiTaskExecutorService) RetryExecution(conversationID uint, content string, createdBy string) error {
 	conv, err := s.taskConvRepo.GetByID(conversationID)
 	if err != nil {
 		return fmt.Errorf("failed to get conversation info: %v", err)
@@ -232,7 +534,7 @@ func (s *aiTaskExecutorService) RetryExecution(conversationID uint, createdBy st
 		return fmt.Errorf("conversation is running, cannot retry")
 	}
 
-	if !s.executionManager.CanExecute() {
+	if projectID, projectLimit := projectConcurrency(conv); !s.executionManager.CanExecute(projectID, projectLimit, conversationWeight(conv)) {
 		return fmt.Errorf("reached maximum concurrency limit, please try again later")
 	}
 
@@ -246,10 +548,18 @@ func (s *aiTaskExecutorService) RetryExecution(conversationID uint, createdBy st
 	}
 
 	conv.Status = database.ConversationStatusPending
+	// The replacement only applies to this attempt, Content keeps the prompt that failed
+	conv.RetryContent = ""
+	if content = strings.TrimSpace(content); content != "" && content != conv.Content {
+		utils.Info("Retrying conversation with a replacement prompt", "conversation_id", conversationID, "createdBy", createdBy)
+		conv.RetryContent = content
+	}
 	if err := s.taskConvRepo.Update(conv); err != nil {
 		return fmt.Errorf("failed to reset conversation status: %v", err)
 	}
 
+	// The dev environment is reloaded by executeTask right before the container starts, so the
+	// retry runs with the environment's current image, limits and env vars
 	if err := s.processConversation(conv); err != nil {
 		conv.Status = database.ConversationStatusFailed
 		s.taskConvRepo.Update(conv)
@@ -259,15 +569,192 @@ func (s *aiTaskExecutorService) RetryExecution(conversationID uint, createdBy st
 	return nil
 }
 
+// ApproveConversation commits the changes of a conversation awaiting approval the way its
+// execution would have without the approval gate. The conversation keeps awaiting approval
+// when committing fails, so approving can be repeated.
+func (s *aiTaskExecutorService) ApproveConversation(conversationID uint, approvedBy string) error {
+	conv, unlock, err := s.lockAwaitingApproval(conversationID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if conv.Task.Project == nil {
+		return appErrors.NewI18nError("taskConversation.approve_failed", "project information is missing")
+	}
+
+	if err := s.commitApprovedChanges(conv); err != nil {
+		utils.Error("Failed to approve conversation changes", "conversationId", conv.ID, "error", err)
+		return appErrors.NewI18nError("taskConversation.approve_failed", err.Error())
+	}
+
+	utils.Info("Conversation changes approved", "conversationId", conv.ID, "commitHash", conv.CommitHash, "approvedBy", approvedBy)
+	return nil
+}
+
+// lockAwaitingApproval takes the task workspace lock of a conversation and checks under the lock
+// that it is still awaiting approval, so concurrent approve and reject calls act only once
+func (s *aiTaskExecutorService) lockAwaitingApproval(conversationID uint) (*database.TaskConversation, func(), error) {
+	conv, err := s.taskConvRepo.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, appErrors.ErrConversationNotFound
+		}
+		return nil, nil, appErrors.ErrConversationGetFailed
+	}
+	if conv.Task == nil {
+		return nil, nil, appErrors.ErrConversationGetFailed
+	}
+
+	unlock := s.lockTaskWorkspace(conv.Task.ID)
+	conv, err = s.taskConvRepo.GetByID(conversationID)
+	if err != nil || conv.Task == nil {
+		unlock()
+		return nil, nil, appErrors.ErrConversationGetFailed
+	}
+	if conv.Status != database.ConversationStatusAwaitingApproval {
+		unlock()
+		return nil, nil, appErrors.ErrConversationNotAwaitingApproval
+	}
+	return conv, unlock, nil
+}
+
+// commitApprovedChanges commits and merges the approved changes, then marks the conversation
+// successful. Only changed columns are written.
+func (s *aiTaskExecutorService) commitApprovedChanges(conv *database.TaskConversation) error {
+	workspacePath, ownWorkspace := s.approvalWorkspace(conv)
+
+	commitBranch := conv.Task.WorkBranch
+	if conv.IsolatedBranch {
+		commitBranch = conv.WorkBranch
+	}
+	if err := s.workspaceManager.EnsureOnBranch(workspacePath, commitBranch); err != nil {
+		return fmt.Errorf("failed to prepare branch for commit: %v", err)
+	}
+
+	commitMessage, authorName, authorEmail := s.resolveCommitSettings(conv, commitBranch)
+	// No changes means an earlier approval committed them before failing to merge
+	commitHash, err := s.workspaceManager.CommitChanges(workspacePath, commitMessage, authorName, authorEmail)
+	if err != nil && err != utils.ErrNoChangesToCommit {
+		return fmt.Errorf("failed to commit approved changes: %v", err)
+	}
+
+	if ownWorkspace {
+		if err := s.workspaceManager.FetchBranchFromWorkspace(conv.Task.WorkspacePath, workspacePath, commitBranch); err != nil {
+			return fmt.Errorf("failed to fetch conversation branch into the task workspace: %v", err)
+		}
+		if err := s.workspaceManager.MergeBranch(conv.Task.WorkspacePath, conv.Task.WorkBranch, commitBranch, authorName, authorEmail); err != nil {
+			return fmt.Errorf("failed to merge conversation branch into the work branch: %v", err)
+		}
+		if err := s.workspaceManager.CleanupTaskWorkspace(workspacePath); err != nil {
+			utils.Error("Failed to remove conversation workspace", "conversationId", conv.ID, "workspace", workspacePath, "error", err)
+		}
+	}
+
+	if commitHash != "" {
+		if err := s.taskConvRepo.UpdateCommitHash(conv.ID, commitHash); err != nil {
+			utils.Error("Failed to update conversation commit hash", "error", err)
+		}
+		conv.CommitHash = commitHash
+	}
+	if err := s.taskConvRepo.UpdateChangesCommitted(conv.ID, commitHash != ""); err != nil {
+		return fmt.Errorf("failed to update conversation changes committed: %v", err)
+	}
+
+	transitioned, err := s.taskConvRepo.TransitionStatus(conv.ID, database.ConversationStatusAwaitingApproval, database.ConversationStatusSuccess)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation status: %v", err)
+	}
+	if !transitioned {
+		return fmt.Errorf("conversation is no longer awaiting approval")
+	}
+	conv.Status = database.ConversationStatusSuccess
+	return nil
+}
+
+// RejectConversation discards the changes of a conversation awaiting approval and cancels it
+func (s *aiTaskExecutorService) RejectConversation(conversationID uint, rejectedBy string) error {
+	conv, unlock, err := s.lockAwaitingApproval(conversationID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	workspacePath, ownWorkspace := s.approvalWorkspace(conv)
+	if ownWorkspace {
+		err = s.workspaceManager.CleanupTaskWorkspace(workspacePath)
+	} else {
+		err = s.workspaceManager.ResetWorkspaceToCleanState(workspacePath)
+	}
+	if err != nil {
+		utils.Error("Failed to discard rejected changes", "conversationId", conv.ID, "error", err)
+		return appErrors.NewI18nError("taskConversation.reject_failed", err.Error())
+	}
+
+	transitioned, err := s.taskConvRepo.TransitionStatus(conv.ID, database.ConversationStatusAwaitingApproval, database.ConversationStatusCancelled)
+	if err != nil {
+		utils.Error("Failed to update rejected conversation status", "conversationId", conv.ID, "error", err)
+		return appErrors.NewI18nError("taskConversation.reject_failed", err.Error())
+	}
+	if !transitioned {
+		return appErrors.ErrConversationNotAwaitingApproval
+	}
+
+	if execLog, err := s.execLogRepo.GetByConversationID(conv.ID); err == nil {
+		updates := map[string]interface{}{"error_message": fmt.Sprintf("changes rejected by %s", rejectedBy)}
+		if err := s.execLogRepo.UpdateMetadata(execLog.ID, updates); err != nil {
+			utils.Warn("Failed to record rejection on execution log", "conversationId", conv.ID, "error", err)
+		}
+	}
+
+	utils.Info("Conversation changes rejected", "conversationId", conv.ID, "rejectedBy", rejectedBy)
+	return nil
+}
+
+// approvalWorkspace returns where the changes of a conversation awaiting approval are and
+// whether that is the conversation's own workspace rather than the task's
+func (s *aiTaskExecutorService) approvalWorkspace(conv *database.TaskConversation) (string, bool) {
+	if conv.Task.ParallelConversations {
+		workspacePath := utils.ConversationWorkspaceName(conv.Task.ID, conv.ID)
+		if s.workspaceManager.CheckGitRepositoryExists(workspacePath) {
+			return workspacePath, true
+		}
+	}
+	return conv.Task.WorkspacePath, false
+}
+
 func (s *aiTaskExecutorService) GetExecutionStatus() map[string]interface{} {
+	pendingCount, err := s.taskConvRepo.CountByStatus(database.ConversationStatusPending)
+	if err != nil {
+		utils.Warn("Failed to count pending conversations", "error", err)
+	}
+
 	return map[string]interface{}{
-		"running_count":   s.executionManager.GetRunningCount(),
-		"max_concurrency": s.executionManager.maxConcurrency,
-		"can_execute":     s.executionManager.CanExecute(),
+		"running_count":     s.executionManager.GetRunningCount(),
+		"running_weight":    s.executionManager.GetRunningWeight(),
+		"max_concurrency":   s.executionManager.maxConcurrency,
+		"can_execute":       s.executionManager.HasCapacity(),
+		"pending_count":     pendingCount,
+		"wait_queue_length": s.executionManager.GetWaitQueueLength(),
+		"paused":            s.isSchedulerPaused(),
 	}
 }
 
-func (s *aiTaskExecutorService) processConversation(conv *database.TaskConversation) error {
+func (s *aiTaskExecutorService) SetSchedulerPauseState(state services.SchedulerPauseState) {
+	s.schedulerPauseState = state
+}
+
+func (s *aiTaskExecutorService) isSchedulerPaused() bool {
+	return s.schedulerPauseState != nil && s.schedulerPauseState.IsPaused()
+}
+
+func (s *aiTaskExecutorService) CheckDockerAvailability() error {
+	return s.dockerExecutor.CheckAvailability()
+}
+
+func (s *aiTaskExecutorService) processConversation(conv *database.TaskConversation) (err error) {
+	spanCtx, span := utils.StartSpan(context.Background(), "conversation.dispatch", conversationSpanAttributes(conv)...)
+	defer func() { utils.EndSpan(span, err) }()
+
 	if conv.Task == nil {
 		s.stateManager.SetFailed(conv, "task information is missing")
 		return fmt.Errorf("task information is missing")
@@ -281,6 +768,12 @@ func (s *aiTaskExecutorService) processConversation(conv *database.TaskConversat
 		return fmt.Errorf("task has no development environment configured, cannot execute")
 	}
 
+	if reason := s.quotaExceededReason(conv); reason != "" {
+		utils.Warn("Task quota exceeded, not executing conversation", "conversation_id", conv.ID, "task_id", conv.Task.ID)
+		s.stateManager.SetFailed(conv, reason)
+		return fmt.Errorf("%s", reason)
+	}
+
 	if conv.Task.Status == database.TaskStatusTodo {
 		if err := s.taskService.UpdateTaskStatus(conv.Task.ID, database.TaskStatusInProgress); err != nil {
 			utils.Error("Failed to update task status", "task_id", conv.Task.ID, "error", err)
@@ -308,35 +801,134 @@ func (s *aiTaskExecutorService) processConversation(conv *database.TaskConversat
 		return fmt.Errorf("failed to create execution log: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(spanCtx)
 
-	if !s.executionManager.AddExecution(conv.ID, cancel) {
+	projectID, projectLimit := projectConcurrency(conv)
+	if !s.executionManager.AddExecution(conv.ID, projectID, projectLimit, conversationWeight(conv), cancel) {
 		s.stateManager.RollbackToState(conv, execLog,
 			database.ConversationStatusPending,
 			"reached maximum concurrency limit")
 		return fmt.Errorf("reached maximum concurrency limit")
 	}
 
+	utils.InfoContext(spanCtx, "Dispatching conversation", "conversationId", conv.ID, "taskId", conv.Task.ID)
 	go s.executeTask(ctx, conv, execLog)
 
 	return nil
 }
 
+// conversationSpanAttributes identifies the conversation, task and project a span belongs to
+func conversationSpanAttributes(conv *database.TaskConversation) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.Int64("xsha.conversation.id", int64(conv.ID)),
+		attribute.Int64("xsha.task.id", int64(conv.TaskID)),
+	}
+	if conv.Task != nil {
+		attrs = append(attrs, attribute.Int64("xsha.project.id", int64(conv.Task.ProjectID)))
+	}
+	return attrs
+}
+
+// newExecutionEvent builds a lifecycle event of the conversation, carrying the trace of ctx
+func newExecutionEvent(ctx context.Context, conv *database.TaskConversation, eventType utils.ExecutionEventType) utils.ExecutionEvent {
+	event := utils.ExecutionEvent{
+		Type:           eventType,
+		ConversationID: conv.ID,
+		TaskID:         conv.TaskID,
+		TraceID:        utils.TraceIDFromContext(ctx),
+	}
+	if conv.Task != nil {
+		event.ProjectID = conv.Task.ProjectID
+	}
+	return event
+}
+
+// publishExecutionPhase publishes that the conversation execution entered phase
+func publishExecutionPhase(ctx context.Context, conv *database.TaskConversation, phase string) {
+	event := newExecutionEvent(ctx, conv, utils.ExecutionEventPhase)
+	event.Phase = phase
+	utils.PublishExecutionEvent(event)
+}
+
+// quotaExceededReason returns why the conversation must not run when its project or task
+// has spent its cost budget or the task has used up its runs, "" otherwise. An admin override
+// on the project lifts both cost caps but not the run cap.
+func (s *aiTaskExecutorService) quotaExceededReason(conv *database.TaskConversation) string {
+	if conv.Task.MaxRuns > 0 {
+		// Only conversations that already started count, the queue may hold ones created before
+		// the cap was lowered
+		runs, err := s.taskConvRepo.CountRunsByTask(conv.Task.ID, conv.ID,
+			database.ConversationStatusDraft, database.ConversationStatusPending)
+		if err != nil {
+			utils.Error("Failed to count task runs, skipping run quota check", "task_id", conv.Task.ID, "error", err)
+		} else if runs >= int64(conv.Task.MaxRuns) {
+			return i18n.T(s.conversationLanguage(conv), "taskConversation.task_run_quota_exceeded", runs, conv.Task.MaxRuns)
+		}
+	}
+
+	project := conv.Task.Project
+	if project.BudgetOverride {
+		return ""
+	}
+
+	if project.CostBudgetUSD > 0 {
+		spent, err := s.taskConvResultRepo.GetTotalCostByProject(project.ID)
+		if err != nil {
+			utils.Error("Failed to get project cost, skipping budget check", "project_id", project.ID, "error", err)
+		} else if spent >= project.CostBudgetUSD {
+			return i18n.T(s.conversationLanguage(conv), "taskConversation.project_budget_exceeded", spent, project.CostBudgetUSD)
+		}
+	}
+
+	if conv.Task.CostBudgetUSD > 0 {
+		spent, err := s.taskConvResultRepo.GetTotalCost(conv.Task.ID)
+		if err != nil {
+			utils.Error("Failed to get task cost, skipping budget check", "task_id", conv.Task.ID, "error", err)
+		} else if spent >= conv.Task.CostBudgetUSD {
+			return i18n.T(s.conversationLanguage(conv), "taskConversation.task_budget_exceeded", spent, conv.Task.CostBudgetUSD)
+		}
+	}
+
+	return ""
+}
+
 func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.TaskConversation, execLog *database.TaskExecutionLog) {
 	var finalStatus database.ConversationStatus
 	var errorMsg string
 	var commitHash string
+	// conversationWorkspace is set when the conversation runs in its own workspace
+	var conversationWorkspace string
+	keepConversationWorkspace := false
+	startedAt := time.Now()
+
+	ctx, span := utils.StartSpan(ctx, "conversation.execute", conversationSpanAttributes(conv)...)
+	defer span.End()
+
+	utils.PublishExecutionEvent(newExecutionEvent(ctx, conv, utils.ExecutionEventStarted))
 
 	defer func() {
 		s.executionManager.RemoveExecution(conv.ID)
 
+		span.SetAttributes(attribute.String("xsha.conversation.status", string(finalStatus)))
+		if finalStatus == database.ConversationStatusFailed {
+			span.SetStatus(codes.Error, errorMsg)
+		}
+
 		conv.Status = finalStatus
+		conv.ChangesCommitted = commitHash != ""
 		if err := s.taskConvRepo.Update(conv); err != nil {
 			utils.Error("Failed to update conversation final status", "error", err)
 		}
+		utils.RecordConversationExecution(string(finalStatus), time.Since(startedAt))
 
-		if finalStatus == database.ConversationStatusFailed || finalStatus == database.ConversationStatusCancelled {
-			if conv.Task != nil && conv.Task.WorkspacePath != "" {
+		if conversationWorkspace != "" {
+			if !keepConversationWorkspace {
+				if cleanupErr := s.workspaceManager.CleanupTaskWorkspace(conversationWorkspace); cleanupErr != nil {
+					utils.Error("Failed to remove conversation workspace", "conversationId", conv.ID, "workspace", conversationWorkspace, "error", cleanupErr)
+				}
+			}
+		} else if finalStatus == database.ConversationStatusFailed || finalStatus == database.ConversationStatusCancelled {
+			if conv.Task != nil && conv.Task.WorkspacePath != "" && !conv.Task.ParallelConversations {
 				if finalStatus == database.ConversationStatusFailed {
 					if cleanupErr := s.workspaceCleaner.CleanupOnFailure(conv.Task.ID, conv.Task.WorkspacePath); cleanupErr != nil {
 						utils.Error("Error during failed task workspace cleanup", "task_id", conv.Task.ID, "error", cleanupErr)
@@ -378,9 +970,39 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 			utils.Error("Failed to get latest execution log", "execLogID", execLog.ID, "error", err)
 			latestExecLog = execLog // use original object as fallback
 		}
+		if toolVersion := s.resultParser.ParseToolVersion(latestExecLog.ExecutionLogs); toolVersion != "" {
+			latestExecLog.ToolVersion = toolVersion
+			if err := s.execLogRepo.UpdateMetadata(execLog.ID, map[string]interface{}{"tool_version": toolVersion}); err != nil {
+				utils.Error("Failed to record tool version", "execLogID", execLog.ID, "error", err)
+			}
+		}
+		if finalStatus == database.ConversationStatusFailed {
+			category := ClassifyFailure(errorMsg, latestExecLog.ExecutionLogs)
+			latestExecLog.FailureCategory = category
+			if err := s.execLogRepo.UpdateMetadata(execLog.ID, map[string]interface{}{"failure_category": category}); err != nil {
+				utils.Error("Failed to record failure category", "execLogID", execLog.ID, "error", err)
+			}
+		}
+		_, parseSpan := utils.StartSpan(ctx, "conversation.parse_result")
 		s.resultParser.ParseAndCreate(conv, latestExecLog)
+		parseSpan.End()
+
+		if err := s.execLogRepo.OffloadLogs(execLog.ID); err != nil {
+			utils.Error("Failed to offload execution log, keeping it in the database", "execLogID", execLog.ID, "error", err)
+		}
+
+		utils.InfoContext(ctx, "Conversation execution completed", "conversationId", conv.ID, "status", string(finalStatus))
 
-		utils.Info("Conversation execution completed", "conversationId", conv.ID, "status", string(finalStatus))
+		s.notifyConversationCompleted(conv, finalStatus, errorMsg, commitHash, time.Since(startedAt))
+
+		completedEvent := newExecutionEvent(ctx, conv, utils.ExecutionEventCompleted)
+		completedEvent.Status = string(finalStatus)
+		completedEvent.ErrorMessage = errorMsg
+		completedEvent.CommitHash = commitHash
+		completedEvent.DurationMs = time.Since(startedAt).Milliseconds()
+		utils.PublishExecutionEvent(completedEvent)
+
+		go s.dispatchQueuedConversations()
 	}()
 
 	select {
@@ -391,14 +1013,44 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 	default:
 	}
 
-	workspacePath, err := s.workspaceManager.GetOrCreateTaskWorkspace(conv.Task.ID, conv.Task.WorkspacePath)
+	// Conversations of the same task set up the shared workspace one at a time
+	unlockTaskWorkspace := s.lockTaskWorkspace(conv.Task.ID)
+	defer unlockTaskWorkspace()
+
+	// Another conversation of the task may have created the workspace or work branch since this
+	// one was loaded
+	if task, err := s.taskRepo.GetByID(conv.Task.ID); err == nil {
+		conv.Task.WorkspacePath = task.WorkspacePath
+		conv.Task.WorkBranch = task.WorkBranch
+	}
+
+	workBranch := conv.Task.WorkBranch
+	if workBranch == "" {
+		workBranch = utils.GenerateWorkBranchName(conv.Task.Title, conv.Task.CreatedBy)
+		conv.Task.WorkBranch = workBranch
+		if updateErr := s.taskRepo.Update(conv.Task); updateErr != nil {
+			utils.Error("Failed to update task work branch", "taskID", conv.Task.ID, "error", updateErr)
+		} else {
+			utils.Info("Generated work branch for existing task", "taskID", conv.Task.ID, "workBranch", workBranch)
+		}
+	}
+
+	publishExecutionPhase(ctx, conv, utils.ExecutionPhaseWorkspace)
+	_, workspaceSpan := utils.StartSpan(ctx, "workspace.prepare")
+	workspacePath, usesWorktree, err := s.getOrCreateTaskWorkspace(conv, workBranch)
+	utils.EndSpan(workspaceSpan, err)
 	if err != nil {
 		finalStatus = database.ConversationStatusFailed
-		errorMsg = fmt.Sprintf("failed to create workspace: %v", err)
+		var notFound *utils.StartBranchNotFoundError
+		if errors.As(err, &notFound) {
+			errorMsg = fmt.Sprintf("failed to create workspace: %s", s.startBranchErrorMessage(conv, notFound))
+		} else {
+			errorMsg = fmt.Sprintf("failed to create workspace: %v", err)
+		}
 		return
 	}
 
-	if conv.Task.WorkspacePath == "" {
+	if conv.Task.WorkspacePath != workspacePath {
 		conv.Task.WorkspacePath = workspacePath
 		if updateErr := s.taskRepo.Update(conv.Task); updateErr != nil {
 			utils.Error("Failed to update task workspace path", "error", updateErr)
@@ -419,14 +1071,21 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 	default:
 	}
 
-	proxyConfig, err := s.systemConfigService.GetGitProxyConfig()
+	proxyConfig, err := s.systemConfigService.GetGitProxyConfigForCredential(conv.Task.Project.Credential)
 	if err != nil {
 		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
 		proxyConfig = nil
 	}
 
 	if s.workspaceManager.CheckGitRepositoryExists(workspacePath) {
-		if err := s.workspaceCleaner.CleanupBeforeExecution(conv.Task.ID, workspacePath); err != nil {
+		dirtyPolicy, err := s.systemConfigService.GetWorkspaceDirtyPolicy()
+		if err != nil {
+			utils.Warn("Failed to get workspace dirty policy, resetting leftover changes", "error", err)
+			dirtyPolicy = services.WorkspaceDirtyPolicyReset
+		}
+
+		keepChanges := dirtyPolicy == services.WorkspaceDirtyPolicyKeep
+		if err := s.workspaceCleaner.CleanupBeforeExecution(conv.Task.ID, workspacePath, keepChanges); err != nil {
 			finalStatus = database.ConversationStatusFailed
 			errorMsg = fmt.Sprintf("failed to cleanup workspace before execution: %v", err)
 			return
@@ -445,20 +1104,61 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 			gitSSLVerify = false
 		}
 
-		if err := s.workspaceManager.CloneRepositoryWithConfig(
+		retryConfig, err := s.systemConfigService.GetGitCloneRetryConfig()
+		if err != nil {
+			utils.Warn("Failed to get git clone retry config, cloning without retry", "error", err)
+			retryConfig = nil
+		}
+
+		sizeCheck, err := services.CheckCloneSize(s.systemConfigService, conv.Task.Project, credential, gitSSLVerify, proxyConfig)
+		if err != nil {
+			finalStatus = database.ConversationStatusFailed
+			errorMsg = s.cloneSizeErrorMessage(conv, err, "taskConversation.repository_too_large")
+			return
+		}
+		if sizeCheck != nil {
+			warning := i18n.T(s.conversationLanguage(conv), "taskConversation.repository_size_warning", sizeCheck.LimitBytes/(1024*1024))
+			s.execLogRepo.AppendLog(execLog.ID, fmt.Sprintf("⚠️ %s\n", warning))
+		}
+
+		publishExecutionPhase(ctx, conv, utils.ExecutionPhaseClone)
+		_, cloneSpan := utils.StartSpan(ctx, "git.clone", attribute.String("xsha.git.start_branch", conv.Task.StartBranch))
+		err = s.workspaceManager.CloneRepositoryWithConfig(
+			ctx,
 			workspacePath,
 			conv.Task.Project.RepoURL,
 			conv.Task.StartBranch,
 			credential,
 			gitSSLVerify,
 			proxyConfig,
-		); err != nil {
+			retryConfig,
+			s.cloneOptions(conv.Task.Project),
+		)
+		utils.EndSpan(cloneSpan, err)
+		if err != nil {
 			finalStatus = database.ConversationStatusFailed
-			errorMsg = fmt.Sprintf("failed to clone repository: %v", err)
+			var notFound *utils.StartBranchNotFoundError
+			if errors.As(err, &notFound) {
+				errorMsg = fmt.Sprintf("failed to clone repository: %s", s.startBranchErrorMessage(conv, notFound))
+			} else {
+				errorMsg = fmt.Sprintf("failed to clone repository: %v", err)
+			}
 			return
 		}
 	}
 
+	gitConfig, err := utils.ParseGitConfigOverrides(conv.Task.Project.GitConfig)
+	if err != nil {
+		finalStatus = database.ConversationStatusFailed
+		errorMsg = fmt.Sprintf("invalid project git config: %v", err)
+		return
+	}
+	if err := s.workspaceManager.ApplyGitConfig(workspacePath, gitConfig); err != nil {
+		finalStatus = database.ConversationStatusFailed
+		errorMsg = fmt.Sprintf("failed to apply project git config: %v", err)
+		return
+	}
+
 	select {
 	case <-ctx.Done():
 		finalStatus = database.ConversationStatusCancelled
@@ -467,28 +1167,70 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 	default:
 	}
 
-	workBranch := conv.Task.WorkBranch
-	if workBranch == "" {
-		workBranch = utils.GenerateWorkBranchName(conv.Task.Title, conv.Task.CreatedBy)
-		conv.Task.WorkBranch = workBranch
-		if updateErr := s.taskRepo.Update(conv.Task); updateErr != nil {
-			utils.Error("Failed to update task work branch", "taskID", conv.Task.ID, "error", updateErr)
-		} else {
-			utils.Info("Generated work branch for existing task", "taskID", conv.Task.ID, "workBranch", workBranch)
-		}
+	failOnPullError, err := s.systemConfigService.GetGitFailOnPullError()
+	if err != nil {
+		utils.Warn("Failed to get git fail on pull error setting, using default false", "error", err)
+		failOnPullError = false
 	}
 
-	if err := s.workspaceManager.CreateAndSwitchToBranch(
-		workspacePath,
-		workBranch,
-		conv.Task.StartBranch,
-		proxyConfig,
-	); err != nil {
+	if usesWorktree {
+		// The worktree was created on the work branch from freshly fetched code, checking out
+		// the start branch could collide with other worktrees of the project
+		err = s.workspaceManager.SwitchToBranch(workspacePath, workBranch)
+	} else {
+		err = s.workspaceManager.CreateAndSwitchToBranch(
+			workspacePath,
+			workBranch,
+			conv.Task.StartBranch,
+			proxyConfig,
+			failOnPullError,
+		)
+	}
+	if err != nil {
 		finalStatus = database.ConversationStatusFailed
 		errorMsg = fmt.Sprintf("failed to create or switch to work branch: %v", err)
 		return
 	}
 
+	taskWorkspacePath := workspacePath
+	if conv.Task.ParallelConversations {
+		conversationWorkspace, err = s.prepareConversationWorkspace(conv, taskWorkspacePath, gitConfig)
+		if err != nil {
+			finalStatus = database.ConversationStatusFailed
+			errorMsg = fmt.Sprintf("failed to prepare conversation workspace: %v", err)
+			return
+		}
+		workspacePath = conversationWorkspace
+		// Its commits reach the shared workspace only through a branch of its own
+		conv.IsolatedBranch = true
+	}
+	unlockTaskWorkspace()
+
+	if conv.IsolatedBranch {
+		convBranch := conv.WorkBranch
+		if convBranch == "" {
+			convBranch = fmt.Sprintf("%s-conv-%d", workBranch, conv.ID)
+		}
+
+		// The work branch is often local only, so pulling it may fail without the base being stale
+		if err := s.workspaceManager.CreateAndSwitchToBranch(
+			workspacePath,
+			convBranch,
+			workBranch,
+			proxyConfig,
+			false,
+		); err != nil {
+			finalStatus = database.ConversationStatusFailed
+			errorMsg = fmt.Sprintf("failed to create or switch to conversation branch: %v", err)
+			return
+		}
+
+		conv.WorkBranch = convBranch
+		if updateErr := s.taskConvRepo.UpdateWorkBranch(conv.ID, convBranch); updateErr != nil {
+			utils.Error("Failed to update conversation work branch", "conversationId", conv.ID, "error", updateErr)
+		}
+	}
+
 	// Process attachments before building Docker command
 	workspaceAttachments, err := s.attachmentService.CopyAttachmentsToWorkspace(conv.ID, workspacePath)
 	if err != nil {
@@ -498,19 +1240,35 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 	}
 
 	// Replace attachment tags in conversation content with workspace paths
-	processedContent := s.attachmentService.ReplaceAttachmentTagsWithPaths(conv.Content, workspaceAttachments, workspacePath)
+	processedContent := s.attachmentService.ReplaceAttachmentTagsWithPaths(attemptContent(conv), workspaceAttachments, workspacePath)
 
 	// Create a temporary conversation with processed content for Docker execution
 	tempConv := *conv
 	tempConv.Content = processedContent
 
+	s.applyPreviousSession(&tempConv)
+
+	if err := s.reloadDevEnvironment(conv); err != nil {
+		finalStatus = database.ConversationStatusFailed
+		errorMsg = err.Error()
+		return
+	}
+
 	dockerCmdForLog := s.dockerExecutor.BuildCommandForLog(&tempConv, workspacePath)
 	dockerUpdates := map[string]interface{}{
-		"docker_command": dockerCmdForLog,
+		"docker_command":   dockerCmdForLog,
+		"effective_prompt": s.dockerExecutor.BuildEffectivePrompt(&tempConv),
 	}
 	s.execLogRepo.UpdateMetadata(execLog.ID, dockerUpdates)
 
+	// The AI may commit on its own, so whether it changed anything is decided by HEAD too
+	headBefore, err := s.workspaceManager.GetHeadCommit(workspacePath)
+	if err != nil {
+		utils.WarnContext(ctx, "Failed to read workspace HEAD before execution", "conversationId", conv.ID, "error", err)
+	}
+
 	// Execute with container tracking using processed conversation
+	publishExecutionPhase(ctx, conv, utils.ExecutionPhaseContainer)
 	containerID, err := s.dockerExecutor.ExecuteWithContainerTracking(ctx, &tempConv, workspacePath, execLog.ID)
 	if containerID != "" {
 		// Set the container ID in execution manager for proper cleanup on cancellation
@@ -534,15 +1292,250 @@ func (s *aiTaskExecutorService) executeTask(ctx context.Context, conv *database.
 		utils.Warn("Failed to cleanup workspace attachments before commit", "workspace", workspacePath, "error", cleanupErr)
 	}
 
-	hash, err := s.workspaceManager.CommitChanges(workspacePath, fmt.Sprintf("AI generated changes for conversation %d", conv.ID))
-	if err != nil {
+	if conv.Task.Project.RequireCommitApproval {
+		dirty, err := s.workspaceManager.CheckWorkspaceIsDirty(workspacePath)
+		if err != nil {
+			finalStatus = database.ConversationStatusFailed
+			errorMsg = fmt.Sprintf("failed to check workspace changes for approval: %v", err)
+			return
+		}
+		if dirty {
+			// The changes stay uncommitted until ApproveConversation or RejectConversation
+			keepConversationWorkspace = true
+			finalStatus = database.ConversationStatusAwaitingApproval
+			return
+		}
+	}
+
+	commitBranch := workBranch
+	if conv.IsolatedBranch {
+		commitBranch = conv.WorkBranch
+	}
+	if err := s.workspaceManager.EnsureOnBranch(workspacePath, commitBranch); err != nil {
+		finalStatus = database.ConversationStatusFailed
+		errorMsg = fmt.Sprintf("failed to prepare branch for commit: %v", err)
+		return
+	}
+
+	commitMessage, authorName, authorEmail := s.resolveCommitSettings(conv, commitBranch)
+	publishExecutionPhase(ctx, conv, utils.ExecutionPhaseCommit)
+	_, commitSpan := utils.StartSpan(ctx, "git.commit")
+	hash, err := s.workspaceManager.CommitChanges(workspacePath, commitMessage, authorName, authorEmail)
+	utils.EndSpan(commitSpan, err)
+	if err == utils.ErrNoChangesToCommit {
+		if headAfter, headErr := s.workspaceManager.GetHeadCommit(workspacePath); headErr == nil && headBefore != "" && headAfter != headBefore {
+			commitHash = headAfter
+		} else {
+			utils.InfoContext(ctx, "Conversation produced no file changes", "conversationId", conv.ID)
+			if s.noChangesPolicy() == services.NoChangesPolicyFail {
+				finalStatus = database.ConversationStatusFailed
+				errorMsg = "the AI made no file changes to commit"
+				return
+			}
+		}
+	} else if err != nil {
+		finalStatus = database.ConversationStatusFailed
+		errorMsg = fmt.Sprintf("failed to commit changes: %v", err)
+		return
 	} else {
 		commitHash = hash
 	}
 
+	if conversationWorkspace != "" {
+		unlock := s.lockTaskWorkspace(conv.Task.ID)
+		err := s.workspaceManager.FetchBranchFromWorkspace(taskWorkspacePath, conversationWorkspace, commitBranch)
+		if err == nil {
+			err = s.workspaceManager.MergeBranch(taskWorkspacePath, workBranch, commitBranch, authorName, authorEmail)
+		}
+		unlock()
+		if err != nil {
+			// Keep the commits, a retry reuses the workspace and the branch can be merged by hand
+			keepConversationWorkspace = true
+			finalStatus = database.ConversationStatusFailed
+			errorMsg = fmt.Sprintf("failed to bring conversation branch into the task workspace: %v", err)
+			return
+		}
+	}
+
 	finalStatus = database.ConversationStatusSuccess
 }
 
+// noChangesPolicy returns how a conversation that changed no files finishes
+func (s *aiTaskExecutorService) noChangesPolicy() string {
+	policy, err := s.systemConfigService.GetNoChangesPolicy()
+	if err != nil {
+		utils.Warn("Failed to get no changes policy, using default succeed", "error", err)
+		return services.NoChangesPolicySucceed
+	}
+	return policy
+}
+
+// startBranchErrorMessage explains a missing start branch with the branches to choose from
+func (s *aiTaskExecutorService) startBranchErrorMessage(conv *database.TaskConversation, err *utils.StartBranchNotFoundError) string {
+	return i18n.T(s.conversationLanguage(conv), "taskConversation.start_branch_not_found", err.Branch, err.AvailableBranchList())
+}
+
+// cloneSizeErrorMessage explains a refused clone in the conversation's language, tooLargeKey
+// being the message for a repository over the limit
+func (s *aiTaskExecutorService) cloneSizeErrorMessage(conv *database.TaskConversation, err error, tooLargeKey string) string {
+	if err == appErrors.ErrRepositorySizeUnknown {
+		return i18n.T(s.conversationLanguage(conv), "taskConversation.repository_size_unknown")
+	}
+	return i18n.T(s.conversationLanguage(conv), tooLargeKey)
+}
+
+// conversationLanguage returns the language for messages recorded while a conversation runs:
+// the preferred language of its creator, or English like requests that name no language
+func (s *aiTaskExecutorService) conversationLanguage(conv *database.TaskConversation) string {
+	if s.authService != nil {
+		preferred, err := s.authService.GetPreferredLanguage(conv.CreatedBy)
+		if err != nil {
+			utils.Warn("Failed to get preferred language of conversation creator", "conversationId", conv.ID, "error", err)
+		} else if preferred != "" {
+			return preferred
+		}
+	}
+	return "en-US"
+}
+
+// getOrCreateTaskWorkspace returns the task's workspace and whether it is a worktree. Projects
+// using worktrees get a worktree of their shared clone, except for tasks that already have a
+// full clone, which keep it.
+func (s *aiTaskExecutorService) getOrCreateTaskWorkspace(conv *database.TaskConversation, workBranch string) (string, bool, error) {
+	task := conv.Task
+	project := task.Project
+
+	hasFullClone := s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) && !s.workspaceManager.IsProjectWorktree(task.WorkspacePath)
+	if !project.UseWorktrees || hasFullClone {
+		workspacePath, err := s.workspaceManager.GetOrCreateTaskWorkspace(task.ID, task.WorkspacePath)
+		return workspacePath, false, err
+	}
+
+	credential, err := s.prepareGitCredential(project)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to prepare git credential: %v", err)
+	}
+
+	gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+	if err != nil {
+		utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+		gitSSLVerify = false
+	}
+
+	proxyConfig, err := s.systemConfigService.GetGitProxyConfigForCredential(project.Credential)
+	if err != nil {
+		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
+		proxyConfig = nil
+	}
+
+	if !s.workspaceManager.HasProjectClone(project.ID) {
+		// The shared clone always holds the full history, shallow clone settings do not apply to it
+		fullClone := *project
+		fullClone.ShallowClone = false
+		if _, err := services.CheckCloneSize(s.systemConfigService, &fullClone, credential, gitSSLVerify, proxyConfig); err != nil {
+			return "", true, errors.New(s.cloneSizeErrorMessage(conv, err, "taskConversation.repository_too_large_worktree"))
+		}
+	}
+
+	workspacePath, err := s.workspaceManager.GetOrCreateProjectWorktree(project.ID, task.ID, workBranch, utils.ProjectWorktreeSource{
+		RepoURL:           project.RepoURL,
+		BaseBranch:        task.StartBranch,
+		Credential:        credential,
+		SSLVerify:         gitSSLVerify,
+		ProxyConfig:       proxyConfig,
+		RecurseSubmodules: project.RecurseSubmodules,
+	})
+	return workspacePath, true, err
+}
+
+// lockTaskWorkspace locks the task's shared workspace and returns an unlock function that may
+// be called more than once. Workspace snapshots and restores take the same lock.
+func (s *aiTaskExecutorService) lockTaskWorkspace(taskID uint) func() {
+	return s.workspaceManager.LockTaskWorkspace(taskID)
+}
+
+// prepareConversationWorkspace gives a parallel conversation its own workspace, cloned from the
+// task's shared one after the work branch was checked out there. A workspace left by an earlier
+// run of the conversation is cleaned and reused.
+func (s *aiTaskExecutorService) prepareConversationWorkspace(conv *database.TaskConversation, taskWorkspacePath string, gitConfig []utils.GitConfigEntry) (string, error) {
+	workspacePath, err := s.workspaceManager.GetOrCreateConversationWorkspace(conv.Task.ID, conv.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if s.workspaceManager.CheckGitRepositoryExists(workspacePath) {
+		if err := s.workspaceCleaner.CleanupBeforeExecution(conv.Task.ID, workspacePath, false); err != nil {
+			return workspacePath, err
+		}
+	} else if err := s.workspaceManager.CloneFromWorkspace(workspacePath, taskWorkspacePath); err != nil {
+		return workspacePath, err
+	}
+
+	if err := s.workspaceManager.ApplyGitConfig(workspacePath, gitConfig); err != nil {
+		return workspacePath, err
+	}
+	return workspacePath, nil
+}
+
+// resolveCommitSettings returns the commit message and author for a conversation, using
+// project settings first, then system config, then the built-in defaults
+func (s *aiTaskExecutorService) resolveCommitSettings(conv *database.TaskConversation, branch string) (string, string, string) {
+	commitConfig, err := s.systemConfigService.GetGitCommitConfig()
+	if err != nil {
+		utils.Warn("Failed to get git commit config, using defaults", "error", err)
+		commitConfig = &services.GitCommitConfig{
+			AuthorName:      "XSHA AI",
+			AuthorEmail:     "ai@xsha.dev",
+			MessageTemplate: utils.DefaultCommitMessageTemplate,
+		}
+	}
+
+	project := conv.Task.Project
+	if project.CommitAuthorName != "" {
+		commitConfig.AuthorName = project.CommitAuthorName
+	}
+	if project.CommitAuthorEmail != "" {
+		commitConfig.AuthorEmail = project.CommitAuthorEmail
+	}
+	if strings.TrimSpace(project.CommitMessageTemplate) != "" {
+		commitConfig.MessageTemplate = project.CommitMessageTemplate
+	}
+
+	data := utils.CommitMessageData{
+		TaskID:              conv.Task.ID,
+		TaskTitle:           conv.Task.Title,
+		ConversationID:      conv.ID,
+		ConversationContent: attemptContent(conv),
+		ProjectName:         project.Name,
+		WorkBranch:          branch,
+	}
+
+	message, err := utils.RenderCommitMessage(commitConfig.MessageTemplate, data)
+	if err != nil {
+		utils.Warn("Failed to render commit message template, using default", "conversationId", conv.ID, "error", err)
+		message, _ = utils.RenderCommitMessage(utils.DefaultCommitMessageTemplate, data)
+	}
+
+	return message, commitConfig.AuthorName, commitConfig.AuthorEmail
+}
+
+// cloneOptions derives clone settings from a project, a shallow clone without a depth uses 1
+func (s *aiTaskExecutorService) cloneOptions(project *database.Project) utils.GitCloneOptions {
+	options := utils.GitCloneOptions{
+		RecurseSubmodules: project.RecurseSubmodules,
+	}
+	if s.workspaceManager.HasReferenceCache(project.ID) {
+		options.ReferencePath = s.workspaceManager.ReferenceCachePath(project.ID)
+	}
+	if project.ShallowClone {
+		options.Depth = 1
+		if project.CloneDepth > 0 {
+			options.Depth = project.CloneDepth
+		}
+	}
+	return options
+}
+
 func (s *aiTaskExecutorService) prepareGitCredential(project *database.Project) (*utils.GitCredentialInfo, error) {
 	if project.Credential == nil {
 		return nil, nil
@@ -567,6 +1560,14 @@ func (s *aiTaskExecutorService) prepareGitCredential(project *database.Project)
 		}
 		credential.PrivateKey = privateKey
 		credential.PublicKey = project.Credential.PublicKey
+	case database.GitCredentialTypeGitHubApp:
+		privateKey, err := s.gitCredService.DecryptCredentialSecret(project.Credential, "private_key")
+		if err != nil {
+			return nil, err
+		}
+		credential.PrivateKey = privateKey
+		credential.AppID = project.Credential.GitHubAppID
+		credential.InstallationID = project.Credential.GitHubInstallationID
 	}
 
 	return credential, nil
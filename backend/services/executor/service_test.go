@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"testing"
+	"xsha-backend/database"
+	"xsha-backend/repository"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&database.GitCredential{},
+		&database.Project{},
+		&database.DevEnvironment{},
+		&database.Task{},
+		&database.TaskConversation{},
+	); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestReloadDevEnvironmentPicksUpChangedEnvironment(t *testing.T) {
+	db := newTestDB(t)
+
+	project := &database.Project{Name: "project", RepoURL: "https://github.com/example/repo.git", Protocol: database.GitProtocolHTTPS}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	devEnv := &database.DevEnvironment{Name: "env", Type: "claude-code", DockerImage: "example/image:v1", CPULimit: 1, MemoryLimit: 1024}
+	if err := db.Create(devEnv).Error; err != nil {
+		t.Fatalf("failed to create dev environment: %v", err)
+	}
+	task := &database.Task{Title: "task", ProjectID: project.ID, DevEnvironmentID: &devEnv.ID, Status: database.TaskStatusInProgress}
+	if err := db.Create(task).Error; err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	conv := &database.TaskConversation{TaskID: task.ID, Content: "fix the bug", Status: database.ConversationStatusFailed}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	s := &aiTaskExecutorService{taskConvRepo: repository.NewTaskConversationRepository(db, nil, nil)}
+
+	// Loaded before the environment changes, like a conversation picked up for a retry
+	loaded, err := s.taskConvRepo.GetByID(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to load conversation: %v", err)
+	}
+
+	if err := db.Model(devEnv).Updates(map[string]interface{}{
+		"docker_image": "example/image:v2",
+		"cpu_limit":    4.0,
+		"memory_limit": 8192,
+	}).Error; err != nil {
+		t.Fatalf("failed to update dev environment: %v", err)
+	}
+
+	if err := s.reloadDevEnvironment(loaded); err != nil {
+		t.Fatalf("reloadDevEnvironment returned an error: %v", err)
+	}
+
+	got := loaded.Task.DevEnvironment
+	if got.DockerImage != "example/image:v2" {
+		t.Errorf("docker image = %q, want %q", got.DockerImage, "example/image:v2")
+	}
+	if got.CPULimit != 4 {
+		t.Errorf("cpu limit = %v, want 4", got.CPULimit)
+	}
+	if got.MemoryLimit != 8192 {
+		t.Errorf("memory limit = %d, want 8192", got.MemoryLimit)
+	}
+}
+
+func TestReloadDevEnvironmentFailsWithoutEnvironment(t *testing.T) {
+	db := newTestDB(t)
+
+	project := &database.Project{Name: "project", RepoURL: "https://github.com/example/repo.git", Protocol: database.GitProtocolHTTPS}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	devEnv := &database.DevEnvironment{Name: "env", Type: "claude-code", DockerImage: "example/image:v1"}
+	if err := db.Create(devEnv).Error; err != nil {
+		t.Fatalf("failed to create dev environment: %v", err)
+	}
+	task := &database.Task{Title: "task", ProjectID: project.ID, DevEnvironmentID: &devEnv.ID, Status: database.TaskStatusInProgress}
+	if err := db.Create(task).Error; err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	conv := &database.TaskConversation{TaskID: task.ID, Content: "fix the bug", Status: database.ConversationStatusFailed}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	s := &aiTaskExecutorService{taskConvRepo: repository.NewTaskConversationRepository(db, nil, nil)}
+
+	loaded, err := s.taskConvRepo.GetByID(conv.ID)
+	if err != nil {
+		t.Fatalf("failed to load conversation: %v", err)
+	}
+
+	if err := db.Model(task).Update("dev_environment_id", nil).Error; err != nil {
+		t.Fatalf("failed to detach dev environment: %v", err)
+	}
+
+	if err := s.reloadDevEnvironment(loaded); err == nil {
+		t.Fatal("reloadDevEnvironment succeeded for a task without a dev environment")
+	}
+}
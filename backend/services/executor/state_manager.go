@@ -71,3 +71,30 @@ func (c *conversationStateManager) RollbackToState(
 		utils.Error("failed to update execution log", "error", updateErr)
 	}
 }
+
+// FailInterrupted fails a running conversation whose execution is gone and records why on its
+// execution log. It reports false when the conversation was no longer running.
+func (c *conversationStateManager) FailInterrupted(conv *database.TaskConversation, errorMessage string) bool {
+	failed, err := c.taskConvRepo.TransitionStatus(conv.ID, database.ConversationStatusRunning, database.ConversationStatusFailed)
+	if err != nil {
+		utils.Error("failed to mark interrupted conversation as failed", "conversationId", conv.ID, "error", err)
+		return false
+	}
+	if !failed {
+		return false
+	}
+	conv.Status = database.ConversationStatusFailed
+
+	if execLog, err := c.execLogRepo.GetByConversationID(conv.ID); err == nil {
+		now := utils.Now()
+		updates := map[string]interface{}{
+			"error_message":    errorMessage,
+			"completed_at":     &now,
+			"failure_category": database.FailureCategoryUnknown,
+		}
+		if err := c.execLogRepo.UpdateMetadata(execLog.ID, updates); err != nil {
+			utils.Warn("failed to record interruption on execution log", "conversationId", conv.ID, "error", err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"xsha-backend/database"
+	"xsha-backend/utils"
+)
+
+// webhookTimeout bounds a single completion notification so a slow endpoint cannot pile up goroutines
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// ConversationCompletedPayload is posted to the notification webhook when a conversation finishes.
+// Text carries a readable summary so Slack and Teams incoming webhooks can render it as is.
+type ConversationCompletedPayload struct {
+	Event          string    `json:"event"`
+	Text           string    `json:"text"`
+	ConversationID uint      `json:"conversation_id"`
+	TaskID         uint      `json:"task_id"`
+	TaskTitle      string    `json:"task_title"`
+	ProjectID      uint      `json:"project_id"`
+	ProjectName    string    `json:"project_name"`
+	Status         string    `json:"status"`
+	ErrorMessage   string    `json:"error_message,omitempty"`
+	CommitHash     string    `json:"commit_hash,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CompletedAt    time.Time `json:"completed_at"`
+}
+
+// webhookURLFor returns the project's webhook override, or the global webhook when it has none
+func (s *aiTaskExecutorService) webhookURLFor(conv *database.TaskConversation) string {
+	if conv.Task != nil && conv.Task.Project != nil && conv.Task.Project.WebhookURL != "" {
+		return conv.Task.Project.WebhookURL
+	}
+
+	webhookURL, err := s.systemConfigService.GetNotificationWebhookURL()
+	if err != nil {
+		utils.Warn("Failed to get notification webhook URL", "error", err)
+		return ""
+	}
+	return webhookURL
+}
+
+// notifyConversationCompleted posts the completion payload in the background; failures are only logged
+func (s *aiTaskExecutorService) notifyConversationCompleted(conv *database.TaskConversation, status database.ConversationStatus, errorMsg, commitHash string, duration time.Duration) {
+	webhookURL := s.webhookURLFor(conv)
+	if webhookURL == "" {
+		return
+	}
+
+	payload := ConversationCompletedPayload{
+		Event:          "conversation.completed",
+		ConversationID: conv.ID,
+		TaskID:         conv.TaskID,
+		Status:         string(status),
+		ErrorMessage:   errorMsg,
+		CommitHash:     commitHash,
+		DurationMs:     duration.Milliseconds(),
+		CompletedAt:    utils.Now(),
+	}
+	if conv.Task != nil {
+		payload.TaskTitle = conv.Task.Title
+		payload.ProjectID = conv.Task.ProjectID
+		if conv.Task.Project != nil {
+			payload.ProjectName = conv.Task.Project.Name
+		}
+	}
+	payload.Text = fmt.Sprintf("[%s] Task \"%s\" conversation #%d finished with status %s in %s",
+		payload.ProjectName, payload.TaskTitle, conv.ID, status, duration.Round(time.Second))
+	if errorMsg != "" {
+		payload.Text += ": " + errorMsg
+	}
+
+	go postWebhook(webhookURL, payload)
+}
+
+func postWebhook(webhookURL string, payload ConversationCompletedPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		utils.Error("Failed to encode webhook payload", "conversationId", payload.ConversationID, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		utils.Error("Failed to create webhook request", "conversationId", payload.ConversationID, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		utils.Warn("Failed to send conversation webhook", "conversationId", payload.ConversationID, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 300 {
+		utils.Warn("Conversation webhook returned unexpected status", "conversationId", payload.ConversationID, "status", resp.StatusCode)
+		return
+	}
+
+	utils.Info("Conversation webhook sent", "conversationId", payload.ConversationID, "status", payload.Status)
+}
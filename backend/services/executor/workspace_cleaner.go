@@ -67,7 +67,9 @@ func (w *workspaceCleaner) CleanupOnCancel(taskID uint, workspacePath string) er
 	return nil
 }
 
-func (w *workspaceCleaner) CleanupBeforeExecution(taskID uint, workspacePath string) error {
+// CleanupBeforeExecution handles uncommitted changes left behind in a reused workspace, e.g. by
+// an aborted run. The changes are reset unless keepChanges is set, and are logged either way.
+func (w *workspaceCleaner) CleanupBeforeExecution(taskID uint, workspacePath string, keepChanges bool) error {
 	if workspacePath == "" {
 		utils.Warn("Workspace path is empty, skipping pre-execution cleanup", "task_id", taskID)
 		return nil
@@ -75,21 +77,29 @@ func (w *workspaceCleaner) CleanupBeforeExecution(taskID uint, workspacePath str
 
 	utils.Info("Starting pre-execution workspace cleanup", "task_id", taskID, "workspace", workspacePath)
 
-	isDirty, err := w.workspaceManager.CheckWorkspaceIsDirty(workspacePath)
+	changes, err := w.workspaceManager.ListUncommittedChanges(workspacePath)
 	if err != nil {
 		utils.Error("Failed to check workspace status during pre-execution cleanup", "task_id", taskID, "workspace", workspacePath, "error", err)
 		return fmt.Errorf("failed to check workspace status: %v", err)
 	}
 
-	if isDirty {
-		if resetErr := w.workspaceManager.ResetWorkspaceToCleanState(workspacePath); resetErr != nil {
-			utils.Error("Failed to reset workspace during pre-execution cleanup", "task_id", taskID, "workspace", workspacePath, "error", resetErr)
-			return fmt.Errorf("failed to cleanup workspace before execution: %v", resetErr)
-		}
-		utils.Info("Cleaned workspace uncommitted changes before execution", "task_id", taskID, "workspace", workspacePath)
-	} else {
+	if len(changes) == 0 {
 		utils.Info("Workspace is clean, no pre-execution cleanup needed", "task_id", taskID, "workspace", workspacePath)
+		return nil
+	}
+
+	utils.Warn("Found leftover uncommitted changes in reused workspace", "task_id", taskID, "workspace", workspacePath, "count", len(changes), "changes", changes)
+
+	if keepChanges {
+		utils.Warn("Keeping leftover workspace changes as configured", "task_id", taskID, "workspace", workspacePath)
+		return nil
+	}
+
+	if resetErr := w.workspaceManager.ResetWorkspaceToCleanState(workspacePath); resetErr != nil {
+		utils.Error("Failed to reset workspace during pre-execution cleanup", "task_id", taskID, "workspace", workspacePath, "error", resetErr)
+		return fmt.Errorf("failed to cleanup workspace before execution: %v", resetErr)
 	}
+	utils.Info("Cleaned workspace uncommitted changes before execution", "task_id", taskID, "workspace", workspacePath)
 
 	return nil
 }
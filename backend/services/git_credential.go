@@ -2,28 +2,33 @@ package services
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"xsha-backend/config"
 	"xsha-backend/database"
 	appErrors "xsha-backend/errors"
 	"xsha-backend/repository"
+	"xsha-backend/utils"
 )
 
 type gitCredentialService struct {
-	repo        repository.GitCredentialRepository
-	projectRepo repository.ProjectRepository
-	config      *config.Config
+	repo                repository.GitCredentialRepository
+	projectRepo         repository.ProjectRepository
+	systemConfigService SystemConfigService
+	config              *config.Config
 }
 
-func NewGitCredentialService(repo repository.GitCredentialRepository, projectRepo repository.ProjectRepository, cfg *config.Config) GitCredentialService {
+func NewGitCredentialService(repo repository.GitCredentialRepository, projectRepo repository.ProjectRepository, systemConfigService SystemConfigService, cfg *config.Config) GitCredentialService {
 	return &gitCredentialService{
-		repo:        repo,
-		projectRepo: projectRepo,
-		config:      cfg,
+		repo:                repo,
+		projectRepo:         projectRepo,
+		systemConfigService: systemConfigService,
+		config:              cfg,
 	}
 }
 
-func (s *gitCredentialService) CreateCredential(name, description, credType, username string, secretData map[string]string, createdBy string) (*database.GitCredential, error) {
+func (s *gitCredentialService) CreateCredential(name, description, credType, username string, secretData map[string]string, proxy GitCredentialProxy, createdBy string) (*database.GitCredential, error) {
 	if err := s.ValidateCredentialData(credType, secretData); err != nil {
 		return nil, err
 	}
@@ -40,6 +45,11 @@ func (s *gitCredentialService) CreateCredential(name, description, credType, use
 		CreatedBy:   createdBy,
 	}
 
+	applyCredentialProxy(credential, proxy)
+	if err := validateCredentialProxy(credential); err != nil {
+		return nil, err
+	}
+
 	switch database.GitCredentialType(credType) {
 	case database.GitCredentialTypePassword, database.GitCredentialTypeToken:
 		if password, ok := secretData["password"]; ok {
@@ -52,6 +62,10 @@ func (s *gitCredentialService) CreateCredential(name, description, credType, use
 		if publicKey, ok := secretData["public_key"]; ok {
 			credential.PublicKey = publicKey
 		}
+	case database.GitCredentialTypeGitHubApp:
+		credential.GitHubAppID = strings.TrimSpace(secretData["app_id"])
+		credential.GitHubInstallationID = strings.TrimSpace(secretData["installation_id"])
+		credential.PrivateKey = secretData["private_key"]
 	}
 
 	if err := s.repo.Create(credential); err != nil {
@@ -84,6 +98,16 @@ func (s *gitCredentialService) UpdateCredential(id uint, updates map[string]inte
 	if username, ok := updates["username"]; ok {
 		credential.Username = username.(string)
 	}
+	if proxy, ok := updates["proxy"]; ok {
+		proxySettings, ok := proxy.(GitCredentialProxy)
+		if !ok {
+			return fmt.Errorf("invalid proxy type")
+		}
+		applyCredentialProxy(credential, proxySettings)
+		if err := validateCredentialProxy(credential); err != nil {
+			return err
+		}
+	}
 
 	if len(secretData) > 0 {
 		switch credential.Type {
@@ -98,6 +122,19 @@ func (s *gitCredentialService) UpdateCredential(id uint, updates map[string]inte
 			if publicKey, ok := secretData["public_key"]; ok {
 				credential.PublicKey = publicKey
 			}
+		case database.GitCredentialTypeGitHubApp:
+			if appID, ok := secretData["app_id"]; ok {
+				credential.GitHubAppID = strings.TrimSpace(appID)
+			}
+			if installationID, ok := secretData["installation_id"]; ok {
+				credential.GitHubInstallationID = strings.TrimSpace(installationID)
+			}
+			if privateKey, ok := secretData["private_key"]; ok {
+				credential.PrivateKey = privateKey
+			}
+			if err := validateGitHubAppCredential(credential.GitHubAppID, credential.GitHubInstallationID, credential.PrivateKey); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -129,6 +166,68 @@ func (s *gitCredentialService) ListActiveCredentials(credType *database.GitCrede
 	return credentials, err
 }
 
+// TestCredential checks that the credential can access repoURL by listing its branches with
+// git ls-remote, using the same SSL verification and proxy settings as clones and pushes
+func (s *gitCredentialService) TestCredential(id uint, repoURL string) (*utils.GitAccessResult, error) {
+	credential, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, appErrors.ErrCredentialNotFound
+	}
+
+	if err := utils.ValidateGitURL(repoURL); err != nil {
+		return &utils.GitAccessResult{
+			CanAccess:    false,
+			ErrorMessage: fmt.Sprintf("invalid repository URL format: %v", err),
+		}, nil
+	}
+
+	credentialInfo := &utils.GitCredentialInfo{
+		Type:     utils.GitCredentialType(credential.Type),
+		Username: credential.Username,
+	}
+
+	var secret string
+	switch credential.Type {
+	case database.GitCredentialTypePassword, database.GitCredentialTypeToken:
+		secret, err = s.DecryptCredentialSecret(credential, "password")
+		credentialInfo.Password = secret
+	case database.GitCredentialTypeSSHKey:
+		secret, err = s.DecryptCredentialSecret(credential, "private_key")
+		credentialInfo.PrivateKey = secret
+		credentialInfo.PublicKey = credential.PublicKey
+	case database.GitCredentialTypeGitHubApp:
+		secret, err = s.DecryptCredentialSecret(credential, "private_key")
+		credentialInfo.PrivateKey = secret
+		credentialInfo.AppID = credential.GitHubAppID
+		credentialInfo.InstallationID = credential.GitHubInstallationID
+	default:
+		err = appErrors.ErrCredentialUnsupportedType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	proxyConfig, err := s.systemConfigService.GetGitProxyConfigForCredential(credential)
+	if err != nil {
+		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
+		proxyConfig = nil
+	}
+
+	gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+	if err != nil {
+		utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+		gitSSLVerify = false
+	}
+
+	result, err := utils.FetchRepositoryBranchesWithConfig(repoURL, credentialInfo, gitSSLVerify, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+	result.ErrorMessage = utils.SanitizeGitOutput(result.ErrorMessage, secret)
+
+	return result, nil
+}
+
 func (s *gitCredentialService) DecryptCredentialSecret(credential *database.GitCredential, secretType string) (string, error) {
 	switch secretType {
 	case "password", "token":
@@ -163,8 +262,59 @@ func (s *gitCredentialService) ValidateCredentialData(credType string, data map[
 		if !strings.Contains(data["private_key"], "BEGIN") {
 			return appErrors.ErrCredentialInvalidPrivateKeyFormat
 		}
+	case database.GitCredentialTypeGitHubApp:
+		return validateGitHubAppCredential(data["app_id"], data["installation_id"], data["private_key"])
 	default:
 		return appErrors.ErrCredentialUnsupportedType
 	}
 	return nil
 }
+
+// validateGitHubAppCredential checks the app identifiers are set and the private key parses
+func validateGitHubAppCredential(appID, installationID, privateKey string) error {
+	if strings.TrimSpace(appID) == "" || strings.TrimSpace(installationID) == "" || privateKey == "" {
+		return appErrors.ErrCredentialGitHubAppFieldsRequired
+	}
+	if _, err := strconv.ParseUint(strings.TrimSpace(installationID), 10, 64); err != nil {
+		return appErrors.ErrCredentialGitHubAppFieldsRequired
+	}
+	if _, err := utils.ParseGitHubAppPrivateKey(privateKey); err != nil {
+		return appErrors.ErrCredentialInvalidPrivateKeyFormat
+	}
+	return nil
+}
+
+func applyCredentialProxy(credential *database.GitCredential, proxy GitCredentialProxy) {
+	credential.ProxyMode = database.GitProxyMode(strings.TrimSpace(proxy.Mode))
+	if credential.ProxyMode == "" {
+		credential.ProxyMode = database.GitProxyModeInherit
+	}
+	credential.ProxyHTTP = strings.TrimSpace(proxy.HTTP)
+	credential.ProxyHTTPS = strings.TrimSpace(proxy.HTTPS)
+	credential.ProxyNoProxy = strings.TrimSpace(proxy.NoProxy)
+}
+
+// validateCredentialProxy checks the proxy mode and that a custom proxy has at least one valid proxy URL
+func validateCredentialProxy(credential *database.GitCredential) error {
+	switch credential.ProxyMode {
+	case database.GitProxyModeInherit, database.GitProxyModeNone:
+		return nil
+	case database.GitProxyModeCustom:
+	default:
+		return appErrors.ErrCredentialProxyInvalid
+	}
+
+	if credential.ProxyHTTP == "" && credential.ProxyHTTPS == "" {
+		return appErrors.ErrCredentialProxyInvalid
+	}
+	for _, proxyURL := range []string{credential.ProxyHTTP, credential.ProxyHTTPS} {
+		if proxyURL == "" {
+			continue
+		}
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return appErrors.ErrCredentialProxyInvalid
+		}
+	}
+	return nil
+}
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"regexp"
 	"time"
 	"xsha-backend/database"
+	"xsha-backend/repository"
 	"xsha-backend/utils"
 )
 
@@ -11,6 +13,8 @@ type AuthService interface {
 	Logout(token, username, clientIP, userAgent string) error
 	IsTokenBlacklisted(token string) (bool, error)
 	CleanExpiredTokens() error
+	GetPreferredLanguage(username string) (string, error)
+	SetPreferredLanguage(username, language string) error
 }
 
 type LoginLogService interface {
@@ -19,18 +23,27 @@ type LoginLogService interface {
 }
 
 type GitCredentialService interface {
-	CreateCredential(name, description, credType, username string, secretData map[string]string, createdBy string) (*database.GitCredential, error)
+	CreateCredential(name, description, credType, username string, secretData map[string]string, proxy GitCredentialProxy, createdBy string) (*database.GitCredential, error)
 	GetCredential(id uint) (*database.GitCredential, error)
 	ListCredentials(name *string, credType *database.GitCredentialType, page, pageSize int) ([]database.GitCredential, int64, error)
 	UpdateCredential(id uint, updates map[string]interface{}, secretData map[string]string) error
 	DeleteCredential(id uint) error
 	ListActiveCredentials(credType *database.GitCredentialType) ([]database.GitCredential, error)
 	DecryptCredentialSecret(credential *database.GitCredential, secretType string) (string, error)
+	TestCredential(id uint, repoURL string) (*utils.GitAccessResult, error)
 	ValidateCredentialData(credType string, data map[string]string) error
 }
 
+// GitCredentialProxy is the proxy override of a git credential, an empty mode inherits the global proxy
+type GitCredentialProxy struct {
+	Mode    string `json:"mode"`
+	HTTP    string `json:"http"`
+	HTTPS   string `json:"https"`
+	NoProxy string `json:"no_proxy"`
+}
+
 type ProjectService interface {
-	CreateProject(name, description, systemPrompt, repoURL, protocol string, credentialID *uint, createdBy string) (*database.Project, error)
+	CreateProject(name, description, systemPrompt, repoURL, protocol string, credentialID *uint, shallowClone bool, cloneDepth int, recurseSubmodules bool, commitAuthorName, commitAuthorEmail, commitMessageTemplate string, createdBy string) (*database.Project, error)
 	GetProject(id uint) (*database.Project, error)
 	ListProjects(name string, protocol *database.GitProtocolType, page, pageSize int) ([]database.Project, int64, error)
 	ListProjectsWithTaskCount(name string, protocol *database.GitProtocolType, sortBy, sortDirection string, page, pageSize int) (interface{}, int64, error)
@@ -40,6 +53,12 @@ type ProjectService interface {
 	GetCompatibleCredentials(protocol database.GitProtocolType) ([]database.GitCredential, error)
 	FetchRepositoryBranches(repoURL string, credentialID *uint) (*utils.GitAccessResult, error)
 	ValidateRepositoryAccess(repoURL string, credentialID *uint) error
+	CheckRepositorySize(repoURL string, credentialID *uint, shallowClone bool) (*RepositorySizeCheck, error)
+	RevalidateAllProjects() (*ProjectRevalidationReport, error)
+	PrewarmProject(id uint) error
+	RefreshReferenceCaches() (int, error)
+	GetProjectBudget(id uint) (*ProjectBudget, error)
+	SetBudgetOverride(id uint, enabled bool) error
 }
 
 type AdminOperationLogService interface {
@@ -60,21 +79,34 @@ type AdminOperationLogService interface {
 }
 
 type DevEnvironmentService interface {
-	CreateEnvironment(name, description, systemPrompt, envType, dockerImage string, cpuLimit float64, memoryLimit int64, envVars map[string]string, createdBy string) (*database.DevEnvironment, error)
+	CreateEnvironment(name, description, systemPrompt, envType, dockerImage string, cpuLimit float64, memoryLimit int64, networkMode string, gpuEnabled bool, gpuDevice string, ulimits map[string]string, extraDockerArgs []string, readOnlyRootFS, noNewPrivileges bool, runAsUser string, tmpfsMounts []string, envVars map[string]string, createdBy string) (*database.DevEnvironment, error)
 	GetEnvironment(id uint) (*database.DevEnvironment, error)
-	ListEnvironments(name *string, dockerImage *string, page, pageSize int) ([]database.DevEnvironment, int64, error)
+	ListEnvironments(name *string, dockerImage *string, minCPU, maxCPU *float64, minMemory, maxMemory *int64, page, pageSize int) ([]database.DevEnvironment, int64, error)
 	UpdateEnvironment(id uint, updates map[string]interface{}) error
 	DeleteEnvironment(id uint) error
 	ValidateEnvVars(envVars map[string]string) error
+	ValidateUlimits(ulimits map[string]string) error
+	ValidateEnvFiles(envFiles map[string]string) error
+	ValidatePermissionMode(mode string) error
+	ValidateAllowedTools(tools []string) error
+	ValidateExtraDockerArgs(args []string) error
+	ValidateRunAsUser(user string) error
+	ValidateConcurrencyWeight(weight int) error
+	ValidateTmpfsMounts(mounts []string) error
 	GetEnvironmentVars(id uint) (map[string]string, error)
 	UpdateEnvironmentVars(id uint, envVars map[string]string) error
 	ValidateResourceLimits(cpuLimit float64, memoryLimit int64) error
+	ValidateNetworkMode(networkMode string) error
+	ValidateGPUSupport() error
 	GetAvailableEnvironmentImages() ([]map[string]interface{}, error)
 	GetStats() (map[string]interface{}, error)
+	CompareEnvironments(aID, bID uint) (*EnvironmentComparison, error)
 }
 
 type TaskService interface {
-	CreateTask(title, startBranch string, projectID uint, devEnvironmentID *uint, createdBy string) (*database.Task, error)
+	CreateTask(title, startBranch string, projectID uint, devEnvironmentID *uint, executionTimeoutSeconds int, createdBy string) (*database.Task, error)
+	// BuildTask validates the task data and returns the task CreateTask would save, without saving it
+	BuildTask(title, startBranch string, projectID uint, devEnvironmentID *uint, executionTimeoutSeconds int, createdBy string) (*database.Task, error)
 	GetTask(id uint) (*database.Task, error)
 	ListTasks(projectID *uint, statuses []database.TaskStatus, title *string, branch *string, devEnvID *uint, sortBy, sortDirection string, page, pageSize int) ([]database.Task, int64, error)
 	GetKanbanTasks(projectID uint) (map[database.TaskStatus][]database.Task, error)
@@ -85,22 +117,92 @@ type TaskService interface {
 	DeleteTask(id uint) error
 	ValidateTaskData(title, startBranch string, projectID uint) error
 	GetTaskGitDiff(task *database.Task, includeContent bool) (*utils.GitDiffSummary, error)
-	GetTaskGitDiffFile(task *database.Task, filePath string) (string, error)
+	GetTaskGitDiffFile(task *database.Task, filePath string, hunkOffset, hunkLimit int) (*utils.FileDiff, error)
 	PushTaskBranch(id uint, forcePush bool) (string, error)
+	PushConversationBranch(id, conversationID uint, forcePush bool) (string, error)
+	MarkTaskBranchMerged(id uint) (*database.Task, error)
+	CleanupMergedBranches() (int, error)
+	GetTaskPushStatus(id uint) (*TaskPushStatus, error)
+	GetTaskBranchStatus(id uint) (*TaskBranchStatus, error)
+	GetTaskWorkspaceUsage(task *database.Task, skipGitObjects bool) (int64, error)
+	CreateWorkspaceSnapshot(taskID uint, createdBy string) (*database.WorkspaceSnapshot, error)
+	ListWorkspaceSnapshots(taskID uint) ([]database.WorkspaceSnapshot, error)
+	RestoreWorkspaceSnapshot(taskID, snapshotID uint) (*database.WorkspaceSnapshot, error)
+}
+
+// TaskTemplateService manages the task templates of a user, other users' templates are not found
+type TaskTemplateService interface {
+	CreateTemplate(name, description string, projectID, devEnvironmentID *uint, startBranch, conversationTemplate, createdBy string) (*database.TaskTemplate, error)
+	GetTemplate(id uint, createdBy string) (*database.TaskTemplate, error)
+	ListTemplates(createdBy string, name *string, projectID *uint, page, pageSize int) ([]database.TaskTemplate, int64, error)
+	UpdateTemplate(id uint, createdBy string, updates map[string]interface{}) (*database.TaskTemplate, error)
+	DeleteTemplate(id uint, createdBy string) error
+}
+
+// SecretService stores encrypted values that dev environment env vars reference as secret://<name>
+// Secrets are managed by their creator, the admin manages every user's secrets.
+type SecretService interface {
+	CreateSecret(name, description, value, createdBy string) (*database.Secret, error)
+	GetSecret(id uint, username string) (*database.Secret, error)
+	ListSecrets(username string, name *string, page, pageSize int) ([]database.Secret, int64, error)
+	UpdateSecret(id uint, username string, updates map[string]interface{}) (*database.Secret, error)
+	DeleteSecret(id uint, username string) error
+	// ResolveSecret returns the decrypted value of the named secret when owner may use it
+	ResolveSecret(name, owner string) (string, error)
+}
+
+// ScheduledTaskService manages cron schedules that create conversations on their task
+type ScheduledTaskService interface {
+	CreateSchedule(name, cronExpression string, taskID uint, templateID *uint, content string, enabled bool, createdBy string) (*database.ScheduledTask, error)
+	// GetSchedule, ListSchedules, UpdateSchedule and DeleteSchedule only see the schedules
+	// username created, the admin sees every schedule
+	GetSchedule(id uint, username string) (*database.ScheduledTask, error)
+	ListSchedules(username string, taskID *uint, enabled *bool, page, pageSize int) ([]database.ScheduledTask, int64, error)
+	UpdateSchedule(id uint, username string, updates map[string]interface{}) (*database.ScheduledTask, error)
+	DeleteSchedule(id uint, username string) error
+	// RunDueSchedules creates the conversations of the schedules that are due and returns
+	// how many were created
+	RunDueSchedules() (int, error)
+}
+
+// TaskPushStatus reports the commits conversations made on a task's work branch that have not been pushed
+type TaskPushStatus struct {
+	WorkBranch         string `json:"work_branch"`
+	UnpushedCommits    int    `json:"unpushed_commits"`
+	RemoteBranchExists bool   `json:"remote_branch_exists"`
+}
+
+// TaskBranchStatus reports where a task's work branch stands relative to its start branch.
+// Available is false until the workspace has the work branch.
+type TaskBranchStatus struct {
+	WorkBranch     string `json:"work_branch"`
+	BaseBranch     string `json:"base_branch"`
+	BaseRef        string `json:"base_ref"`
+	Available      bool   `json:"available"`
+	Ahead          int    `json:"ahead"`
+	Behind         int    `json:"behind"`
+	CanFastForward bool   `json:"can_fast_forward"`
 }
 
 type TaskConversationService interface {
 	CreateConversation(taskID uint, content, createdBy string) (*database.TaskConversation, error)
-	CreateConversationWithExecutionTime(taskID uint, content, createdBy string, executionTime *time.Time, envParams string) (*database.TaskConversation, error)
-	CreateConversationWithExecutionTimeAndAttachments(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint) (*database.TaskConversation, error)
+	CreateConversationWithExecutionTime(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, priority int, isolatedBranch bool) (*database.TaskConversation, error)
+	CreateConversationWithExecutionTimeAndAttachments(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint, priority int, isolatedBranch bool) (*database.TaskConversation, error)
+	CreateDraftConversation(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint, priority int, isolatedBranch bool) (*database.TaskConversation, error)
+	PromoteDraftConversation(id uint) (*database.TaskConversation, error)
 	GetConversation(id uint) (*database.TaskConversation, error)
 	GetConversationWithResult(id uint) (map[string]interface{}, error)
 	ListConversations(taskID uint, page, pageSize int) ([]database.TaskConversation, int64, error)
+	SearchConversations(query string, projectID *uint, includeLogs bool, page, pageSize int) ([]repository.ConversationSearchHit, int64, error)
 	UpdateConversation(id uint, updates map[string]interface{}) error
 	DeleteConversation(id uint) error
 	GetLatestConversation(taskID uint) (*database.TaskConversation, error)
 	GetConversationGitDiff(conversationID uint, includeContent bool) (*utils.GitDiffSummary, error)
-	GetConversationGitDiffFile(conversationID uint, filePath string) (string, error)
+	GetConversationGitDiffFile(conversationID uint, filePath string, hunkOffset, hunkLimit int) (*utils.FileDiff, error)
+	BuildConversationBundle(conversationID uint) ([]byte, error)
+	ExportTask(taskID uint, format string) ([]byte, error)
+	ImportTask(export *TaskExport, projectID uint, devEnvironmentMap map[uint]uint, createdBy string) (*database.Task, error)
+	EstimateCost(taskID *uint, content, envParams string) (*CostEstimate, error)
 	ValidateConversationData(taskID uint, content string) error
 }
 
@@ -112,6 +214,8 @@ type TaskConversationResultService interface {
 	DeleteResult(id uint) error
 	ListResultsByTaskID(taskID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error)
 	ListResultsByProjectID(projectID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error)
+	ListResultsBySessionID(sessionID string, page, pageSize int) ([]database.TaskConversationResult, int64, *SessionResultSummary, error)
+	ListConversationsMissingResult(projectID *uint, page, pageSize int) ([]database.TaskConversation, int64, error)
 	GetTaskStats(taskID uint) (map[string]interface{}, error)
 	GetProjectStats(projectID uint) (map[string]interface{}, error)
 	ExistsForConversation(conversationID uint) (bool, error)
@@ -121,11 +225,46 @@ type TaskConversationResultService interface {
 type AITaskExecutorService interface {
 	ProcessPendingConversations() error
 	GetExecutionLog(conversationID uint) (*database.TaskExecutionLog, error)
+	GetLogTail(conversationID uint, offset int) (string, int, error)
+	GetEffectivePrompt(conversationID uint) (string, error)
 	CancelExecution(conversationID uint, createdBy string) error
-	RetryExecution(conversationID uint, createdBy string) error
+	CancelProjectConversations(projectID uint, createdBy string) ([]uint, []uint, error)
+	CancelTaskConversations(taskID uint, createdBy string) ([]uint, []uint, error)
+	PreviewCommand(conversationID uint) (string, error)
+	RetryExecution(conversationID uint, content string, createdBy string) error
+	ApproveConversation(conversationID uint, approvedBy string) error
+	RejectConversation(conversationID uint, rejectedBy string) error
 	GetExecutionStatus() map[string]interface{}
+	SetSchedulerPauseState(state SchedulerPauseState)
+	CheckDockerAvailability() error
 	CleanupWorkspaceOnFailure(taskID uint, workspacePath string) error
 	CleanupWorkspaceOnCancel(taskID uint, workspacePath string) error
+	ListManagedContainers() ([]ManagedContainer, error)
+	RemoveManagedContainer(name string) error
+	// ReconcileOrphanedContainers removes the conversation containers no execution of this
+	// process owns, left behind by an unclean shutdown, and returns how many were removed
+	ReconcileOrphanedContainers() (int, error)
+	// ReconcileInterruptedConversations marks the conversations left running by a previous
+	// process as failed and returns how many were found
+	ReconcileInterruptedConversations() (int, error)
+}
+
+// ManagedContainer is a container the executor started for a conversation. Tracked is false
+// for orphans no running execution owns.
+type ManagedContainer struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Image          string `json:"image"`
+	Status         string `json:"status"`
+	CreatedAt      string `json:"created_at"`
+	TaskID         uint   `json:"task_id"`
+	ConversationID uint   `json:"conversation_id"`
+	Tracked        bool   `json:"tracked"`
+}
+
+// SchedulerPauseState reports whether the conversation scheduler is paused
+type SchedulerPauseState interface {
+	IsPaused() bool
 }
 
 type ConfigUpdateItem struct {
@@ -133,17 +272,111 @@ type ConfigUpdateItem struct {
 	ConfigValue string
 }
 
+// GitCommitConfig is the default identity and message template for conversation commits
+type GitCommitConfig struct {
+	AuthorName      string `json:"author_name"`
+	AuthorEmail     string `json:"author_email"`
+	MessageTemplate string `json:"message_template"`
+}
+
+// ConversationRateLimitConfig limits how many conversations a user may create per window
+type ConversationRateLimitConfig struct {
+	Limit       int
+	Window      time.Duration
+	ExemptAdmin bool
+	AdminUser   string
+}
+
+// ModelPricing holds the token rates of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// CostEstimate is a rough cost estimate of a conversation before it runs
+type CostEstimate struct {
+	Model                    string       `json:"model"`
+	InputTokens              int          `json:"input_tokens"`
+	HistoryTokens            int          `json:"history_tokens"`
+	OutputTokensMin          int          `json:"output_tokens_min"`
+	OutputTokensMax          int          `json:"output_tokens_max"`
+	CostMinUsd               float64      `json:"cost_min_usd"`
+	CostMaxUsd               float64      `json:"cost_max_usd"`
+	HistoricalAverageCostUsd *float64     `json:"historical_average_cost_usd,omitempty"`
+	Pricing                  ModelPricing `json:"pricing"`
+}
+
+// DockerRegistryConfig holds credentials for a private image registry
+type DockerRegistryConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"-"`
+}
+
+// Enabled reports whether registry login is fully configured
+func (c *DockerRegistryConfig) Enabled() bool {
+	return c.URL != "" && c.Username != "" && c.Password != ""
+}
+
+// EffectiveConfig is a configuration key resolved against its built-in default
+type EffectiveConfig struct {
+	ConfigKey      string                  `json:"config_key"`
+	EffectiveValue string                  `json:"effective_value"`
+	DefaultValue   string                  `json:"default_value"`
+	Overridden     bool                    `json:"overridden"`
+	Category       string                  `json:"category"`
+	FormType       database.ConfigFormType `json:"form_type"`
+	Description    string                  `json:"description"`
+	IsEditable     bool                    `json:"is_editable"`
+	SortOrder      int                     `json:"sort_order"`
+}
+
 type SystemConfigService interface {
 	ListAllConfigs() ([]database.SystemConfig, error)
 	BatchUpdateConfigs(configs []ConfigUpdateItem) error
 	GetValue(key string) (string, error)
 	SetValue(key, value string) error
+	// IsAdminUser reports whether username is the configured admin, who may manage every user's resources
+	IsAdminUser(username string) bool
 	InitializeDefaultConfigs() error
 	ValidateConfigData(key, value, category string) error
 	GetGitProxyConfig() (*utils.GitProxyConfig, error)
+	GetGitProxyConfigForCredential(credential *database.GitCredential) (*utils.GitProxyConfig, error)
+	GetGitCloneRetryConfig() (*utils.GitCloneRetryConfig, error)
+	GetCustomCACertFile() (string, error)
+	GetGitCommitConfig() (*GitCommitConfig, error)
 	GetGitCloneTimeout() (time.Duration, error)
 	GetGitSSLVerify() (bool, error)
+	GetGitFailOnPullError() (bool, error)
+	GetGitUnshallowBeforePush() (bool, error)
+	GetDockerAllowPrivilegedArgs() (bool, error)
 	GetDockerTimeout() (time.Duration, error)
+	GetDockerStopTimeout() (time.Duration, error)
+	GetGitMaxConcurrentOperations() (int, error)
+	GetExecutionLogRetentionDays() (int, error)
+	GetWorkspaceRetentionDays() (int, error)
+	GetContainerRuntime() (string, error)
+	GetDockerRegistryConfig() (*DockerRegistryConfig, error)
+	GetRedactedEnvVarKeys() ([]string, error)
+	GetModelPricing() (map[string]ModelPricing, error)
+	GetNotificationWebhookURL() (string, error)
+	GetStderrErrorPatterns() ([]*regexp.Regexp, error)
+	GetWorkspaceDirtyPolicy() (string, error)
+	GetNoChangesPolicy() (string, error)
+	GetDuplicateConversationPolicy() (string, error)
+	GetDiffMaxBytes() (int, error)
+	GetDiffTotalMaxBytes() (int, error)
+	GetGitCloneSizeLimit() (int64, string, error)
+	GetConversationRateLimitConfig() (*ConversationRateLimitConfig, error)
+	GetEffectiveConfigs() ([]EffectiveConfig, error)
+}
+
+type ExecutionLogRetentionService interface {
+	CleanupExpiredLogs() (int64, error)
+}
+
+type WorkspaceRetentionService interface {
+	CleanupStaleWorkspaces() (int, int64, error)
 }
 
 type DashboardService interface {
@@ -3,7 +3,9 @@ package services
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"xsha-backend/config"
 	"xsha-backend/database"
 	appErrors "xsha-backend/errors"
@@ -16,7 +18,9 @@ type projectService struct {
 	gitCredRepo         repository.GitCredentialRepository
 	gitCredService      GitCredentialService
 	taskRepo            repository.TaskRepository
+	resultRepo          repository.TaskConversationResultRepository
 	systemConfigService SystemConfigService
+	workspaceManager    *utils.WorkspaceManager
 	config              *config.Config
 }
 
@@ -25,18 +29,50 @@ type ProjectWithTaskCount struct {
 	TaskCount int64 `json:"task_count"`
 }
 
-func NewProjectService(repo repository.ProjectRepository, gitCredRepo repository.GitCredentialRepository, gitCredService GitCredentialService, taskRepo repository.TaskRepository, systemConfigService SystemConfigService, cfg *config.Config) ProjectService {
+type ProjectAccessFailure struct {
+	ProjectID   uint   `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	RepoURL     string `json:"repo_url"`
+	Reason      string `json:"reason"`
+}
+
+type ProjectRevalidationReport struct {
+	Total        int                    `json:"total"`
+	Accessible   int                    `json:"accessible"`
+	Inaccessible []ProjectAccessFailure `json:"inaccessible"`
+}
+
+// RepositorySizeCheck reports a repository that exceeds the clone size limit
+type RepositorySizeCheck struct {
+	Estimate   *utils.RepositorySizeEstimate `json:"estimate"`
+	LimitBytes int64                         `json:"limit_bytes"`
+}
+
+// ProjectBudget compares the project's AI cost so far with its budget, RemainingUSD is
+// nil when the project has no budget
+type ProjectBudget struct {
+	ProjectID    uint     `json:"project_id"`
+	BudgetUSD    float64  `json:"budget_usd"`
+	SpentUSD     float64  `json:"spent_usd"`
+	RemainingUSD *float64 `json:"remaining_usd"`
+	Exceeded     bool     `json:"exceeded"`
+	Override     bool     `json:"override"`
+}
+
+func NewProjectService(repo repository.ProjectRepository, gitCredRepo repository.GitCredentialRepository, gitCredService GitCredentialService, taskRepo repository.TaskRepository, resultRepo repository.TaskConversationResultRepository, systemConfigService SystemConfigService, workspaceManager *utils.WorkspaceManager, cfg *config.Config) ProjectService {
 	return &projectService{
 		repo:                repo,
 		gitCredRepo:         gitCredRepo,
 		gitCredService:      gitCredService,
 		taskRepo:            taskRepo,
+		resultRepo:          resultRepo,
 		systemConfigService: systemConfigService,
+		workspaceManager:    workspaceManager,
 		config:              cfg,
 	}
 }
 
-func (s *projectService) CreateProject(name, description, systemPrompt, repoURL, protocol string, credentialID *uint, createdBy string) (*database.Project, error) {
+func (s *projectService) CreateProject(name, description, systemPrompt, repoURL, protocol string, credentialID *uint, shallowClone bool, cloneDepth int, recurseSubmodules bool, commitAuthorName, commitAuthorEmail, commitMessageTemplate string, createdBy string) (*database.Project, error) {
 	if err := s.validateProjectData(name, repoURL, protocol); err != nil {
 		return nil, err
 	}
@@ -46,7 +82,8 @@ func (s *projectService) CreateProject(name, description, systemPrompt, repoURL,
 	}
 
 	protocolType := database.GitProtocolType(protocol)
-	if err := s.validateRepositoryURL(repoURL, protocolType); err != nil {
+	repoURL, err := normalizeRepositoryURL(repoURL, protocolType)
+	if err != nil {
 		return nil, err
 	}
 
@@ -54,6 +91,14 @@ func (s *projectService) CreateProject(name, description, systemPrompt, repoURL,
 		return nil, err
 	}
 
+	if cloneDepth < 0 {
+		return nil, appErrors.ErrCloneDepthInvalid
+	}
+
+	if err := validateCommitMessageTemplate(commitMessageTemplate); err != nil {
+		return nil, err
+	}
+
 	project := &database.Project{
 		Name:         name,
 		Description:  description,
@@ -61,7 +106,15 @@ func (s *projectService) CreateProject(name, description, systemPrompt, repoURL,
 		RepoURL:      repoURL,
 		Protocol:     protocolType,
 		CredentialID: credentialID,
+		ShallowClone: shallowClone,
+		CloneDepth:   cloneDepth,
 		CreatedBy:    createdBy,
+
+		RecurseSubmodules: recurseSubmodules,
+
+		CommitAuthorName:      strings.TrimSpace(commitAuthorName),
+		CommitAuthorEmail:     strings.TrimSpace(commitAuthorEmail),
+		CommitMessageTemplate: commitMessageTemplate,
 	}
 
 	if err := s.repo.Create(project); err != nil {
@@ -126,10 +179,160 @@ func (s *projectService) UpdateProject(id uint, updates map[string]interface{})
 		project.SystemPrompt = systemPrompt.(string)
 	}
 	if repoURL, ok := updates["repo_url"]; ok {
-		project.RepoURL = repoURL.(string)
-		if err := s.validateRepositoryURL(project.RepoURL, project.Protocol); err != nil {
+		rawURL, ok := repoURL.(string)
+		if !ok {
+			return fmt.Errorf("invalid repo_url type")
+		}
+		normalized, err := normalizeRepositoryURL(rawURL, project.Protocol)
+		if err != nil {
+			return err
+		}
+		project.RepoURL = normalized
+	}
+
+	if retentionDays, ok := updates["execution_log_retention_days"]; ok {
+		if retentionDays == nil {
+			project.ExecutionLogRetentionDays = nil
+		} else {
+			days, ok := retentionDays.(*int)
+			if !ok {
+				return fmt.Errorf("invalid execution_log_retention_days type")
+			}
+			if days != nil && *days < 0 {
+				return appErrors.ErrLogRetentionInvalid
+			}
+			project.ExecutionLogRetentionDays = days
+		}
+	}
+
+	if maxConcurrent, ok := updates["max_concurrent_tasks"]; ok {
+		limit, ok := maxConcurrent.(int)
+		if !ok {
+			return fmt.Errorf("invalid max_concurrent_tasks type")
+		}
+		if limit < 0 {
+			return appErrors.ErrMaxConcurrentInvalid
+		}
+		project.MaxConcurrentTasks = limit
+	}
+
+	if shallowClone, ok := updates["shallow_clone"]; ok {
+		enabled, ok := shallowClone.(bool)
+		if !ok {
+			return fmt.Errorf("invalid shallow_clone type")
+		}
+		project.ShallowClone = enabled
+	}
+
+	if cloneDepth, ok := updates["clone_depth"]; ok {
+		depth, ok := cloneDepth.(int)
+		if !ok {
+			return fmt.Errorf("invalid clone_depth type")
+		}
+		if depth < 0 {
+			return appErrors.ErrCloneDepthInvalid
+		}
+		project.CloneDepth = depth
+	}
+
+	if recurseSubmodules, ok := updates["recurse_submodules"]; ok {
+		enabled, ok := recurseSubmodules.(bool)
+		if !ok {
+			return fmt.Errorf("invalid recurse_submodules type")
+		}
+		project.RecurseSubmodules = enabled
+	}
+
+	if useWorktrees, ok := updates["use_worktrees"]; ok {
+		enabled, ok := useWorktrees.(bool)
+		if !ok {
+			return fmt.Errorf("invalid use_worktrees type")
+		}
+		project.UseWorktrees = enabled
+	}
+
+	if requireApproval, ok := updates["require_commit_approval"]; ok {
+		enabled, ok := requireApproval.(bool)
+		if !ok {
+			return fmt.Errorf("invalid require_commit_approval type")
+		}
+		project.RequireCommitApproval = enabled
+	}
+
+	if deleteBranch, ok := updates["delete_branch_after_merge"]; ok {
+		enabled, ok := deleteBranch.(bool)
+		if !ok {
+			return fmt.Errorf("invalid delete_branch_after_merge type")
+		}
+		project.DeleteBranchAfterMerge = enabled
+	}
+
+	if removeWorkspace, ok := updates["remove_workspace_after_merge"]; ok {
+		enabled, ok := removeWorkspace.(bool)
+		if !ok {
+			return fmt.Errorf("invalid remove_workspace_after_merge type")
+		}
+		project.RemoveWorkspaceAfterMerge = enabled
+	}
+
+	if authorName, ok := updates["commit_author_name"]; ok {
+		name, ok := authorName.(string)
+		if !ok {
+			return fmt.Errorf("invalid commit_author_name type")
+		}
+		project.CommitAuthorName = strings.TrimSpace(name)
+	}
+	if authorEmail, ok := updates["commit_author_email"]; ok {
+		email, ok := authorEmail.(string)
+		if !ok {
+			return fmt.Errorf("invalid commit_author_email type")
+		}
+		project.CommitAuthorEmail = strings.TrimSpace(email)
+	}
+	if messageTemplate, ok := updates["commit_message_template"]; ok {
+		tmpl, ok := messageTemplate.(string)
+		if !ok {
+			return fmt.Errorf("invalid commit_message_template type")
+		}
+		if err := validateCommitMessageTemplate(tmpl); err != nil {
+			return err
+		}
+		project.CommitMessageTemplate = tmpl
+	}
+
+	if webhookURL, ok := updates["webhook_url"]; ok {
+		rawURL, ok := webhookURL.(string)
+		if !ok {
+			return fmt.Errorf("invalid webhook_url type")
+		}
+		value := strings.TrimSpace(rawURL)
+		if err := validateWebhookURL(value); err != nil {
 			return err
 		}
+		project.WebhookURL = value
+	}
+
+	if gitConfig, ok := updates["git_config"]; ok {
+		overrides, ok := gitConfig.(string)
+		if !ok {
+			return fmt.Errorf("invalid git_config type")
+		}
+		value := strings.TrimSpace(overrides)
+		if _, err := utils.ParseGitConfigOverrides(value); err != nil {
+			return appErrors.NewI18nError("project.git_config_invalid", err.Error())
+		}
+		project.GitConfig = value
+	}
+
+	if budget, ok := updates["cost_budget_usd"]; ok {
+		budgetUSD, ok := budget.(float64)
+		if !ok {
+			return fmt.Errorf("invalid cost_budget_usd type")
+		}
+		if budgetUSD < 0 {
+			return appErrors.ErrProjectCostBudgetInvalid
+		}
+		project.CostBudgetUSD = budgetUSD
 	}
 
 	if credentialID, ok := updates["credential_id"]; ok {
@@ -154,6 +357,45 @@ func (s *projectService) UpdateProject(id uint, updates map[string]interface{})
 	return s.repo.Update(project)
 }
 
+// GetProjectBudget returns spent vs. remaining cost for the project
+func (s *projectService) GetProjectBudget(id uint) (*ProjectBudget, error) {
+	project, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	spent, err := s.resultRepo.GetTotalCostByProject(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project cost: %v", err)
+	}
+
+	budget := &ProjectBudget{
+		ProjectID: project.ID,
+		BudgetUSD: project.CostBudgetUSD,
+		SpentUSD:  spent,
+		Override:  project.BudgetOverride,
+	}
+	if project.CostBudgetUSD > 0 {
+		remaining := project.CostBudgetUSD - spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		budget.RemainingUSD = &remaining
+		budget.Exceeded = spent >= project.CostBudgetUSD
+	}
+	return budget, nil
+}
+
+// SetBudgetOverride lets conversations of the project run past its cost budget, or stops them again
+func (s *projectService) SetBudgetOverride(id uint, enabled bool) error {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return err
+	}
+
+	// Only the flag is written, so a concurrent project update is not overwritten
+	return s.repo.UpdateBudgetOverride(id, enabled)
+}
+
 func (s *projectService) DeleteProject(id uint) error {
 	project, err := s.repo.GetByID(id)
 	if err != nil {
@@ -169,7 +411,119 @@ func (s *projectService) DeleteProject(id uint) error {
 		return appErrors.ErrProjectHasInProgressTasks
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	if err := s.workspaceManager.RemoveReferenceCache(id); err != nil {
+		utils.Warn("Failed to remove project reference cache", "projectID", id, "error", err)
+	}
+	if err := s.workspaceManager.RemoveProjectClone(id); err != nil {
+		utils.Warn("Failed to remove project worktree clone", "projectID", id, "error", err)
+	}
+	return nil
+}
+
+// PrewarmProject creates or refreshes the project's reference clone that new task
+// workspaces copy objects from
+func (s *projectService) PrewarmProject(id uint) error {
+	project, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.updateReferenceCache(project)
+}
+
+// RefreshReferenceCaches refreshes the reference clones of all prewarmed projects
+func (s *projectService) RefreshReferenceCaches() (int, error) {
+	projects, err := s.repo.ListAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list projects: %v", err)
+	}
+
+	refreshed := 0
+	for i := range projects {
+		if projects[i].ReferenceCacheUpdatedAt == nil {
+			continue
+		}
+
+		if err := s.updateReferenceCache(&projects[i]); err != nil {
+			utils.Error("Failed to refresh project reference cache", "projectID", projects[i].ID, "error", err)
+			continue
+		}
+		refreshed++
+	}
+
+	return refreshed, nil
+}
+
+func (s *projectService) updateReferenceCache(project *database.Project) error {
+	credential, err := s.prepareCredentialInfo(project)
+	if err != nil {
+		return fmt.Errorf("failed to prepare git credential: %v", err)
+	}
+
+	proxyConfig, err := s.getGitProxyConfig(project.Credential)
+	if err != nil {
+		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
+		proxyConfig = nil
+	}
+
+	gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+	if err != nil {
+		utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+		gitSSLVerify = false
+	}
+
+	if err := s.workspaceManager.UpdateReferenceCache(project.ID, project.RepoURL, credential, gitSSLVerify, proxyConfig); err != nil {
+		return err
+	}
+
+	now := utils.Now()
+	if err := s.repo.UpdateReferenceCacheUpdatedAt(project.ID, now); err != nil {
+		return err
+	}
+	project.ReferenceCacheUpdatedAt = &now
+	return nil
+}
+
+// prepareCredentialInfo decrypts the project's credential for git network operations
+func (s *projectService) prepareCredentialInfo(project *database.Project) (*utils.GitCredentialInfo, error) {
+	if project.Credential == nil {
+		return nil, nil
+	}
+
+	credential := &utils.GitCredentialInfo{
+		Type:     utils.GitCredentialType(project.Credential.Type),
+		Username: project.Credential.Username,
+	}
+
+	switch project.Credential.Type {
+	case database.GitCredentialTypePassword, database.GitCredentialTypeToken:
+		password, err := s.gitCredService.DecryptCredentialSecret(project.Credential, "password")
+		if err != nil {
+			return nil, err
+		}
+		credential.Password = password
+	case database.GitCredentialTypeSSHKey:
+		privateKey, err := s.gitCredService.DecryptCredentialSecret(project.Credential, "private_key")
+		if err != nil {
+			return nil, err
+		}
+		credential.PrivateKey = privateKey
+		credential.PublicKey = project.Credential.PublicKey
+	case database.GitCredentialTypeGitHubApp:
+		privateKey, err := s.gitCredService.DecryptCredentialSecret(project.Credential, "private_key")
+		if err != nil {
+			return nil, err
+		}
+		credential.PrivateKey = privateKey
+		credential.AppID = project.Credential.GitHubAppID
+		credential.InstallationID = project.Credential.GitHubInstallationID
+	}
+
+	return credential, nil
 }
 
 func (s *projectService) ValidateProtocolCredential(protocol database.GitProtocolType, credentialID *uint) error {
@@ -184,7 +538,7 @@ func (s *projectService) ValidateProtocolCredential(protocol database.GitProtoco
 
 	switch protocol {
 	case database.GitProtocolHTTPS:
-		if credential.Type != database.GitCredentialTypePassword && credential.Type != database.GitCredentialTypeToken {
+		if credential.Type != database.GitCredentialTypePassword && credential.Type != database.GitCredentialTypeToken && credential.Type != database.GitCredentialTypeGitHubApp {
 			return appErrors.ErrIncompatibleCredential
 		}
 	case database.GitProtocolSSH:
@@ -213,7 +567,14 @@ func (s *projectService) GetCompatibleCredentials(protocol database.GitProtocolT
 			return nil, err
 		}
 
+		githubAppType := database.GitCredentialTypeGitHubApp
+		githubAppCreds, err := s.gitCredService.ListActiveCredentials(&githubAppType)
+		if err != nil {
+			return nil, err
+		}
+
 		credentials := append(passwordCreds, tokenCreds...)
+		credentials = append(credentials, githubAppCreds...)
 		return credentials, nil
 
 	case database.GitProtocolSSH:
@@ -234,8 +595,10 @@ func (s *projectService) FetchRepositoryBranches(repoURL string, credentialID *u
 	}
 
 	var credentialInfo *utils.GitCredentialInfo
+	var credential *database.GitCredential
 	if credentialID != nil {
-		credential, err := s.gitCredRepo.GetByID(*credentialID)
+		var err error
+		credential, err = s.gitCredRepo.GetByID(*credentialID)
 		if err != nil {
 			return &utils.GitAccessResult{
 				CanAccess:    false,
@@ -258,10 +621,14 @@ func (s *projectService) FetchRepositoryBranches(repoURL string, credentialID *u
 				credentialInfo.PrivateKey = credential.PrivateKey
 				credentialInfo.PublicKey = credential.PublicKey
 			}
+		case database.GitCredentialTypeGitHubApp:
+			credentialInfo.PrivateKey = credential.PrivateKey
+			credentialInfo.AppID = credential.GitHubAppID
+			credentialInfo.InstallationID = credential.GitHubInstallationID
 		}
 	}
 
-	proxyConfig, err := s.getGitProxyConfig()
+	proxyConfig, err := s.getGitProxyConfig(credential)
 	if err != nil {
 		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
 		proxyConfig = nil
@@ -276,8 +643,85 @@ func (s *projectService) FetchRepositoryBranches(repoURL string, credentialID *u
 	return utils.FetchRepositoryBranchesWithConfig(repoURL, credentialInfo, gitSSLVerify, proxyConfig)
 }
 
-func (s *projectService) getGitProxyConfig() (*utils.GitProxyConfig, error) {
-	return s.systemConfigService.GetGitProxyConfig()
+// CheckRepositorySize checks a repository against the clone size limit before a project
+// using it is saved
+func (s *projectService) CheckRepositorySize(repoURL string, credentialID *uint, shallowClone bool) (*RepositorySizeCheck, error) {
+	project := &database.Project{RepoURL: repoURL, ShallowClone: shallowClone}
+	if credentialID != nil {
+		credential, err := s.gitCredRepo.GetByID(*credentialID)
+		if err != nil {
+			utils.Warn("Failed to get credential for repository size check, skipping it", "error", err)
+			return nil, nil
+		}
+		project.Credential = credential
+	}
+
+	credentialInfo, err := s.prepareCredentialInfo(project)
+	if err != nil {
+		utils.Warn("Failed to prepare git credential for repository size check, skipping it", "error", err)
+		return nil, nil
+	}
+
+	proxyConfig, err := s.getGitProxyConfig(project.Credential)
+	if err != nil {
+		utils.Warn("Failed to get proxy config, using no proxy", "error", err)
+		proxyConfig = nil
+	}
+
+	gitSSLVerify, err := s.systemConfigService.GetGitSSLVerify()
+	if err != nil {
+		utils.Warn("Failed to get git SSL verify setting, using default false", "error", err)
+		gitSSLVerify = false
+	}
+
+	return CheckCloneSize(s.systemConfigService, project, credentialInfo, gitSSLVerify, proxyConfig)
+}
+
+// CheckCloneSize estimates the size of a project's repository before it is cloned and
+// compares it with git_clone_size_limit_mb. It returns the check when the repository is too
+// large, with ErrRepositoryTooLarge when the refuse policy applies and the project does not
+// use shallow clones. A size that cannot be determined fails the check under that policy with
+// ErrRepositorySizeUnknown, and is only logged otherwise.
+func CheckCloneSize(configService SystemConfigService, project *database.Project, credential *utils.GitCredentialInfo, sslVerify bool, proxyConfig *utils.GitProxyConfig) (*RepositorySizeCheck, error) {
+	limitBytes, policy, err := configService.GetGitCloneSizeLimit()
+	if err != nil {
+		utils.Warn("Failed to get git clone size limit, skipping the check", "error", err)
+		return nil, nil
+	}
+	if limitBytes <= 0 {
+		return nil, nil
+	}
+
+	estimate, err := utils.EstimateRepositorySize(project.RepoURL, credential, sslVerify, proxyConfig)
+	if err != nil {
+		if policy == GitCloneSizePolicyRefuse && !project.ShallowClone {
+			utils.Warn("Failed to estimate repository size, refusing the clone", "repoURL", project.RepoURL, "error", err)
+			return nil, appErrors.ErrRepositorySizeUnknown
+		}
+		utils.Warn("Failed to estimate repository size, skipping the check", "repoURL", project.RepoURL, "error", err)
+		return nil, nil
+	}
+	if !estimate.Exceeds(limitBytes) {
+		return nil, nil
+	}
+
+	check := &RepositorySizeCheck{Estimate: estimate, LimitBytes: limitBytes}
+	utils.Warn("Repository exceeds the clone size limit",
+		"repoURL", project.RepoURL,
+		"sizeBytes", estimate.SizeBytes,
+		"branchCount", estimate.BranchCount,
+		"source", estimate.Source,
+		"limitBytes", limitBytes,
+	)
+
+	if policy == GitCloneSizePolicyRefuse && !project.ShallowClone {
+		return check, appErrors.ErrRepositoryTooLarge
+	}
+	return check, nil
+}
+
+func (s *projectService) getGitProxyConfig(credential *database.GitCredential) (*utils.GitProxyConfig, error) {
+	return s.systemConfigService.GetGitProxyConfigForCredential(credential)
 }
 
 func (s *projectService) ValidateRepositoryAccess(repoURL string, credentialID *uint) error {
@@ -293,6 +737,86 @@ func (s *projectService) ValidateRepositoryAccess(repoURL string, credentialID *
 	return nil
 }
 
+// RevalidateAllProjects checks repository access for every project, running at most
+// git_max_concurrent_operations checks at a time
+func (s *projectService) RevalidateAllProjects() (*ProjectRevalidationReport, error) {
+	projects, err := s.repo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	maxConcurrent, err := s.systemConfigService.GetGitMaxConcurrentOperations()
+	if err != nil {
+		utils.Warn("Failed to get git max concurrent operations, using default 4", "error", err)
+		maxConcurrent = 4
+	}
+
+	report := &ProjectRevalidationReport{
+		Total:        len(projects),
+		Inaccessible: []ProjectAccessFailure{},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrent)
+
+	for _, project := range projects {
+		wg.Add(1)
+		go func(project database.Project) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			accessErr := s.ValidateRepositoryAccess(project.RepoURL, project.CredentialID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if accessErr != nil {
+				utils.Warn("Project repository is no longer accessible", "projectID", project.ID, "repoURL", project.RepoURL, "error", accessErr)
+				report.Inaccessible = append(report.Inaccessible, ProjectAccessFailure{
+					ProjectID:   project.ID,
+					ProjectName: project.Name,
+					RepoURL:     project.RepoURL,
+					Reason:      accessErr.Error(),
+				})
+				return
+			}
+			report.Accessible++
+		}(project)
+	}
+
+	wg.Wait()
+
+	sort.Slice(report.Inaccessible, func(i, j int) bool {
+		return report.Inaccessible[i].ProjectID < report.Inaccessible[j].ProjectID
+	})
+
+	return report, nil
+}
+
+// validateWebhookURL accepts an empty value or an absolute http(s) URL
+func validateWebhookURL(webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return appErrors.ErrWebhookURLInvalid
+	}
+	return nil
+}
+
+func validateCommitMessageTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil
+	}
+	if err := utils.ValidateCommitMessageTemplate(tmpl); err != nil {
+		return appErrors.NewI18nError("project.commit_message_template_invalid", err.Error())
+	}
+	return nil
+}
+
 func (s *projectService) validateProjectData(name, repoURL, protocol string) error {
 	if strings.TrimSpace(name) == "" {
 		return appErrors.ErrRequired
@@ -306,21 +830,15 @@ func (s *projectService) validateProjectData(name, repoURL, protocol string) err
 	return nil
 }
 
-func (s *projectService) validateRepositoryURL(repoURL string, protocol database.GitProtocolType) error {
-	switch protocol {
-	case database.GitProtocolHTTPS:
-		if !strings.HasPrefix(repoURL, "https://") {
-			return appErrors.ErrInvalidFormat
-		}
-		if _, err := url.Parse(repoURL); err != nil {
-			return fmt.Errorf("invalid HTTPS URL format: %v", err)
-		}
-	case database.GitProtocolSSH:
-		if !strings.Contains(repoURL, "@") || !strings.Contains(repoURL, ":") {
-			return appErrors.ErrInvalidFormat
-		}
-	default:
-		return appErrors.ErrInvalidProtocol
+// normalizeRepositoryURL validates the repository URL for the protocol and returns its canonical
+// form, so the same repository is always stored the same way
+func normalizeRepositoryURL(repoURL string, protocol database.GitProtocolType) (string, error) {
+	if protocol != database.GitProtocolHTTPS && protocol != database.GitProtocolSSH {
+		return "", appErrors.ErrInvalidProtocol
 	}
-	return nil
+	normalized, err := utils.NormalizeRepoURL(repoURL, utils.GitProtocolType(protocol))
+	if err != nil {
+		return "", appErrors.NewI18nError(appErrors.ErrRepoURLInvalid.Key, err.Error())
+	}
+	return normalized, nil
 }
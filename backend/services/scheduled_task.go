@@ -0,0 +1,258 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/repository"
+	"xsha-backend/utils"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+type scheduledTaskService struct {
+	repo                repository.ScheduledTaskRepository
+	taskRepo            repository.TaskRepository
+	templateRepo        repository.TaskTemplateRepository
+	conversationService TaskConversationService
+	configService       SystemConfigService
+}
+
+func NewScheduledTaskService(repo repository.ScheduledTaskRepository, taskRepo repository.TaskRepository, templateRepo repository.TaskTemplateRepository, conversationService TaskConversationService, configService SystemConfigService) ScheduledTaskService {
+	return &scheduledTaskService{
+		repo:                repo,
+		taskRepo:            taskRepo,
+		templateRepo:        templateRepo,
+		conversationService: conversationService,
+		configService:       configService,
+	}
+}
+
+// ownerScope returns the creator to scope queries by, the admin is not scoped
+func (s *scheduledTaskService) ownerScope(username string) string {
+	if s.configService.IsAdminUser(username) {
+		return ""
+	}
+	return username
+}
+
+// parseCronExpression accepts five field expressions and descriptors such as @daily,
+// evaluated in UTC unless prefixed with CRON_TZ=
+func parseCronExpression(expression string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(strings.TrimSpace(expression))
+	if err != nil {
+		return nil, appErrors.NewI18nError("scheduled_task.cron_invalid", err.Error())
+	}
+	return schedule, nil
+}
+
+// nextRunAfter returns the next time the expression fires after t, nil when it never does
+func nextRunAfter(schedule cron.Schedule, t time.Time) *time.Time {
+	next := schedule.Next(t)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+func (s *scheduledTaskService) CreateSchedule(name, cronExpression string, taskID uint, templateID *uint, content string, enabled bool, createdBy string) (*database.ScheduledTask, error) {
+	schedule := &database.ScheduledTask{
+		Name:           strings.TrimSpace(name),
+		CronExpression: strings.TrimSpace(cronExpression),
+		Enabled:        enabled,
+		TaskID:         taskID,
+		TemplateID:     templateID,
+		Content:        strings.TrimSpace(content),
+		CreatedBy:      createdBy,
+	}
+
+	if err := s.prepareSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(schedule); err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(schedule.ID, createdBy)
+}
+
+func (s *scheduledTaskService) GetSchedule(id uint, username string) (*database.ScheduledTask, error) {
+	schedule, err := s.repo.GetByID(id, s.ownerScope(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrScheduledTaskNotFound
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *scheduledTaskService) ListSchedules(username string, taskID *uint, enabled *bool, page, pageSize int) ([]database.ScheduledTask, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	return s.repo.List(s.ownerScope(username), taskID, enabled, page, pageSize)
+}
+
+func (s *scheduledTaskService) UpdateSchedule(id uint, username string, updates map[string]interface{}) (*database.ScheduledTask, error) {
+	schedule, err := s.GetSchedule(id, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, ok := updates["name"]; ok {
+		value, ok := name.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid name type")
+		}
+		schedule.Name = strings.TrimSpace(value)
+	}
+	if cronExpression, ok := updates["cron_expression"]; ok {
+		value, ok := cronExpression.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid cron_expression type")
+		}
+		schedule.CronExpression = strings.TrimSpace(value)
+	}
+	if enabled, ok := updates["enabled"]; ok {
+		value, ok := enabled.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid enabled type")
+		}
+		schedule.Enabled = value
+	}
+	if templateID, ok := updates["template_id"]; ok {
+		value, ok := templateID.(*uint)
+		if !ok {
+			return nil, fmt.Errorf("invalid template_id type")
+		}
+		schedule.TemplateID = value
+	}
+	if content, ok := updates["content"]; ok {
+		value, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid content type")
+		}
+		schedule.Content = strings.TrimSpace(value)
+	}
+
+	if err := s.prepareSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(schedule); err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(id, username)
+}
+
+func (s *scheduledTaskService) DeleteSchedule(id uint, username string) error {
+	if _, err := s.GetSchedule(id, username); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// prepareSchedule validates the schedule and computes its next run, which is cleared while
+// the schedule is disabled
+func (s *scheduledTaskService) prepareSchedule(schedule *database.ScheduledTask) error {
+	if schedule.Name == "" {
+		return appErrors.ErrScheduledTaskNameRequired
+	}
+	if len(schedule.Name) > 200 {
+		return appErrors.ErrScheduledTaskNameTooLong
+	}
+
+	cronSchedule, err := parseCronExpression(schedule.CronExpression)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.taskRepo.GetByID(schedule.TaskID); err != nil {
+		return appErrors.ErrTaskNotFound
+	}
+
+	templateContent := ""
+	if schedule.TemplateID != nil {
+		template, err := s.templateRepo.GetByID(*schedule.TemplateID, schedule.CreatedBy)
+		if err != nil {
+			return appErrors.ErrTaskTemplateNotFound
+		}
+		templateContent = strings.TrimSpace(template.ConversationTemplate)
+	}
+	if schedule.Content == "" && templateContent == "" {
+		return appErrors.ErrScheduledTaskContentRequired
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = nextRunAfter(cronSchedule, utils.Now())
+	}
+	return nil
+}
+
+// RunDueSchedules creates a conversation for every enabled schedule whose next run has
+// passed. A schedule missed while the scheduler was down or paused runs once, then resumes
+// from the current time. Failures are recorded on the schedule and do not stop the others.
+func (s *scheduledTaskService) RunDueSchedules() (int, error) {
+	now := utils.Now()
+	schedules, err := s.repo.ListDue(now)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i := range schedules {
+		schedule := &schedules[i]
+		updates := map[string]interface{}{
+			"last_run_at": &now,
+			"last_error":  "",
+		}
+
+		cronSchedule, err := parseCronExpression(schedule.CronExpression)
+		if err != nil {
+			// Only possible for rows edited outside the API, stop firing until it is fixed
+			updates["next_run_at"] = nil
+			updates["last_error"] = err.Error()
+			s.updateRunState(schedule.ID, updates)
+			continue
+		}
+		updates["next_run_at"] = nextRunAfter(cronSchedule, now)
+
+		content := schedule.Content
+		if content == "" && schedule.Template != nil {
+			content = schedule.Template.ConversationTemplate
+		}
+
+		conversation, err := s.conversationService.CreateConversation(schedule.TaskID, content, schedule.CreatedBy)
+		if err != nil {
+			utils.Warn("Scheduled task failed to create conversation", "scheduleId", schedule.ID, "taskId", schedule.TaskID, "error", err)
+			updates["last_error"] = err.Error()
+		} else {
+			utils.Info("Scheduled task created conversation", "scheduleId", schedule.ID, "taskId", schedule.TaskID, "conversationId", conversation.ID)
+			updates["last_conversation_id"] = conversation.ID
+			created++
+		}
+
+		s.updateRunState(schedule.ID, updates)
+	}
+
+	return created, nil
+}
+
+func (s *scheduledTaskService) updateRunState(id uint, updates map[string]interface{}) {
+	if err := s.repo.UpdateRunState(id, updates); err != nil {
+		utils.Error("Failed to update scheduled task run state", "scheduleId", id, "error", err)
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/repository"
+	"xsha-backend/utils"
+
+	"gorm.io/gorm"
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+type secretService struct {
+	repo          repository.SecretRepository
+	devEnvRepo    repository.DevEnvironmentRepository
+	configService SystemConfigService
+	cipher        *utils.SecretCipher
+}
+
+func NewSecretService(repo repository.SecretRepository, devEnvRepo repository.DevEnvironmentRepository, configService SystemConfigService, cipher *utils.SecretCipher) SecretService {
+	return &secretService{
+		repo:          repo,
+		devEnvRepo:    devEnvRepo,
+		configService: configService,
+		cipher:        cipher,
+	}
+}
+
+// ownerScope returns the creator to scope queries by, the admin is not scoped
+func (s *secretService) ownerScope(username string) string {
+	if s.configService.IsAdminUser(username) {
+		return ""
+	}
+	return username
+}
+
+func (s *secretService) CreateSecret(name, description, value, createdBy string) (*database.Secret, error) {
+	if s.cipher == nil {
+		return nil, appErrors.ErrSecretEncryptionUnavailable
+	}
+
+	name = strings.TrimSpace(name)
+	if !secretNamePattern.MatchString(name) {
+		return nil, appErrors.ErrSecretNameInvalid
+	}
+	if value == "" {
+		return nil, appErrors.ErrSecretValueRequired
+	}
+	if existing, _ := s.repo.GetByName(name, createdBy); existing != nil {
+		return nil, appErrors.ErrSecretNameExists
+	}
+
+	encrypted, err := s.cipher.Encrypt(value)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &database.Secret{
+		Name:           name,
+		Description:    description,
+		EncryptedValue: encrypted,
+		CreatedBy:      createdBy,
+	}
+	if err := s.repo.Create(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func (s *secretService) GetSecret(id uint, username string) (*database.Secret, error) {
+	secret, err := s.repo.GetByID(id, s.ownerScope(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrSecretNotFound
+		}
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (s *secretService) ListSecrets(username string, name *string, page, pageSize int) ([]database.Secret, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	return s.repo.List(s.ownerScope(username), name, page, pageSize)
+}
+
+// UpdateSecret changes the description and, when "value" is set, re-encrypts the value. The
+// name is fixed since dev environments reference the secret by it.
+func (s *secretService) UpdateSecret(id uint, username string, updates map[string]interface{}) (*database.Secret, error) {
+	secret, err := s.GetSecret(id, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if description, ok := updates["description"]; ok {
+		text, ok := description.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid description type")
+		}
+		secret.Description = text
+	}
+	if value, ok := updates["value"]; ok {
+		if s.cipher == nil {
+			return nil, appErrors.ErrSecretEncryptionUnavailable
+		}
+		plaintext, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value type")
+		}
+		if plaintext == "" {
+			return nil, appErrors.ErrSecretValueRequired
+		}
+		encrypted, err := s.cipher.Encrypt(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		secret.EncryptedValue = encrypted
+	}
+
+	if err := s.repo.Update(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// DeleteSecret refuses to delete a secret that dev environment env vars still reference
+func (s *secretService) DeleteSecret(id uint, username string) error {
+	secret, err := s.GetSecret(id, username)
+	if err != nil {
+		return err
+	}
+
+	envs, err := s.devEnvRepo.ListByEnvVarValue(utils.SecretReferencePrefix+secret.Name, secret.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if len(envs) > 0 {
+		names := make([]string, 0, len(envs))
+		for _, env := range envs {
+			names = append(names, env.Name)
+		}
+		sort.Strings(names)
+		return appErrors.NewI18nError(appErrors.ErrSecretInUse.Key, strings.Join(names, ", "))
+	}
+
+	return s.repo.Delete(id)
+}
+
+// ResolveSecret decrypts the named secret for an environment owned by owner. Only the owner's
+// secrets resolve, or any secret for the admin, so an environment cannot read other users' secrets.
+func (s *secretService) ResolveSecret(name, owner string) (string, error) {
+	if s.cipher == nil {
+		return "", appErrors.ErrSecretEncryptionUnavailable
+	}
+
+	secret, err := findOwnerSecret(s.repo, s.configService, name, owner)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", appErrors.ErrSecretNotFound
+		}
+		return "", err
+	}
+
+	return s.cipher.Decrypt(secret.EncryptedValue)
+}
+
+// findOwnerSecret looks the named secret up among owner's secrets. The admin, who may use any
+// secret, falls back to another user's secret of that name.
+func findOwnerSecret(repo repository.SecretRepository, configService SystemConfigService, name, owner string) (*database.Secret, error) {
+	// An empty owner would leave the lookup unscoped
+	if owner == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	secret, err := repo.GetByName(name, owner)
+	if errors.Is(err, gorm.ErrRecordNotFound) && configService.IsAdminUser(owner) {
+		return repo.GetByName(name, "")
+	}
+	return secret, err
+}
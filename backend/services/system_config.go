@@ -1,10 +1,14 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"xsha-backend/config"
 	"xsha-backend/database"
 	appErrors "xsha-backend/errors"
 	"xsha-backend/repository"
@@ -14,12 +18,14 @@ import (
 )
 
 type systemConfigService struct {
-	repo repository.SystemConfigRepository
+	repo   repository.SystemConfigRepository
+	config *config.Config
 }
 
-func NewSystemConfigService(repo repository.SystemConfigRepository) SystemConfigService {
+func NewSystemConfigService(repo repository.SystemConfigRepository, cfg *config.Config) SystemConfigService {
 	return &systemConfigService{
-		repo: repo,
+		repo:   repo,
+		config: cfg,
 	}
 }
 
@@ -27,6 +33,74 @@ func (s *systemConfigService) ListAllConfigs() ([]database.SystemConfig, error)
 	return s.repo.ListAll()
 }
 
+// GetEffectiveConfigs returns every known configuration key with its effective
+// and default value. Password values are never exposed.
+func (s *systemConfigService) GetEffectiveConfigs() ([]EffectiveConfig, error) {
+	stored, err := s.repo.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	storedByKey := make(map[string]database.SystemConfig, len(stored))
+	for _, config := range stored {
+		storedByKey[config.ConfigKey] = config
+	}
+
+	var result []EffectiveConfig
+	seen := make(map[string]bool)
+	for _, def := range repository.DefaultSystemConfigs() {
+		item := EffectiveConfig{
+			ConfigKey:      def.Key,
+			EffectiveValue: def.Value,
+			DefaultValue:   def.Value,
+			Category:       def.Category,
+			FormType:       database.ConfigFormType(def.FormType),
+			Description:    def.Description,
+			IsEditable:     true,
+			SortOrder:      def.SortOrder,
+		}
+		if config, ok := storedByKey[def.Key]; ok {
+			item.EffectiveValue = config.ConfigValue
+			item.Overridden = config.ConfigValue != def.Value
+			item.IsEditable = config.IsEditable
+		}
+		result = append(result, item)
+		seen[def.Key] = true
+	}
+
+	for _, config := range stored {
+		if seen[config.ConfigKey] {
+			continue
+		}
+		result = append(result, EffectiveConfig{
+			ConfigKey:      config.ConfigKey,
+			EffectiveValue: config.ConfigValue,
+			Overridden:     true,
+			Category:       config.Category,
+			FormType:       config.FormType,
+			Description:    config.Description,
+			IsEditable:     config.IsEditable,
+			SortOrder:      config.SortOrder,
+		})
+	}
+
+	for i := range result {
+		if result[i].FormType == database.ConfigFormTypePassword {
+			result[i].EffectiveValue = ""
+			result[i].DefaultValue = ""
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Category != result[j].Category {
+			return result[i].Category < result[j].Category
+		}
+		return result[i].SortOrder < result[j].SortOrder
+	})
+
+	return result, nil
+}
+
 func (s *systemConfigService) BatchUpdateConfigs(configItems []ConfigUpdateItem) error {
 	for _, item := range configItems {
 		existingConfig, err := s.repo.GetByKey(item.ConfigKey)
@@ -42,10 +116,26 @@ func (s *systemConfigService) BatchUpdateConfigs(configItems []ConfigUpdateItem)
 		}
 
 		if err := s.ValidateConfigData(item.ConfigKey, item.ConfigValue, existingConfig.Category); err != nil {
-			return fmt.Errorf("validation failed for key %s: %v", item.ConfigKey, err)
+			return wrapConfigValidationError(item.ConfigKey, err)
+		}
+
+		value, err := coerceConfigValue(item.ConfigKey, item.ConfigValue)
+		if err != nil {
+			return wrapConfigValidationError(item.ConfigKey, err)
+		}
+
+		if item.ConfigKey == "git_commit_message_template" && strings.TrimSpace(value) != "" {
+			if err := utils.ValidateCommitMessageTemplate(value); err != nil {
+				return appErrors.NewI18nError("project.commit_message_template_invalid", err.Error())
+			}
 		}
 
-		existingConfig.ConfigValue = item.ConfigValue
+		// Without the key enabling encryption would silently keep storing plaintext
+		if item.ConfigKey == "encrypt_execution_logs" && value == "true" && s.config.EncryptionKey == "" {
+			return appErrors.ErrSystemConfigEncryptionKeyMissing
+		}
+
+		existingConfig.ConfigValue = value
 		if err := s.repo.Update(existingConfig); err != nil {
 			return fmt.Errorf("failed to update config %s: %v", item.ConfigKey, err)
 		}
@@ -62,6 +152,18 @@ func (s *systemConfigService) SetValue(key, value string) error {
 	return s.repo.SetValue(key, value)
 }
 
+func (s *systemConfigService) IsAdminUser(username string) bool {
+	if username == "" {
+		return false
+	}
+	adminUser, err := s.repo.GetValue("admin_user")
+	if err != nil {
+		utils.Warn("Failed to get admin user from system config", "error", err)
+		return false
+	}
+	return username == adminUser
+}
+
 func (s *systemConfigService) InitializeDefaultConfigs() error {
 	return s.repo.InitializeDefaultConfigs()
 }
@@ -87,7 +189,109 @@ func (s *systemConfigService) ValidateConfigData(key, value, category string) er
 		}
 	}
 
-	return nil
+	_, err := coerceConfigValue(key, value)
+	return err
+}
+
+// coerceConfigValue validates a value against the type of its configuration key
+// and returns it in normalized form. Unknown keys are accepted as plain strings.
+func coerceConfigValue(key, value string) (string, error) {
+	def, ok := repository.GetDefaultSystemConfig(key)
+	if !ok {
+		return value, nil
+	}
+
+	trimmed := strings.TrimSpace(value)
+	switch def.ValueType {
+	case repository.ConfigValueTypeDuration:
+		duration, err := time.ParseDuration(trimmed)
+		if err != nil || duration <= 0 {
+			return "", appErrors.ErrSystemConfigInvalidDuration
+		}
+		return trimmed, nil
+	case repository.ConfigValueTypeInt:
+		number, err := strconv.Atoi(trimmed)
+		if err != nil {
+			return "", appErrors.ErrSystemConfigInvalidInt
+		}
+		if number < def.MinValue {
+			return "", appErrors.ErrSystemConfigIntTooSmall
+		}
+		return strconv.Itoa(number), nil
+	case repository.ConfigValueTypeBool:
+		enabled, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return "", appErrors.ErrSystemConfigInvalidBool
+		}
+		return strconv.FormatBool(enabled), nil
+	case repository.ConfigValueTypeJSON:
+		if !json.Valid([]byte(trimmed)) {
+			return "", appErrors.ErrSystemConfigInvalidJSON
+		}
+		return trimmed, nil
+	case repository.ConfigValueTypeEnum:
+		for _, option := range def.Options {
+			if trimmed == option {
+				return trimmed, nil
+			}
+		}
+		return "", appErrors.ErrSystemConfigInvalidOption
+	default:
+		return value, nil
+	}
+}
+
+// wrapConfigValidationError attaches the offending key to a validation error
+func wrapConfigValidationError(key string, err error) error {
+	if i18nErr, ok := err.(*appErrors.I18nError); ok {
+		return appErrors.NewI18nError(i18nErr.Key, key)
+	}
+	return fmt.Errorf("validation failed for key %s: %v", key, err)
+}
+
+func isSupportedContainerRuntime(runtime string) bool {
+	return runtime == "docker" || runtime == "podman"
+}
+
+// Policies for uncommitted changes found in a reused workspace before a run
+const (
+	WorkspaceDirtyPolicyReset = "reset"
+	WorkspaceDirtyPolicyKeep  = "keep"
+)
+
+func isSupportedWorkspaceDirtyPolicy(policy string) bool {
+	return policy == WorkspaceDirtyPolicyReset || policy == WorkspaceDirtyPolicyKeep
+}
+
+// Policies for a conversation whose AI run changed no files
+const (
+	NoChangesPolicySucceed = "succeed"
+	NoChangesPolicyFail    = "fail"
+)
+
+func isSupportedNoChangesPolicy(policy string) bool {
+	return policy == NoChangesPolicySucceed || policy == NoChangesPolicyFail
+}
+
+// Policies for a new conversation whose content matches a pending or running one on the task
+const (
+	DuplicateConversationPolicyOff    = "off"
+	DuplicateConversationPolicyReject = "reject"
+	DuplicateConversationPolicyLink   = "link"
+)
+
+func isSupportedDuplicateConversationPolicy(policy string) bool {
+	return policy == DuplicateConversationPolicyOff || policy == DuplicateConversationPolicyReject || policy == DuplicateConversationPolicyLink
+}
+
+// Policies for repositories exceeding the clone size limit
+const (
+	GitCloneSizePolicyWarn   = "warn"
+	GitCloneSizePolicyRefuse = "refuse"
+)
+
+func isSupportedGitCloneSizePolicy(policy string) bool {
+	return policy == GitCloneSizePolicyWarn || policy == GitCloneSizePolicyRefuse
 }
 
 func (s *systemConfigService) isOptionalConfig(key string) bool {
@@ -95,6 +299,13 @@ func (s *systemConfigService) isOptionalConfig(key string) bool {
 		"git_proxy_http",
 		"git_proxy_https",
 		"git_proxy_no_proxy",
+		"docker_registry_url",
+		"docker_registry_username",
+		"docker_registry_password",
+		"redacted_env_var_keys",
+		"custom_ca_certificate",
+		"notification_webhook_url",
+		"stderr_error_patterns",
 	}
 
 	for _, optionalKey := range optionalConfigs {
@@ -132,14 +343,156 @@ func (s *systemConfigService) GetGitProxyConfig() (*utils.GitProxyConfig, error)
 		return nil, fmt.Errorf("failed to get git_proxy_no_proxy: %v", err)
 	}
 
+	caCertFile, err := s.GetCustomCACertFile()
+	if err != nil {
+		utils.Warn("Failed to prepare custom CA certificate, using system trust store", "error", err)
+		caCertFile = ""
+	}
+
 	return &utils.GitProxyConfig{
 		Enabled:    isEnabled,
 		HttpProxy:  httpProxy,
 		HttpsProxy: httpsProxy,
 		NoProxy:    noProxy,
+		CACertFile: caCertFile,
 	}, nil
 }
 
+// GetGitProxyConfigForCredential returns the proxy for git operations using the credential,
+// which overrides the global proxy unless it inherits it
+func (s *systemConfigService) GetGitProxyConfigForCredential(credential *database.GitCredential) (*utils.GitProxyConfig, error) {
+	globalConfig, err := s.GetGitProxyConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil {
+		return globalConfig, nil
+	}
+
+	switch credential.ProxyMode {
+	case database.GitProxyModeNone:
+		return &utils.GitProxyConfig{CACertFile: globalConfig.CACertFile}, nil
+	case database.GitProxyModeCustom:
+		return &utils.GitProxyConfig{
+			Enabled:    true,
+			HttpProxy:  credential.ProxyHTTP,
+			HttpsProxy: credential.ProxyHTTPS,
+			NoProxy:    credential.ProxyNoProxy,
+			CACertFile: globalConfig.CACertFile,
+		}, nil
+	default:
+		return globalConfig, nil
+	}
+}
+
+// GetCustomCACertFile writes the configured CA certificate under the workspace base
+// directory and returns its path, or an empty string when none is configured
+func (s *systemConfigService) GetCustomCACertFile() (string, error) {
+	content, err := s.repo.GetValue("custom_ca_certificate")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get custom_ca_certificate: %v", err)
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return "", nil
+	}
+
+	return utils.EnsureCACertFile(s.config.WorkspaceBaseDir, content)
+}
+
+func (s *systemConfigService) GetDockerRegistryConfig() (*DockerRegistryConfig, error) {
+	registryConfig := &DockerRegistryConfig{}
+	for key, target := range map[string]*string{
+		"docker_registry_url":      &registryConfig.URL,
+		"docker_registry_username": &registryConfig.Username,
+		"docker_registry_password": &registryConfig.Password,
+	} {
+		value, err := s.repo.GetValue(key)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("failed to get %s: %v", key, err)
+		}
+		*target = strings.TrimSpace(value)
+	}
+
+	return registryConfig, nil
+}
+
+func (s *systemConfigService) GetRedactedEnvVarKeys() ([]string, error) {
+	value, err := s.repo.GetValue("redacted_env_var_keys")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get redacted_env_var_keys: %v", err)
+	}
+
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *systemConfigService) GetStderrErrorPatterns() ([]*regexp.Regexp, error) {
+	value, err := s.repo.GetValue("stderr_error_patterns")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get stderr_error_patterns: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		def, _ := repository.GetDefaultSystemConfig("stderr_error_patterns")
+		value = def.Value
+	}
+
+	var patterns []*regexp.Regexp
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		pattern, err := regexp.Compile("(?i)" + line)
+		if err != nil {
+			utils.Warn("Ignoring invalid stderr error pattern", "pattern", line, "error", err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func (s *systemConfigService) GetNotificationWebhookURL() (string, error) {
+	value, err := s.repo.GetValue("notification_webhook_url")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get notification_webhook_url: %v", err)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func (s *systemConfigService) GetModelPricing() (map[string]ModelPricing, error) {
+	value, err := s.repo.GetValue("model_pricing")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get model_pricing: %v", err)
+	}
+	if err == gorm.ErrRecordNotFound || strings.TrimSpace(value) == "" {
+		def, _ := repository.GetDefaultSystemConfig("model_pricing")
+		value = def.Value
+	}
+
+	var pricing map[string]ModelPricing
+	if err := json.Unmarshal([]byte(value), &pricing); err != nil {
+		return nil, fmt.Errorf("failed to parse model_pricing: %v", err)
+	}
+	return pricing, nil
+}
+
 func (s *systemConfigService) GetGitCloneTimeout() (time.Duration, error) {
 	timeoutStr, err := s.repo.GetValue("git_clone_timeout")
 	if err != nil {
@@ -158,6 +511,64 @@ func (s *systemConfigService) GetGitCloneTimeout() (time.Duration, error) {
 	return timeout, nil
 }
 
+func (s *systemConfigService) GetGitCloneRetryConfig() (*utils.GitCloneRetryConfig, error) {
+	retryConfig := &utils.GitCloneRetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+	}
+
+	attemptsStr, err := s.repo.GetValue("git_clone_max_attempts")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get git_clone_max_attempts: %v", err)
+	}
+	if attemptsStr != "" {
+		if attempts, parseErr := strconv.Atoi(attemptsStr); parseErr == nil && attempts > 0 {
+			retryConfig.MaxAttempts = attempts
+		} else {
+			utils.Error("Failed to parse git clone max attempts, using default 3", "value", attemptsStr)
+		}
+	}
+
+	delayStr, err := s.repo.GetValue("git_clone_retry_base_delay")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get git_clone_retry_base_delay: %v", err)
+	}
+	if delayStr != "" {
+		if delay, parseErr := time.ParseDuration(delayStr); parseErr == nil {
+			retryConfig.BaseDelay = delay
+		} else {
+			utils.Error("Failed to parse git clone retry base delay, using default 2 seconds", "value", delayStr, "error", parseErr)
+		}
+	}
+
+	return retryConfig, nil
+}
+
+func (s *systemConfigService) GetGitCommitConfig() (*GitCommitConfig, error) {
+	commitConfig := &GitCommitConfig{
+		AuthorName:      "XSHA AI",
+		AuthorEmail:     "ai@xsha.dev",
+		MessageTemplate: utils.DefaultCommitMessageTemplate,
+	}
+
+	values := map[string]*string{
+		"git_commit_author_name":      &commitConfig.AuthorName,
+		"git_commit_author_email":     &commitConfig.AuthorEmail,
+		"git_commit_message_template": &commitConfig.MessageTemplate,
+	}
+	for key, target := range values {
+		value, err := s.repo.GetValue(key)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("failed to get %s: %v", key, err)
+		}
+		if strings.TrimSpace(value) != "" {
+			*target = value
+		}
+	}
+
+	return commitConfig, nil
+}
+
 func (s *systemConfigService) GetGitSSLVerify() (bool, error) {
 	verifyStr, err := s.repo.GetValue("git_ssl_verify")
 	if err != nil {
@@ -176,6 +587,64 @@ func (s *systemConfigService) GetGitSSLVerify() (bool, error) {
 	return verify, nil
 }
 
+// GetGitFailOnPullError reports whether a failed pull of the base branch fails the conversation
+func (s *systemConfigService) GetGitFailOnPullError() (bool, error) {
+	failStr, err := s.repo.GetValue("git_fail_on_pull_error")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get git_fail_on_pull_error: %v", err)
+	}
+
+	fail, err := strconv.ParseBool(failStr)
+	if err != nil {
+		utils.Error("Failed to parse git fail on pull error, using default false", "value", failStr, "error", err)
+		return false, nil
+	}
+
+	return fail, nil
+}
+
+// GetGitUnshallowBeforePush reports whether shallow workspaces are unshallowed before every push
+// rather than after a push is rejected for missing history
+func (s *systemConfigService) GetGitUnshallowBeforePush() (bool, error) {
+	unshallowStr, err := s.repo.GetValue("git_unshallow_before_push")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get git_unshallow_before_push: %v", err)
+	}
+
+	unshallow, err := strconv.ParseBool(unshallowStr)
+	if err != nil {
+		utils.Error("Failed to parse git unshallow before push, using default false", "value", unshallowStr, "error", err)
+		return false, nil
+	}
+
+	return unshallow, nil
+}
+
+// GetDockerAllowPrivilegedArgs reports whether environments may use privileged extra docker args
+func (s *systemConfigService) GetDockerAllowPrivilegedArgs() (bool, error) {
+	allowStr, err := s.repo.GetValue("docker_allow_privileged_args")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get docker_allow_privileged_args: %v", err)
+	}
+
+	allow, err := strconv.ParseBool(allowStr)
+	if err != nil {
+		utils.Error("Failed to parse docker allow privileged args, using default false", "value", allowStr, "error", err)
+		return false, nil
+	}
+
+	return allow, nil
+}
+
 func (s *systemConfigService) GetDockerTimeout() (time.Duration, error) {
 	timeoutStr, err := s.repo.GetValue("docker_timeout")
 	if err != nil {
@@ -193,3 +662,280 @@ func (s *systemConfigService) GetDockerTimeout() (time.Duration, error) {
 
 	return timeout, nil
 }
+
+// GetDockerStopTimeout returns the grace period given to a container between stop and kill
+func (s *systemConfigService) GetDockerStopTimeout() (time.Duration, error) {
+	timeoutStr, err := s.repo.GetValue("docker_stop_timeout")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 10 * time.Second, nil
+		}
+		return 0, fmt.Errorf("failed to get docker_stop_timeout: %v", err)
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(timeoutStr))
+	if err != nil || timeout <= 0 {
+		utils.Error("Failed to parse docker stop timeout, using default 10 seconds", "timeout", timeoutStr, "error", err)
+		return 10 * time.Second, nil
+	}
+
+	return timeout, nil
+}
+
+func (s *systemConfigService) GetGitMaxConcurrentOperations() (int, error) {
+	valueStr, err := s.repo.GetValue("git_max_concurrent_operations")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 4, nil
+		}
+		return 0, fmt.Errorf("failed to get git_max_concurrent_operations: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil || value <= 0 {
+		utils.Error("Failed to parse git max concurrent operations, using default 4", "value", valueStr, "error", err)
+		return 4, nil
+	}
+
+	return value, nil
+}
+
+func (s *systemConfigService) GetExecutionLogRetentionDays() (int, error) {
+	valueStr, err := s.repo.GetValue("execution_log_retention_days")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get execution_log_retention_days: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil || value < 0 {
+		utils.Error("Failed to parse execution log retention days, keeping logs forever", "value", valueStr, "error", err)
+		return 0, nil
+	}
+
+	return value, nil
+}
+
+func (s *systemConfigService) GetWorkspaceRetentionDays() (int, error) {
+	valueStr, err := s.repo.GetValue("workspace_retention_days")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get workspace_retention_days: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil || value < 0 {
+		utils.Error("Failed to parse workspace retention days, keeping workspaces forever", "value", valueStr, "error", err)
+		return 0, nil
+	}
+
+	return value, nil
+}
+
+// defaultDiffMaxBytes keeps single file diffs small enough for the browser to render
+const defaultDiffMaxBytes = 1024 * 1024
+
+func (s *systemConfigService) GetDiffMaxBytes() (int, error) {
+	valueStr, err := s.repo.GetValue("diff_max_bytes")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return defaultDiffMaxBytes, nil
+		}
+		return 0, fmt.Errorf("failed to get diff_max_bytes: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil || value < 0 {
+		utils.Error("Failed to parse diff max bytes, using default", "value", valueStr, "error", err)
+		return defaultDiffMaxBytes, nil
+	}
+
+	return value, nil
+}
+
+// defaultDiffTotalMaxBytes keeps whole diffs with content small enough for the browser to render
+const defaultDiffTotalMaxBytes = 5 * 1024 * 1024
+
+func (s *systemConfigService) GetDiffTotalMaxBytes() (int, error) {
+	valueStr, err := s.repo.GetValue("diff_total_max_bytes")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return defaultDiffTotalMaxBytes, nil
+		}
+		return 0, fmt.Errorf("failed to get diff_total_max_bytes: %v", err)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil || value < 0 {
+		utils.Error("Failed to parse diff total max bytes, using default", "value", valueStr, "error", err)
+		return defaultDiffTotalMaxBytes, nil
+	}
+
+	return value, nil
+}
+
+// GetConversationRateLimitConfig returns the per-user conversation creation limit, a limit of 0 disables it
+func (s *systemConfigService) GetConversationRateLimitConfig() (*ConversationRateLimitConfig, error) {
+	limitConfig := &ConversationRateLimitConfig{
+		Limit:       0,
+		Window:      time.Minute,
+		ExemptAdmin: true,
+	}
+
+	limitStr, err := s.repo.GetValue("conversation_rate_limit")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get conversation_rate_limit: %v", err)
+	}
+	if strings.TrimSpace(limitStr) != "" {
+		limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+		if err != nil || limit < 0 {
+			utils.Error("Failed to parse conversation rate limit, disabling the limit", "value", limitStr, "error", err)
+		} else {
+			limitConfig.Limit = limit
+		}
+	}
+
+	windowStr, err := s.repo.GetValue("conversation_rate_limit_window_seconds")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get conversation_rate_limit_window_seconds: %v", err)
+	}
+	if strings.TrimSpace(windowStr) != "" {
+		seconds, err := strconv.Atoi(strings.TrimSpace(windowStr))
+		if err != nil || seconds <= 0 {
+			utils.Error("Failed to parse conversation rate limit window, using default 60 seconds", "value", windowStr, "error", err)
+		} else {
+			limitConfig.Window = time.Duration(seconds) * time.Second
+		}
+	}
+
+	exemptStr, err := s.repo.GetValue("conversation_rate_limit_exempt_admin")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get conversation_rate_limit_exempt_admin: %v", err)
+	}
+	if strings.TrimSpace(exemptStr) != "" {
+		exempt, err := strconv.ParseBool(strings.TrimSpace(exemptStr))
+		if err != nil {
+			utils.Error("Failed to parse conversation rate limit admin exemption, using default true", "value", exemptStr, "error", err)
+		} else {
+			limitConfig.ExemptAdmin = exempt
+		}
+	}
+
+	if limitConfig.ExemptAdmin {
+		adminUser, err := s.repo.GetValue("admin_user")
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("failed to get admin_user: %v", err)
+		}
+		limitConfig.AdminUser = adminUser
+	}
+
+	return limitConfig, nil
+}
+
+// GetGitCloneSizeLimit returns the clone size limit in bytes and the policy applied to larger
+// repositories, a limit of 0 disables the check
+func (s *systemConfigService) GetGitCloneSizeLimit() (int64, string, error) {
+	limitStr, err := s.repo.GetValue("git_clone_size_limit_mb")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, GitCloneSizePolicyWarn, nil
+		}
+		return 0, "", fmt.Errorf("failed to get git_clone_size_limit_mb: %v", err)
+	}
+
+	limitMB, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limitMB < 0 {
+		utils.Error("Failed to parse git clone size limit, disabling the check", "value", limitStr, "error", err)
+		return 0, GitCloneSizePolicyWarn, nil
+	}
+
+	policy, err := s.repo.GetValue("git_clone_size_policy")
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, "", fmt.Errorf("failed to get git_clone_size_policy: %v", err)
+	}
+	policy = strings.TrimSpace(policy)
+	if !isSupportedGitCloneSizePolicy(policy) {
+		if policy != "" {
+			utils.Error("Unsupported git clone size policy, using default warn", "policy", policy)
+		}
+		policy = GitCloneSizePolicyWarn
+	}
+
+	return int64(limitMB) * 1024 * 1024, policy, nil
+}
+
+func (s *systemConfigService) GetNoChangesPolicy() (string, error) {
+	policy, err := s.repo.GetValue("no_changes_policy")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return NoChangesPolicySucceed, nil
+		}
+		return "", fmt.Errorf("failed to get no_changes_policy: %v", err)
+	}
+
+	policy = strings.TrimSpace(policy)
+	if !isSupportedNoChangesPolicy(policy) {
+		utils.Error("Unsupported no changes policy, using default succeed", "policy", policy)
+		return NoChangesPolicySucceed, nil
+	}
+
+	return policy, nil
+}
+
+func (s *systemConfigService) GetWorkspaceDirtyPolicy() (string, error) {
+	policy, err := s.repo.GetValue("workspace_dirty_policy")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return WorkspaceDirtyPolicyReset, nil
+		}
+		return "", fmt.Errorf("failed to get workspace_dirty_policy: %v", err)
+	}
+
+	policy = strings.TrimSpace(policy)
+	if !isSupportedWorkspaceDirtyPolicy(policy) {
+		utils.Error("Unsupported workspace dirty policy, using default reset", "policy", policy)
+		return WorkspaceDirtyPolicyReset, nil
+	}
+
+	return policy, nil
+}
+
+func (s *systemConfigService) GetDuplicateConversationPolicy() (string, error) {
+	policy, err := s.repo.GetValue("duplicate_conversation_policy")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return DuplicateConversationPolicyOff, nil
+		}
+		return "", fmt.Errorf("failed to get duplicate_conversation_policy: %v", err)
+	}
+
+	policy = strings.TrimSpace(policy)
+	if !isSupportedDuplicateConversationPolicy(policy) {
+		utils.Error("Unsupported duplicate conversation policy, using default off", "policy", policy)
+		return DuplicateConversationPolicyOff, nil
+	}
+
+	return policy, nil
+}
+
+func (s *systemConfigService) GetContainerRuntime() (string, error) {
+	runtime, err := s.repo.GetValue("container_runtime")
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "docker", nil
+		}
+		return "", fmt.Errorf("failed to get container_runtime: %v", err)
+	}
+
+	runtime = strings.TrimSpace(runtime)
+	if !isSupportedContainerRuntime(runtime) {
+		utils.Error("Unsupported container runtime, using default docker", "runtime", runtime)
+		return "docker", nil
+	}
+
+	return runtime, nil
+}
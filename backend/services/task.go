@@ -1,12 +1,9 @@
 package services
 
 import (
-	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
-	"time"
 	"xsha-backend/config"
 	"xsha-backend/database"
 	appErrors "xsha-backend/errors"
@@ -22,13 +19,14 @@ type taskService struct {
 	taskExecutionLogRepo           repository.TaskExecutionLogRepository
 	taskConversationResultRepo     repository.TaskConversationResultRepository
 	taskConversationAttachmentRepo repository.TaskConversationAttachmentRepository
+	workspaceSnapshotRepo          repository.WorkspaceSnapshotRepository
 	workspaceManager               *utils.WorkspaceManager
 	config                         *config.Config
 	gitCredService                 GitCredentialService
 	systemConfigService            SystemConfigService
 }
 
-func NewTaskService(repo repository.TaskRepository, projectRepo repository.ProjectRepository, devEnvRepo repository.DevEnvironmentRepository, taskConversationRepo repository.TaskConversationRepository, taskExecutionLogRepo repository.TaskExecutionLogRepository, taskConversationResultRepo repository.TaskConversationResultRepository, taskConversationAttachmentRepo repository.TaskConversationAttachmentRepository, workspaceManager *utils.WorkspaceManager, cfg *config.Config, gitCredService GitCredentialService, systemConfigService SystemConfigService) TaskService {
+func NewTaskService(repo repository.TaskRepository, projectRepo repository.ProjectRepository, devEnvRepo repository.DevEnvironmentRepository, taskConversationRepo repository.TaskConversationRepository, taskExecutionLogRepo repository.TaskExecutionLogRepository, taskConversationResultRepo repository.TaskConversationResultRepository, taskConversationAttachmentRepo repository.TaskConversationAttachmentRepository, workspaceSnapshotRepo repository.WorkspaceSnapshotRepository, workspaceManager *utils.WorkspaceManager, cfg *config.Config, gitCredService GitCredentialService, systemConfigService SystemConfigService) TaskService {
 	return &taskService{
 		repo:                           repo,
 		projectRepo:                    projectRepo,
@@ -37,6 +35,7 @@ func NewTaskService(repo repository.TaskRepository, projectRepo repository.Proje
 		taskExecutionLogRepo:           taskExecutionLogRepo,
 		taskConversationResultRepo:     taskConversationResultRepo,
 		taskConversationAttachmentRepo: taskConversationAttachmentRepo,
+		workspaceSnapshotRepo:          workspaceSnapshotRepo,
 		workspaceManager:               workspaceManager,
 		config:                         cfg,
 		gitCredService:                 gitCredService,
@@ -44,11 +43,32 @@ func NewTaskService(repo repository.TaskRepository, projectRepo repository.Proje
 	}
 }
 
-func (s *taskService) CreateTask(title, startBranch string, projectID uint, devEnvironmentID *uint, createdBy string) (*database.Task, error) {
+func (s *taskService) CreateTask(title, startBranch string, projectID uint, devEnvironmentID *uint, executionTimeoutSeconds int, createdBy string) (*database.Task, error) {
+	task, err := s.BuildTask(title, startBranch, projectID, devEnvironmentID, executionTimeoutSeconds, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	project, devEnv := task.Project, task.DevEnvironment
+	task.Project, task.DevEnvironment = nil, nil
+	if err := s.repo.Create(task); err != nil {
+		return nil, err
+	}
+
+	task.Project = project
+	task.DevEnvironment = devEnv
+	return task, nil
+}
+
+func (s *taskService) BuildTask(title, startBranch string, projectID uint, devEnvironmentID *uint, executionTimeoutSeconds int, createdBy string) (*database.Task, error) {
 	if err := s.ValidateTaskData(title, startBranch, projectID); err != nil {
 		return nil, err
 	}
 
+	if executionTimeoutSeconds < 0 {
+		return nil, appErrors.ErrTaskExecutionTimeoutInvalid
+	}
+
 	project, err := s.projectRepo.GetByID(projectID)
 	if err != nil {
 		return nil, appErrors.ErrProjectNotFound
@@ -72,14 +92,11 @@ func (s *taskService) CreateTask(title, startBranch string, projectID uint, devE
 		ProjectID:        projectID,
 		DevEnvironmentID: devEnvironmentID,
 		CreatedBy:        createdBy,
-	}
 
-	if err := s.repo.Create(task); err != nil {
-		return nil, err
+		ExecutionTimeoutSeconds: executionTimeoutSeconds,
+		Project:                 project,
+		DevEnvironment:          devEnv,
 	}
-
-	task.Project = project
-	task.DevEnvironment = devEnv
 	return task, nil
 }
 
@@ -151,6 +168,57 @@ func (s *taskService) UpdateTask(id uint, updates map[string]interface{}) error
 
 	task.Title = strings.TrimSpace(titleStr)
 
+	if timeout, ok := updates["execution_timeout_seconds"]; ok {
+		timeoutSeconds, ok := timeout.(int)
+		if !ok {
+			return appErrors.ErrInvalidFormat
+		}
+		if timeoutSeconds < 0 {
+			return appErrors.ErrTaskExecutionTimeoutInvalid
+		}
+		task.ExecutionTimeoutSeconds = timeoutSeconds
+	}
+
+	if budget, ok := updates["cost_budget_usd"]; ok {
+		budgetUSD, ok := budget.(float64)
+		if !ok {
+			return appErrors.ErrInvalidFormat
+		}
+		if budgetUSD < 0 {
+			return appErrors.ErrTaskCostBudgetInvalid
+		}
+		task.CostBudgetUSD = budgetUSD
+	}
+
+	if maxRuns, ok := updates["max_runs"]; ok {
+		runs, ok := maxRuns.(int)
+		if !ok {
+			return appErrors.ErrInvalidFormat
+		}
+		if runs < 0 {
+			return appErrors.ErrTaskMaxRunsInvalid
+		}
+		task.MaxRuns = runs
+	}
+
+	if parallel, ok := updates["parallel_conversations"]; ok {
+		parallelConversations, ok := parallel.(bool)
+		if !ok {
+			return appErrors.ErrInvalidFormat
+		}
+		// Queued conversations were accepted under the current mode and must run in it
+		if parallelConversations != task.ParallelConversations {
+			active, err := s.taskConversationRepo.HasPendingOrRunningConversations(task.ID)
+			if err != nil {
+				return err
+			}
+			if active {
+				return appErrors.ErrTaskParallelConversationsBusy
+			}
+		}
+		task.ParallelConversations = parallelConversations
+	}
+
 	return s.repo.Update(task)
 }
 
@@ -246,6 +314,15 @@ func (s *taskService) DeleteTask(id uint) error {
 		}
 	}
 
+	if err := s.workspaceManager.CleanupConversationWorkspaces(id); err != nil {
+		utils.Error("Failed to cleanup conversation workspaces",
+			"task_id", id,
+			"error", err.Error(),
+		)
+	}
+
+	s.deleteWorkspaceSnapshots(id)
+
 	// Finally, delete the task record
 	if err := s.repo.Delete(id); err != nil {
 		return err
@@ -355,7 +432,7 @@ func (s *taskService) GetTaskGitDiff(task *database.Task, includeContent bool) (
 		return nil, fmt.Errorf("work branch validation failed: %v", err)
 	}
 
-	diff, err := utils.GetBranchDiff(absoluteWorkspacePath, task.StartBranch, task.WorkBranch, includeContent)
+	diff, err := utils.GetBranchDiff(absoluteWorkspacePath, task.StartBranch, task.WorkBranch, includeContent, diffContentLimits(s.systemConfigService))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get branch diff: %v", err)
 	}
@@ -363,46 +440,65 @@ func (s *taskService) GetTaskGitDiff(task *database.Task, includeContent bool) (
 	return diff, nil
 }
 
-func (s *taskService) GetTaskGitDiffFile(task *database.Task, filePath string) (string, error) {
+func (s *taskService) GetTaskGitDiffFile(task *database.Task, filePath string, hunkOffset, hunkLimit int) (*utils.FileDiff, error) {
 	if task == nil {
-		return "", fmt.Errorf("task cannot be nil")
+		return nil, fmt.Errorf("task cannot be nil")
 	}
 
 	if filePath == "" {
-		return "", fmt.Errorf("file path cannot be empty")
+		return nil, fmt.Errorf("file path cannot be empty")
 	}
 
 	if task.WorkspacePath == "" {
-		return "", fmt.Errorf("task workspace path is empty")
+		return nil, fmt.Errorf("task workspace path is empty")
 	}
 
 	if task.StartBranch == "" {
-		return "", fmt.Errorf("task start branch is empty")
+		return nil, fmt.Errorf("task start branch is empty")
 	}
 
 	if task.WorkBranch == "" {
-		return "", fmt.Errorf("task work branch is empty")
+		return nil, fmt.Errorf("task work branch is empty")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	// Convert relative workspace path to absolute for git operations
 	absoluteWorkspacePath := s.workspaceManager.GetAbsolutePath(task.WorkspacePath)
 
-	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", fmt.Sprintf("%s..%s", task.StartBranch, task.WorkBranch), "--", filePath)
-	cmd.Dir = absoluteWorkspacePath
+	opts := fileDiffOptions(s.systemConfigService, hunkOffset, hunkLimit)
+	return utils.GetFileDiff(absoluteWorkspacePath, task.StartBranch, task.WorkBranch, filePath, opts)
+}
 
-	output, err := cmd.Output()
+// fileDiffOptions applies the diff_max_bytes config to a requested page of hunks
+func fileDiffOptions(systemConfigService SystemConfigService, hunkOffset, hunkLimit int) utils.FileDiffOptions {
+	maxBytes, err := systemConfigService.GetDiffMaxBytes()
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			errorMessage := string(exitError.Stderr)
-			return "", fmt.Errorf("git diff failed for file %s: %s", filePath, errorMessage)
-		}
-		return "", fmt.Errorf("failed to execute git diff for file %s: %v", filePath, err)
+		utils.Warn("Failed to get diff max bytes, returning diff without a size limit", "error", err)
+		maxBytes = 0
 	}
+	return utils.FileDiffOptions{MaxBytes: maxBytes, HunkOffset: hunkOffset, HunkLimit: hunkLimit}
+}
 
-	return string(output), nil
+// diffContentLimits applies the diff_max_bytes and diff_total_max_bytes configs to a whole diff
+func diffContentLimits(systemConfigService SystemConfigService) utils.DiffContentLimits {
+	maxFileBytes, err := systemConfigService.GetDiffMaxBytes()
+	if err != nil {
+		utils.Warn("Failed to get diff max bytes, returning diff without a size limit", "error", err)
+		maxFileBytes = 0
+	}
+	maxTotalBytes, err := systemConfigService.GetDiffTotalMaxBytes()
+	if err != nil {
+		utils.Warn("Failed to get diff total max bytes, returning diff without a total size limit", "error", err)
+		maxTotalBytes = 0
+	}
+	return utils.DiffContentLimits{MaxFileBytes: maxFileBytes, MaxTotalBytes: maxTotalBytes}
+}
+
+// GetTaskWorkspaceUsage returns the disk usage of the task's workspace, 0 when it has none
+func (s *taskService) GetTaskWorkspaceUsage(task *database.Task, skipGitObjects bool) (int64, error) {
+	if task.WorkspacePath == "" {
+		return 0, nil
+	}
+	return s.workspaceManager.GetWorkspaceDiskUsage(task.WorkspacePath, skipGitObjects)
 }
 
 func (s *taskService) PushTaskBranch(id uint, forcePush bool) (string, error) {
@@ -411,14 +507,38 @@ func (s *taskService) PushTaskBranch(id uint, forcePush bool) (string, error) {
 		return "", err
 	}
 
-	if task.Status == database.TaskStatusCancelled {
-		return "", fmt.Errorf("cannot push cancelled task")
-	}
-
 	if task.WorkBranch == "" {
 		return "", fmt.Errorf("task work branch does not exist")
 	}
 
+	return s.pushBranch(task, task.WorkBranch, forcePush)
+}
+
+// PushConversationBranch pushes the branch a conversation of the task ran on when it was
+// isolated from the task's work branch
+func (s *taskService) PushConversationBranch(id, conversationID uint, forcePush bool) (string, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return "", err
+	}
+
+	conv, err := s.taskConversationRepo.GetByID(conversationID)
+	if err != nil {
+		return "", appErrors.ErrConversationGetFailed
+	}
+	if conv.TaskID != task.ID || !conv.IsolatedBranch || conv.WorkBranch == "" {
+		return "", appErrors.ErrConversationNoIsolatedBranch
+	}
+
+	return s.pushBranch(task, conv.WorkBranch, forcePush)
+}
+
+// pushBranch pushes a branch of the task workspace to the project's repository
+func (s *taskService) pushBranch(task *database.Task, branch string, forcePush bool) (string, error) {
+	if task.Status == database.TaskStatusCancelled {
+		return "", fmt.Errorf("cannot push cancelled task")
+	}
+
 	if task.WorkspacePath == "" {
 		return "", appErrors.ErrWorkspacePathEmpty
 	}
@@ -431,44 +551,12 @@ func (s *taskService) PushTaskBranch(id uint, forcePush bool) (string, error) {
 		return "", appErrors.ErrProjectNotAssociatedWithCredential
 	}
 
-	var credential *utils.GitCredentialInfo
-	if task.Project.CredentialID != nil {
-		cred, err := s.gitCredService.GetCredential(*task.Project.CredentialID)
-		if err != nil {
-			return "", fmt.Errorf("failed to get Git credential: %v", err)
-		}
-
-		credential = &utils.GitCredentialInfo{
-			Type:     utils.GitCredentialType(cred.Type),
-			Username: cred.Username,
-		}
-
-		switch cred.Type {
-		case database.GitCredentialTypePassword:
-			password, err := s.gitCredService.DecryptCredentialSecret(cred, "password")
-			if err != nil {
-				return "", fmt.Errorf("failed to decrypt password: %v", err)
-			}
-			credential.Password = password
-
-		case database.GitCredentialTypeToken:
-			token, err := s.gitCredService.DecryptCredentialSecret(cred, "token")
-			if err != nil {
-				return "", fmt.Errorf("failed to decrypt token: %v", err)
-			}
-			credential.Password = token
-
-		case database.GitCredentialTypeSSHKey:
-			privateKey, err := s.gitCredService.DecryptCredentialSecret(cred, "private_key")
-			if err != nil {
-				return "", fmt.Errorf("failed to decrypt SSH private key: %v", err)
-			}
-			credential.PrivateKey = privateKey
-			credential.PublicKey = cred.PublicKey
-		}
+	credential, cred, err := s.projectGitCredential(task.Project)
+	if err != nil {
+		return "", err
 	}
 
-	proxyConfig, err := s.getGitProxyConfig()
+	proxyConfig, err := s.getGitProxyConfig(cred)
 	if err != nil {
 		utils.Warn("Failed to get proxy config for push, using no proxy", "error", err)
 		proxyConfig = nil
@@ -480,25 +568,142 @@ func (s *taskService) PushTaskBranch(id uint, forcePush bool) (string, error) {
 		gitSSLVerify = false
 	}
 
+	unshallowBeforePush, err := s.systemConfigService.GetGitUnshallowBeforePush()
+	if err != nil {
+		utils.Warn("Failed to get git unshallow before push setting, unshallowing on demand", "error", err)
+		unshallowBeforePush = false
+	}
+
 	output, err := s.workspaceManager.PushBranch(
 		task.WorkspacePath,
-		task.WorkBranch,
+		branch,
 		task.Project.RepoURL,
 		credential,
 		gitSSLVerify,
 		proxyConfig,
 		forcePush,
+		unshallowBeforePush,
 	)
 
 	if err != nil {
-		utils.Error("Failed to push task branch", "taskID", id, "branch", task.WorkBranch, "error", err)
+		utils.Error("Failed to push task branch", "taskID", task.ID, "branch", branch, "error", err)
 		return output, err
 	}
 
-	utils.Info("Successfully pushed task branch", "taskID", id, "branch", task.WorkBranch)
+	utils.Info("Successfully pushed task branch", "taskID", task.ID, "branch", branch)
 	return output, nil
 }
 
+// projectGitCredential decrypts the credential of a project for git network operations, both
+// are nil when the project has no credential
+func (s *taskService) projectGitCredential(project *database.Project) (*utils.GitCredentialInfo, *database.GitCredential, error) {
+	if project.CredentialID == nil {
+		return nil, nil, nil
+	}
+
+	cred, err := s.gitCredService.GetCredential(*project.CredentialID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get Git credential: %v", err)
+	}
+
+	credential := &utils.GitCredentialInfo{
+		Type:     utils.GitCredentialType(cred.Type),
+		Username: cred.Username,
+	}
+
+	switch cred.Type {
+	case database.GitCredentialTypePassword:
+		password, err := s.gitCredService.DecryptCredentialSecret(cred, "password")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt password: %v", err)
+		}
+		credential.Password = password
+
+	case database.GitCredentialTypeToken:
+		token, err := s.gitCredService.DecryptCredentialSecret(cred, "token")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt token: %v", err)
+		}
+		credential.Password = token
+
+	case database.GitCredentialTypeSSHKey:
+		privateKey, err := s.gitCredService.DecryptCredentialSecret(cred, "private_key")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt SSH private key: %v", err)
+		}
+		credential.PrivateKey = privateKey
+		credential.PublicKey = cred.PublicKey
+
+	case database.GitCredentialTypeGitHubApp:
+		privateKey, err := s.gitCredService.DecryptCredentialSecret(cred, "private_key")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt GitHub App private key: %v", err)
+		}
+		credential.PrivateKey = privateKey
+		credential.AppID = cred.GitHubAppID
+		credential.InstallationID = cred.GitHubInstallationID
+	}
+
+	return credential, cred, nil
+}
+
+// GetTaskPushStatus counts the commits accumulated on the work branch since its last push,
+// or since the start branch when it has never been pushed
+func (s *taskService) GetTaskPushStatus(id uint) (*TaskPushStatus, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TaskPushStatus{WorkBranch: task.WorkBranch}
+	if task.WorkBranch == "" || task.WorkspacePath == "" || !s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+		return status, nil
+	}
+
+	count, remoteExists, err := s.workspaceManager.CountUnpushedCommits(task.WorkspacePath, task.WorkBranch, task.StartBranch)
+	if err != nil {
+		return nil, err
+	}
+	status.UnpushedCommits = count
+	status.RemoteBranchExists = remoteExists
+
+	return status, nil
+}
+
+// GetTaskBranchStatus compares the work branch with the start branch. A fast-forward merge into
+// the base is possible when the base has no commits the work branch lacks.
+func (s *taskService) GetTaskBranchStatus(id uint) (*TaskBranchStatus, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TaskBranchStatus{WorkBranch: task.WorkBranch, BaseBranch: task.StartBranch}
+	if task.WorkBranch == "" || task.WorkspacePath == "" || !s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+		return status, nil
+	}
+
+	exists, err := s.workspaceManager.CheckBranchExists(task.WorkspacePath, task.WorkBranch)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return status, nil
+	}
+
+	ahead, behind, baseRef, err := s.workspaceManager.CompareBranchToBase(task.WorkspacePath, task.WorkBranch, task.StartBranch)
+	if err != nil {
+		return nil, err
+	}
+	status.Available = true
+	status.BaseRef = baseRef
+	status.Ahead = ahead
+	status.Behind = behind
+	status.CanFastForward = behind == 0
+
+	return status, nil
+}
+
 func (s *taskService) GetKanbanTasks(projectID uint) (map[database.TaskStatus][]database.Task, error) {
 	// Validate project exists
 	_, err := s.projectRepo.GetByID(projectID)
@@ -638,6 +843,6 @@ func (s *taskService) deleteConversationCascade(conversationID uint, workspacePa
 	return nil
 }
 
-func (s *taskService) getGitProxyConfig() (*utils.GitProxyConfig, error) {
-	return s.systemConfigService.GetGitProxyConfig()
+func (s *taskService) getGitProxyConfig(credential *database.GitCredential) (*utils.GitProxyConfig, error) {
+	return s.systemConfigService.GetGitProxyConfigForCredential(credential)
 }
@@ -1,8 +1,14 @@
 package services
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
 	"time"
+	"xsha-backend/config"
 	"xsha-backend/database"
 	appErrors "xsha-backend/errors"
 	"xsha-backend/repository"
@@ -17,9 +23,12 @@ type taskConversationService struct {
 	taskService       TaskService
 	attachmentService TaskConversationAttachmentService
 	workspaceManager  *utils.WorkspaceManager
+	config            *config.Config
+
+	systemConfigService SystemConfigService
 }
 
-func NewTaskConversationService(repo repository.TaskConversationRepository, taskRepo repository.TaskRepository, execLogRepo repository.TaskExecutionLogRepository, resultRepo repository.TaskConversationResultRepository, taskService TaskService, attachmentService TaskConversationAttachmentService, workspaceManager *utils.WorkspaceManager) TaskConversationService {
+func NewTaskConversationService(repo repository.TaskConversationRepository, taskRepo repository.TaskRepository, execLogRepo repository.TaskExecutionLogRepository, resultRepo repository.TaskConversationResultRepository, taskService TaskService, attachmentService TaskConversationAttachmentService, workspaceManager *utils.WorkspaceManager, systemConfigService SystemConfigService, cfg *config.Config) TaskConversationService {
 	return &taskConversationService{
 		repo:              repo,
 		taskRepo:          taskRepo,
@@ -28,6 +37,9 @@ func NewTaskConversationService(repo repository.TaskConversationRepository, task
 		taskService:       taskService,
 		attachmentService: attachmentService,
 		workspaceManager:  workspaceManager,
+		config:            cfg,
+
+		systemConfigService: systemConfigService,
 	}
 }
 
@@ -45,30 +57,42 @@ func (s *taskConversationService) CreateConversation(taskID uint, content, creat
 		return nil, appErrors.ErrConversationTaskCompleted
 	}
 
-	hasPendingOrRunning, err := s.repo.HasPendingOrRunningConversations(taskID)
-	if err != nil {
-		return nil, appErrors.ErrConversationGetFailed
+	policy := s.duplicateConversationPolicy()
+	if duplicate, err := s.findDuplicateConversation(policy, taskID, content, "", nil); err != nil {
+		return nil, err
+	} else if duplicate != nil {
+		duplicate.Task = task
+		return duplicate, nil
 	}
-	if hasPendingOrRunning {
-		return nil, appErrors.ErrConversationCreateFailed
+
+	if err := s.checkTaskQuota(task, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTaskIdle(taskID, task); err != nil {
+		return nil, err
 	}
 
 	conversation := &database.TaskConversation{
-		TaskID:    taskID,
-		Content:   strings.TrimSpace(content),
-		Status:    database.ConversationStatusPending,
-		CreatedBy: createdBy,
+		TaskID:         taskID,
+		Content:        strings.TrimSpace(content),
+		Status:         database.ConversationStatusPending,
+		IsolatedBranch: task.ParallelConversations,
+		CreatedBy:      createdBy,
 	}
 
-	if err := s.repo.Create(conversation); err != nil {
+	if duplicate, err := s.createConversation(policy, conversation, nil); err != nil {
 		return nil, err
+	} else if duplicate != nil {
+		duplicate.Task = task
+		return duplicate, nil
 	}
 
 	conversation.Task = task
 	return conversation, nil
 }
 
-func (s *taskConversationService) CreateConversationWithExecutionTime(taskID uint, content, createdBy string, executionTime *time.Time, envParams string) (*database.TaskConversation, error) {
+func (s *taskConversationService) CreateConversationWithExecutionTime(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, priority int, isolatedBranch bool) (*database.TaskConversation, error) {
 	if err := s.ValidateConversationData(taskID, content); err != nil {
 		return nil, err
 	}
@@ -82,37 +106,165 @@ func (s *taskConversationService) CreateConversationWithExecutionTime(taskID uin
 		return nil, appErrors.ErrConversationTaskCompleted
 	}
 
-	hasPendingOrRunning, err := s.repo.HasPendingOrRunningConversations(taskID)
-	if err != nil {
-		return nil, appErrors.ErrConversationGetFailed
-	}
-	if hasPendingOrRunning {
-		return nil, appErrors.ErrConversationCreateFailed
-	}
-
 	// Ensure envParams is valid JSON, default to empty object if not provided
 	if envParams == "" {
 		envParams = "{}"
 	}
 
+	policy := s.duplicateConversationPolicy()
+	if duplicate, err := s.findDuplicateConversation(policy, taskID, content, envParams, nil); err != nil {
+		return nil, err
+	} else if duplicate != nil {
+		duplicate.Task = task
+		return duplicate, nil
+	}
+
+	if err := s.checkTaskQuota(task, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTaskIdle(taskID, task); err != nil {
+		return nil, err
+	}
+
 	conversation := &database.TaskConversation{
-		TaskID:        taskID,
-		Content:       strings.TrimSpace(content),
-		Status:        database.ConversationStatusPending,
-		ExecutionTime: executionTime,
-		EnvParams:     envParams,
-		CreatedBy:     createdBy,
+		TaskID:         taskID,
+		Content:        strings.TrimSpace(content),
+		Status:         database.ConversationStatusPending,
+		ExecutionTime:  executionTime,
+		EnvParams:      envParams,
+		Priority:       priority,
+		IsolatedBranch: isolatedBranch || task.ParallelConversations,
+		CreatedBy:      createdBy,
 	}
 
-	if err := s.repo.Create(conversation); err != nil {
+	if duplicate, err := s.createConversation(policy, conversation, nil); err != nil {
 		return nil, err
+	} else if duplicate != nil {
+		duplicate.Task = task
+		return duplicate, nil
 	}
 
 	conversation.Task = task
 	return conversation, nil
 }
 
-func (s *taskConversationService) CreateConversationWithExecutionTimeAndAttachments(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint) (*database.TaskConversation, error) {
+// duplicateConversationPolicy returns the duplicate_conversation_policy config, off when it
+// cannot be read
+func (s *taskConversationService) duplicateConversationPolicy() string {
+	policy, err := s.systemConfigService.GetDuplicateConversationPolicy()
+	if err != nil {
+		utils.Warn("Failed to get duplicate conversation policy, skipping duplicate check", "error", err)
+		return DuplicateConversationPolicyOff
+	}
+	return policy
+}
+
+// findDuplicateConversation applies the duplicate conversation policy to a new conversation
+// before it is checked against quotas. It returns the existing pending or running conversation
+// with the same content when the policy links duplicates, an error when it rejects them, and
+// nil otherwise.
+func (s *taskConversationService) findDuplicateConversation(policy string, taskID uint, content, envParams string, attachmentIDs []uint) (*database.TaskConversation, error) {
+	if policy == DuplicateConversationPolicyOff {
+		return nil, nil
+	}
+
+	existing, err := s.repo.FindPendingOrRunningByContent(taskID, strings.TrimSpace(content))
+	if err != nil {
+		return nil, appErrors.ErrConversationGetFailed
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	return resolveDuplicateConversation(policy, existing, envParams, attachmentIDs)
+}
+
+// createConversation saves a new conversation. Unless the policy is off, the duplicate check is
+// repeated in the same transaction as the insert, so concurrent identical requests cannot both
+// create one. A duplicate found there is resolved like in findDuplicateConversation.
+func (s *taskConversationService) createConversation(policy string, conversation *database.TaskConversation, attachmentIDs []uint) (*database.TaskConversation, error) {
+	if policy == DuplicateConversationPolicyOff {
+		return nil, s.repo.Create(conversation)
+	}
+
+	existing, err := s.repo.CreateUnlessDuplicate(conversation)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	return resolveDuplicateConversation(policy, existing, conversation.EnvParams, attachmentIDs)
+}
+
+// resolveDuplicateConversation rejects the new conversation or links it to the existing one. A
+// request carrying attachments or different env params is rejected even when linking, since
+// they would otherwise be silently dropped.
+func resolveDuplicateConversation(policy string, existing *database.TaskConversation, envParams string, attachmentIDs []uint) (*database.TaskConversation, error) {
+	if policy == DuplicateConversationPolicyReject {
+		return nil, appErrors.ErrConversationDuplicate
+	}
+	if len(attachmentIDs) > 0 || !sameEnvParams(existing.EnvParams, envParams) {
+		return nil, appErrors.ErrConversationDuplicateConflict
+	}
+
+	utils.Info("Linked duplicate conversation to existing one",
+		"task_id", existing.TaskID,
+		"conversation_id", existing.ID)
+	return existing, nil
+}
+
+// sameEnvParams compares env params as JSON objects, empty meaning no params
+func sameEnvParams(a, b string) bool {
+	var aParams, bParams map[string]interface{}
+	if a != "" && json.Unmarshal([]byte(a), &aParams) != nil {
+		return a == b
+	}
+	if b != "" && json.Unmarshal([]byte(b), &bParams) != nil {
+		return a == b
+	}
+	if len(aParams) == 0 && len(bParams) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(aParams, bParams)
+}
+
+// checkTaskQuota rejects a new run once the task has reached its run cap or cost budget.
+// excludeID is a draft being promoted, which is not counted yet. An admin budget override on
+// the project lifts the cost cap, as it does in the executor.
+func (s *taskConversationService) checkTaskQuota(task *database.Task, excludeID uint) error {
+	if task.MaxRuns > 0 {
+		runs, err := s.repo.CountRunsByTask(task.ID, excludeID, database.ConversationStatusDraft)
+		if err != nil {
+			return appErrors.ErrConversationGetFailed
+		}
+		if runs >= int64(task.MaxRuns) {
+			return appErrors.ErrConversationRunQuotaExceeded
+		}
+	}
+
+	if task.CostBudgetUSD > 0 && (task.Project == nil || !task.Project.BudgetOverride) {
+		spent, err := s.resultRepo.GetTotalCost(task.ID)
+		if err != nil {
+			utils.Warn("Failed to get task cost, skipping cost quota check", "task_id", task.ID, "error", err)
+		} else if spent >= task.CostBudgetUSD {
+			return appErrors.ErrConversationCostQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+func (s *taskConversationService) CreateConversationWithExecutionTimeAndAttachments(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint, priority int, isolatedBranch bool) (*database.TaskConversation, error) {
+	return s.createConversationWithStatus(taskID, content, createdBy, executionTime, envParams, attachmentIDs, priority, isolatedBranch, database.ConversationStatusPending)
+}
+
+// CreateDraftConversation saves a conversation without queueing it for execution
+func (s *taskConversationService) CreateDraftConversation(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint, priority int, isolatedBranch bool) (*database.TaskConversation, error) {
+	return s.createConversationWithStatus(taskID, content, createdBy, executionTime, envParams, attachmentIDs, priority, isolatedBranch, database.ConversationStatusDraft)
+}
+
+func (s *taskConversationService) createConversationWithStatus(taskID uint, content, createdBy string, executionTime *time.Time, envParams string, attachmentIDs []uint, priority int, isolatedBranch bool, status database.ConversationStatus) (*database.TaskConversation, error) {
 	if err := s.ValidateConversationData(taskID, content); err != nil {
 		return nil, err
 	}
@@ -126,19 +278,31 @@ func (s *taskConversationService) CreateConversationWithExecutionTimeAndAttachme
 		return nil, appErrors.ErrConversationTaskCompleted
 	}
 
-	hasPendingOrRunning, err := s.repo.HasPendingOrRunningConversations(taskID)
-	if err != nil {
-		return nil, appErrors.ErrConversationGetFailed
-	}
-	if hasPendingOrRunning {
-		return nil, appErrors.ErrConversationCreateFailed
-	}
-
 	// Ensure envParams is valid JSON, default to empty object if not provided
 	if envParams == "" {
 		envParams = "{}"
 	}
 
+	// Drafts are not executed, so they don't conflict with queued conversations
+	policy := DuplicateConversationPolicyOff
+	if status != database.ConversationStatusDraft {
+		policy = s.duplicateConversationPolicy()
+		if duplicate, err := s.findDuplicateConversation(policy, taskID, content, envParams, attachmentIDs); err != nil {
+			return nil, err
+		} else if duplicate != nil {
+			duplicate.Task = task
+			return duplicate, nil
+		}
+
+		if err := s.checkTaskQuota(task, 0); err != nil {
+			return nil, err
+		}
+
+		if err := s.checkTaskIdle(taskID, task); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate and process attachments
 	var attachments []database.TaskConversationAttachment
 	if len(attachmentIDs) > 0 {
@@ -154,16 +318,21 @@ func (s *taskConversationService) CreateConversationWithExecutionTimeAndAttachme
 	}
 
 	conversation := &database.TaskConversation{
-		TaskID:        taskID,
-		Content:       processedContent,
-		Status:        database.ConversationStatusPending,
-		ExecutionTime: executionTime,
-		EnvParams:     envParams,
-		CreatedBy:     createdBy,
+		TaskID:         taskID,
+		Content:        processedContent,
+		Status:         status,
+		ExecutionTime:  executionTime,
+		EnvParams:      envParams,
+		Priority:       priority,
+		IsolatedBranch: isolatedBranch || task.ParallelConversations,
+		CreatedBy:      createdBy,
 	}
 
-	if err := s.repo.Create(conversation); err != nil {
+	if duplicate, err := s.createConversation(policy, conversation, attachmentIDs); err != nil {
 		return nil, err
+	} else if duplicate != nil {
+		duplicate.Task = task
+		return duplicate, nil
 	}
 
 	// Process attachment associations if provided
@@ -229,6 +398,36 @@ func (s *taskConversationService) ListConversations(taskID uint, page, pageSize
 	return s.repo.List(taskID, page, pageSize)
 }
 
+// conversationSearchQueryMaxLength bounds search terms, longer ones are not useful for LIKE matching
+const conversationSearchQueryMaxLength = 200
+
+// SearchConversations finds conversations across projects whose content, or execution log
+// when includeLogs is set, contains the query case-insensitively
+func (s *taskConversationService) SearchConversations(query string, projectID *uint, includeLogs bool, page, pageSize int) ([]repository.ConversationSearchHit, int64, error) {
+	query = strings.TrimSpace(query)
+	if len(query) < 2 || len(query) > conversationSearchQueryMaxLength {
+		return nil, 0, appErrors.ErrConversationSearchQueryInvalid
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	hits, total, err := s.repo.Search(repository.ConversationSearchParams{
+		Query:       query,
+		ProjectID:   projectID,
+		IncludeLogs: includeLogs,
+		Page:        page,
+		PageSize:    pageSize,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search conversations: %v", err)
+	}
+	return hits, total, nil
+}
+
 func (s *taskConversationService) UpdateConversation(id uint, updates map[string]interface{}) error {
 	conversation, err := s.repo.GetByID(id)
 	if err != nil {
@@ -241,28 +440,106 @@ func (s *taskConversationService) UpdateConversation(id uint, updates map[string
 		}
 	}
 
+	isDraft := conversation.Status == database.ConversationStatusDraft
 	for key, value := range updates {
 		switch key {
 		case "content":
 			if v, ok := value.(string); ok {
 				conversation.Content = strings.TrimSpace(v)
 			}
+		case "execution_time":
+			if !isDraft {
+				return appErrors.ErrConversationNotDraft
+			}
+			if v, ok := value.(*time.Time); ok {
+				conversation.ExecutionTime = v
+			}
+		case "env_params":
+			if !isDraft {
+				return appErrors.ErrConversationNotDraft
+			}
+			if v, ok := value.(string); ok {
+				if v == "" {
+					v = "{}"
+				}
+				conversation.EnvParams = v
+			}
 		}
 	}
 
 	return s.repo.Update(conversation)
 }
 
+// PromoteDraftConversation queues a draft conversation for execution
+func (s *taskConversationService) PromoteDraftConversation(id uint) (*database.TaskConversation, error) {
+	conversation, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.Status != database.ConversationStatusDraft {
+		return nil, appErrors.ErrConversationNotDraft
+	}
+
+	if conversation.Task != nil && (conversation.Task.Status == database.TaskStatusDone || conversation.Task.Status == database.TaskStatusCancelled) {
+		return nil, appErrors.ErrConversationTaskCompleted
+	}
+
+	if conversation.Task != nil {
+		if err := s.checkTaskQuota(conversation.Task, conversation.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkTaskIdle(conversation.TaskID, conversation.Task); err != nil {
+		return nil, err
+	}
+
+	conversation.Status = database.ConversationStatusPending
+	if err := s.repo.Update(conversation); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// checkTaskIdle rejects a conversation while another one of the task is pending or running.
+// Tasks running conversations in parallel give each its own workspace and accept any number.
+func (s *taskConversationService) checkTaskIdle(taskID uint, task *database.Task) error {
+	if task != nil && task.ParallelConversations {
+		return nil
+	}
+
+	hasPendingOrRunning, err := s.repo.HasPendingOrRunningConversations(taskID)
+	if err != nil {
+		return appErrors.ErrConversationGetFailed
+	}
+	if hasPendingOrRunning {
+		return appErrors.ErrConversationCreateFailed
+	}
+	return nil
+}
+
 func (s *taskConversationService) DeleteConversation(id uint) error {
 	conversation, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if conversation.Status == database.ConversationStatusRunning {
+	if conversation.Status == database.ConversationStatusRunning || conversation.Status == database.ConversationStatusAwaitingApproval {
 		return appErrors.ErrConversationDeleteFailed
 	}
 
+	// Drafts never ran, so there is no workspace state or session to roll back
+	if conversation.Status == database.ConversationStatusDraft {
+		if err := s.attachmentService.DeleteAttachmentsByConversation(id); err != nil {
+			utils.Warn("Failed to delete conversation attachments",
+				"conversation_id", id,
+				"error", err)
+		}
+		return s.repo.Delete(id)
+	}
+
 	latestConversation, err := s.repo.GetLatestByTask(conversation.TaskID)
 	if err != nil {
 		return appErrors.ErrConversationGetFailed
@@ -272,7 +549,10 @@ func (s *taskConversationService) DeleteConversation(id uint) error {
 		return appErrors.ErrConversationDeleteLatestOnly
 	}
 
-	if conversation.CommitHash != "" && conversation.Task != nil && conversation.Task.WorkspacePath != "" {
+	// A conversation that ran in its own workspace committed only to its own branch, resetting
+	// the shared workspace would rewind whatever branch is checked out there
+	ranInOwnWorkspace := conversation.Task != nil && conversation.Task.ParallelConversations && conversation.IsolatedBranch
+	if conversation.CommitHash != "" && conversation.Task != nil && conversation.Task.WorkspacePath != "" && !ranInOwnWorkspace {
 		if err := utils.GitResetToPreviousCommit(conversation.Task.WorkspacePath, conversation.CommitHash); err != nil {
 			utils.Error("Failed to reset git repository to previous commit",
 				"conversation_id", id,
@@ -367,19 +647,12 @@ func (s *taskConversationService) GetConversationGitDiff(conversationID uint, in
 		return nil, appErrors.ErrNoCommitHash
 	}
 
-	task, err := s.taskRepo.GetByID(conversation.TaskID)
+	absoluteWorkspacePath, err := s.conversationCommitWorkspace(conversation)
 	if err != nil {
-		return nil, appErrors.ErrTaskNotFound
-	}
-
-	if task.WorkspacePath == "" {
-		return nil, appErrors.ErrWorkspacePathEmpty
+		return nil, err
 	}
 
-	// Convert relative workspace path to absolute for git operations
-	absoluteWorkspacePath := s.workspaceManager.GetAbsolutePath(task.WorkspacePath)
-
-	diff, err := utils.GetCommitDiff(absoluteWorkspacePath, conversation.CommitHash, includeContent)
+	diff, err := utils.GetCommitDiff(absoluteWorkspacePath, conversation.CommitHash, includeContent, diffContentLimits(s.systemConfigService))
 	if err != nil {
 		return nil, err
 	}
@@ -387,20 +660,32 @@ func (s *taskConversationService) GetConversationGitDiff(conversationID uint, in
 	return diff, nil
 }
 
-func (s *taskConversationService) GetConversationGitDiffFile(conversationID uint, filePath string) (string, error) {
+func (s *taskConversationService) GetConversationGitDiffFile(conversationID uint, filePath string, hunkOffset, hunkLimit int) (*utils.FileDiff, error) {
 	if filePath == "" {
-		return "", appErrors.ErrFilePathEmpty
+		return nil, appErrors.ErrFilePathEmpty
 	}
 
 	conversation, err := s.repo.GetByID(conversationID)
 	if err != nil {
-		return "", appErrors.ErrTaskNotFound
+		return nil, appErrors.ErrTaskNotFound
 	}
 
 	if conversation.CommitHash == "" {
-		return "", appErrors.ErrNoCommitHash
+		return nil, appErrors.ErrNoCommitHash
 	}
 
+	absoluteWorkspacePath, err := s.conversationCommitWorkspace(conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := fileDiffOptions(s.systemConfigService, hunkOffset, hunkLimit)
+	return utils.GetCommitFileDiff(absoluteWorkspacePath, conversation.CommitHash, filePath, opts)
+}
+
+// conversationCommitWorkspace returns the absolute workspace path holding the conversation's
+// commit. The workspace may have been cleaned up or re-cloned since the conversation ran.
+func (s *taskConversationService) conversationCommitWorkspace(conversation *database.TaskConversation) (string, error) {
 	task, err := s.taskRepo.GetByID(conversation.TaskID)
 	if err != nil {
 		return "", appErrors.ErrTaskNotFound
@@ -409,14 +694,137 @@ func (s *taskConversationService) GetConversationGitDiffFile(conversationID uint
 	if task.WorkspacePath == "" {
 		return "", appErrors.ErrWorkspacePathEmpty
 	}
+	if !s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+		return "", appErrors.ErrConversationWorkspaceUnavailable
+	}
 
 	// Convert relative workspace path to absolute for git operations
 	absoluteWorkspacePath := s.workspaceManager.GetAbsolutePath(task.WorkspacePath)
+	if !utils.CommitExists(absoluteWorkspacePath, conversation.CommitHash) {
+		return "", appErrors.ErrConversationCommitUnavailable
+	}
+
+	return absoluteWorkspacePath, nil
+}
 
-	diffContent, err := utils.GetCommitFileDiff(absoluteWorkspacePath, conversation.CommitHash, filePath)
+// BuildConversationBundle packs the execution log, result, commit patch and metadata
+// of a conversation into a zip archive
+func (s *taskConversationService) BuildConversationBundle(conversationID uint) ([]byte, error) {
+	conversation, result, executionLog, err := s.repo.GetWithResult(conversationID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"conversation": map[string]interface{}{
+			"id":             conversation.ID,
+			"task_id":        conversation.TaskID,
+			"content":        conversation.Content,
+			"retry_content":  conversation.RetryContent,
+			"status":         conversation.Status,
+			"commit_hash":    conversation.CommitHash,
+			"env_params":     conversation.EnvParams,
+			"execution_time": conversation.ExecutionTime,
+			"created_by":     conversation.CreatedBy,
+			"created_at":     conversation.CreatedAt,
+		},
+		"exported_at": utils.Now(),
+	}
+
+	if task := conversation.Task; task != nil {
+		taskMetadata := map[string]interface{}{
+			"id":           task.ID,
+			"title":        task.Title,
+			"start_branch": task.StartBranch,
+			"work_branch":  task.WorkBranch,
+		}
+		if task.Project != nil {
+			taskMetadata["project"] = map[string]interface{}{
+				"id":       task.Project.ID,
+				"name":     task.Project.Name,
+				"repo_url": task.Project.RepoURL,
+			}
+		}
+		if task.DevEnvironment != nil {
+			taskMetadata["dev_environment"] = map[string]interface{}{
+				"id":           task.DevEnvironment.ID,
+				"name":         task.DevEnvironment.Name,
+				"type":         task.DevEnvironment.Type,
+				"docker_image": task.DevEnvironment.DockerImage,
+			}
+		}
+		metadata["task"] = taskMetadata
 	}
 
-	return diffContent, nil
+	if executionLog != nil {
+		dockerCommand := executionLog.DockerCommand
+		if keys, err := s.systemConfigService.GetRedactedEnvVarKeys(); err != nil {
+			utils.Warn("Failed to get redacted env var keys", "error", err)
+		} else {
+			dockerCommand = utils.RedactDockerEnvArgs(dockerCommand, keys)
+		}
+		metadata["execution"] = map[string]interface{}{
+			"docker_command": dockerCommand,
+			"error_message":  executionLog.ErrorMessage,
+			"started_at":     executionLog.StartedAt,
+			"completed_at":   executionLog.CompletedAt,
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+
+	if err := writeJSONToZip(zipWriter, "metadata.json", metadata); err != nil {
+		return nil, err
+	}
+
+	if executionLog != nil {
+		if err := writeFileToZip(zipWriter, "execution.log", []byte(executionLog.ExecutionLogs)); err != nil {
+			return nil, err
+		}
+	}
+
+	if result != nil {
+		if err := writeJSONToZip(zipWriter, "result.json", result); err != nil {
+			return nil, err
+		}
+	}
+
+	if conversation.CommitHash != "" && conversation.Task != nil && conversation.Task.WorkspacePath != "" {
+		absoluteWorkspacePath := s.workspaceManager.GetAbsolutePath(conversation.Task.WorkspacePath)
+		patch, err := utils.GetCommitPatch(absoluteWorkspacePath, conversation.CommitHash)
+		if err != nil {
+			utils.Warn("Failed to get commit patch for conversation bundle",
+				"conversation_id", conversationID,
+				"commit_hash", conversation.CommitHash,
+				"error", err)
+		} else if err := writeFileToZip(zipWriter, "changes.patch", []byte(patch)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize bundle: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSONToZip(zipWriter *zip.Writer, name string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s: %v", name, err)
+	}
+	return writeFileToZip(zipWriter, name, content)
+}
+
+func writeFileToZip(zipWriter *zip.Writer, name string, content []byte) error {
+	fileWriter, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %v", name, err)
+	}
+	if _, err := fileWriter.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %v", name, err)
+	}
+	return nil
 }
@@ -10,11 +10,24 @@ import (
 	"xsha-backend/utils"
 )
 
+// SessionResultSummary totals the results of every conversation that took part in a session
+type SessionResultSummary struct {
+	SessionID         string  `json:"session_id"`
+	ResultCount       int     `json:"result_count"`
+	ErrorCount        int     `json:"error_count"`
+	TotalCostUsd      float64 `json:"total_cost_usd"`
+	TotalDurationMs   int64   `json:"total_duration_ms"`
+	TotalNumTurns     int     `json:"total_num_turns"`
+	TotalInputTokens  int64   `json:"total_input_tokens"`
+	TotalOutputTokens int64   `json:"total_output_tokens"`
+}
+
 type taskConversationResultService struct {
 	repo             repository.TaskConversationResultRepository
 	conversationRepo repository.TaskConversationRepository
 	taskRepo         repository.TaskRepository
 	projectRepo      repository.ProjectRepository
+	execLogRepo      repository.TaskExecutionLogRepository
 }
 
 func NewTaskConversationResultService(
@@ -22,12 +35,14 @@ func NewTaskConversationResultService(
 	conversationRepo repository.TaskConversationRepository,
 	taskRepo repository.TaskRepository,
 	projectRepo repository.ProjectRepository,
+	execLogRepo repository.TaskExecutionLogRepository,
 ) TaskConversationResultService {
 	return &taskConversationResultService{
 		repo:             repo,
 		conversationRepo: conversationRepo,
 		taskRepo:         taskRepo,
 		projectRepo:      projectRepo,
+		execLogRepo:      execLogRepo,
 	}
 }
 
@@ -76,6 +91,10 @@ func (s *taskConversationResultService) CreateResult(conversationID uint, result
 		result.SessionID = sessionID
 	}
 
+	if toolVersion, ok := resultData["tool_version"].(string); ok {
+		result.ToolVersion = toolVersion
+	}
+
 	if totalCost, ok := resultData["total_cost_usd"].(float64); ok {
 		result.TotalCostUsd = totalCost
 	}
@@ -87,6 +106,19 @@ func (s *taskConversationResultService) CreateResult(conversationID uint, result
 		} else {
 			result.Usage = string(usageBytes)
 		}
+		if usageMap, ok := usage.(map[string]interface{}); ok {
+			result.InputTokens = tokenCount(usageMap, "input_tokens")
+			result.OutputTokens = tokenCount(usageMap, "output_tokens")
+		}
+	}
+
+	if timeline, ok := resultData["timeline"]; ok {
+		timelineBytes, err := json.Marshal(timeline)
+		if err != nil {
+			utils.Warn("Failed to marshal timeline data", "error", err)
+		} else {
+			result.Timeline = string(timelineBytes)
+		}
 	}
 
 	if err := s.repo.Create(result); err != nil {
@@ -101,14 +133,30 @@ func (s *taskConversationResultService) CreateResult(conversationID uint, result
 }
 
 func (s *taskConversationResultService) GetResult(id uint) (*database.TaskConversationResult, error) {
-	return s.repo.GetByID(id)
+	result, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	setChangesCommitted(result)
+	return result, nil
 }
 
 func (s *taskConversationResultService) GetResultByConversationID(conversationID uint) (*database.TaskConversationResult, error) {
-	return s.repo.GetByConversationID(conversationID)
+	result, err := s.repo.GetByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	setChangesCommitted(result)
+	return result, nil
 }
 
+// UpdateResult applies the given fields to a result. Values come from decoded JSON, so numbers
+// are float64, and a field of the wrong type is rejected rather than ignored.
 func (s *taskConversationResultService) UpdateResult(id uint, updates map[string]interface{}) error {
+	if err := validateResultUpdates(updates); err != nil {
+		return err
+	}
+
 	result, err := s.repo.GetByID(id)
 	if err != nil {
 		return appErrors.ErrConversationResultNotFound
@@ -123,14 +171,14 @@ func (s *taskConversationResultService) UpdateResult(id uint, updates map[string
 	if isErrorVal, ok := updates["is_error"].(bool); ok {
 		result.IsError = isErrorVal
 	}
-	if durationMs, ok := updates["duration_ms"].(int64); ok {
-		result.DurationMs = durationMs
+	if durationMs, ok := updates["duration_ms"].(float64); ok {
+		result.DurationMs = int64(durationMs)
 	}
-	if durationApiMs, ok := updates["duration_api_ms"].(int64); ok {
-		result.DurationApiMs = durationApiMs
+	if durationApiMs, ok := updates["duration_api_ms"].(float64); ok {
+		result.DurationApiMs = int64(durationApiMs)
 	}
-	if numTurns, ok := updates["num_turns"].(int); ok {
-		result.NumTurns = numTurns
+	if numTurns, ok := updates["num_turns"].(float64); ok {
+		result.NumTurns = int(numTurns)
 	}
 	if resultStr, ok := updates["result"].(string); ok {
 		result.Result = resultStr
@@ -141,23 +189,133 @@ func (s *taskConversationResultService) UpdateResult(id uint, updates map[string
 	if totalCost, ok := updates["total_cost_usd"].(float64); ok {
 		result.TotalCostUsd = totalCost
 	}
-	if usage, ok := updates["usage"].(string); ok {
-		result.Usage = usage
+	if usage, ok := updates["usage"]; ok {
+		switch usageVal := usage.(type) {
+		case string:
+			result.Usage = usageVal
+		case map[string]interface{}:
+			usageBytes, err := json.Marshal(usageVal)
+			if err != nil {
+				return fmt.Errorf("failed to serialize usage: %v", err)
+			}
+			result.Usage = string(usageBytes)
+			result.InputTokens = tokenCount(usageVal, "input_tokens")
+			result.OutputTokens = tokenCount(usageVal, "output_tokens")
+		}
+	}
+	if inputTokens, ok := updates["input_tokens"].(float64); ok {
+		result.InputTokens = int64(inputTokens)
+	}
+	if outputTokens, ok := updates["output_tokens"].(float64); ok {
+		result.OutputTokens = int64(outputTokens)
 	}
 
 	return s.repo.Update(result)
 }
 
+// validateResultUpdates checks the type of every known field present in a result update
+func validateResultUpdates(updates map[string]interface{}) error {
+	for _, field := range []string{"type", "subtype", "result", "session_id"} {
+		if value, ok := updates[field]; ok {
+			if _, isString := value.(string); !isString {
+				return fmt.Errorf("%s must be a string", field)
+			}
+		}
+	}
+
+	if isError, ok := updates["is_error"]; ok {
+		if _, isBool := isError.(bool); !isBool {
+			return errors.New("is_error must be boolean")
+		}
+	}
+
+	for _, field := range []string{"duration_ms", "duration_api_ms", "num_turns", "total_cost_usd", "input_tokens", "output_tokens"} {
+		if value, ok := updates[field]; ok {
+			if number, isFloat := value.(float64); !isFloat || number < 0 {
+				return fmt.Errorf("%s must be a non-negative number", field)
+			}
+		}
+	}
+
+	if usage, ok := updates["usage"]; ok {
+		switch usageVal := usage.(type) {
+		case string:
+		case map[string]interface{}:
+			for _, field := range []string{"input_tokens", "output_tokens"} {
+				if tokens, ok := usageVal[field]; ok {
+					if _, isFloat := tokens.(float64); !isFloat {
+						return fmt.Errorf("usage.%s must be a number", field)
+					}
+				}
+			}
+		default:
+			return errors.New("usage must be a string or an object")
+		}
+	}
+
+	return nil
+}
+
 func (s *taskConversationResultService) DeleteResult(id uint) error {
 	return s.repo.Delete(id)
 }
 
 func (s *taskConversationResultService) ListResultsByTaskID(taskID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error) {
-	return s.repo.ListByTaskID(taskID, page, pageSize)
+	results, total, err := s.repo.ListByTaskID(taskID, page, pageSize)
+	for i := range results {
+		setChangesCommitted(&results[i])
+	}
+	return results, total, err
 }
 
 func (s *taskConversationResultService) ListResultsByProjectID(projectID uint, page, pageSize int) ([]database.TaskConversationResult, int64, error) {
-	return s.repo.ListByProjectID(projectID, page, pageSize)
+	results, total, err := s.repo.ListByProjectID(projectID, page, pageSize)
+	for i := range results {
+		setChangesCommitted(&results[i])
+	}
+	return results, total, err
+}
+
+// setChangesCommitted copies the changes committed flag from the result's preloaded conversation
+func setChangesCommitted(result *database.TaskConversationResult) {
+	if result.Conversation != nil {
+		result.ChangesCommitted = result.Conversation.ChangesCommitted
+	}
+}
+
+// ListResultsBySessionID returns a page of the results of a session across conversations and
+// tasks, along with the totals of the whole session
+func (s *taskConversationResultService) ListResultsBySessionID(sessionID string, page, pageSize int) ([]database.TaskConversationResult, int64, *SessionResultSummary, error) {
+	results, total, err := s.repo.ListBySessionID(sessionID, page, pageSize)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	for i := range results {
+		setChangesCommitted(&results[i])
+	}
+
+	totals, err := s.repo.GetSessionTotals(sessionID)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	summary := &SessionResultSummary{
+		SessionID:         sessionID,
+		ResultCount:       int(totals.ResultCount),
+		ErrorCount:        int(totals.ErrorCount),
+		TotalCostUsd:      totals.TotalCostUsd,
+		TotalDurationMs:   totals.TotalDurationMs,
+		TotalNumTurns:     int(totals.TotalNumTurns),
+		TotalInputTokens:  totals.TotalInputTokens,
+		TotalOutputTokens: totals.TotalOutputTokens,
+	}
+
+	return results, total, summary, nil
+}
+
+// ListConversationsMissingResult lists succeeded conversations whose output produced no result record
+func (s *taskConversationResultService) ListConversationsMissingResult(projectID *uint, page, pageSize int) ([]database.TaskConversation, int64, error) {
+	return s.conversationRepo.ListSucceededWithoutResult(projectID, page, pageSize)
 }
 
 func (s *taskConversationResultService) GetTaskStats(taskID uint) (map[string]interface{}, error) {
@@ -184,6 +342,14 @@ func (s *taskConversationResultService) GetTaskStats(taskID uint) (map[string]in
 	}
 	stats["average_duration_ms"] = avgDuration
 
+	inputTokens, outputTokens, err := s.repo.GetTotalTokens(taskID)
+	if err != nil {
+		utils.Warn("Failed to get total tokens", "task_id", taskID, "error", err)
+		inputTokens, outputTokens = 0, 0
+	}
+	stats["total_input_tokens"] = inputTokens
+	stats["total_output_tokens"] = outputTokens
+
 	return stats, nil
 }
 
@@ -198,6 +364,8 @@ func (s *taskConversationResultService) GetProjectStats(projectID uint) (map[str
 	successCount := 0
 	totalCost := 0.0
 	totalDuration := int64(0)
+	totalInputTokens := int64(0)
+	totalOutputTokens := int64(0)
 
 	for _, result := range results {
 		if !result.IsError {
@@ -205,6 +373,8 @@ func (s *taskConversationResultService) GetProjectStats(projectID uint) (map[str
 		}
 		totalCost += result.TotalCostUsd
 		totalDuration += result.DurationMs
+		totalInputTokens += result.InputTokens
+		totalOutputTokens += result.OutputTokens
 	}
 
 	stats["total_conversations"] = totalCount
@@ -215,14 +385,36 @@ func (s *taskConversationResultService) GetProjectStats(projectID uint) (map[str
 		stats["success_rate"] = float64(successCount) / float64(totalCount)
 	}
 	stats["total_cost_usd"] = totalCost
+	stats["total_input_tokens"] = totalInputTokens
+	stats["total_output_tokens"] = totalOutputTokens
 	stats["average_duration_ms"] = 0.0
 	if totalCount > 0 {
 		stats["average_duration_ms"] = float64(totalDuration) / float64(totalCount)
 	}
 
+	failureCategories, err := s.execLogRepo.CountFailureCategoriesByProject(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count project failure categories: %w", err)
+	}
+	stats["failure_categories"] = failureCategories
+	stats["dominant_failure_category"] = dominantFailureCategory(failureCategories)
+
 	return stats, nil
 }
 
+// dominantFailureCategory returns the most frequent failure category, "" when there are none
+func dominantFailureCategory(counts map[database.FailureCategory]int64) database.FailureCategory {
+	var dominant database.FailureCategory
+	var dominantCount int64
+	for category, count := range counts {
+		if count > dominantCount || (count == dominantCount && category < dominant) {
+			dominant = category
+			dominantCount = count
+		}
+	}
+	return dominant
+}
+
 func (s *taskConversationResultService) ExistsForConversation(conversationID uint) (bool, error) {
 	return s.repo.ExistsByConversationID(conversationID)
 }
@@ -244,8 +436,11 @@ func (s *taskConversationResultService) ValidateResultData(resultData map[string
 		return errors.New("result content is required")
 	}
 
-	if sessionID, ok := resultData["session_id"].(string); !ok || sessionID == "" {
-		return errors.New("session_id is required")
+	// Tools without resumable sessions report none
+	if sessionID, ok := resultData["session_id"]; ok {
+		if _, isString := sessionID.(string); !isString {
+			return errors.New("session_id must be a string")
+		}
 	}
 
 	if durationMs, ok := resultData["duration_ms"]; ok {
@@ -272,5 +467,27 @@ func (s *taskConversationResultService) ValidateResultData(resultData map[string
 		}
 	}
 
+	if usage, ok := resultData["usage"]; ok && usage != nil {
+		usageMap, isMap := usage.(map[string]interface{})
+		if !isMap {
+			return errors.New("usage must be an object")
+		}
+		for _, field := range []string{"input_tokens", "output_tokens"} {
+			if tokens, ok := usageMap[field]; ok {
+				if _, isFloat := tokens.(float64); !isFloat {
+					return fmt.Errorf("usage.%s must be a number", field)
+				}
+			}
+		}
+	}
+
 	return nil
 }
+
+// tokenCount reads a token count from the decoded usage object, 0 when it is missing
+func tokenCount(usage map[string]interface{}, field string) int64 {
+	if tokens, ok := usage[field].(float64); ok {
+		return int64(tokens)
+	}
+	return 0
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// Task export formats
+const (
+	TaskExportFormatMarkdown = "markdown"
+	TaskExportFormatJSON     = "json"
+)
+
+// TaskExportSchemaVersion is bumped whenever the JSON export layout changes incompatibly
+const TaskExportSchemaVersion = 1
+
+const redactedSecret = "***"
+
+// TaskExport is the JSON export of a task and all of its conversations
+type TaskExport struct {
+	SchemaVersion int                      `json:"schema_version"`
+	ExportedAt    time.Time                `json:"exported_at"`
+	Task          TaskExportTask           `json:"task"`
+	Conversations []TaskExportConversation `json:"conversations"`
+}
+
+type TaskExportTask struct {
+	ID                 uint                `json:"id"`
+	Title              string              `json:"title"`
+	Status             database.TaskStatus `json:"status"`
+	StartBranch        string              `json:"start_branch"`
+	WorkBranch         string              `json:"work_branch"`
+	ProjectID          uint                `json:"project_id"`
+	ProjectName        string              `json:"project_name"`
+	DevEnvironmentID   *uint               `json:"dev_environment_id"`
+	DevEnvironmentName string              `json:"dev_environment_name"`
+	CreatedBy          string              `json:"created_by"`
+	CreatedAt          time.Time           `json:"created_at"`
+	RedactedFields     []string            `json:"redacted_fields,omitempty"`
+}
+
+type TaskExportConversation struct {
+	ID             uint                        `json:"id"`
+	Content        string                      `json:"content"`
+	Status         database.ConversationStatus `json:"status"`
+	CommitHash     string                      `json:"commit_hash"`
+	IsolatedBranch bool                        `json:"isolated_branch"`
+	WorkBranch     string                      `json:"work_branch"`
+	EnvParams      string                      `json:"env_params"`
+	CreatedBy      string                      `json:"created_by"`
+	CreatedAt      time.Time                   `json:"created_at"`
+	Result         *TaskExportResult           `json:"result"`
+	Execution      *TaskExportExecution        `json:"execution"`
+	// RedactedFields names the fields in which env var values were masked
+	RedactedFields []string `json:"redacted_fields,omitempty"`
+}
+
+type TaskExportResult struct {
+	IsError      bool                   `json:"is_error"`
+	Subtype      database.ResultSubtype `json:"subtype"`
+	Result       string                 `json:"result"`
+	DurationMs   int64                  `json:"duration_ms"`
+	NumTurns     int                    `json:"num_turns"`
+	TotalCostUsd float64                `json:"total_cost_usd"`
+	InputTokens  int64                  `json:"input_tokens"`
+	OutputTokens int64                  `json:"output_tokens"`
+}
+
+// TaskExportExecution summarizes the execution log, the raw log output is left out
+type TaskExportExecution struct {
+	StartedAt       *time.Time               `json:"started_at"`
+	CompletedAt     *time.Time               `json:"completed_at"`
+	ErrorMessage    string                   `json:"error_message"`
+	FailureCategory database.FailureCategory `json:"failure_category"`
+	ToolVersion     string                   `json:"tool_version"`
+}
+
+// ExportTask renders a task with its conversations, results and execution summaries as
+// Markdown or JSON. Values of the dev environment's env vars and env files are masked wherever
+// they appear, and the fields that were masked are listed in the export.
+func (s *taskConversationService) ExportTask(taskID uint, format string) ([]byte, error) {
+	if format != TaskExportFormatMarkdown && format != TaskExportFormatJSON {
+		return nil, appErrors.ErrTaskExportFormatInvalid
+	}
+
+	export, err := s.buildTaskExport(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == TaskExportFormatJSON {
+		content, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize task export: %v", err)
+		}
+		return content, nil
+	}
+	return []byte(renderTaskExportMarkdown(export)), nil
+}
+
+func (s *taskConversationService) buildTaskExport(taskID uint) (*TaskExport, error) {
+	task, err := s.taskRepo.GetByID(taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrTaskNotFound
+		}
+		return nil, err
+	}
+
+	conversations, err := s.repo.ListByTask(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task conversations: %v", err)
+	}
+
+	redactor := s.newSecretRedactor(task.DevEnvironment)
+
+	var taskRedacted []string
+	export := &TaskExport{
+		SchemaVersion: TaskExportSchemaVersion,
+		ExportedAt:    utils.Now(),
+		Task: TaskExportTask{
+			ID:          task.ID,
+			Title:       redactor.redact(task.Title, "title", &taskRedacted),
+			Status:      task.Status,
+			StartBranch: task.StartBranch,
+			WorkBranch:  task.WorkBranch,
+			ProjectID:   task.ProjectID,
+			CreatedBy:   task.CreatedBy,
+			CreatedAt:   task.CreatedAt,
+		},
+		Conversations: make([]TaskExportConversation, 0, len(conversations)),
+	}
+	export.Task.RedactedFields = taskRedacted
+	if task.Project != nil {
+		export.Task.ProjectName = task.Project.Name
+	}
+	if task.DevEnvironment != nil {
+		export.Task.DevEnvironmentID = &task.DevEnvironment.ID
+		export.Task.DevEnvironmentName = task.DevEnvironment.Name
+	}
+
+	for _, conversation := range conversations {
+		var redacted []string
+		item := TaskExportConversation{
+			ID:             conversation.ID,
+			Content:        redactor.redact(conversation.Content, "content", &redacted),
+			Status:         conversation.Status,
+			CommitHash:     conversation.CommitHash,
+			IsolatedBranch: conversation.IsolatedBranch,
+			WorkBranch:     conversation.WorkBranch,
+			EnvParams:      redactor.redactEnvParams(conversation.EnvParams, &redacted),
+			CreatedBy:      conversation.CreatedBy,
+			CreatedAt:      conversation.CreatedAt,
+		}
+
+		result, err := s.resultRepo.GetByConversationID(conversation.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get result of conversation %d: %v", conversation.ID, err)
+		}
+		if result != nil {
+			item.Result = &TaskExportResult{
+				IsError:      result.IsError,
+				Subtype:      result.Subtype,
+				Result:       redactor.redact(result.Result, "result", &redacted),
+				DurationMs:   result.DurationMs,
+				NumTurns:     result.NumTurns,
+				TotalCostUsd: result.TotalCostUsd,
+				InputTokens:  result.InputTokens,
+				OutputTokens: result.OutputTokens,
+			}
+		}
+
+		execLog, err := s.execLogRepo.GetByConversationID(conversation.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get execution log of conversation %d: %v", conversation.ID, err)
+		}
+		if execLog != nil {
+			item.Execution = &TaskExportExecution{
+				StartedAt:       execLog.StartedAt,
+				CompletedAt:     execLog.CompletedAt,
+				ErrorMessage:    redactor.redact(execLog.ErrorMessage, "error_message", &redacted),
+				FailureCategory: execLog.FailureCategory,
+				ToolVersion:     execLog.ToolVersion,
+			}
+		}
+
+		item.RedactedFields = redacted
+		export.Conversations = append(export.Conversations, item)
+	}
+
+	return export, nil
+}
+
+// secretRedactor masks the values of a dev environment's env vars in exported text
+type secretRedactor struct {
+	keys     map[string]bool
+	replacer *strings.Replacer
+}
+
+// newSecretRedactor collects the values of every env var and env file of the environment,
+// whatever their length, since any of them may hold a credential. Longer values are replaced
+// first so a value containing another one is masked whole.
+func (s *taskConversationService) newSecretRedactor(devEnv *database.DevEnvironment) *secretRedactor {
+	redactor := &secretRedactor{keys: make(map[string]bool)}
+	if devEnv == nil {
+		return redactor
+	}
+
+	values := make(map[string]string)
+	if devEnv.EnvVars != "" {
+		if err := json.Unmarshal([]byte(devEnv.EnvVars), &values); err != nil {
+			utils.Warn("Failed to parse environment variables for export redaction", "envID", devEnv.ID, "error", err)
+		}
+	}
+
+	if devEnv.EnvFiles != "" {
+		envFiles := make(map[string]string)
+		if err := json.Unmarshal([]byte(devEnv.EnvFiles), &envFiles); err != nil {
+			utils.Warn("Failed to parse env files for export redaction", "envID", devEnv.ID, "error", err)
+		}
+		for key, relativePath := range envFiles {
+			value, err := utils.ReadEnvFile(s.config.EnvFilesDir, relativePath)
+			if err != nil {
+				utils.Warn("Failed to read env file for export redaction", "envID", devEnv.ID, "key", key, "error", err)
+				continue
+			}
+			values[key] = value
+		}
+	}
+
+	secrets := make([]string, 0, len(values))
+	for key, value := range values {
+		redactor.keys[key] = true
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	if len(secrets) == 0 {
+		return redactor
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, redactedSecret)
+	}
+	redactor.replacer = strings.NewReplacer(pairs...)
+	return redactor
+}
+
+// redact masks secret values in text, recording the field name when anything was masked
+func (r *secretRedactor) redact(text, field string, redactedFields *[]string) string {
+	if r.replacer == nil || text == "" {
+		return text
+	}
+	masked := r.replacer.Replace(text)
+	if masked != text {
+		*redactedFields = append(*redactedFields, field)
+	}
+	return masked
+}
+
+// redactEnvParams masks conversation env params named after an env var of the environment,
+// then masks secret values in the remaining ones
+func (r *secretRedactor) redactEnvParams(envParams string, redactedFields *[]string) string {
+	params := make(map[string]interface{})
+	if len(r.keys) == 0 || envParams == "" || json.Unmarshal([]byte(envParams), &params) != nil {
+		return r.redact(envParams, "env_params", redactedFields)
+	}
+
+	maskedKey := false
+	for key := range params {
+		if r.keys[key] {
+			params[key] = redactedSecret
+			maskedKey = true
+		}
+	}
+	if !maskedKey {
+		return r.redact(envParams, "env_params", redactedFields)
+	}
+
+	content, err := json.Marshal(params)
+	if err != nil {
+		return r.redact(envParams, "env_params", redactedFields)
+	}
+	*redactedFields = append(*redactedFields, "env_params")
+	var ignored []string
+	return r.redact(string(content), "env_params", &ignored)
+}
+
+func renderTaskExportMarkdown(export *TaskExport) string {
+	var b strings.Builder
+	task := export.Task
+
+	fmt.Fprintf(&b, "# Task #%d: %s\n\n", task.ID, task.Title)
+	fmt.Fprintf(&b, "- Project: %s\n", task.ProjectName)
+	fmt.Fprintf(&b, "- Status: %s\n", task.Status)
+	fmt.Fprintf(&b, "- Start branch: %s\n", task.StartBranch)
+	fmt.Fprintf(&b, "- Work branch: %s\n", task.WorkBranch)
+	if task.DevEnvironmentName != "" {
+		fmt.Fprintf(&b, "- Environment: %s\n", task.DevEnvironmentName)
+	}
+	fmt.Fprintf(&b, "- Created by: %s at %s\n", task.CreatedBy, task.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Exported at: %s\n", export.ExportedAt.Format(time.RFC3339))
+	if len(task.RedactedFields) > 0 {
+		fmt.Fprintf(&b, "- Redacted: %s\n", strings.Join(task.RedactedFields, ", "))
+	}
+
+	for i, conversation := range export.Conversations {
+		fmt.Fprintf(&b, "\n## Conversation %d (#%d) - %s\n\n", i+1, conversation.ID, conversation.Status)
+		fmt.Fprintf(&b, "- Created by: %s at %s\n", conversation.CreatedBy, conversation.CreatedAt.Format(time.RFC3339))
+		if conversation.CommitHash != "" {
+			fmt.Fprintf(&b, "- Commit: %s\n", conversation.CommitHash)
+		}
+		if conversation.IsolatedBranch && conversation.WorkBranch != "" {
+			fmt.Fprintf(&b, "- Branch: %s\n", conversation.WorkBranch)
+		}
+		if len(conversation.RedactedFields) > 0 {
+			fmt.Fprintf(&b, "- Redacted: %s\n", strings.Join(conversation.RedactedFields, ", "))
+		}
+
+		b.WriteString("\n### Prompt\n\n")
+		b.WriteString(markdownQuote(conversation.Content))
+
+		if result := conversation.Result; result != nil {
+			b.WriteString("\n### Result\n\n")
+			if result.IsError {
+				fmt.Fprintf(&b, "Finished with an error (%s).\n\n", result.Subtype)
+			}
+			b.WriteString(markdownQuote(result.Result))
+			fmt.Fprintf(&b, "\n- Duration: %s\n", time.Duration(result.DurationMs)*time.Millisecond)
+			fmt.Fprintf(&b, "- Turns: %d\n", result.NumTurns)
+			fmt.Fprintf(&b, "- Cost: $%.4f\n", result.TotalCostUsd)
+			fmt.Fprintf(&b, "- Tokens: %d in, %d out\n", result.InputTokens, result.OutputTokens)
+		}
+
+		if execution := conversation.Execution; execution != nil {
+			b.WriteString("\n### Execution\n\n")
+			if execution.StartedAt != nil {
+				fmt.Fprintf(&b, "- Started at: %s\n", execution.StartedAt.Format(time.RFC3339))
+			}
+			if execution.CompletedAt != nil {
+				fmt.Fprintf(&b, "- Completed at: %s\n", execution.CompletedAt.Format(time.RFC3339))
+			}
+			if execution.ToolVersion != "" {
+				fmt.Fprintf(&b, "- Tool version: %s\n", execution.ToolVersion)
+			}
+			if execution.FailureCategory != "" {
+				fmt.Fprintf(&b, "- Failure category: %s\n", execution.FailureCategory)
+			}
+			if execution.ErrorMessage != "" {
+				fmt.Fprintf(&b, "- Error: %s\n", strings.ReplaceAll(execution.ErrorMessage, "\n", " "))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// markdownQuote renders text as a block quote, keeping its own Markdown readable without
+// letting its headings break the structure of the export
+func markdownQuote(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return "> _(empty)_\n"
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/repository"
+	"xsha-backend/utils"
+)
+
+// ImportTask recreates an exported task and its conversations in a project. Dev environment IDs
+// of the export are translated through devEnvironmentMap, unmapped IDs are used as they are
+// and must exist. Nothing is executed: the task starts over as todo on a fresh work branch,
+// conversations that had not finished are imported as cancelled and no workspace is created.
+// The task and its history are created in one transaction.
+func (s *taskConversationService) ImportTask(export *TaskExport, projectID uint, devEnvironmentMap map[uint]uint, createdBy string) (*database.Task, error) {
+	if export.SchemaVersion != TaskExportSchemaVersion {
+		return nil, appErrors.ErrTaskImportSchemaUnsupported
+	}
+
+	devEnvironmentID := export.Task.DevEnvironmentID
+	if devEnvironmentID != nil {
+		if mappedID, ok := devEnvironmentMap[*devEnvironmentID]; ok {
+			devEnvironmentID = &mappedID
+		}
+	}
+
+	task, err := s.taskService.BuildTask(export.Task.Title, export.Task.StartBranch, projectID, devEnvironmentID, 0, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	conversations := make([]repository.ImportedConversation, 0, len(export.Conversations))
+	for _, item := range export.Conversations {
+		conversations = append(conversations, importedConversation(item, createdBy))
+	}
+
+	project, devEnv := task.Project, task.DevEnvironment
+	if err := s.taskRepo.CreateImported(task, conversations); err != nil {
+		return nil, fmt.Errorf("failed to create imported task: %v", err)
+	}
+	task.Project = project
+	task.DevEnvironment = devEnv
+
+	utils.Info("Task imported",
+		"task_id", task.ID,
+		"project_id", projectID,
+		"conversations", len(conversations),
+		"created_by", createdBy)
+
+	return task, nil
+}
+
+// importedConversation builds the conversation with its result and execution summary. Isolated
+// branch names belong to the exporting instance and are dropped.
+func importedConversation(item TaskExportConversation, createdBy string) repository.ImportedConversation {
+	conversation := &database.TaskConversation{
+		Content:        item.Content,
+		Status:         importedConversationStatus(item.Status),
+		CommitHash:     item.CommitHash,
+		EnvParams:      item.EnvParams,
+		IsolatedBranch: item.IsolatedBranch,
+		CreatedBy:      createdBy,
+	}
+	conversation.CreatedAt = item.CreatedAt
+	if conversation.EnvParams == "" {
+		conversation.EnvParams = "{}"
+	}
+	imported := repository.ImportedConversation{Conversation: conversation}
+
+	if item.Result != nil {
+		result := &database.TaskConversationResult{
+			Type:         database.ResultTypeResult,
+			Subtype:      item.Result.Subtype,
+			IsError:      item.Result.IsError,
+			DurationMs:   item.Result.DurationMs,
+			NumTurns:     item.Result.NumTurns,
+			Result:       item.Result.Result,
+			TotalCostUsd: item.Result.TotalCostUsd,
+			InputTokens:  item.Result.InputTokens,
+			OutputTokens: item.Result.OutputTokens,
+		}
+		if result.Subtype == "" {
+			result.Subtype = database.ResultSubtypeSuccess
+			if result.IsError {
+				result.Subtype = database.ResultSubtypeError
+			}
+		}
+		imported.Result = result
+	}
+
+	if item.Execution != nil {
+		imported.ExecutionLog = &database.TaskExecutionLog{
+			ErrorMessage:    item.Execution.ErrorMessage,
+			FailureCategory: item.Execution.FailureCategory,
+			ToolVersion:     item.Execution.ToolVersion,
+			StartedAt:       item.Execution.StartedAt,
+			CompletedAt:     item.Execution.CompletedAt,
+		}
+	}
+
+	return imported
+}
+
+// importedConversationStatus keeps finished statuses, anything that could still be picked up
+// by the scheduler becomes cancelled
+func importedConversationStatus(status database.ConversationStatus) database.ConversationStatus {
+	switch status {
+	case database.ConversationStatusSuccess, database.ConversationStatusFailed, database.ConversationStatusCancelled:
+		return status
+	default:
+		return database.ConversationStatusCancelled
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/repository"
+
+	"gorm.io/gorm"
+)
+
+type taskTemplateService struct {
+	repo        repository.TaskTemplateRepository
+	projectRepo repository.ProjectRepository
+	devEnvRepo  repository.DevEnvironmentRepository
+}
+
+func NewTaskTemplateService(repo repository.TaskTemplateRepository, projectRepo repository.ProjectRepository, devEnvRepo repository.DevEnvironmentRepository) TaskTemplateService {
+	return &taskTemplateService{
+		repo:        repo,
+		projectRepo: projectRepo,
+		devEnvRepo:  devEnvRepo,
+	}
+}
+
+func (s *taskTemplateService) CreateTemplate(name, description string, projectID, devEnvironmentID *uint, startBranch, conversationTemplate, createdBy string) (*database.TaskTemplate, error) {
+	if err := s.validateTemplateData(name, projectID, devEnvironmentID); err != nil {
+		return nil, err
+	}
+
+	template := &database.TaskTemplate{
+		Name:                 strings.TrimSpace(name),
+		Description:          description,
+		ProjectID:            projectID,
+		DevEnvironmentID:     devEnvironmentID,
+		StartBranch:          strings.TrimSpace(startBranch),
+		ConversationTemplate: conversationTemplate,
+		CreatedBy:            createdBy,
+	}
+
+	if err := s.repo.Create(template); err != nil {
+		return nil, err
+	}
+
+	return s.GetTemplate(template.ID, createdBy)
+}
+
+func (s *taskTemplateService) GetTemplate(id uint, createdBy string) (*database.TaskTemplate, error) {
+	template, err := s.repo.GetByID(id, createdBy)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, appErrors.ErrTaskTemplateNotFound
+		}
+		return nil, err
+	}
+	return template, nil
+}
+
+func (s *taskTemplateService) ListTemplates(createdBy string, name *string, projectID *uint, page, pageSize int) ([]database.TaskTemplate, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	return s.repo.List(createdBy, name, projectID, page, pageSize)
+}
+
+func (s *taskTemplateService) UpdateTemplate(id uint, createdBy string, updates map[string]interface{}) (*database.TaskTemplate, error) {
+	template, err := s.GetTemplate(id, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, ok := updates["name"]; ok {
+		value, ok := name.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid name type")
+		}
+		template.Name = strings.TrimSpace(value)
+	}
+	if description, ok := updates["description"]; ok {
+		value, ok := description.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid description type")
+		}
+		template.Description = value
+	}
+	if projectID, ok := updates["project_id"]; ok {
+		value, ok := projectID.(*uint)
+		if !ok {
+			return nil, fmt.Errorf("invalid project_id type")
+		}
+		template.ProjectID = value
+	}
+	if devEnvironmentID, ok := updates["dev_environment_id"]; ok {
+		value, ok := devEnvironmentID.(*uint)
+		if !ok {
+			return nil, fmt.Errorf("invalid dev_environment_id type")
+		}
+		template.DevEnvironmentID = value
+	}
+	if startBranch, ok := updates["start_branch"]; ok {
+		value, ok := startBranch.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid start_branch type")
+		}
+		template.StartBranch = strings.TrimSpace(value)
+	}
+	if conversationTemplate, ok := updates["conversation_template"]; ok {
+		value, ok := conversationTemplate.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid conversation_template type")
+		}
+		template.ConversationTemplate = value
+	}
+
+	if err := s.validateTemplateData(template.Name, template.ProjectID, template.DevEnvironmentID); err != nil {
+		return nil, err
+	}
+
+	// Drop the preloaded associations so saving does not reset the foreign keys to them
+	template.Project = nil
+	template.DevEnvironment = nil
+	if err := s.repo.Update(template); err != nil {
+		return nil, err
+	}
+
+	return s.GetTemplate(id, createdBy)
+}
+
+func (s *taskTemplateService) DeleteTemplate(id uint, createdBy string) error {
+	if _, err := s.GetTemplate(id, createdBy); err != nil {
+		return err
+	}
+	return s.repo.Delete(id, createdBy)
+}
+
+func (s *taskTemplateService) validateTemplateData(name string, projectID, devEnvironmentID *uint) error {
+	if strings.TrimSpace(name) == "" {
+		return appErrors.ErrTaskTemplateNameRequired
+	}
+
+	if len(name) > 200 {
+		return appErrors.ErrTaskTemplateNameTooLong
+	}
+
+	if projectID != nil {
+		if _, err := s.projectRepo.GetByID(*projectID); err != nil {
+			return appErrors.ErrProjectNotFound
+		}
+	}
+
+	if devEnvironmentID != nil {
+		if _, err := s.devEnvRepo.GetByID(*devEnvironmentID); err != nil {
+			return appErrors.ErrDevEnvironmentNotFound
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"time"
+	"xsha-backend/database"
+	"xsha-backend/repository"
+	"xsha-backend/utils"
+)
+
+type workspaceRetentionService struct {
+	taskRepo            repository.TaskRepository
+	taskConvRepo        repository.TaskConversationRepository
+	workspaceManager    *utils.WorkspaceManager
+	systemConfigService SystemConfigService
+}
+
+func NewWorkspaceRetentionService(taskRepo repository.TaskRepository, taskConvRepo repository.TaskConversationRepository, workspaceManager *utils.WorkspaceManager, systemConfigService SystemConfigService) WorkspaceRetentionService {
+	return &workspaceRetentionService{
+		taskRepo:            taskRepo,
+		taskConvRepo:        taskConvRepo,
+		workspaceManager:    workspaceManager,
+		systemConfigService: systemConfigService,
+	}
+}
+
+// CleanupStaleWorkspaces removes the workspaces of tasks whose conversations have all finished
+// and whose last activity is older than the retention period. Workspaces whose work branch has
+// unpushed commits are kept. It returns the number of removed workspaces and the bytes reclaimed.
+func (s *workspaceRetentionService) CleanupStaleWorkspaces() (int, int64, error) {
+	retentionDays, err := s.systemConfigService.GetWorkspaceRetentionDays()
+	if err != nil {
+		return 0, 0, err
+	}
+	if retentionDays <= 0 {
+		return 0, 0, nil
+	}
+
+	tasks, err := s.taskRepo.ListWithWorkspace()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := utils.Now().AddDate(0, 0, -retentionDays)
+	removed := 0
+	var reclaimedBytes int64
+
+	for i := range tasks {
+		task := &tasks[i]
+
+		lastActivity, finished, err := s.lastActivity(task)
+		if err != nil {
+			utils.Error("Failed to inspect task conversations for workspace cleanup", "taskID", task.ID, "error", err)
+			continue
+		}
+		if !finished || lastActivity.After(cutoff) {
+			continue
+		}
+
+		// Re-check right before deleting, a conversation may have been queued meanwhile
+		active, err := s.taskConvRepo.HasPendingOrRunningConversations(task.ID)
+		if err != nil || active {
+			continue
+		}
+
+		// Commits that never reached the remote exist only in the workspace
+		if task.WorkBranch != "" && s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+			unpushed, _, err := s.workspaceManager.CountUnpushedCommits(task.WorkspacePath, task.WorkBranch, task.StartBranch)
+			if err != nil {
+				utils.Warn("Failed to count unpushed commits of stale workspace", "taskID", task.ID, "workspace", task.WorkspacePath, "error", err)
+				continue
+			}
+			if unpushed > 0 {
+				utils.Info("Keeping stale task workspace with unpushed commits", "taskID", task.ID, "workspace", task.WorkspacePath, "unpushedCommits", unpushed)
+				continue
+			}
+		}
+
+		size, err := s.workspaceManager.GetWorkspaceDiskUsage(task.WorkspacePath, false)
+		if err != nil {
+			utils.Warn("Failed to measure stale workspace size", "taskID", task.ID, "workspace", task.WorkspacePath, "error", err)
+			size = 0
+		}
+
+		if err := s.workspaceManager.CleanupTaskWorkspace(task.WorkspacePath); err != nil {
+			utils.Error("Failed to remove stale workspace", "taskID", task.ID, "workspace", task.WorkspacePath, "error", err)
+			continue
+		}
+
+		if err := s.workspaceManager.CleanupConversationWorkspaces(task.ID); err != nil {
+			utils.Warn("Failed to remove stale conversation workspaces", "taskID", task.ID, "error", err)
+		}
+
+		utils.Info("Removed stale task workspace", "taskID", task.ID, "workspace", task.WorkspacePath, "lastActivity", lastActivity, "reclaimedBytes", size)
+
+		if err := s.taskRepo.ClearWorkspacePath(task.ID); err != nil {
+			utils.Error("Failed to clear workspace path of task", "taskID", task.ID, "error", err)
+		}
+
+		removed++
+		reclaimedBytes += size
+	}
+
+	return removed, reclaimedBytes, nil
+}
+
+// lastActivity returns the most recent update time of a task and its conversations, and
+// whether every conversation of the task is in a terminal state
+func (s *workspaceRetentionService) lastActivity(task *database.Task) (time.Time, bool, error) {
+	conversations, err := s.taskConvRepo.ListByTask(task.ID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	lastActivity := task.UpdatedAt
+	for _, conv := range conversations {
+		switch conv.Status {
+		case database.ConversationStatusSuccess, database.ConversationStatusFailed, database.ConversationStatusCancelled:
+		default:
+			return time.Time{}, false, nil
+		}
+
+		if conv.UpdatedAt.After(lastActivity) {
+			lastActivity = conv.UpdatedAt
+		}
+	}
+
+	return lastActivity, true, nil
+}
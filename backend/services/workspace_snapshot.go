@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"xsha-backend/database"
+	appErrors "xsha-backend/errors"
+	"xsha-backend/utils"
+)
+
+// CreateWorkspaceSnapshot archives the task workspace, including uncommitted changes and
+// .git, so it can be restored later. Workspaces above the configured size are refused, and the
+// oldest snapshots beyond the configured count are pruned.
+func (s *taskService) CreateWorkspaceSnapshot(taskID uint, createdBy string) (*database.WorkspaceSnapshot, error) {
+	unlock := s.workspaceManager.LockTaskWorkspace(taskID)
+	defer unlock()
+
+	task, err := s.lockedTaskWorkspace(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.WorkspaceSnapshotMaxSizeMB > 0 {
+		size, err := s.workspaceManager.GetWorkspaceDiskUsage(task.WorkspacePath, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure task workspace: %v", err)
+		}
+		if size > int64(s.config.WorkspaceSnapshotMaxSizeMB)*1024*1024 {
+			utils.Warn("Task workspace exceeds the snapshot size limit", "task_id", taskID, "size_bytes", size, "limit_mb", s.config.WorkspaceSnapshotMaxSizeMB)
+			return nil, appErrors.ErrWorkspaceSnapshotTooLarge
+		}
+	}
+
+	snapshot := &database.WorkspaceSnapshot{
+		TaskID:    task.ID,
+		FileName:  fmt.Sprintf("task-%d-%d.tar.gz", task.ID, utils.Now().UnixNano()),
+		CreatedBy: createdBy,
+	}
+	if s.workspaceManager.CheckGitRepositoryExists(task.WorkspacePath) {
+		if snapshot.Branch, err = s.workspaceManager.GetCurrentBranch(task.WorkspacePath); err != nil {
+			utils.Warn("Failed to get workspace branch for snapshot", "task_id", taskID, "error", err)
+		}
+		if snapshot.CommitHash, err = s.workspaceManager.GetHeadCommit(task.WorkspacePath); err != nil {
+			utils.Warn("Failed to get workspace commit for snapshot", "task_id", taskID, "error", err)
+		}
+	}
+
+	archivePath := filepath.Join(s.config.WorkspaceSnapshotsDir, snapshot.FileName)
+	size, err := s.workspaceManager.SnapshotWorkspace(task.WorkspacePath, archivePath)
+	if err != nil {
+		utils.Error("Failed to snapshot task workspace", "task_id", taskID, "error", err)
+		return nil, appErrors.NewI18nError(appErrors.ErrWorkspaceSnapshotFailed.Key, err.Error())
+	}
+	snapshot.SizeBytes = size
+
+	if err := s.workspaceSnapshotRepo.Create(snapshot); err != nil {
+		os.Remove(archivePath)
+		return nil, fmt.Errorf("failed to save workspace snapshot: %v", err)
+	}
+
+	utils.Info("Task workspace snapshot created",
+		"task_id", taskID,
+		"snapshot_id", snapshot.ID,
+		"size_bytes", size)
+
+	s.pruneWorkspaceSnapshots(taskID)
+	return snapshot, nil
+}
+
+// pruneWorkspaceSnapshots deletes the oldest snapshots of a task beyond the configured count
+func (s *taskService) pruneWorkspaceSnapshots(taskID uint) {
+	if s.config.WorkspaceSnapshotsPerTask <= 0 {
+		return
+	}
+
+	snapshots, err := s.workspaceSnapshotRepo.ListByTask(taskID)
+	if err != nil {
+		utils.Warn("Failed to list workspace snapshots for pruning", "task_id", taskID, "error", err)
+		return
+	}
+	if len(snapshots) <= s.config.WorkspaceSnapshotsPerTask {
+		return
+	}
+
+	// Snapshots are listed newest first
+	for _, snapshot := range snapshots[s.config.WorkspaceSnapshotsPerTask:] {
+		if err := s.workspaceSnapshotRepo.Delete(snapshot.ID); err != nil {
+			utils.Warn("Failed to delete pruned workspace snapshot", "snapshot_id", snapshot.ID, "error", err)
+			continue
+		}
+		archivePath := filepath.Join(s.config.WorkspaceSnapshotsDir, snapshot.FileName)
+		if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+			utils.Warn("Failed to delete pruned workspace snapshot archive", "path", archivePath, "error", err)
+		}
+	}
+}
+
+func (s *taskService) ListWorkspaceSnapshots(taskID uint) ([]database.WorkspaceSnapshot, error) {
+	return s.workspaceSnapshotRepo.ListByTask(taskID)
+}
+
+// RestoreWorkspaceSnapshot replaces the task workspace with a snapshot taken of it. Conversations
+// and their results are kept, only the files on disk are rewound.
+func (s *taskService) RestoreWorkspaceSnapshot(taskID, snapshotID uint) (*database.WorkspaceSnapshot, error) {
+	snapshot, err := s.workspaceSnapshotRepo.GetByID(snapshotID)
+	if err != nil || snapshot.TaskID != taskID {
+		return nil, appErrors.ErrWorkspaceSnapshotNotFound
+	}
+
+	unlock := s.workspaceManager.LockTaskWorkspace(taskID)
+	defer unlock()
+
+	task, err := s.lockedTaskWorkspace(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath := filepath.Join(s.config.WorkspaceSnapshotsDir, snapshot.FileName)
+	if _, err := os.Stat(archivePath); err != nil {
+		utils.Error("Workspace snapshot archive is missing", "snapshot_id", snapshotID, "path", archivePath, "error", err)
+		return nil, appErrors.ErrWorkspaceSnapshotNotFound
+	}
+
+	if err := s.workspaceManager.RestoreWorkspace(task.WorkspacePath, archivePath, snapshot.Branch, snapshot.CommitHash); err != nil {
+		utils.Error("Failed to restore task workspace", "task_id", taskID, "snapshot_id", snapshotID, "error", err)
+		return nil, appErrors.NewI18nError(appErrors.ErrWorkspaceSnapshotRestoreFailed.Key, err.Error())
+	}
+
+	utils.Info("Task workspace restored from snapshot",
+		"task_id", taskID,
+		"snapshot_id", snapshotID)
+	return snapshot, nil
+}
+
+// lockedTaskWorkspace returns the task when its workspace exists and no conversation is
+// pending or running, which could write to it while it is archived or replaced. The caller
+// holds the task workspace lock, so no conversation starts using the workspace meanwhile.
+func (s *taskService) lockedTaskWorkspace(taskID uint) (*database.Task, error) {
+	task, err := s.repo.GetByID(taskID)
+	if err != nil {
+		return nil, appErrors.ErrTaskNotFound
+	}
+	if task.WorkspacePath == "" || !s.workspaceManager.CheckWorkspaceExists(task.WorkspacePath) {
+		return nil, appErrors.ErrTaskWorkspaceUnavailable
+	}
+
+	busy, err := s.taskConversationRepo.HasPendingOrRunningConversations(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check task conversations: %v", err)
+	}
+	if busy {
+		return nil, appErrors.ErrTaskWorkspaceBusy
+	}
+	return task, nil
+}
+
+// deleteWorkspaceSnapshots removes a deleted task's snapshot archives and records
+func (s *taskService) deleteWorkspaceSnapshots(taskID uint) {
+	snapshots, err := s.workspaceSnapshotRepo.ListByTask(taskID)
+	if err != nil {
+		utils.Error("Failed to list workspace snapshots for deletion", "task_id", taskID, "error", err)
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		archivePath := filepath.Join(s.config.WorkspaceSnapshotsDir, snapshot.FileName)
+		if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+			utils.Warn("Failed to delete workspace snapshot archive", "path", archivePath, "error", err)
+		}
+	}
+	if err := s.workspaceSnapshotRepo.DeleteByTask(taskID); err != nil {
+		utils.Error("Failed to delete workspace snapshots", "task_id", taskID, "error", err)
+	}
+}
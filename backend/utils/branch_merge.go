@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrMergeDetectionUnavailable is returned when the provider cannot be asked whether a branch
+// was merged, because the credential is not a token or the host has no known API
+var ErrMergeDetectionUnavailable = errors.New("merge detection is not available for this repository")
+
+// MergedBranchHead asks the repository provider whether a pull or merge request from branch has
+// been merged, and returns the head commit it was merged at, or an empty string when it was not.
+// Bitbucket reports abbreviated commit hashes. Only token and GitHub App credentials can call
+// the provider APIs.
+func MergedBranchHead(repoURL, branch string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("branch name cannot be empty")
+	}
+	if credential == nil || (credential.Type != GitCredentialTypeToken && credential.Type != GitCredentialTypeGitHubApp) {
+		return "", ErrMergeDetectionUnavailable
+	}
+
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %v", err)
+	}
+	provider := repositoryProvider(parsedURL, credential)
+	if provider == "" {
+		return "", ErrMergeDetectionUnavailable
+	}
+
+	parsedURL, token, err := providerAPIAccess(repoURL, credential, sslVerify, proxyConfig)
+	if err != nil {
+		return "", err
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git")
+
+	switch provider {
+	case repositoryProviderGitHub:
+		owner := strings.SplitN(repoPath, "/", 2)[0]
+		var pulls []struct {
+			MergedAt *string `json:"merged_at"`
+			Head     struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s/pulls?state=closed&head=%s", githubAPIBaseURL(parsedURL), repoPath, url.QueryEscape(owner+":"+branch))
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &pulls); err != nil {
+			return "", err
+		}
+		for _, pull := range pulls {
+			if pull.MergedAt != nil && pull.Head.SHA != "" {
+				return pull.Head.SHA, nil
+			}
+		}
+		return "", nil
+
+	case repositoryProviderGitLab:
+		var mergeRequests []struct {
+			SHA string `json:"sha"`
+		}
+		endpoint := fmt.Sprintf("%s://%s/api/v4/projects/%s/merge_requests?state=merged&source_branch=%s", parsedURL.Scheme, parsedURL.Host, url.PathEscape(repoPath), url.QueryEscape(branch))
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &mergeRequests); err != nil {
+			return "", err
+		}
+		for _, mergeRequest := range mergeRequests {
+			if mergeRequest.SHA != "" {
+				return mergeRequest.SHA, nil
+			}
+		}
+		return "", nil
+
+	default:
+		var pullRequests struct {
+			Values []struct {
+				Source struct {
+					Commit struct {
+						Hash string `json:"hash"`
+					} `json:"commit"`
+				} `json:"source"`
+			} `json:"values"`
+		}
+		query := fmt.Sprintf(`source.branch.name="%s"`, branch)
+		endpoint := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/pullrequests?state=MERGED&q=%s", repoPath, url.QueryEscape(query))
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &pullRequests); err != nil {
+			return "", err
+		}
+		for _, pullRequest := range pullRequests.Values {
+			if pullRequest.Source.Commit.Hash != "" {
+				return pullRequest.Source.Commit.Hash, nil
+			}
+		}
+		return "", nil
+	}
+}
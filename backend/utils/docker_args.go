@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// allowedDockerArgFlags are extra docker run flags any environment may use
+var allowedDockerArgFlags = map[string]bool{
+	"--shm-size": true, "--tmpfs": true, "--ulimit": true, "--pids-limit": true, "--init": true,
+	"--add-host": true, "--dns": true, "--dns-search": true, "--memory-swap": true,
+	"--memory-reservation": true, "--cpu-shares": true, "--read-only": true,
+}
+
+// privilegedDockerArgFlags weaken container isolation, they are only accepted when the
+// docker_allow_privileged_args setting is enabled
+var privilegedDockerArgFlags = map[string]bool{
+	"--privileged": true, "--volume": true, "--mount": true, "--cap-add": true, "--device": true,
+	"--security-opt": true, "--network": true, "--net": true, "--pid": true, "--ipc": true,
+	"--userns": true, "--uts": true,
+}
+
+// ParseExtraDockerArg checks an extra docker run argument, written as "--flag" or
+// "--flag=value", and reports whether the flag is privileged. Short flags, unknown flags and
+// values the shell would expand are rejected.
+func ParseExtraDockerArg(arg string) (flag string, privileged bool, err error) {
+	if !strings.HasPrefix(arg, "--") {
+		return "", false, fmt.Errorf("docker argument must be a long flag such as --shm-size=1g: %s", arg)
+	}
+	if strings.ContainsAny(arg, "$`\\\"' \t\r\n") {
+		return "", false, fmt.Errorf("docker argument contains unsupported characters: %s", arg)
+	}
+
+	flag, _, _ = strings.Cut(arg, "=")
+	if privilegedDockerArgFlags[flag] {
+		return flag, true, nil
+	}
+	if !allowedDockerArgFlags[flag] {
+		return flag, false, fmt.Errorf("docker argument is not allowed: %s", flag)
+	}
+	return flag, false, nil
+}
+
+// RedactDockerEnvArgs hides the given env var keys in a logged docker command. Commands are
+// logged with quoted args, -e 'KEY=value' for plain values and -e 'KEY' for values passed
+// through the client environment, older logs used the unquoted -e KEY=value.
+func RedactDockerEnvArgs(command string, keys []string) string {
+	for _, key := range keys {
+		quotedKey := regexp.QuoteMeta(strings.ReplaceAll(key, "'", `'\''`))
+		withValue := regexp.MustCompile(`-e '` + quotedKey + `=(?:[^']|'\\'')*'`)
+		command = withValue.ReplaceAllLiteralString(command, "-e ***=***")
+		keyOnly := regexp.MustCompile(`-e '` + quotedKey + `'`)
+		command = keyOnly.ReplaceAllLiteralString(command, "-e ***")
+		unquoted := regexp.MustCompile(`-e ` + regexp.QuoteMeta(key) + `=\S*`)
+		command = unquoted.ReplaceAllLiteralString(command, "-e ***=***")
+	}
+	return command
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "testing"
+
+func TestRedactDockerEnvArgs(t *testing.T) {
+	keys := []string{"API_TOKEN", "DB_PASSWORD"}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "quoted key and value",
+			in:   `docker run --rm -e 'API_TOKEN=abc123' -e 'LANG=C' 'image'`,
+			want: `docker run --rm -e ***=*** -e 'LANG=C' 'image'`,
+		},
+		{
+			name: "quoted value with escaped quote",
+			in:   `docker run -e 'DB_PASSWORD=it'\''s secret' -e 'LANG=C' 'image'`,
+			want: `docker run -e ***=*** -e 'LANG=C' 'image'`,
+		},
+		{
+			name: "quoted empty value",
+			in:   `docker run -e 'API_TOKEN=' 'image'`,
+			want: `docker run -e ***=*** 'image'`,
+		},
+		{
+			name: "bare key from secret or env file",
+			in:   `docker run -e 'API_TOKEN' -e 'LANG' 'image'`,
+			want: `docker run -e *** -e 'LANG' 'image'`,
+		},
+		{
+			name: "unquoted legacy form",
+			in:   `docker run -e API_TOKEN=abc123 -e LANG=C image`,
+			want: `docker run -e ***=*** -e LANG=C image`,
+		},
+		{
+			name: "key prefix of another key",
+			in:   `docker run -e 'API_TOKEN_URL=https://example.com' -e 'API_TOKEN_URL' 'image'`,
+			want: `docker run -e 'API_TOKEN_URL=https://example.com' -e 'API_TOKEN_URL' 'image'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactDockerEnvArgs(tt.in, keys); got != tt.want {
+				t.Errorf("RedactDockerEnvArgs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
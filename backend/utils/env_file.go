@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// envFileMaxBytes bounds values loaded from files, enough for service account JSON and certificates
+const envFileMaxBytes = 1024 * 1024
+
+// ValidateEnvFilePath checks that an env file path is relative and stays inside the env files directory
+func ValidateEnvFilePath(relativePath string) error {
+	if strings.TrimSpace(relativePath) == "" {
+		return fmt.Errorf("env file path cannot be empty")
+	}
+	if filepath.IsAbs(relativePath) {
+		return fmt.Errorf("env file path must be relative to the env files directory: %s", relativePath)
+	}
+
+	cleaned := filepath.Clean(relativePath)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("env file path must stay inside the env files directory: %s", relativePath)
+	}
+	return nil
+}
+
+// ReadEnvFile reads an env var value from a file under baseDir. Symlinks are resolved before
+// the containment check, and a single trailing newline is dropped.
+func ReadEnvFile(baseDir, relativePath string) (string, error) {
+	if baseDir == "" {
+		return "", fmt.Errorf("env files directory is not configured")
+	}
+	if err := ValidateEnvFilePath(relativePath); err != nil {
+		return "", err
+	}
+
+	resolvedBase, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve env files directory: %v", err)
+	}
+	resolvedPath, err := filepath.EvalSymlinks(filepath.Join(resolvedBase, relativePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve env file %s: %v", relativePath, err)
+	}
+
+	rel, err := filepath.Rel(resolvedBase, resolvedPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("env file must stay inside the env files directory: %s", relativePath)
+	}
+
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open env file %s: %v", relativePath, err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, envFileMaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read env file %s: %v", relativePath, err)
+	}
+	if len(content) > envFileMaxBytes {
+		return "", fmt.Errorf("env file %s exceeds %d bytes", relativePath, envFileMaxBytes)
+	}
+
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
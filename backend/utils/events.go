@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	EventBrokerKafka = "kafka"
+	EventBrokerNATS  = "nats"
+	EventBrokerRedis = "redis"
+)
+
+// eventQueueSize bounds the events waiting to be published, events are dropped once it is
+// full so a slow broker never holds up an execution
+const eventQueueSize = 1000
+
+// eventPublishTimeout bounds a single publish to the broker
+const eventPublishTimeout = 5 * time.Second
+
+// kafkaBatchTimeout caps how long the Kafka writer waits to fill a batch. Events are written
+// one at a time, with the writer's 1s default every event would wait that long.
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+// redisStreamMaxLen caps the Redis stream, older entries are trimmed approximately as events
+// are added so the stream does not grow without bound when nothing consumes it
+const redisStreamMaxLen = 100000
+
+type ExecutionEventType string
+
+const (
+	ExecutionEventStarted   ExecutionEventType = "execution.started"
+	ExecutionEventPhase     ExecutionEventType = "execution.phase"
+	ExecutionEventCompleted ExecutionEventType = "execution.completed"
+)
+
+const (
+	ExecutionPhaseWorkspace = "workspace"
+	ExecutionPhaseClone     = "clone"
+	ExecutionPhaseContainer = "container"
+	ExecutionPhaseCommit    = "commit"
+)
+
+// ExecutionEvent is published for every lifecycle point of a conversation execution. Events
+// of one conversation share the message key so brokers that partition by key keep their order.
+type ExecutionEvent struct {
+	Type           ExecutionEventType `json:"type"`
+	ConversationID uint               `json:"conversation_id"`
+	TaskID         uint               `json:"task_id"`
+	ProjectID      uint               `json:"project_id"`
+	// Phase is set on execution.phase events
+	Phase string `json:"phase,omitempty"`
+	// Status, ErrorMessage, CommitHash and DurationMs are set on execution.completed events
+	Status       string    `json:"status,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	CommitHash   string    `json:"commit_hash,omitempty"`
+	DurationMs   int64     `json:"duration_ms,omitempty"`
+	TraceID      string    `json:"trace_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EventPublisherConfig selects the broker execution events are published to, publishing
+// stays disabled while Broker is empty. Topic is the Kafka topic, NATS subject or Redis stream.
+type EventPublisherConfig struct {
+	Broker string
+	URL    string
+	Topic  string
+}
+
+type eventSink interface {
+	publish(ctx context.Context, key string, payload []byte) error
+	close() error
+}
+
+var (
+	eventQueue   chan ExecutionEvent
+	eventsDone   chan struct{}
+	eventsMu     sync.RWMutex
+	eventsClosed bool
+)
+
+// InitEventPublisher connects to the configured broker and starts publishing execution
+// events in the background. The returned function publishes the queued events and
+// disconnects, it gives up on the queue once ctx is done.
+func InitEventPublisher(cfg EventPublisherConfig) (func(context.Context) error, error) {
+	if cfg.Broker == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.URL == "" || cfg.Topic == "" {
+		return nil, fmt.Errorf("event broker %s requires a URL and a topic", cfg.Broker)
+	}
+
+	sink, err := newEventSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	eventsMu.Lock()
+	eventQueue = make(chan ExecutionEvent, eventQueueSize)
+	eventsDone = make(chan struct{})
+	eventsClosed = false
+	queue, done := eventQueue, eventsDone
+	eventsMu.Unlock()
+
+	go runEventPublisher(sink, queue, done)
+
+	Info("Execution event publishing enabled", "broker", cfg.Broker, "topic", cfg.Topic)
+	return func(ctx context.Context) error {
+		eventsMu.Lock()
+		if !eventsClosed {
+			eventsClosed = true
+			close(queue)
+		}
+		eventsMu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return sink.close()
+	}, nil
+}
+
+// PublishExecutionEvent queues an event for publishing, it never blocks and is a no-op
+// while no broker is configured
+func PublishExecutionEvent(event ExecutionEvent) {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+	if eventQueue == nil || eventsClosed {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = Now()
+	}
+
+	select {
+	case eventQueue <- event:
+	default:
+		Warn("Execution event queue is full, dropping event", "type", event.Type, "conversationId", event.ConversationID)
+	}
+}
+
+func runEventPublisher(sink eventSink, queue <-chan ExecutionEvent, done chan<- struct{}) {
+	defer close(done)
+
+	for event := range queue {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			Error("Failed to encode execution event", "type", event.Type, "conversationId", event.ConversationID, "error", err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		err = sink.publish(ctx, strconv.FormatUint(uint64(event.ConversationID), 10), payload)
+		cancel()
+		if err != nil {
+			Warn("Failed to publish execution event", "type", event.Type, "conversationId", event.ConversationID, "error", err)
+		}
+	}
+}
+
+func newEventSink(cfg EventPublisherConfig) (eventSink, error) {
+	switch cfg.Broker {
+	case EventBrokerKafka:
+		var brokers []string
+		for _, broker := range strings.Split(cfg.URL, ",") {
+			if broker = strings.TrimSpace(broker); broker != "" {
+				brokers = append(brokers, broker)
+			}
+		}
+		return &kafkaEventSink{writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Topic:                  cfg.Topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireOne,
+			BatchTimeout:           kafkaBatchTimeout,
+			AllowAutoTopicCreation: true,
+		}}, nil
+
+	case EventBrokerNATS:
+		conn, err := nats.Connect(cfg.URL, nats.Name("xsha-backend"), nats.MaxReconnects(-1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+		}
+		return &natsEventSink{conn: conn, subject: cfg.Topic}, nil
+
+	case EventBrokerRedis:
+		options, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %v", err)
+		}
+		return &redisEventSink{client: redis.NewClient(options), stream: cfg.Topic}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported event broker %q, expected kafka, nats or redis", cfg.Broker)
+	}
+}
+
+type kafkaEventSink struct {
+	writer *kafka.Writer
+}
+
+func (k *kafkaEventSink) publish(ctx context.Context, key string, payload []byte) error {
+	return k.writer.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: payload})
+}
+
+func (k *kafkaEventSink) close() error {
+	return k.writer.Close()
+}
+
+type natsEventSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func (n *natsEventSink) publish(ctx context.Context, key string, payload []byte) error {
+	return n.conn.Publish(n.subject, payload)
+}
+
+func (n *natsEventSink) close() error {
+	return n.conn.Drain()
+}
+
+type redisEventSink struct {
+	client *redis.Client
+	stream string
+}
+
+func (r *redisEventSink) publish(ctx context.Context, key string, payload []byte) error {
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.stream,
+		MaxLen: redisStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"key": key, "event": payload},
+	}).Err()
+}
+
+func (r *redisEventSink) close() error {
+	return r.client.Close()
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -26,6 +28,8 @@ const (
 	GitCredentialTypePassword GitCredentialType = "password"
 	GitCredentialTypeToken    GitCredentialType = "token"
 	GitCredentialTypeSSHKey   GitCredentialType = "ssh_key"
+	// GitCredentialTypeGitHubApp mints a GitHub App installation token for each HTTPS operation
+	GitCredentialTypeGitHubApp GitCredentialType = "github_app"
 )
 
 type GitURLInfo struct {
@@ -34,6 +38,10 @@ type GitURLInfo struct {
 	Owner    string          `json:"owner"`
 	Repo     string          `json:"repo"`
 	IsValid  bool            `json:"is_valid"`
+	// NormalizedURL is the canonical form of the URL, see NormalizeRepoURL
+	NormalizedURL string `json:"normalized_url"`
+	// Error explains why the URL is invalid
+	Error string `json:"-"`
 }
 
 type GitBranch struct {
@@ -54,6 +62,19 @@ type GitCredentialInfo struct {
 	Password   string            `json:"password"`
 	PrivateKey string            `json:"private_key"`
 	PublicKey  string            `json:"public_key"`
+
+	// GitHub App identifiers, the app's private key is held in PrivateKey
+	AppID          string `json:"app_id"`
+	InstallationID string `json:"installation_id"`
+}
+
+// UsesAuthenticatedURL reports whether the credential is passed to git in the remote URL
+func (c *GitCredentialInfo) UsesAuthenticatedURL() bool {
+	switch c.Type {
+	case GitCredentialTypePassword, GitCredentialTypeToken, GitCredentialTypeGitHubApp:
+		return true
+	}
+	return false
 }
 
 type GitProxyConfig struct {
@@ -61,10 +82,108 @@ type GitProxyConfig struct {
 	HttpProxy  string `json:"http_proxy"`
 	HttpsProxy string `json:"https_proxy"`
 	NoProxy    string `json:"no_proxy"`
+	// CACertFile is a custom CA bundle passed to git as GIT_SSL_CAINFO
+	CACertFile string `json:"-"`
+}
+
+// GitCloneRetryConfig controls retries of clones that fail with transient network errors
+type GitCloneRetryConfig struct {
+	MaxAttempts int           `json:"max_attempts"`
+	BaseDelay   time.Duration `json:"base_delay"`
+}
+
+// CACertDirName is the directory under the workspace base dir holding the custom CA
+// certificate, so it is shared with containers through the workspace volume
+const CACertDirName = ".xsha-ca"
+
+// EnsureCACertFile writes the CA certificate content below baseDir, rewriting it only
+// when the content changed, and returns the absolute file path
+func EnsureCACertFile(baseDir, content string) (string, error) {
+	dir, err := filepath.Abs(filepath.Join(baseDir, CACertDirName))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve CA certificate directory: %v", err)
+	}
+	certFile := filepath.Join(dir, "ca.crt")
+
+	if existing, err := os.ReadFile(certFile); err == nil && string(existing) == content {
+		return certFile, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create CA certificate directory: %v", err)
+	}
+	if err := os.WriteFile(certFile, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write CA certificate: %v", err)
+	}
+
+	return certFile, nil
+}
+
+// DefaultCommitMessageTemplate keeps the historical commit message when nothing is configured
+const DefaultCommitMessageTemplate = "AI generated changes for conversation {{.ConversationID}}"
+
+// CommitMessageData holds the fields available to commit message templates
+type CommitMessageData struct {
+	TaskID              uint
+	TaskTitle           string
+	ConversationID      uint
+	ConversationContent string
+	ProjectName         string
+	WorkBranch          string
+}
+
+// ValidateCommitMessageTemplate checks that a commit message template parses and only uses
+// the fields of CommitMessageData. Whether it renders an empty message depends on the data,
+// so that is only checked when rendering.
+func ValidateCommitMessageTemplate(tmpl string) error {
+	t, err := template.New("commit").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	return t.Execute(io.Discard, CommitMessageData{})
+}
+
+// RenderCommitMessage renders a commit message template with the given data
+func RenderCommitMessage(tmpl string, data CommitMessageData) (string, error) {
+	t, err := template.New("commit").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	message := strings.TrimSpace(buf.String())
+	if message == "" {
+		return "", fmt.Errorf("commit message template rendered an empty message")
+	}
+	return message, nil
+}
+
+// GitCloneOptions tunes how much of a repository is cloned
+type GitCloneOptions struct {
+	// Depth limits history to the given number of commits, 0 clones the full history
+	Depth             int
+	RecurseSubmodules bool
+	// ReferencePath is a local mirror to copy objects from instead of downloading them
+	ReferencePath string
 }
 
 func ApplyProxyToGitEnv(env []string, proxyConfig *GitProxyConfig) []string {
-	if proxyConfig == nil || !proxyConfig.Enabled {
+	if proxyConfig == nil {
+		return env
+	}
+
+	if proxyConfig.CACertFile != "" {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(append([]string(nil), env...), "GIT_SSL_CAINFO="+proxyConfig.CACertFile)
+	}
+
+	if !proxyConfig.Enabled {
 		return env
 	}
 
@@ -144,6 +263,14 @@ func ParseGitURL(repoURL string) *GitURLInfo {
 		return info
 	}
 
+	normalized, err := NormalizeRepoURL(repoURL, info.Protocol)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.NormalizedURL = normalized
+	repoURL = normalized
+
 	switch info.Protocol {
 	case GitProtocolHTTPS:
 		return parseHTTPSURL(repoURL, info)
@@ -221,6 +348,9 @@ func parseSSHURL(repoURL string, info *GitURLInfo) *GitURLInfo {
 func ValidateGitURL(repoURL string) error {
 	info := ParseGitURL(repoURL)
 	if !info.IsValid {
+		if info.Error != "" {
+			return fmt.Errorf("invalid Git URL format: %s", info.Error)
+		}
 		return fmt.Errorf("invalid Git URL format")
 	}
 	return nil
@@ -241,6 +371,20 @@ func IsGitURL(str string) bool {
 	return sshPattern.MatchString(str)
 }
 
+// gitURLUserInfoPattern matches the credentials embedded in an http(s) remote URL
+var gitURLUserInfoPattern = regexp.MustCompile(`(https?://)[^/@\s]+@`)
+
+// SanitizeGitOutput removes credentials embedded in remote URLs and the given secrets from git output
+func SanitizeGitOutput(output string, secrets ...string) string {
+	output = gitURLUserInfoPattern.ReplaceAllString(output, "${1}***@")
+	for _, secret := range secrets {
+		if secret != "" {
+			output = strings.ReplaceAll(output, secret, "***")
+		}
+	}
+	return output
+}
+
 func FetchRepositoryBranchesWithConfig(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (*GitAccessResult, error) {
 	tempDir, err := ioutil.TempDir("", "git-repo-*")
 	if err != nil {
@@ -301,6 +445,28 @@ func FetchRepositoryBranchesWithConfig(repoURL string, credential *GitCredential
 
 			cmd = exec.CommandContext(ctx, "git", "ls-remote", "--heads", authenticatedURL)
 
+		case GitCredentialTypeGitHubApp:
+			token, err := MintGitHubAppInstallationToken(repoURL, credential, sslVerify, proxyConfig)
+			if err != nil {
+				return &GitAccessResult{
+					CanAccess:    false,
+					ErrorMessage: err.Error(),
+				}, nil
+			}
+
+			parsedURL, err := url.Parse(repoURL)
+			if err != nil {
+				return &GitAccessResult{
+					CanAccess:    false,
+					ErrorMessage: fmt.Sprintf("failed to parse URL: %v", err),
+				}, nil
+			}
+
+			parsedURL.User = url.UserPassword("x-access-token", token)
+			authenticatedURL := parsedURL.String()
+
+			cmd = exec.CommandContext(ctx, "git", "ls-remote", "--heads", authenticatedURL)
+
 		case GitCredentialTypeSSHKey:
 			if credential.PrivateKey == "" {
 				return &GitAccessResult{
@@ -446,6 +612,8 @@ type GitDiffFile struct {
 	IsBinary    bool   `json:"is_binary"`
 	OldPath     string `json:"old_path"`
 	DiffContent string `json:"diff_content"`
+	// Truncated is set when DiffContent was cut short or left out by the content limits
+	Truncated bool `json:"truncated"`
 }
 
 type GitDiffSummary struct {
@@ -455,9 +623,18 @@ type GitDiffSummary struct {
 	Files          []GitDiffFile `json:"files"`
 	CommitsBehind  int           `json:"commits_behind"`
 	CommitsAhead   int           `json:"commits_ahead"`
+	// ContentTruncated is set when the total limit left the content of some files out
+	ContentTruncated bool `json:"content_truncated"`
 }
 
-func GetBranchDiff(workspacePath, baseBranch, compareBranch string, includeContent bool) (*GitDiffSummary, error) {
+// DiffContentLimits caps the content of a whole diff, per file and across all files. A limit
+// of 0 disables it.
+type DiffContentLimits struct {
+	MaxFileBytes  int
+	MaxTotalBytes int
+}
+
+func GetBranchDiff(workspacePath, baseBranch, compareBranch string, includeContent bool, limits DiffContentLimits) (*GitDiffSummary, error) {
 	if workspacePath == "" {
 		return nil, fmt.Errorf("workspace path cannot be empty")
 	}
@@ -485,7 +662,7 @@ func GetBranchDiff(workspacePath, baseBranch, compareBranch string, includeConte
 		return nil, fmt.Errorf("failed to get commit diff: %v", err)
 	}
 
-	if err := getBranchFileDiff(ctx, workspacePath, baseBranch, compareBranch, summary, includeContent); err != nil {
+	if err := getBranchFileDiff(ctx, workspacePath, baseBranch, compareBranch, summary, includeContent, limits); err != nil {
 		return nil, fmt.Errorf("failed to get file diff: %v", err)
 	}
 
@@ -520,7 +697,7 @@ func getBranchCommitDiff(ctx context.Context, workspacePath, baseBranch, compare
 	return nil
 }
 
-func getBranchFileDiff(ctx context.Context, workspacePath, baseBranch, compareBranch string, summary *GitDiffSummary, includeContent bool) error {
+func getBranchFileDiff(ctx context.Context, workspacePath, baseBranch, compareBranch string, summary *GitDiffSummary, includeContent bool, limits DiffContentLimits) error {
 	statCmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", "--numstat", fmt.Sprintf("%s..%s", baseBranch, compareBranch))
 	statCmd.Dir = workspacePath
 
@@ -540,17 +717,52 @@ func getBranchFileDiff(ctx context.Context, workspacePath, baseBranch, compareBr
 	summary.TotalDeletions = totalDeletions
 
 	if includeContent {
-		for i := range summary.Files {
-			content, err := getFileDiffContent(ctx, workspacePath, baseBranch, compareBranch, summary.Files[i].Path)
-			if err != nil {
-				Warn("Failed to get diff content for file", "file", summary.Files[i].Path, "error", err)
+		fillDiffContent(summary, limits, func(filePath string) (string, error) {
+			return getFileDiffContent(ctx, workspacePath, baseBranch, compareBranch, filePath)
+		})
+	}
+
+	return nil
+}
+
+// fillDiffContent sets the content of each file in order until the total limit is reached,
+// the files past it are marked truncated without content
+func fillDiffContent(summary *GitDiffSummary, limits DiffContentLimits, fileContent func(filePath string) (string, error)) {
+	totalBytes := 0
+	for i := range summary.Files {
+		file := &summary.Files[i]
+		if limits.MaxTotalBytes > 0 && totalBytes >= limits.MaxTotalBytes {
+			file.Truncated = true
+			summary.ContentTruncated = true
+			continue
+		}
+
+		content, err := fileContent(file.Path)
+		if err != nil {
+			Warn("Failed to get diff content for file", "file", file.Path, "error", err)
+			continue
+		}
+
+		maxBytes := limits.MaxFileBytes
+		remaining := limits.MaxTotalBytes - totalBytes
+		if limits.MaxTotalBytes > 0 && (maxBytes == 0 || remaining < maxBytes) {
+			maxBytes = remaining
+		}
+		if maxBytes > 0 && len(content) > maxBytes {
+			content = truncateDiffContent(content, maxBytes)
+			file.Truncated = true
+			if maxBytes == remaining {
+				// The total limit cut this file, leave the content of the rest out
+				summary.ContentTruncated = true
+				totalBytes = limits.MaxTotalBytes
+				file.DiffContent = content
 				continue
 			}
-			summary.Files[i].DiffContent = content
 		}
-	}
 
-	return nil
+		file.DiffContent = content
+		totalBytes += len(content)
+	}
 }
 
 func parseNumstat(output string) ([]GitDiffFile, int, int) {
@@ -640,7 +852,7 @@ func ValidateBranchExists(workspacePath, branchName string) error {
 	return nil
 }
 
-func GetCommitDiff(workspacePath, commitHash string, includeContent bool) (*GitDiffSummary, error) {
+func GetCommitDiff(workspacePath, commitHash string, includeContent bool, limits DiffContentLimits) (*GitDiffSummary, error) {
 	if workspacePath == "" {
 		return nil, fmt.Errorf("workspace path cannot be empty")
 	}
@@ -664,7 +876,12 @@ func GetCommitDiff(workspacePath, commitHash string, includeContent bool) (*GitD
 		Files: []GitDiffFile{},
 	}
 
-	if err := getCommitFileDiff(ctx, workspacePath, commitHash, summary, includeContent); err != nil {
+	diffBase, err := commitDiffBase(ctx, workspacePath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := getCommitFileDiff(ctx, workspacePath, diffBase, commitHash, summary, includeContent, limits); err != nil {
 		return nil, fmt.Errorf("failed to get commit diff: %v", err)
 	}
 
@@ -687,8 +904,36 @@ func validateCommitExists(ctx context.Context, workspacePath, commitHash string)
 	return nil
 }
 
-func getCommitFileDiff(ctx context.Context, workspacePath, commitHash string, summary *GitDiffSummary, includeContent bool) error {
-	statCmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", "--numstat", commitHash+"^", commitHash)
+// CommitExists reports whether the commit is present in the workspace repository
+func CommitExists(workspacePath, commitHash string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return validateCommitExists(ctx, workspacePath, commitHash) == nil
+}
+
+// commitDiffBase returns what a commit's changes are diffed against: its first parent, or the
+// empty tree when it is a root commit
+func commitDiffBase(ctx context.Context, workspacePath, commitHash string) (string, error) {
+	parentCmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", commitHash+"^")
+	parentCmd.Dir = workspacePath
+	if parentCmd.Run() == nil {
+		return commitHash + "^", nil
+	}
+
+	// Hashing empty input gives the empty tree ID for the repository's hash algorithm
+	treeCmd := exec.CommandContext(ctx, "git", "hash-object", "-t", "tree", "--stdin")
+	treeCmd.Dir = workspacePath
+	treeCmd.Stdin = strings.NewReader("")
+	output, err := treeCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve empty tree: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func getCommitFileDiff(ctx context.Context, workspacePath, diffBase, commitHash string, summary *GitDiffSummary, includeContent bool, limits DiffContentLimits) error {
+	statCmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", "--numstat", diffBase, commitHash)
 	statCmd.Dir = workspacePath
 
 	statOutput, err := statCmd.Output()
@@ -707,21 +952,16 @@ func getCommitFileDiff(ctx context.Context, workspacePath, commitHash string, su
 	summary.TotalDeletions = totalDeletions
 
 	if includeContent {
-		for i := range summary.Files {
-			content, err := getCommitFileDiffContent(ctx, workspacePath, commitHash, summary.Files[i].Path)
-			if err != nil {
-				Warn("Failed to get diff content for file", "file", summary.Files[i].Path, "error", err)
-				continue
-			}
-			summary.Files[i].DiffContent = content
-		}
+		fillDiffContent(summary, limits, func(filePath string) (string, error) {
+			return getCommitFileDiffContent(ctx, workspacePath, diffBase, commitHash, filePath)
+		})
 	}
 
 	return nil
 }
 
-func getCommitFileDiffContent(ctx context.Context, workspacePath, commitHash, filePath string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", commitHash+"^", commitHash, "--", filePath)
+func getCommitFileDiffContent(ctx context.Context, workspacePath, diffBase, commitHash, filePath string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", diffBase, commitHash, "--", filePath)
 	cmd.Dir = workspacePath
 
 	output, err := cmd.Output()
@@ -736,25 +976,62 @@ func getCommitFileDiffContent(ctx context.Context, workspacePath, commitHash, fi
 	return string(output), nil
 }
 
-func GetCommitFileDiff(workspacePath, commitHash, filePath string) (string, error) {
+// GetCommitFileDiff returns one page of the changes a commit made to a file
+func GetCommitFileDiff(workspacePath, commitHash, filePath string, opts FileDiffOptions) (*FileDiff, error) {
 	if workspacePath == "" {
-		return "", fmt.Errorf("workspace path cannot be empty")
+		return nil, fmt.Errorf("workspace path cannot be empty")
 	}
 
 	if commitHash == "" {
-		return "", fmt.Errorf("commit hash cannot be empty")
+		return nil, fmt.Errorf("commit hash cannot be empty")
 	}
 
 	if filePath == "" {
-		return "", fmt.Errorf("file path cannot be empty")
+		return nil, fmt.Errorf("file path cannot be empty")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if err := validateCommitExists(ctx, workspacePath, commitHash); err != nil {
+		return nil, err
+	}
+
+	diffBase, err := commitDiffBase(ctx, workspacePath, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetFileDiff(workspacePath, diffBase, commitHash, filePath, opts)
+}
+
+// GetCommitPatch returns the commit as a mailbox-formatted patch, including its message
+func GetCommitPatch(workspacePath, commitHash string) (string, error) {
+	if workspacePath == "" {
+		return "", fmt.Errorf("workspace path cannot be empty")
+	}
+
+	if commitHash == "" {
+		return "", fmt.Errorf("commit hash cannot be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
 	if err := validateCommitExists(ctx, workspacePath, commitHash); err != nil {
 		return "", err
 	}
 
-	return getCommitFileDiffContent(ctx, workspacePath, commitHash, filePath)
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "format-patch", "-1", "--stdout", commitHash)
+	cmd.Dir = workspacePath
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git format-patch failed: %s", string(exitError.Stderr))
+		}
+		return "", fmt.Errorf("failed to execute git format-patch: %v", err)
+	}
+
+	return string(output), nil
 }
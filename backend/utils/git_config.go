@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Git runs on the host, and many keys make it execute programs, load other config files or
+// rewrite remotes, often in combination, so only these known-safe keys may be overridden.
+// Keys are lowercase, git treats section and variable names case-insensitively.
+var allowedGitConfigKeys = map[string]bool{
+	"user.name":  true,
+	"user.email": true,
+
+	"core.autocrlf":          true,
+	"core.eol":               true,
+	"core.safecrlf":          true,
+	"core.filemode":          true,
+	"core.ignorecase":        true,
+	"core.quotepath":         true,
+	"core.precomposeunicode": true,
+	"core.longpaths":         true,
+	"core.whitespace":        true,
+	"core.abbrev":            true,
+	"core.compression":       true,
+	"core.bigfilethreshold":  true,
+
+	"commit.cleanup":            true,
+	"commit.status":             true,
+	"i18n.commitencoding":       true,
+	"i18n.logoutputencoding":    true,
+	"init.defaultbranch":        true,
+	"branch.autosetupmerge":     true,
+	"branch.autosetuprebase":    true,
+	"pull.rebase":               true,
+	"pull.ff":                   true,
+	"merge.ff":                  true,
+	"merge.conflictstyle":       true,
+	"merge.renamelimit":         true,
+	"rebase.autostash":          true,
+	"rebase.autosquash":         true,
+	"fetch.prune":               true,
+	"push.default":              true,
+	"push.autosetupremote":      true,
+	"diff.renames":              true,
+	"diff.renamelimit":          true,
+	"diff.algorithm":            true,
+	"status.showuntrackedfiles": true,
+	"advice.detachedhead":       true,
+	"color.ui":                  true,
+
+	"http.postbuffer":    true,
+	"http.lowspeedlimit": true,
+	"http.lowspeedtime":  true,
+	"http.version":       true,
+	"pack.threads":       true,
+	"pack.windowmemory":  true,
+	"gc.auto":            true,
+	"index.version":      true,
+	"feature.manyfiles":  true,
+}
+
+// GitConfigEntry is a single local git config setting
+type GitConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// ParseGitConfigOverrides parses a JSON object of git config keys to values and validates
+// the keys. Entries are returned sorted by key so they are applied in a stable order.
+func ParseGitConfigOverrides(raw string) ([]GitConfigEntry, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf("git config must be a JSON object of string values: %v", err)
+	}
+
+	entries := make([]GitConfigEntry, 0, len(values))
+	for key, value := range values {
+		if err := validateGitConfigKey(key); err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("git config value for %s must be a single line", key)
+		}
+		entries = append(entries, GitConfigEntry{Key: key, Value: value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+func validateGitConfigKey(key string) error {
+	if !allowedGitConfigKeys[strings.ToLower(key)] {
+		return fmt.Errorf("git config key is not allowed: %s", key)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FileDiffOptions limits how much of a single file diff is returned. HunkLimit 0 returns every
+// hunk from HunkOffset, MaxBytes 0 disables the size cap.
+type FileDiffOptions struct {
+	MaxBytes   int
+	HunkOffset int
+	HunkLimit  int
+}
+
+// FileDiff is one page of a file diff. Content always starts with the file header, followed by
+// the hunks of the requested page, and TotalBytes is the size of the full diff.
+type FileDiff struct {
+	FilePath   string `json:"file_path"`
+	Content    string `json:"diff_content"`
+	IsBinary   bool   `json:"is_binary"`
+	Truncated  bool   `json:"truncated"`
+	TotalBytes int    `json:"total_bytes"`
+	TotalHunks int    `json:"total_hunks"`
+	HunkOffset int    `json:"hunk_offset"`
+	HunkCount  int    `json:"hunk_count"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// GetFileDiff diffs one file between two revisions. Binary files are reported as binary
+// without content, so generated artifacts never reach the browser as raw bytes.
+func GetFileDiff(workspacePath, fromRef, toRef, filePath string, opts FileDiffOptions) (*FileDiff, error) {
+	if workspacePath == "" {
+		return nil, fmt.Errorf("workspace path cannot be empty")
+	}
+
+	if filePath == "" {
+		return nil, fmt.Errorf("file path cannot be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result := &FileDiff{FilePath: filePath, HunkOffset: opts.HunkOffset}
+
+	statCmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", "--numstat", fromRef, toRef, "--", filePath)
+	statCmd.Dir = workspacePath
+	statOutput, err := statCmd.Output()
+	if err != nil {
+		return nil, gitDiffCommandError(filePath, err)
+	}
+	if strings.HasPrefix(string(statOutput), "-\t-\t") {
+		result.IsBinary = true
+		return result, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-c", "core.quotepath=false", "diff", fromRef, toRef, "--", filePath)
+	cmd.Dir = workspacePath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, gitDiffCommandError(filePath, err)
+	}
+
+	result.TotalBytes = len(output)
+	header, hunks := splitDiffHunks(string(output))
+	result.TotalHunks = len(hunks)
+
+	start := opts.HunkOffset
+	if start > len(hunks) {
+		start = len(hunks)
+	}
+	end := len(hunks)
+	if opts.HunkLimit > 0 && start+opts.HunkLimit < end {
+		end = start + opts.HunkLimit
+	}
+	result.HunkCount = end - start
+	result.HasMore = end < len(hunks)
+
+	content := header + strings.Join(hunks[start:end], "")
+	if opts.MaxBytes > 0 && len(content) > opts.MaxBytes {
+		content = truncateDiffContent(content, opts.MaxBytes)
+		result.Truncated = true
+	}
+	result.Content = content
+
+	return result, nil
+}
+
+// splitDiffHunks splits a single file diff into its header and the hunks that follow it,
+// each hunk starting at its "@@" line
+func splitDiffHunks(diff string) (string, []string) {
+	lines := strings.SplitAfter(diff, "\n")
+
+	var header strings.Builder
+	hunks := []string{}
+	var current strings.Builder
+	inHunks := false
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "@@ ") {
+			if inHunks {
+				hunks = append(hunks, current.String())
+				current.Reset()
+			}
+			inHunks = true
+		}
+		if inHunks {
+			current.WriteString(line)
+		} else {
+			header.WriteString(line)
+		}
+	}
+	if inHunks {
+		hunks = append(hunks, current.String())
+	}
+
+	return header.String(), hunks
+}
+
+// truncateDiffContent cuts content to at most maxBytes, at the last complete line when there is one
+func truncateDiffContent(content string, maxBytes int) string {
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	truncated := content[:cut]
+	if lastNewline := strings.LastIndexByte(truncated, '\n'); lastNewline >= 0 {
+		truncated = truncated[:lastNewline+1]
+	}
+	return truncated
+}
+
+func gitDiffCommandError(filePath string, err error) error {
+	if exitError, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("git diff failed for file %s: %s", filePath, string(exitError.Stderr))
+	}
+	return fmt.Errorf("failed to execute git diff for file %s: %v", filePath, err)
+}
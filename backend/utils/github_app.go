@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// githubAppTokenTimeout bounds the installation token request made before each git operation
+const githubAppTokenTimeout = 30 * time.Second
+
+// ParseGitHubAppPrivateKey parses the PEM encoded RSA private key of a GitHub App
+func ParseGitHubAppPrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %v", err)
+	}
+	return key, nil
+}
+
+// githubAPIBaseURL returns the REST API root for a repository host: api.github.com for
+// github.com, and /api/v3 on the host for GitHub Enterprise Server
+func githubAPIBaseURL(repoURL *url.URL) string {
+	host := strings.ToLower(repoURL.Hostname())
+	if host == "github.com" || host == "www.github.com" {
+		return "https://api.github.com"
+	}
+	return fmt.Sprintf("https://%s/api/v3", repoURL.Host)
+}
+
+// MintGitHubAppInstallationToken signs an app JWT and exchanges it for an installation token.
+// Installation tokens expire after an hour, so a new one is minted for every git operation
+// instead of being stored. The request goes through the same proxy and CA settings as the git
+// operation it is minted for.
+func MintGitHubAppInstallationToken(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (string, error) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %v", err)
+	}
+
+	key, err := ParseGitHubAppPrivateKey(credential.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Backdate the JWT to allow for clock drift, GitHub rejects expirations over 10 minutes
+	now := time.Now()
+	appJWT, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer:    credential.AppID,
+		IssuedAt:  jwt.NewNumericDate(now.Add(-60 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/app/installations/%s/access_tokens", githubAPIBaseURL(parsedURL), url.PathEscape(credential.InstallationID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create installation token request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	client, err := newGitHTTPClient(githubAppTokenTimeout, sslVerify, proxyConfig)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request installation token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read installation token response: %v", err)
+	}
+
+	var result struct {
+		Token   string `json:"token"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &result)
+
+	if resp.StatusCode != http.StatusCreated || result.Token == "" {
+		message := result.Message
+		if message == "" {
+			message = strings.TrimSpace(string(body))
+		}
+		return "", fmt.Errorf("failed to mint installation token: status %d: %s", resp.StatusCode, message)
+	}
+
+	return result.Token, nil
+}
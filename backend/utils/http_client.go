@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newGitHTTPClient returns a client for the provider APIs of a git host, applying the same
+// proxy, custom CA and SSL verification settings as the git commands talking to it
+func newGitHTTPClient(timeout time.Duration, sslVerify bool, proxyConfig *GitProxyConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{}
+
+	if !sslVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	} else if proxyConfig != nil && proxyConfig.CACertFile != "" {
+		pem, err := os.ReadFile(proxyConfig.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read custom CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("custom CA certificate contains no valid certificate")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if proxyConfig != nil && proxyConfig.Enabled {
+		// Git falls back to the http proxy for https URLs when no https proxy is set
+		httpsProxy := proxyConfig.HttpsProxy
+		if httpsProxy == "" {
+			httpsProxy = proxyConfig.HttpProxy
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  proxyConfig.HttpProxy,
+			HTTPSProxy: httpsProxy,
+			NoProxy:    proxyConfig.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
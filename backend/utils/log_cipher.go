@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encrypted log segments are stored inline as prefix + base64(nonce || ciphertext) + "\n",
+// so appends stay a plain string concatenation and plaintext written before encryption
+// was enabled is left as is
+const (
+	encryptedLogSegmentPrefix = "\x1exsha-enc:v1:"
+	undecryptableLogSegment   = "[encrypted log segment could not be decrypted]\n"
+)
+
+// LogCipher encrypts execution log content with AES-256-GCM
+type LogCipher struct {
+	aead cipher.AEAD
+}
+
+// NewLogCipher derives an AES-256 key from the given secret, and returns nil when the
+// secret is empty so callers can treat encryption as unavailable
+func NewLogCipher(secret string) (*LogCipher, error) {
+	if secret == "" {
+		return nil, nil
+	}
+
+	aead, err := newAESGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &LogCipher{aead: aead}, nil
+}
+
+// newAESGCM returns an AES-256-GCM cipher keyed by the SHA-256 of secret
+func newAESGCM(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return aead, nil
+}
+
+// EncryptSegment encrypts one appended chunk of log content into a self-contained segment
+func (c *LogCipher) EncryptSegment(content string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(content), nil)
+	return encryptedLogSegmentPrefix + base64.StdEncoding.EncodeToString(sealed) + "\n", nil
+}
+
+// Decrypt returns stored log content with every encrypted segment replaced by its plaintext.
+// Segments that cannot be decrypted, or any segment when c is nil, become a placeholder line.
+func (c *LogCipher) Decrypt(stored string) string {
+	if !strings.Contains(stored, encryptedLogSegmentPrefix) {
+		return stored
+	}
+
+	var builder strings.Builder
+	rest := stored
+	for {
+		start := strings.Index(rest, encryptedLogSegmentPrefix)
+		if start < 0 {
+			builder.WriteString(rest)
+			break
+		}
+		builder.WriteString(rest[:start])
+		rest = rest[start+len(encryptedLogSegmentPrefix):]
+
+		end := strings.IndexByte(rest, '\n')
+		segment := rest
+		if end < 0 {
+			rest = ""
+		} else {
+			segment = rest[:end]
+			rest = rest[end+1:]
+		}
+
+		plaintext, err := c.decryptSegment(segment)
+		if err != nil {
+			Warn("Failed to decrypt execution log segment", "error", err)
+			builder.WriteString(undecryptableLogSegment)
+			continue
+		}
+		builder.WriteString(plaintext)
+	}
+
+	return builder.String()
+}
+
+func (c *LogCipher) decryptSegment(segment string) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("no encryption key configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(segment)
+	if err != nil {
+		return "", err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("segment too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -123,6 +123,8 @@ func WithContext(ctx context.Context) *zap.Logger {
 	fields := make([]zap.Field, 0, 3)
 	if traceID := ctx.Value("trace_id"); traceID != nil {
 		fields = append(fields, zap.Any("trace_id", traceID))
+	} else if traceID := TraceIDFromContext(ctx); traceID != "" {
+		fields = append(fields, zap.String("trace_id", traceID))
 	}
 	if userID := ctx.Value("user_id"); userID != nil {
 		fields = append(fields, zap.Any("user_id", userID))
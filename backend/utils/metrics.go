@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics carry no per-conversation, task or project labels, only bounded ones such as
+// the final status, so the number of series stays constant.
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	conversationExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xsha_conversation_executions_total",
+		Help: "Conversation executions by final status",
+	}, []string{"status"})
+
+	executionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "xsha_conversation_execution_duration_seconds",
+		Help: "Duration of conversation executions by final status",
+		// 10 seconds to about 3 hours
+		Buckets: prometheus.ExponentialBuckets(10, 2, 11),
+	}, []string{"status"})
+
+	pendingConversations = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xsha_pending_conversations",
+		Help: "Pending conversations found by the last scheduler run",
+	})
+
+	waitQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xsha_execution_wait_queue_length",
+		Help: "Pending conversations held back by the concurrency limit in the last scheduler run",
+	})
+
+	dockerUnavailableErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "xsha_docker_unavailable_errors_total",
+		Help: "Executions that failed because docker was unavailable",
+	})
+
+	schedulerRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xsha_scheduler_runs_total",
+		Help: "Scheduler runs by result",
+	}, []string{"result"})
+
+	executionGaugesOnce sync.Once
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		conversationExecutions,
+		executionDuration,
+		pendingConversations,
+		waitQueueLength,
+		dockerUnavailableErrors,
+		schedulerRuns,
+	)
+}
+
+// MetricsHandler serves the registered metrics in the Prometheus text format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// RegisterExecutionGauges exposes the running execution count and the concurrency limit,
+// read from the execution manager on every scrape. Only the first registration is kept.
+func RegisterExecutionGauges(runningCount, maxConcurrency func() int) {
+	executionGaugesOnce.Do(func() {
+		metricsRegistry.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "xsha_executions_running",
+				Help: "Conversations currently executing",
+			}, func() float64 { return float64(runningCount()) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "xsha_execution_max_concurrency",
+				Help: "Maximum number of concurrent conversation executions",
+			}, func() float64 { return float64(maxConcurrency()) }),
+		)
+	})
+}
+
+// RecordConversationExecution counts a finished execution and observes its duration
+func RecordConversationExecution(status string, duration time.Duration) {
+	conversationExecutions.WithLabelValues(status).Inc()
+	executionDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// SetSchedulerQueueDepth records the pending conversations seen by a scheduler run and
+// how many of them had to wait for capacity
+func SetSchedulerQueueDepth(pending, waiting int) {
+	pendingConversations.Set(float64(pending))
+	waitQueueLength.Set(float64(waiting))
+}
+
+// RecordDockerUnavailable counts an execution that failed because docker was unavailable
+func RecordDockerUnavailable() {
+	dockerUnavailableErrors.Inc()
+}
+
+// RecordSchedulerRun counts a scheduler run, failed reports whether processing returned an error
+func RecordSchedulerRun(failed bool) {
+	result := "success"
+	if failed {
+		result = "error"
+	}
+	schedulerRuns.WithLabelValues(result).Inc()
+}
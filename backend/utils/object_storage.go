@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const objectStorageTimeout = 60 * time.Second
+
+// ObjectStorageConfig points at an S3-compatible bucket, objects are addressed path-style
+// (endpoint/bucket/key) which AWS, MinIO and most other implementations accept
+type ObjectStorageConfig struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to object names by Key, e.g. "execution-logs/"
+	Prefix string
+}
+
+// ObjectStorage stores objects in an S3-compatible bucket, signing requests with AWS
+// Signature Version 4
+type ObjectStorage struct {
+	endpoint *url.URL
+	config   ObjectStorageConfig
+	client   *http.Client
+}
+
+// NewObjectStorage returns nil when no bucket is configured so callers can treat object
+// storage as disabled
+func NewObjectStorage(config ObjectStorageConfig) (*ObjectStorage, error) {
+	if config.Bucket == "" {
+		return nil, nil
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint: %s", config.Endpoint)
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("object storage access key and secret key are required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	return &ObjectStorage{
+		endpoint: endpoint,
+		config:   config,
+		client:   &http.Client{Timeout: objectStorageTimeout},
+	}, nil
+}
+
+// Key returns the full object key for name
+func (s *ObjectStorage) Key(name string) string {
+	return s.config.Prefix + name
+}
+
+// Put uploads content under key, replacing any existing object
+func (s *ObjectStorage) Put(key string, content []byte) error {
+	resp, err := s.do(http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkObjectStorageResponse(resp, "upload", key)
+}
+
+// Get downloads the object stored under key
+func (s *ObjectStorage) Get(key string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkObjectStorageResponse(resp, "download", key); err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %v", key, err)
+	}
+	return content, nil
+}
+
+// Delete removes the object stored under key, a missing object is not an error
+func (s *ObjectStorage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkObjectStorageResponse(resp, "delete", key)
+}
+
+func (s *ObjectStorage) do(method, key string, body []byte) (*http.Response, error) {
+	canonicalURI := s.endpoint.EscapedPath() + "/" + encodeObjectPath(s.config.Bucket) + "/" + encodeObjectPath(key)
+	target := s.endpoint.Scheme + "://" + s.endpoint.Host + canonicalURI
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage request: %v", err)
+	}
+	s.sign(req, canonicalURI, body, Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("object storage request failed: %v", err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers, the payload hash is always computed since
+// bodies are small enough to hold in memory
+func (s *ObjectStorage) sign(req *http.Request, canonicalURI string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := shortDate + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretKey), shortDate)
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func checkObjectStorageResponse(resp *http.Response, action, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("failed to %s object %s: status %d: %s", action, key, resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+// encodeObjectPath percent-encodes everything but unreserved characters and "/", as the
+// canonical URI of Signature Version 4 requires for S3
+func encodeObjectPath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// referenceCacheDirName holds bare mirrors of project repositories under the workspace
+// base dir, used as clone references so new workspaces fetch little over the network
+const referenceCacheDirName = ".xsha-cache"
+
+// referenceCacheLocks serializes updates of the same cache across workspace managers
+var referenceCacheLocks sync.Map
+
+// ReferenceCachePath returns the absolute path of a project's reference mirror
+func (w *WorkspaceManager) ReferenceCachePath(projectID uint) string {
+	return w.GetAbsolutePath(filepath.Join(referenceCacheDirName, fmt.Sprintf("project-%d.git", projectID)))
+}
+
+// HasReferenceCache reports whether a project's reference mirror has been created
+func (w *WorkspaceManager) HasReferenceCache(projectID uint) bool {
+	_, err := os.Stat(filepath.Join(w.ReferenceCachePath(projectID), "HEAD"))
+	return err == nil
+}
+
+// UpdateReferenceCache creates the project's reference mirror, or fetches new objects into
+// it when it already exists. Credentials are only used for the network call and are not
+// stored in the mirror's config.
+func (w *WorkspaceManager) UpdateReferenceCache(projectID uint, repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) error {
+	lock, _ := referenceCacheLocks.LoadOrStore(projectID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	var keyFile string
+	if credential != nil {
+		if err := w.validateCredential(credential); err != nil {
+			return fmt.Errorf("credential validation failed: %v", err)
+		}
+
+		if credential.Type == GitCredentialTypeSSHKey {
+			file, err := os.CreateTemp("", "xsha-ssh-key-*")
+			if err != nil {
+				return fmt.Errorf("failed to create SSH key file: %v", err)
+			}
+			keyFile = file.Name()
+			defer os.Remove(keyFile)
+
+			_, writeErr := file.WriteString(credential.PrivateKey)
+			file.Close()
+			if writeErr != nil {
+				return fmt.Errorf("failed to write SSH key file: %v", writeErr)
+			}
+		}
+	}
+
+	fetchURL := repoURL
+	if credential != nil && credential.UsesAuthenticatedURL() {
+		authenticatedURL, err := w.buildAuthenticatedURL(repoURL, credential, sslVerify, proxyConfig)
+		if err != nil {
+			return err
+		}
+		fetchURL = authenticatedURL
+	}
+
+	env := w.buildCloneEnv(credential, keyFile, sslVerify, proxyConfig)
+	cachePath := w.ReferenceCachePath(projectID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*w.gitCloneTimeout)
+	defer cancel()
+
+	if w.HasReferenceCache(projectID) {
+		fetchCmd := exec.CommandContext(ctx, "git", "fetch", "--prune", fetchURL,
+			"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+		fetchCmd.Dir = cachePath
+		fetchCmd.Env = env
+		if output, err := fetchCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to refresh reference cache: %v, output: %s", err, strings.TrimSpace(string(output)))
+		}
+
+		Info("Reference cache refreshed", "projectID", projectID, "path", cachePath)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return fmt.Errorf("failed to create reference cache directory: %v", err)
+	}
+
+	// Clone next to the final path and rename, so a failed clone never looks like a usable cache
+	tempPath := fmt.Sprintf("%s.tmp-%d", cachePath, time.Now().UnixNano())
+	defer os.RemoveAll(tempPath)
+
+	cloneCmd := exec.CommandContext(ctx, "git", "clone", "--mirror", fetchURL, tempPath)
+	cloneCmd.Env = env
+	if output, err := cloneCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create reference cache: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	setURLCmd := exec.CommandContext(ctx, "git", "remote", "set-url", "origin", repoURL)
+	setURLCmd.Dir = tempPath
+	if err := setURLCmd.Run(); err != nil {
+		return fmt.Errorf("failed to reset reference cache remote: %v", err)
+	}
+
+	if err := os.Rename(tempPath, cachePath); err != nil {
+		return fmt.Errorf("failed to move reference cache into place: %v", err)
+	}
+
+	Info("Reference cache created", "projectID", projectID, "path", cachePath)
+	return nil
+}
+
+// RemoveReferenceCache deletes a project's reference mirror
+func (w *WorkspaceManager) RemoveReferenceCache(projectID uint) error {
+	return os.RemoveAll(w.ReferenceCachePath(projectID))
+}
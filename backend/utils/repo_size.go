@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// repositorySizeTimeout bounds the provider API request made before cloning
+const repositorySizeTimeout = 15 * time.Second
+
+// largeRepositoryBranchCount is the branch count above which a repository whose size
+// cannot be read from its provider is assumed to exceed the clone size limit
+const largeRepositoryBranchCount = 1000
+
+const (
+	RepositorySizeSourceGitHub    = "github_api"
+	RepositorySizeSourceGitLab    = "gitlab_api"
+	RepositorySizeSourceBitbucket = "bitbucket_api"
+	RepositorySizeSourceLsRemote  = "ls_remote"
+)
+
+// RepositorySizeEstimate is the size of a remote repository as reported by its provider,
+// or the branch count from git ls-remote when the provider cannot be asked
+type RepositorySizeEstimate struct {
+	// SizeBytes is 0 when the size is unknown
+	SizeBytes   int64  `json:"size_bytes"`
+	BranchCount int    `json:"branch_count"`
+	Source      string `json:"source"`
+}
+
+// Exceeds reports whether the repository is larger than limitBytes, a limit of 0 disables
+// the check. Without a known size, repositories with many branches are treated as too large.
+func (e *RepositorySizeEstimate) Exceeds(limitBytes int64) bool {
+	if limitBytes <= 0 {
+		return false
+	}
+	if e.SizeBytes > 0 {
+		return e.SizeBytes > limitBytes
+	}
+	return e.BranchCount > largeRepositoryBranchCount
+}
+
+// errNoProviderSizeAPI is returned for repositories whose provider API is unknown
+var errNoProviderSizeAPI = errors.New("no size API known for the repository host")
+
+// EstimateRepositorySize asks the repository provider for the repository size when a token
+// credential is available, and counts branches with git ls-remote when the provider has no
+// known API. A failing provider API is an error rather than a reason to fall back, since the
+// branch count says little about the size.
+func EstimateRepositorySize(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (*RepositorySizeEstimate, error) {
+	if credential != nil && (credential.Type == GitCredentialTypeToken || credential.Type == GitCredentialTypeGitHubApp) {
+		estimate, err := fetchProviderRepositorySize(repoURL, credential, sslVerify, proxyConfig)
+		if err == nil {
+			return estimate, nil
+		}
+		if !errors.Is(err, errNoProviderSizeAPI) {
+			return nil, fmt.Errorf("failed to get repository size from provider API: %v", err)
+		}
+	}
+
+	result, err := FetchRepositoryBranchesWithConfig(repoURL, credential, sslVerify, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !result.CanAccess {
+		return nil, errors.New(result.ErrorMessage)
+	}
+
+	return &RepositorySizeEstimate{
+		BranchCount: len(result.Branches),
+		Source:      RepositorySizeSourceLsRemote,
+	}, nil
+}
+
+func fetchProviderRepositorySize(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (*RepositorySizeEstimate, error) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") {
+		return nil, errNoProviderSizeAPI
+	}
+	provider := repositoryProvider(parsedURL, credential)
+	if provider == "" {
+		return nil, errNoProviderSizeAPI
+	}
+
+	parsedURL, token, err := providerAPIAccess(repoURL, credential, sslVerify, proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(parsedURL.Path, "/"), ".git")
+
+	switch provider {
+	case repositoryProviderGitHub:
+		var repo struct {
+			Size int64 `json:"size"`
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s", githubAPIBaseURL(parsedURL), repoPath)
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &repo); err != nil {
+			return nil, err
+		}
+		// GitHub reports the size in kilobytes
+		return &RepositorySizeEstimate{SizeBytes: repo.Size * 1024, Source: RepositorySizeSourceGitHub}, nil
+
+	case repositoryProviderGitLab:
+		var project struct {
+			Statistics struct {
+				RepositorySize int64 `json:"repository_size"`
+			} `json:"statistics"`
+		}
+		endpoint := fmt.Sprintf("%s://%s/api/v4/projects/%s?statistics=true", parsedURL.Scheme, parsedURL.Host, url.PathEscape(repoPath))
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &project); err != nil {
+			return nil, err
+		}
+		if project.Statistics.RepositorySize == 0 {
+			return nil, fmt.Errorf("repository statistics are not available to this token")
+		}
+		return &RepositorySizeEstimate{SizeBytes: project.Statistics.RepositorySize, Source: RepositorySizeSourceGitLab}, nil
+
+	case repositoryProviderBitbucket:
+		var repo struct {
+			Size int64 `json:"size"`
+		}
+		endpoint := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s", repoPath)
+		if err := getProviderJSON(endpoint, "Bearer "+token, sslVerify, proxyConfig, &repo); err != nil {
+			return nil, err
+		}
+		return &RepositorySizeEstimate{SizeBytes: repo.Size, Source: RepositorySizeSourceBitbucket}, nil
+
+	default:
+		return nil, errNoProviderSizeAPI
+	}
+}
+
+const (
+	repositoryProviderGitHub    = "github"
+	repositoryProviderGitLab    = "gitlab"
+	repositoryProviderBitbucket = "bitbucket"
+)
+
+// repositoryProvider returns the provider whose API serves a repository host, matching the hosted
+// services by exact host. Other hosts are only known through a GitHub App credential, which
+// belongs to a GitHub Enterprise server; an empty result means the provider is unknown.
+func repositoryProvider(parsedURL *url.URL, credential *GitCredentialInfo) string {
+	switch strings.ToLower(parsedURL.Hostname()) {
+	case "github.com", "www.github.com":
+		return repositoryProviderGitHub
+	case "gitlab.com", "www.gitlab.com":
+		return repositoryProviderGitLab
+	case "bitbucket.org", "www.bitbucket.org":
+		return repositoryProviderBitbucket
+	}
+	if credential != nil && credential.Type == GitCredentialTypeGitHubApp {
+		return repositoryProviderGitHub
+	}
+	return ""
+}
+
+// providerAPIAccess parses an http or https repository URL and returns the token used to call
+// its provider API, minting an installation token for GitHub App credentials
+func providerAPIAccess(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (*url.URL, string, error) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse URL: %v", err)
+	}
+	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
+		return nil, "", fmt.Errorf("provider API requires an http or https repository URL")
+	}
+
+	token := credential.Password
+	if credential.Type == GitCredentialTypeGitHubApp {
+		token, err = MintGitHubAppInstallationToken(repoURL, credential, sslVerify, proxyConfig)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if token == "" {
+		return nil, "", fmt.Errorf("token cannot be empty")
+	}
+
+	return parsedURL, token, nil
+}
+
+func getProviderJSON(endpoint, authorization string, sslVerify bool, proxyConfig *GitProxyConfig, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create provider API request: %v", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+
+	client, err := newGitHTTPClient(repositorySizeTimeout, sslVerify, proxyConfig)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request provider API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return fmt.Errorf("failed to read provider API response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider API returned status %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("failed to parse provider API response: %v", err)
+	}
+	return nil
+}
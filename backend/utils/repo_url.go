@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// scpLikeRepoURLPattern matches the SSH user@host:path form, the path may not look like a port
+var scpLikeRepoURLPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)@([A-Za-z0-9.-]+):([^:]+)$`)
+
+// repoPathSegmentPattern matches one segment of a repository path, URL paths stay percent-encoded
+var repoPathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_.~%+-]+$`)
+
+// NormalizeRepoURL returns the canonical form of a repository URL for the given protocol: the
+// host lowercased, trailing slashes removed and a single .git suffix. HTTPS URLs must use
+// https://, SSH URLs either user@host:path or ssh://[user@]host[:port]/path.
+func NormalizeRepoURL(repoURL string, protocol GitProtocolType) (string, error) {
+	repoURL = strings.TrimSpace(repoURL)
+	if repoURL == "" {
+		return "", fmt.Errorf("repository URL is empty")
+	}
+
+	switch protocol {
+	case GitProtocolHTTPS:
+		if !strings.HasPrefix(repoURL, "https://") {
+			return "", fmt.Errorf("HTTPS repository URL must start with https://")
+		}
+		return normalizeHierarchicalRepoURL(repoURL)
+	case GitProtocolSSH:
+		if strings.HasPrefix(repoURL, "ssh://") {
+			return normalizeHierarchicalRepoURL(repoURL)
+		}
+		return normalizeSCPLikeRepoURL(repoURL)
+	default:
+		return "", fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+}
+
+func normalizeHierarchicalRepoURL(repoURL string) (string, error) {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository URL: %v", err)
+	}
+	if parsedURL.Hostname() == "" {
+		return "", fmt.Errorf("repository URL has no host")
+	}
+	if parsedURL.RawQuery != "" || parsedURL.Fragment != "" {
+		return "", fmt.Errorf("repository URL cannot have a query or fragment")
+	}
+
+	path, err := normalizeRepoPath(parsedURL.EscapedPath())
+	if err != nil {
+		return "", err
+	}
+
+	userInfo := ""
+	if parsedURL.User != nil {
+		userInfo = parsedURL.User.String() + "@"
+	}
+	return fmt.Sprintf("%s://%s%s/%s", parsedURL.Scheme, userInfo, strings.ToLower(parsedURL.Host), path), nil
+}
+
+func normalizeSCPLikeRepoURL(repoURL string) (string, error) {
+	matches := scpLikeRepoURLPattern.FindStringSubmatch(repoURL)
+	if matches == nil {
+		return "", fmt.Errorf("SSH repository URL must be user@host:path or ssh://[user@]host[:port]/path")
+	}
+
+	path, err := normalizeRepoPath(matches[3])
+	if err != nil {
+		return "", err
+	}
+
+	// A leading slash makes the path absolute on the server, keep it
+	if strings.HasPrefix(matches[3], "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s@%s:%s", matches[1], strings.ToLower(matches[2]), path), nil
+}
+
+// normalizeRepoPath trims surrounding slashes and the .git suffix, checks every segment, and
+// returns the path with a single .git suffix. Self-hosted servers may serve repositories from a
+// single segment like git@server:repo.git, so no owner is required.
+func normalizeRepoPath(path string) (string, error) {
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	if path == "" {
+		return "", fmt.Errorf("repository path must contain a repository name")
+	}
+
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		if segment == "." || segment == ".." || !repoPathSegmentPattern.MatchString(segment) {
+			return "", fmt.Errorf("invalid repository path segment: %q", segment)
+		}
+	}
+
+	return path + ".git", nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SecretReferencePrefix marks a dev environment env var value that names a stored secret
+const SecretReferencePrefix = "secret://"
+
+// ParseSecretReference returns the secret name of a secret://<name> env var value
+func ParseSecretReference(value string) (string, bool) {
+	if !strings.HasPrefix(value, SecretReferencePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, SecretReferencePrefix), true
+}
+
+// SecretCipher encrypts stored secret values with AES-256-GCM, as base64(nonce || ciphertext)
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher derives an AES-256 key from the given secret, and returns nil when the
+// secret is empty so callers can treat encryption as unavailable
+func NewSecretCipher(secret string) (*SecretCipher, error) {
+	if secret == "" {
+		return nil, nil
+	}
+
+	aead, err := newAESGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+func (c *SecretCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *SecretCipher) Decrypt(stored string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %v", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("stored secret too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret, the encryption key may have changed: %v", err)
+	}
+	return string(plaintext), nil
+}
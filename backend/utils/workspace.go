@@ -2,13 +2,17 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +21,10 @@ type WorkspaceManager struct {
 	gitCloneTimeout time.Duration
 }
 
+// taskWorkspaceLocks holds a *sync.Mutex per task ID guarding the task's workspace against
+// concurrent git operations, archiving and restoring
+var taskWorkspaceLocks sync.Map
+
 func NewWorkspaceManager(baseDir string, gitCloneTimeout time.Duration) *WorkspaceManager {
 	if baseDir == "" {
 		baseDir = "/tmp/xsha-workspaces"
@@ -51,85 +59,492 @@ func (w *WorkspaceManager) GetOrCreateTaskWorkspace(taskID uint, existingPath st
 	return dirName, nil
 }
 
+// ConversationWorkspaceName returns the directory of a conversation that runs in a workspace
+// of its own instead of the task's shared one
+func ConversationWorkspaceName(taskID, conversationID uint) string {
+	return fmt.Sprintf("task-%d-conv-%d", taskID, conversationID)
+}
+
+// LockTaskWorkspace locks a task's workspace and returns the function unlocking it, which may be
+// called more than once
+func (w *WorkspaceManager) LockTaskWorkspace(taskID uint) func() {
+	value, _ := taskWorkspaceLocks.LoadOrStore(taskID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+
+	var once sync.Once
+	return func() { once.Do(mu.Unlock) }
+}
+
+// GetOrCreateConversationWorkspace returns the workspace of a conversation running in parallel
+// with others of its task. The name is stable, so a directory left behind by an earlier run of
+// the conversation is reused.
+func (w *WorkspaceManager) GetOrCreateConversationWorkspace(taskID, conversationID uint) (string, error) {
+	dirName := ConversationWorkspaceName(taskID, conversationID)
+	if err := os.MkdirAll(filepath.Join(w.baseDir, dirName), 0777); err != nil {
+		return "", fmt.Errorf("failed to create conversation workspace directory: %v", err)
+	}
+
+	return dirName, nil
+}
+
+// CleanupConversationWorkspaces removes the conversation workspaces left for a task
+func (w *WorkspaceManager) CleanupConversationWorkspaces(taskID uint) error {
+	matches, err := filepath.Glob(filepath.Join(w.baseDir, fmt.Sprintf("task-%d-conv-*", taskID)))
+	if err != nil {
+		return fmt.Errorf("failed to list conversation workspaces: %v", err)
+	}
+
+	for _, match := range matches {
+		if err := os.RemoveAll(match); err != nil {
+			return fmt.Errorf("failed to remove conversation workspace %s: %v", match, err)
+		}
+	}
+	return nil
+}
+
+// CloneFromWorkspace clones the repository of sourcePath into workspacePath. The clone is local,
+// so it needs no credentials and includes branches that were never pushed; its origin is the
+// source workspace.
+func (w *WorkspaceManager) CloneFromWorkspace(workspacePath, sourcePath string) error {
+	if !w.CheckGitRepositoryExists(sourcePath) {
+		return fmt.Errorf("not a git repository: %s", sourcePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.gitCloneTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", w.GetAbsolutePath(sourcePath), w.GetAbsolutePath(workspacePath))
+	cmd.Env = w.createNonInteractiveGitEnv()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clone workspace %s: %v, output: %s", sourcePath, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// FetchBranchFromWorkspace copies branchName from sourcePath into workspacePath, replacing the
+// branch there. The branch must not be checked out in workspacePath.
+func (w *WorkspaceManager) FetchBranchFromWorkspace(workspacePath, sourcePath, branchName string) error {
+	if branchName == "" {
+		return fmt.Errorf("branch name cannot be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/heads/%s", branchName, branchName)
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--quiet", w.GetAbsolutePath(sourcePath), refspec)
+	cmd.Dir = w.GetAbsolutePath(workspacePath)
+	cmd.Env = w.createNonInteractiveGitEnv()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch branch %s from %s: %v, output: %s", branchName, sourcePath, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// MergeBranch merges sourceBranch into targetBranch in workspacePath, leaving targetBranch
+// checked out. Merge commits are made as authorName. A conflicting merge is aborted so the
+// workspace stays clean, and the conflicting files are reported in the error.
+func (w *WorkspaceManager) MergeBranch(workspacePath, targetBranch, sourceBranch, authorName, authorEmail string) error {
+	if targetBranch == "" || sourceBranch == "" {
+		return fmt.Errorf("branch name cannot be empty")
+	}
+
+	absoluteWorkspacePath := w.GetAbsolutePath(workspacePath)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	checkoutCmd := exec.CommandContext(ctx, "git", "checkout", "--quiet", targetBranch)
+	checkoutCmd.Dir = absoluteWorkspacePath
+	checkoutCmd.Env = w.createNonInteractiveGitEnv()
+	if output, err := checkoutCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to check out work branch %s: %v, output: %s", targetBranch, err, strings.TrimSpace(string(output)))
+	}
+
+	mergeCmd := exec.CommandContext(ctx, "git",
+		"-c", "user.name="+authorName,
+		"-c", "user.email="+authorEmail,
+		"merge", "--no-edit", "--quiet", sourceBranch)
+	mergeCmd.Dir = absoluteWorkspacePath
+	mergeCmd.Env = w.createNonInteractiveGitEnv()
+	output, err := mergeCmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	conflictsCmd := exec.CommandContext(ctx, "git", "diff", "--name-only", "--diff-filter=U")
+	conflictsCmd.Dir = absoluteWorkspacePath
+	conflicts, _ := conflictsCmd.Output()
+
+	abortCmd := exec.CommandContext(ctx, "git", "merge", "--abort")
+	abortCmd.Dir = absoluteWorkspacePath
+	if abortOutput, abortErr := abortCmd.CombinedOutput(); abortErr != nil {
+		Warn("failed to abort merge", "workspace", workspacePath, "error", abortErr, "output", strings.TrimSpace(string(abortOutput)))
+	}
+
+	if files := strings.Fields(string(conflicts)); len(files) > 0 {
+		return fmt.Errorf("merge conflict merging %s into work branch %s in %s", sourceBranch, targetBranch, strings.Join(files, ", "))
+	}
+	return fmt.Errorf("failed to merge %s into work branch %s: %v, output: %s", sourceBranch, targetBranch, err, strings.TrimSpace(string(output)))
+}
+
+// GetWorkspaceDiskUsage returns the total size in bytes of the files in a workspace.
+// skipGitObjects leaves out .git/objects, which dominates the size of large repositories.
+func (w *WorkspaceManager) GetWorkspaceDiskUsage(workspacePath string, skipGitObjects bool) (int64, error) {
+	if workspacePath == "" {
+		return 0, fmt.Errorf("workspace path cannot be empty")
+	}
+
+	return dirDiskUsage(w.GetAbsolutePath(workspacePath), skipGitObjects)
+}
+
+// GetTotalWorkspacesUsage returns the size in bytes of everything under the workspace base dir,
+// including orphaned workspaces no task refers to anymore
+func (w *WorkspaceManager) GetTotalWorkspacesUsage(skipGitObjects bool) (int64, error) {
+	return dirDiskUsage(w.baseDir, skipGitObjects)
+}
+
+func dirDiskUsage(root string, skipGitObjects bool) (int64, error) {
+	if _, err := os.Stat(root); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var total int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files can disappear while a conversation is running, skip them
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if skipGitObjects && info.Name() == "objects" && filepath.Base(filepath.Dir(path)) == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate disk usage of %s: %v", root, err)
+	}
+
+	return total, nil
+}
+
 func (w *WorkspaceManager) CleanupTaskWorkspace(workspacePath string) error {
 	if workspacePath == "" {
 		return nil
 	}
+	if w.IsProjectWorktree(workspacePath) {
+		return w.RemoveProjectWorktree(workspacePath)
+	}
 	// Convert to absolute path if relative
 	absolutePath := w.GetAbsolutePath(workspacePath)
 	return os.RemoveAll(absolutePath)
 }
 
-func (w *WorkspaceManager) CloneRepositoryWithConfig(workspacePath, repoURL, branch string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) error {
+// CloneRepositoryWithConfig clones the branch into the workspace, retrying transient failures
+// per retryConfig. Cancelling ctx stops the running attempt and any pending retry.
+func (w *WorkspaceManager) CloneRepositoryWithConfig(ctx context.Context, workspacePath, repoURL, branch string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig, retryConfig *GitCloneRetryConfig, options GitCloneOptions) error {
 	// Convert to absolute path for operations
 	absolutePath := w.GetAbsolutePath(workspacePath)
 
-	ctx, cancel := context.WithTimeout(context.Background(), w.gitCloneTimeout)
-	defer cancel()
-
-	var cmd *exec.Cmd
-	var envVars []string
-
-	baseEnv := w.createNonInteractiveGitEnv()
-
+	var keyFile string
 	if credential != nil {
 		if err := w.validateCredential(credential); err != nil {
 			return fmt.Errorf("credential validation failed: %v", err)
 		}
 
-		switch credential.Type {
-		case GitCredentialTypePassword, GitCredentialTypeToken:
-			authenticatedURL, err := w.buildAuthenticatedURL(repoURL, credential)
-			if err != nil {
-				return err
-			}
-			cmd = exec.CommandContext(ctx, "git", "clone", "-b", branch, authenticatedURL, absolutePath)
-			cmd.Env = ApplyProxyToGitEnv(baseEnv, proxyConfig)
-
-		case GitCredentialTypeSSHKey:
-			keyFile := filepath.Join(absolutePath, ".ssh_key")
+		if credential.Type == GitCredentialTypeSSHKey {
+			keyFile = filepath.Join(absolutePath, ".ssh_key")
 			if err := ioutil.WriteFile(keyFile, []byte(credential.PrivateKey), 0600); err != nil {
 				return fmt.Errorf("failed to create SSH key file: %v", err)
 			}
 			defer os.Remove(keyFile)
+		}
+	}
 
-			envVars = append(baseEnv,
-				fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no -o BatchMode=yes -o PasswordAuthentication=no", keyFile),
-			)
-			envVars = ApplyProxyToGitEnv(envVars, proxyConfig)
-			cmd = exec.CommandContext(ctx, "git", "clone", "-b", branch, repoURL, absolutePath)
-			cmd.Env = envVars
+	// The same environment is used for submodules so SSH keys and proxies apply to their URLs too
+	env := w.buildCloneEnv(credential, keyFile, sslVerify, proxyConfig)
+
+	maxAttempts := 1
+	var baseDelay time.Duration
+	if retryConfig != nil && retryConfig.MaxAttempts > 1 {
+		maxAttempts = retryConfig.MaxAttempts
+		baseDelay = retryConfig.BaseDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		output, err := w.runClone(ctx, absolutePath, repoURL, branch, credential, sslVerify, proxyConfig, env, options)
+		if err == nil {
+			if attempt > 1 {
+				Info("Git clone succeeded after retry", "workspace", workspacePath, "attempt", attempt)
+			}
+			break
 		}
-	} else {
-		cmd = exec.CommandContext(ctx, "git", "clone", "-b", branch, repoURL, absolutePath)
-		cmd.Env = ApplyProxyToGitEnv(baseEnv, proxyConfig)
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("clone repository cancelled: %v", ctx.Err())
+		}
+		if isMissingRemoteBranchError(output) {
+			return startBranchNotFound(repoURL, branch, credential, sslVerify, proxyConfig)
+		}
+		if attempt >= maxAttempts || !isRetryableCloneError(output, err) {
+			return fmt.Errorf("clone repository failed: %v", err)
+		}
+
+		// A killed or timed out attempt can leave a partial clone, git refuses to clone into it
+		if err := clearCloneTarget(absolutePath, keyFile); err != nil {
+			return fmt.Errorf("failed to clean up partial clone: %v", err)
+		}
+
+		delay := baseDelay * time.Duration(1<<uint(attempt-1))
+		Warn("Git clone failed with transient error, retrying",
+			"workspace", workspacePath,
+			"attempt", attempt,
+			"maxAttempts", maxAttempts,
+			"delay", delay,
+			"error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("clone repository cancelled: %v", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+
+	if options.RecurseSubmodules {
+		if err := w.updateSubmodules(absolutePath, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildCloneEnv returns the git environment for clone and submodule commands
+func (w *WorkspaceManager) buildCloneEnv(credential *GitCredentialInfo, keyFile string, sslVerify bool, proxyConfig *GitProxyConfig) []string {
+	env := w.createNonInteractiveGitEnv()
+
+	if credential != nil && credential.Type == GitCredentialTypeSSHKey {
+		env = append(env,
+			fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no -o BatchMode=yes -o PasswordAuthentication=no", keyFile),
+		)
 	}
+	env = ApplyProxyToGitEnv(env, proxyConfig)
 
 	if !sslVerify {
-		cmd.Env = append(cmd.Env, "GIT_SSL_NO_VERIFY=true")
+		env = append(env, "GIT_SSL_NO_VERIFY=true")
+	}
+
+	return env
+}
+
+// clearCloneTarget removes what a failed clone attempt left in the target directory, keeping
+// the SSH key file written for the clone
+func clearCloneTarget(absolutePath, keyFile string) error {
+	entries, err := os.ReadDir(absolutePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		entryPath := filepath.Join(absolutePath, entry.Name())
+		if entryPath == keyFile {
+			continue
+		}
+		if err := os.RemoveAll(entryPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runClone runs a single clone attempt and returns the combined git output
+func (w *WorkspaceManager) runClone(parent context.Context, absolutePath, repoURL, branch string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig, env []string, options GitCloneOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, w.gitCloneTimeout)
+	defer cancel()
+
+	cloneArgs := []string{"clone", "-b", branch}
+	if options.Depth > 0 {
+		cloneArgs = append(cloneArgs, "--depth", strconv.Itoa(options.Depth), "--single-branch")
+	}
+	if options.RecurseSubmodules {
+		cloneArgs = append(cloneArgs, "--recurse-submodules")
+	}
+	if options.ReferencePath != "" {
+		// Dissociate so the workspace stays self-contained when mounted into containers
+		cloneArgs = append(cloneArgs, "--reference-if-able", options.ReferencePath, "--dissociate")
+	}
+
+	cloneURL := repoURL
+	if credential != nil && credential.UsesAuthenticatedURL() {
+		authenticatedURL, err := w.buildAuthenticatedURL(repoURL, credential, sslVerify, proxyConfig)
+		if err != nil {
+			return "", err
+		}
+		cloneURL = authenticatedURL
 	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("clone repository failed: %v", err)
+	cmd := exec.CommandContext(ctx, "git", append(cloneArgs, cloneURL, absolutePath)...)
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("timed out after %v", w.gitCloneTimeout)
+	}
+	return string(output), err
+}
+
+// updateSubmodules initializes nested submodules that the clone did not fetch
+func (w *WorkspaceManager) updateSubmodules(absolutePath string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.gitCloneTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "submodule", "update", "--init", "--recursive")
+	cmd.Dir = absolutePath
+	cmd.Env = env
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update submodules: %v, output: %s", err, strings.TrimSpace(string(output)))
 	}
 
 	return nil
 }
 
-func (w *WorkspaceManager) CommitChanges(workspacePath, message string) (string, error) {
+// maxListedBranches caps the branches StartBranchNotFoundError lists
+const maxListedBranches = 20
+
+// StartBranchNotFoundError reports a start branch that does not exist on the remote, with the
+// branches that do so the task can be pointed at one of them
+type StartBranchNotFoundError struct {
+	Branch            string
+	AvailableBranches []string
+}
+
+func (e *StartBranchNotFoundError) Error() string {
+	return fmt.Sprintf("start branch %s does not exist on the remote, available branches: %s", e.Branch, e.AvailableBranchList())
+}
+
+// AvailableBranchList returns the available branches comma separated, the first
+// maxListedBranches of them
+func (e *StartBranchNotFoundError) AvailableBranchList() string {
+	if len(e.AvailableBranches) == 0 {
+		return "(none)"
+	}
+	if len(e.AvailableBranches) > maxListedBranches {
+		return fmt.Sprintf("%s and %d more", strings.Join(e.AvailableBranches[:maxListedBranches], ", "), len(e.AvailableBranches)-maxListedBranches)
+	}
+	return strings.Join(e.AvailableBranches, ", ")
+}
+
+// isMissingRemoteBranchError reports whether git clone -b failed because the branch does not
+// exist, git prints "Remote branch <name> not found in upstream origin"
+func isMissingRemoteBranchError(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "remote branch") && strings.Contains(lower, "not found")
+}
+
+// startBranchNotFound lists the remote's branches with git ls-remote for the error, a listing
+// failure only leaves the list empty
+func startBranchNotFound(repoURL, branch string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) error {
+	notFound := &StartBranchNotFoundError{Branch: branch}
+
+	result, err := FetchRepositoryBranchesWithConfig(repoURL, credential, sslVerify, proxyConfig)
+	if err != nil || !result.CanAccess {
+		errorMessage := ""
+		if result != nil {
+			errorMessage = result.ErrorMessage
+		}
+		Warn("Failed to list remote branches for missing start branch", "branch", branch, "error", err, "errorMessage", errorMessage)
+		return notFound
+	}
+
+	notFound.AvailableBranches = result.Branches
+	sort.Strings(notFound.AvailableBranches)
+	return notFound
+}
+
+// isRetryableCloneError reports whether a failed clone might succeed on another attempt.
+// Authentication and missing repository errors never do, so they fail immediately.
+func isRetryableCloneError(output string, err error) bool {
+	lower := strings.ToLower(output)
+
+	permanent := []string{
+		"authentication failed",
+		"403",
+		"permission denied",
+		"could not read username",
+		"could not read password",
+		"repository not found",
+		"remote branch",
+		"already exists and is not an empty directory",
+	}
+	for _, marker := range permanent {
+		if strings.Contains(lower, marker) {
+			return false
+		}
+	}
+
+	if strings.Contains(err.Error(), "timed out") {
+		return true
+	}
+
+	transient := []string{
+		"could not resolve host",
+		"could not resolve proxy",
+		"temporary failure in name resolution",
+		"connection timed out",
+		"connection refused",
+		"connection reset",
+		"failed to connect",
+		"operation timed out",
+		"early eof",
+		"rpc failed",
+		"the remote end hung up unexpectedly",
+		"gnutls",
+		"ssl_read",
+		"502",
+		"503",
+		"504",
+	}
+	for _, marker := range transient {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrNoChangesToCommit is returned by CommitChanges when the workspace has no changes
+var ErrNoChangesToCommit = errors.New("no changes to commit")
+
+func (w *WorkspaceManager) CommitChanges(workspacePath, message, authorName, authorEmail string) (string, error) {
 	// Convert to absolute path for operations
 	absolutePath := w.GetAbsolutePath(workspacePath)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	configCmd1 := exec.CommandContext(ctx, "git", "config", "user.name", "XSHA AI")
+	configCmd1 := exec.CommandContext(ctx, "git", "config", "user.name", authorName)
 	configCmd1.Dir = absolutePath
 	if err := configCmd1.Run(); err != nil {
 		return "", fmt.Errorf("failed to configure git user name: %v", err)
 	}
 
-	configCmd2 := exec.CommandContext(ctx, "git", "config", "user.email", "ai@xsha.dev")
+	configCmd2 := exec.CommandContext(ctx, "git", "config", "user.email", authorEmail)
 	configCmd2.Dir = absolutePath
 	if err := configCmd2.Run(); err != nil {
 		return "", fmt.Errorf("failed to configure git email: %v", err)
@@ -149,7 +564,7 @@ func (w *WorkspaceManager) CommitChanges(workspacePath, message string) (string,
 	}
 
 	if len(strings.TrimSpace(string(statusOutput))) == 0 {
-		return "", fmt.Errorf("no changes to commit")
+		return "", ErrNoChangesToCommit
 	}
 
 	commitCmd := exec.CommandContext(ctx, "git", "commit", "-m", message)
@@ -168,7 +583,7 @@ func (w *WorkspaceManager) CommitChanges(workspacePath, message string) (string,
 	return strings.TrimSpace(string(output)), nil
 }
 
-func (w *WorkspaceManager) buildAuthenticatedURL(repoURL string, credential *GitCredentialInfo) (string, error) {
+func (w *WorkspaceManager) buildAuthenticatedURL(repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) (string, error) {
 	parsedURL, err := url.Parse(repoURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse url: %v", err)
@@ -207,6 +622,13 @@ func (w *WorkspaceManager) buildAuthenticatedURL(repoURL string, credential *Git
 			parsedURL.User = url.UserPassword(credential.Password, "x-oauth-basic")
 		}
 
+	case GitCredentialTypeGitHubApp:
+		token, err := MintGitHubAppInstallationToken(repoURL, credential, sslVerify, proxyConfig)
+		if err != nil {
+			return "", err
+		}
+		parsedURL.User = url.UserPassword("x-access-token", token)
+
 	default:
 		return "", fmt.Errorf("unsupported credential type for url building: %s", credential.Type)
 	}
@@ -237,7 +659,8 @@ func (w *WorkspaceManager) CheckGitRepositoryExists(workspacePath string) bool {
 	absolutePath := w.GetAbsolutePath(workspacePath)
 	gitDir := filepath.Join(absolutePath, ".git")
 	info, err := os.Stat(gitDir)
-	return err == nil && info.IsDir()
+	// Worktrees have a .git file pointing at their clone instead of a directory
+	return err == nil && (info.IsDir() || info.Mode().IsRegular())
 }
 
 func (w *WorkspaceManager) ResetWorkspaceToCleanState(workspacePath string) error {
@@ -324,7 +747,34 @@ func (w *WorkspaceManager) CheckWorkspaceIsDirty(workspacePath string) (bool, er
 	return len(strings.TrimSpace(string(output))) > 0, nil
 }
 
-func (w *WorkspaceManager) CreateAndSwitchToBranch(workspacePath, branchName, baseBranch string, proxyConfig *GitProxyConfig) error {
+// ListUncommittedChanges returns the porcelain status lines of all uncommitted changes in a workspace
+func (w *WorkspaceManager) ListUncommittedChanges(workspacePath string) ([]string, error) {
+	if !w.CheckGitRepositoryExists(workspacePath) {
+		return nil, fmt.Errorf("not a git repository: %s", workspacePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	statusCmd := exec.CommandContext(ctx, "git", "status", "--porcelain")
+	statusCmd.Dir = w.GetAbsolutePath(workspacePath)
+	output, err := statusCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %v", err)
+	}
+
+	var changes []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			changes = append(changes, line)
+		}
+	}
+	return changes, nil
+}
+
+// CreateAndSwitchToBranch checks out baseBranch, pulls it and switches to branchName, creating it
+// when missing. A failed pull is only logged unless failOnPullError is set.
+func (w *WorkspaceManager) CreateAndSwitchToBranch(workspacePath, branchName, baseBranch string, proxyConfig *GitProxyConfig, failOnPullError bool) error {
 	if workspacePath == "" {
 		return fmt.Errorf("workspace path cannot be empty")
 	}
@@ -360,7 +810,10 @@ func (w *WorkspaceManager) CreateAndSwitchToBranch(workspacePath, branchName, ba
 	pullCmd := exec.CommandContext(ctx, "git", "pull", "origin", baseBranch)
 	pullCmd.Dir = absoluteWorkspacePath
 	pullCmd.Env = ApplyProxyToGitEnv(os.Environ(), proxyConfig)
-	if err := pullCmd.Run(); err != nil {
+	if output, err := pullCmd.CombinedOutput(); err != nil {
+		if failOnPullError {
+			return fmt.Errorf("failed to pull latest code of %s: %v, output: %s", baseBranch, err, strings.TrimSpace(string(output)))
+		}
 		Warn("failed to pull latest code", "workspace", workspacePath, "baseBranch", baseBranch, "error", err)
 	}
 
@@ -421,6 +874,158 @@ func (w *WorkspaceManager) CheckBranchExists(workspacePath, branchName string) (
 	return len(strings.TrimSpace(string(output))) > 0, nil
 }
 
+// GetCurrentBranch returns the checked out branch, or an empty string when HEAD is detached
+func (w *WorkspaceManager) GetCurrentBranch(workspacePath string) (string, error) {
+	if !w.CheckGitRepositoryExists(workspacePath) {
+		return "", fmt.Errorf("not a git repository: %s", workspacePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// symbolic-ref exits with status 1 and no output when HEAD is detached
+	cmd := exec.CommandContext(ctx, "git", "symbolic-ref", "--short", "-q", "HEAD")
+	cmd.Dir = w.GetAbsolutePath(workspacePath)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get current branch: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetHeadCommit returns the commit HEAD points to, or an empty string when there is none yet
+func (w *WorkspaceManager) GetHeadCommit(workspacePath string) (string, error) {
+	if !w.CheckGitRepositoryExists(workspacePath) {
+		return "", fmt.Errorf("not a git repository: %s", workspacePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "-q", "HEAD")
+	cmd.Dir = w.GetAbsolutePath(workspacePath)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get HEAD commit: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// EnsureOnBranch makes sure commits land on a branch. A detached HEAD gets branchName
+// created at the current commit; if that branch already exists the caller must resolve
+// it, since moving the branch could drop its commits.
+func (w *WorkspaceManager) EnsureOnBranch(workspacePath, branchName string) error {
+	current, err := w.GetCurrentBranch(workspacePath)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		return nil
+	}
+
+	if branchName == "" {
+		return fmt.Errorf("workspace is in detached HEAD state, specify a work branch so changes can be committed")
+	}
+
+	exists, err := w.CheckBranchExists(workspacePath, branchName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("workspace is in detached HEAD state and branch '%s' already exists, check out the branch or specify a different one before committing", branchName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	createCmd := exec.CommandContext(ctx, "git", "checkout", "-b", branchName)
+	createCmd.Dir = w.GetAbsolutePath(workspacePath)
+	if output, err := createCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create branch %s from detached HEAD: %v, output: %s", branchName, err, strings.TrimSpace(string(output)))
+	}
+
+	Info("created branch from detached HEAD", "workspace", workspacePath, "branch", branchName)
+	return nil
+}
+
+// managedGitConfigKey records the override keys ApplyGitConfig set, so keys removed from the
+// project's overrides are unset on the next apply
+const managedGitConfigKey = "xsha.managedkey"
+
+// ApplyGitConfig writes the given entries to the workspace repository's local git config and
+// unsets the keys a previous apply set that are no longer among them
+func (w *WorkspaceManager) ApplyGitConfig(workspacePath string, entries []GitConfigEntry) error {
+	if !w.CheckGitRepositoryExists(workspacePath) {
+		if len(entries) == 0 {
+			return nil
+		}
+		return fmt.Errorf("not a git repository: %s", workspacePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	runConfig := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"config", "--local"}, args...)...)
+		cmd.Dir = w.GetAbsolutePath(workspacePath)
+		output, err := cmd.CombinedOutput()
+		return strings.TrimSpace(string(output)), err
+	}
+
+	// --get-all exits 1 when the key is unset, which just means nothing was applied before
+	previous, _ := runConfig("--get-all", managedGitConfigKey)
+
+	current := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		current[strings.ToLower(entry.Key)] = true
+	}
+
+	unset := 0
+	for _, key := range strings.Fields(previous) {
+		if current[key] {
+			continue
+		}
+		// Exit status 5 means the key was already gone
+		if output, err := runConfig("--unset-all", key); err != nil && !isExitCode(err, 5) {
+			return fmt.Errorf("failed to unset git config %s: %v, output: %s", key, err, output)
+		}
+		unset++
+	}
+	if previous != "" {
+		if output, err := runConfig("--unset-all", managedGitConfigKey); err != nil && !isExitCode(err, 5) {
+			return fmt.Errorf("failed to reset managed git config keys: %v, output: %s", err, output)
+		}
+	}
+
+	for _, entry := range entries {
+		if output, err := runConfig(entry.Key, entry.Value); err != nil {
+			return fmt.Errorf("failed to set git config %s: %v, output: %s", entry.Key, err, output)
+		}
+		if output, err := runConfig("--add", managedGitConfigKey, strings.ToLower(entry.Key)); err != nil {
+			return fmt.Errorf("failed to record git config %s: %v, output: %s", entry.Key, err, output)
+		}
+	}
+
+	if len(entries) > 0 || unset > 0 {
+		Info("Applied git config overrides", "workspace", workspacePath, "count", len(entries), "unset", unset)
+	}
+	return nil
+}
+
+// isExitCode reports whether err is an exec exit error with the given status
+func isExitCode(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
+
 func (w *WorkspaceManager) validateCredential(credential *GitCredentialInfo) error {
 	if credential == nil {
 		return fmt.Errorf("credential information cannot be empty")
@@ -445,6 +1050,13 @@ func (w *WorkspaceManager) validateCredential(credential *GitCredentialInfo) err
 		if !strings.Contains(credential.PrivateKey, "BEGIN") || !strings.Contains(credential.PrivateKey, "PRIVATE KEY") {
 			return fmt.Errorf("ssh private key format is incorrect")
 		}
+	case GitCredentialTypeGitHubApp:
+		if credential.AppID == "" || credential.InstallationID == "" {
+			return fmt.Errorf("github app id and installation id cannot be empty")
+		}
+		if _, err := ParseGitHubAppPrivateKey(credential.PrivateKey); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported credential type: %s", credential.Type)
 	}
@@ -452,7 +1064,10 @@ func (w *WorkspaceManager) validateCredential(credential *GitCredentialInfo) err
 	return nil
 }
 
-func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig, forcePush bool) (string, error) {
+// PushBranch pushes branchName to origin. Shallow workspaces are unshallowed before the push when
+// unshallowBeforePush is set, otherwise only after the remote rejects the push for missing
+// history, in which case the push is retried once.
+func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig, forcePush, unshallowBeforePush bool) (string, error) {
 	if workspacePath == "" {
 		return "", fmt.Errorf("workspace path cannot be empty")
 	}
@@ -475,6 +1090,13 @@ func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string,
 		}
 	}
 
+	// A missing branch in a detached workspace means commits were made on no branch
+	if exists, err := w.CheckBranchExists(workspacePath, branchName); err == nil && !exists {
+		if current, err := w.GetCurrentBranch(workspacePath); err == nil && current == "" {
+			return "", fmt.Errorf("workspace is in detached HEAD state and branch '%s' does not exist, specify a branch to push", branchName)
+		}
+	}
+
 	// Convert relative workspace path to absolute for Git operations
 	absoluteWorkspacePath := w.GetAbsolutePath(workspacePath)
 
@@ -489,8 +1111,8 @@ func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string,
 
 	if credential != nil {
 		switch credential.Type {
-		case GitCredentialTypePassword, GitCredentialTypeToken:
-			authenticatedURL, err := w.buildAuthenticatedURL(repoURL, credential)
+		case GitCredentialTypePassword, GitCredentialTypeToken, GitCredentialTypeGitHubApp:
+			authenticatedURL, err := w.buildAuthenticatedURL(repoURL, credential, sslVerify, proxyConfig)
 			if err != nil {
 				return "", fmt.Errorf("failed to build authenticated URL: %v", err)
 			}
@@ -582,14 +1204,31 @@ func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string,
 		cmd.Env = ApplyProxyToGitEnv(baseEnv, proxyConfig)
 	}
 
-	var outputBuilder strings.Builder
-	cmd.Stdout = &outputBuilder
-	cmd.Stderr = &outputBuilder
+	// History missing from a shallow clone can make the remote reject the push
+	if unshallowBeforePush {
+		if _, err := w.unshallowIfNeeded(ctx, absoluteWorkspacePath, cmd.Env); err != nil {
+			return "", err
+		}
+	}
 
 	Info("starting Git push command", "workspace", workspacePath, "branch", branchName)
 
-	err := cmd.Run()
-	output = outputBuilder.String()
+	output, err := runPushCommand(cmd)
+
+	if err != nil && !unshallowBeforePush && isShallowPushRejection(output) {
+		Warn("Push rejected for missing history, unshallowing and retrying", "workspace", workspacePath, "branch", branchName, "output", output)
+
+		unshallowed, unshallowErr := w.unshallowIfNeeded(ctx, absoluteWorkspacePath, cmd.Env)
+		if unshallowErr != nil {
+			return output, fmt.Errorf("push branch failed: %v, and unshallowing for a retry failed: %v", err, unshallowErr)
+		}
+		if unshallowed {
+			retryCmd := exec.CommandContext(ctx, "git", cmd.Args[1:]...)
+			retryCmd.Dir = cmd.Dir
+			retryCmd.Env = cmd.Env
+			output, err = runPushCommand(retryCmd)
+		}
+	}
 
 	if err != nil {
 		Error("Git push failed", "workspace", workspacePath, "branch", branchName, "error", err, "output", output)
@@ -607,10 +1246,267 @@ func (w *WorkspaceManager) PushBranch(workspacePath, branchName, repoURL string,
 		return output, fmt.Errorf("push branch failed: %v", err)
 	}
 
+	// Single-branch clones have no fetch refspec for the work branch, so git does not update its
+	// remote-tracking ref on push. Record it here so unpushed commits can be counted against it.
+	trackCmd := exec.CommandContext(ctx, "git", "update-ref", "refs/remotes/origin/"+branchName, "refs/heads/"+branchName)
+	trackCmd.Dir = absoluteWorkspacePath
+	if trackOutput, err := trackCmd.CombinedOutput(); err != nil {
+		Warn("Failed to update remote-tracking ref after push", "workspace", workspacePath, "branch", branchName, "error", err, "output", strings.TrimSpace(string(trackOutput)))
+	}
+
 	Info("successfully pushed branch", "workspace", workspacePath, "branch", branchName, "output", output)
 	return output, nil
 }
 
+// ErrRemoteBranchMoved is returned by DeleteRemoteBranch when the remote branch no longer points
+// at the expected commit, so commits pushed after the merge are not deleted with it
+var ErrRemoteBranchMoved = errors.New("remote branch has commits that were not merged")
+
+// DeleteRemoteBranch deletes branchName from the remote repository. The push runs from an empty
+// temporary repository so it does not depend on the task workspace still existing. A branch
+// that is already gone from the remote is not an error. When expectedHead is set, the branch is
+// only deleted while its remote head still starts with it.
+func (w *WorkspaceManager) DeleteRemoteBranch(repoURL, branchName, expectedHead string, credential *GitCredentialInfo, sslVerify bool, proxyConfig *GitProxyConfig) error {
+	if branchName == "" {
+		return fmt.Errorf("branch name cannot be empty")
+	}
+
+	if credential != nil {
+		if err := w.validateCredential(credential); err != nil {
+			return fmt.Errorf("credential validation failed: %v", err)
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "xsha-branch-delete-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	initCmd := exec.CommandContext(ctx, "git", "init", "-q")
+	initCmd.Dir = tempDir
+	if output, err := initCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to initialize temporary repository: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	remoteURL := repoURL
+	env := ApplyProxyToGitEnv(w.createNonInteractiveGitEnv(), proxyConfig)
+	if !sslVerify {
+		env = append(env, "GIT_SSL_NO_VERIFY=true")
+	}
+
+	if credential != nil {
+		switch credential.Type {
+		case GitCredentialTypePassword, GitCredentialTypeToken, GitCredentialTypeGitHubApp:
+			remoteURL, err = w.buildAuthenticatedURL(repoURL, credential, sslVerify, proxyConfig)
+			if err != nil {
+				return fmt.Errorf("failed to build authenticated URL: %v", err)
+			}
+		case GitCredentialTypeSSHKey:
+			keyFile := filepath.Join(tempDir, ".ssh_key_delete")
+			if err := os.WriteFile(keyFile, []byte(credential.PrivateKey), 0600); err != nil {
+				return fmt.Errorf("failed to create SSH key file: %v", err)
+			}
+			env = append(env,
+				fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no -o BatchMode=yes -o PasswordAuthentication=no", keyFile),
+			)
+		}
+	}
+
+	args := []string{"push", remoteURL, "--delete", branchName}
+	if expectedHead != "" {
+		lsCmd := exec.CommandContext(ctx, "git", "ls-remote", remoteURL, "refs/heads/"+branchName)
+		lsCmd.Dir = tempDir
+		lsCmd.Env = env
+		lsOutput, err := lsCmd.Output()
+		if err != nil {
+			// The output may echo the authenticated URL, so it is not included in the error
+			return fmt.Errorf("failed to look up remote branch head: %v", err)
+		}
+		fields := strings.Fields(string(lsOutput))
+		if len(fields) == 0 {
+			Info("remote branch already deleted", "branch", branchName)
+			return nil
+		}
+		remoteHead := fields[0]
+		if !strings.HasPrefix(remoteHead, strings.ToLower(expectedHead)) {
+			return ErrRemoteBranchMoved
+		}
+		// The lease keeps a push landing between the lookup and the delete from being lost
+		args = []string{"push", fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", branchName, remoteHead), remoteURL, "--delete", branchName}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = tempDir
+	cmd.Env = env
+	outputBytes, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(outputBytes))
+	if err != nil {
+		if strings.Contains(output, "remote ref does not exist") {
+			Info("remote branch already deleted", "branch", branchName)
+			return nil
+		}
+		// The output may echo the authenticated URL, so it is not included in the error
+		Error("Failed to delete remote branch", "branch", branchName, "error", err)
+		if strings.Contains(output, "Authentication failed") || strings.Contains(output, "403") {
+			return fmt.Errorf("authentication failed, please check if the credential is correct: %v", err)
+		}
+		if strings.Contains(output, "protected") {
+			return fmt.Errorf("branch '%s' is protected on the remote: %v", branchName, err)
+		}
+		if strings.Contains(output, "stale info") {
+			return ErrRemoteBranchMoved
+		}
+		return fmt.Errorf("delete remote branch failed: %v", err)
+	}
+
+	Info("successfully deleted remote branch", "branch", branchName)
+	return nil
+}
+
+// CountUnpushedCommits counts commits on branchName that are not on its remote-tracking branch.
+// When the branch has never been pushed, commits are counted from baseBranch instead.
+func (w *WorkspaceManager) CountUnpushedCommits(workspacePath, branchName, baseBranch string) (int, bool, error) {
+	exists, err := w.CheckBranchExists(workspacePath, branchName)
+	if err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	absoluteWorkspacePath := w.GetAbsolutePath(workspacePath)
+
+	remoteRef := "refs/remotes/origin/" + branchName
+	verifyCmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", remoteRef)
+	verifyCmd.Dir = absoluteWorkspacePath
+	remoteExists := verifyCmd.Run() == nil
+
+	base := baseBranch
+	if remoteExists {
+		base = remoteRef
+	}
+
+	countCmd := exec.CommandContext(ctx, "git", "rev-list", "--count", fmt.Sprintf("%s..refs/heads/%s", base, branchName))
+	countCmd.Dir = absoluteWorkspacePath
+	output, err := countCmd.CombinedOutput()
+	if err != nil {
+		return 0, remoteExists, fmt.Errorf("failed to count unpushed commits: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, remoteExists, fmt.Errorf("failed to parse unpushed commit count: %v", err)
+	}
+	return count, remoteExists, nil
+}
+
+// CompareBranchToBase counts the commits branchName is ahead of and behind baseBranch using
+// git rev-list --left-right. The base is the remote-tracking branch as of the last fetch when it
+// exists locally, otherwise the local branch; the returned ref says which one was used.
+func (w *WorkspaceManager) CompareBranchToBase(workspacePath, branchName, baseBranch string) (int, int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	absoluteWorkspacePath := w.GetAbsolutePath(workspacePath)
+
+	baseRef := ""
+	for _, candidate := range []string{"refs/remotes/origin/" + baseBranch, "refs/heads/" + baseBranch} {
+		verifyCmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", candidate)
+		verifyCmd.Dir = absoluteWorkspacePath
+		if verifyCmd.Run() == nil {
+			baseRef = candidate
+			break
+		}
+	}
+	if baseRef == "" {
+		return 0, 0, "", fmt.Errorf("base branch %s not found in workspace", baseBranch)
+	}
+
+	countCmd := exec.CommandContext(ctx, "git", "rev-list", "--left-right", "--count", fmt.Sprintf("%s...refs/heads/%s", baseRef, branchName))
+	countCmd.Dir = absoluteWorkspacePath
+	output, err := countCmd.CombinedOutput()
+	if err != nil {
+		return 0, 0, baseRef, fmt.Errorf("failed to compare branch with base: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// Left side counts commits only on the base, right side commits only on the branch
+	counts := strings.Fields(string(output))
+	if len(counts) != 2 {
+		return 0, 0, baseRef, fmt.Errorf("unexpected rev-list output: %s", strings.TrimSpace(string(output)))
+	}
+	behind, err := strconv.Atoi(counts[0])
+	if err != nil {
+		return 0, 0, baseRef, fmt.Errorf("failed to parse behind count: %v", err)
+	}
+	ahead, err := strconv.Atoi(counts[1])
+	if err != nil {
+		return 0, 0, baseRef, fmt.Errorf("failed to parse ahead count: %v", err)
+	}
+	return ahead, behind, baseRef, nil
+}
+
+// unshallowIfNeeded fetches the full history of a shallow repository and reports whether it
+// had to
+func (w *WorkspaceManager) unshallowIfNeeded(ctx context.Context, absoluteWorkspacePath string, env []string) (bool, error) {
+	checkCmd := exec.CommandContext(ctx, "git", "rev-parse", "--is-shallow-repository")
+	checkCmd.Dir = absoluteWorkspacePath
+	output, err := checkCmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to check shallow repository: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "true" {
+		return false, nil
+	}
+
+	Info("unshallowing repository before push", "workspace", absoluteWorkspacePath)
+
+	fetchCmd := exec.CommandContext(ctx, "git", "fetch", "--unshallow", "origin")
+	fetchCmd.Dir = absoluteWorkspacePath
+	fetchCmd.Env = env
+	if output, err := fetchCmd.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("failed to unshallow repository: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return true, nil
+}
+
+// shallowPushRejections are messages of remotes refusing a push because objects or history
+// the shallow workspace does not have are needed
+var shallowPushRejections = []string{
+	"shallow update not allowed",
+	"missing necessary objects",
+	"did not receive expected object",
+	"failed to traverse parents",
+	"could not find the merge base",
+}
+
+func isShallowPushRejection(output string) bool {
+	lower := strings.ToLower(output)
+	for _, message := range shallowPushRejections {
+		if strings.Contains(lower, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPushCommand runs a push and returns its combined output
+func runPushCommand(cmd *exec.Cmd) (string, error) {
+	var outputBuilder strings.Builder
+	cmd.Stdout = &outputBuilder
+	cmd.Stderr = &outputBuilder
+
+	err := cmd.Run()
+	return outputBuilder.String(), err
+}
+
 func (w *WorkspaceManager) createNonInteractiveGitEnv() []string {
 	return append(os.Environ(),
 		"GIT_TERMINAL_PROMPT=0",              // disable terminal prompt
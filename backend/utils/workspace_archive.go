@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// worktreeIndexEntry is the archive entry holding a worktree's index. The index lives in the
+// worktree's admin directory inside the project clone, not in the workspace itself.
+const worktreeIndexEntry = ".xsha-worktree-index"
+
+// SnapshotWorkspace archives a task workspace to destPath and returns the archive size. A
+// worktree's index is archived along with it, its commits stay in the project clone.
+func (w *WorkspaceManager) SnapshotWorkspace(workspacePath, destPath string) (int64, error) {
+	if !w.CheckWorkspaceExists(workspacePath) {
+		return 0, fmt.Errorf("workspace does not exist: %s", workspacePath)
+	}
+
+	absolutePath := w.GetAbsolutePath(workspacePath)
+	extraFiles := map[string]string{}
+	if w.IsProjectWorktree(workspacePath) {
+		adminDir, err := readWorktreeLink(absolutePath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read worktree link: %v", err)
+		}
+		indexPath := filepath.Join(adminDir, "index")
+		if _, err := os.Stat(indexPath); err == nil {
+			extraFiles[worktreeIndexEntry] = indexPath
+		}
+	}
+	return ArchiveDirectory(absolutePath, destPath, extraFiles)
+}
+
+// RestoreWorkspace replaces a task workspace with the content of a snapshot archive. The
+// archive is extracted next to the workspace first, so a failed restore leaves it untouched.
+// Worktrees also get their index back, and their HEAD and branch are reset to commitHash, which
+// the project clone must still have.
+func (w *WorkspaceManager) RestoreWorkspace(workspacePath, archivePath, branch, commitHash string) error {
+	if workspacePath == "" {
+		return fmt.Errorf("workspace path cannot be empty")
+	}
+
+	absolutePath := w.GetAbsolutePath(workspacePath)
+	suffix := strconv.FormatInt(Now().UnixNano(), 10)
+	restorePath := absolutePath + ".restore-" + suffix
+	if err := ExtractArchive(archivePath, restorePath); err != nil {
+		os.RemoveAll(restorePath)
+		return err
+	}
+
+	var worktreeAdminDir string
+	restoredIndex := filepath.Join(restorePath, worktreeIndexEntry)
+	if _, err := os.Stat(restoredIndex); err == nil {
+		adminDir, err := readWorktreeLink(restorePath)
+		if err != nil {
+			os.RemoveAll(restorePath)
+			return fmt.Errorf("failed to read worktree link of snapshot: %v", err)
+		}
+		if commitHash != "" {
+			if _, err := runGitIn(adminDir, nil, 30*time.Second, "cat-file", "-e", commitHash+"^{commit}"); err != nil {
+				os.RemoveAll(restorePath)
+				return fmt.Errorf("commit %s of the snapshot is no longer in the project clone", commitHash)
+			}
+		}
+		worktreeAdminDir = adminDir
+	}
+
+	oldPath := absolutePath + ".old-" + suffix
+	hadWorkspace := w.CheckWorkspaceExists(workspacePath)
+	if hadWorkspace {
+		if err := os.Rename(absolutePath, oldPath); err != nil {
+			os.RemoveAll(restorePath)
+			return fmt.Errorf("failed to move current workspace aside: %v", err)
+		}
+	}
+	if err := os.Rename(restorePath, absolutePath); err != nil {
+		if hadWorkspace {
+			os.Rename(oldPath, absolutePath)
+		}
+		os.RemoveAll(restorePath)
+		return fmt.Errorf("failed to move restored workspace into place: %v", err)
+	}
+
+	if hadWorkspace {
+		if err := os.RemoveAll(oldPath); err != nil {
+			Warn("Failed to remove previous workspace after restore", "path", oldPath, "error", err)
+		}
+	}
+
+	if worktreeAdminDir != "" {
+		return restoreWorktreeState(absolutePath, worktreeAdminDir, branch, commitHash)
+	}
+	return nil
+}
+
+// restoreWorktreeState moves the archived index back into the worktree's admin directory and
+// points HEAD, and the branch when there is one, at commitHash
+func restoreWorktreeState(absolutePath, adminDir, branch, commitHash string) error {
+	if err := os.Rename(filepath.Join(absolutePath, worktreeIndexEntry), filepath.Join(adminDir, "index")); err != nil {
+		return fmt.Errorf("failed to restore worktree index: %v", err)
+	}
+	if commitHash == "" {
+		return nil
+	}
+
+	if branch == "" {
+		if output, err := runGitIn(absolutePath, nil, 30*time.Second, "update-ref", "--no-deref", "HEAD", commitHash); err != nil {
+			return fmt.Errorf("failed to restore worktree HEAD: %v, output: %s", err, output)
+		}
+		return nil
+	}
+
+	if output, err := runGitIn(absolutePath, nil, 30*time.Second, "update-ref", "refs/heads/"+branch, commitHash); err != nil {
+		return fmt.Errorf("failed to restore worktree branch %s: %v, output: %s", branch, err, output)
+	}
+	if output, err := runGitIn(absolutePath, nil, 30*time.Second, "symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+		return fmt.Errorf("failed to restore worktree HEAD: %v, output: %s", err, output)
+	}
+	return nil
+}
+
+// ArchiveDirectory writes srcDir, including .git, as a gzipped tar to destPath and returns the
+// archive size. Regular files, directories and symlinks are kept, anything else is skipped.
+// extraFiles adds files from outside srcDir, keyed by their name in the archive.
+func ArchiveDirectory(srcDir, destPath string, extraFiles map[string]string) (int64, error) {
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive: %v", err)
+	}
+
+	writeErr := writeDirectoryArchive(srcDir, file, extraFiles)
+	closeErr := file.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(destPath)
+		return 0, writeErr
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat archive: %v", err)
+	}
+	return info.Size(), nil
+}
+
+func writeDirectoryArchive(srcDir string, w io.Writer, extraFiles map[string]string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		var link string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("failed to read symlink %s: %v", relPath, err)
+			}
+		case info.IsDir(), info.Mode().IsRegular():
+		default:
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("failed to create archive header for %s: %v", relPath, err)
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write archive header for %s: %v", relPath, err)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", relPath, err)
+		}
+		defer file.Close()
+		if _, err := io.Copy(tarWriter, file); err != nil {
+			return fmt.Errorf("failed to archive %s: %v", relPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, path := range extraFiles {
+		if err := writeArchiveFile(tarWriter, name, path); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %v", err)
+	}
+	return nil
+}
+
+// writeArchiveFile adds the regular file at path to the archive under name
+func writeArchiveFile(tarWriter *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to create archive header for %s: %v", name, err)
+	}
+	header.Name = name
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive header for %s: %v", name, err)
+	}
+	if _, err := io.Copy(tarWriter, file); err != nil {
+		return fmt.Errorf("failed to archive %s: %v", name, err)
+	}
+	return nil
+}
+
+// ExtractArchive unpacks an archive written by ArchiveDirectory into destDir, which must not
+// exist yet. Entries that would land outside destDir, directly or through an extracted
+// symlink, are rejected.
+func ExtractArchive(archivePath, destDir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %v", err)
+	}
+	defer gzipReader.Close()
+
+	if err := os.Mkdir(destDir, 0777); err != nil {
+		return fmt.Errorf("failed to create restore directory: %v", err)
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		target, err := archiveEntryPath(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", header.Name, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %v", header.Name, err)
+			}
+		case tar.TypeReg:
+			if err := extractArchiveFile(tarReader, target, mode); err != nil {
+				return fmt.Errorf("failed to extract %s: %v", header.Name, err)
+			}
+		}
+	}
+}
+
+// archiveEntryPath returns where an entry is extracted, checking the entry name and the real
+// path of its parent directory both stay inside destDir
+func archiveEntryPath(destDir, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry escapes the target directory: %s", name)
+	}
+
+	target := filepath.Join(destDir, cleaned)
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory of %s: %v", name, err)
+	}
+	resolvedDest, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve restore directory: %v", err)
+	}
+	rel, err := filepath.Rel(resolvedDest, parent)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry escapes the target directory: %s", name)
+	}
+	return target, nil
+}
+
+func extractArchiveFile(r io.Reader, target string, mode os.FileMode) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
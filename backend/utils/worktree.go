@@ -0,0 +1,395 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// worktreeCloneDirName holds one bare clone per project under the workspace base dir, task
+// workspaces of projects using worktrees are worktrees of it instead of full clones
+const worktreeCloneDirName = ".xsha-worktrees"
+
+// projectCloneLocks holds a *sync.Mutex per project clone path. Fetches and worktree changes
+// of the same clone would otherwise race on its refs and worktree list.
+var projectCloneLocks sync.Map
+
+// ProjectWorktreeSource describes where a project's shared clone is fetched from and what a
+// task branch starts from when it does not exist yet
+type ProjectWorktreeSource struct {
+	RepoURL           string
+	BaseBranch        string
+	Credential        *GitCredentialInfo
+	SSLVerify         bool
+	ProxyConfig       *GitProxyConfig
+	RecurseSubmodules bool
+}
+
+// ProjectClonePath returns the absolute path of a project's shared clone
+func (w *WorkspaceManager) ProjectClonePath(projectID uint) string {
+	return w.GetAbsolutePath(filepath.Join(worktreeCloneDirName, fmt.Sprintf("project-%d.git", projectID)))
+}
+
+// HasProjectClone reports whether a project's shared clone has been created
+func (w *WorkspaceManager) HasProjectClone(projectID uint) bool {
+	_, err := os.Stat(filepath.Join(w.ProjectClonePath(projectID), "HEAD"))
+	return err == nil
+}
+
+// IsProjectWorktree reports whether a workspace is a worktree of a shared clone rather than
+// a clone of its own
+func (w *WorkspaceManager) IsProjectWorktree(workspacePath string) bool {
+	if workspacePath == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(w.GetAbsolutePath(workspacePath), ".git"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// GetOrCreateProjectWorktree returns the task's workspace as a worktree of the project's shared
+// clone. The clone is created on first use and fetched every time. A new worktree is checked out
+// on branch, which is created from the origin branch of the same name or else from the base
+// branch. An existing worktree is returned on whatever it has checked out, also when the fetch
+// fails.
+func (w *WorkspaceManager) GetOrCreateProjectWorktree(projectID, taskID uint, branch string, source ProjectWorktreeSource) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("branch name cannot be empty")
+	}
+
+	dirName := fmt.Sprintf("task-%d-worktree", taskID)
+	existing := w.IsProjectWorktree(dirName)
+
+	clonePath := w.ProjectClonePath(projectID)
+	unlock := lockProjectClone(clonePath)
+	defer unlock()
+
+	var keyFile string
+	if source.Credential != nil {
+		if err := w.validateCredential(source.Credential); err != nil {
+			return "", fmt.Errorf("credential validation failed: %v", err)
+		}
+
+		if source.Credential.Type == GitCredentialTypeSSHKey {
+			file, err := os.CreateTemp("", "xsha-ssh-key-*")
+			if err != nil {
+				return "", fmt.Errorf("failed to create SSH key file: %v", err)
+			}
+			keyFile = file.Name()
+			defer os.Remove(keyFile)
+
+			_, writeErr := file.WriteString(source.Credential.PrivateKey)
+			file.Close()
+			if writeErr != nil {
+				return "", fmt.Errorf("failed to write SSH key file: %v", writeErr)
+			}
+		}
+	}
+
+	env := w.buildCloneEnv(source.Credential, keyFile, source.SSLVerify, source.ProxyConfig)
+	if source.Credential != nil && source.Credential.UsesAuthenticatedURL() {
+		authenticatedURL, err := w.buildAuthenticatedURL(source.RepoURL, source.Credential, source.SSLVerify, source.ProxyConfig)
+		if err != nil {
+			return "", err
+		}
+		if env, err = withAuthorizationHeader(env, authenticatedURL); err != nil {
+			return "", err
+		}
+	}
+
+	if err := w.updateProjectClone(projectID, source.RepoURL, env); err != nil {
+		if !existing {
+			return "", err
+		}
+		Warn("Failed to fetch project clone, using the task worktree as it is", "projectID", projectID, "taskID", taskID, "error", err)
+	}
+	if existing {
+		return dirName, nil
+	}
+
+	absolutePath := w.GetAbsolutePath(dirName)
+	// A directory left by a failed attempt is not registered with the clone and can go
+	if err := os.RemoveAll(absolutePath); err != nil {
+		return "", fmt.Errorf("failed to remove leftover workspace directory: %v", err)
+	}
+	if output, err := runGitIn(clonePath, env, w.gitCloneTimeout, "worktree", "prune"); err != nil {
+		Warn("Failed to prune worktrees of project clone", "projectID", projectID, "error", err, "output", output)
+	}
+
+	args := []string{"worktree", "add"}
+	switch {
+	case refExists(clonePath, "refs/heads/"+branch):
+		args = append(args, absolutePath, branch)
+	case refExists(clonePath, "refs/remotes/origin/"+branch):
+		args = append(args, "-b", branch, absolutePath, "origin/"+branch)
+	default:
+		baseBranch := source.BaseBranch
+		if baseBranch == "" {
+			baseBranch = "main"
+		}
+		// The clone was just fetched, so its origin branches are the remote's
+		if !refExists(clonePath, "refs/remotes/origin/"+baseBranch) {
+			return "", &StartBranchNotFoundError{Branch: baseBranch, AvailableBranches: listOriginBranches(clonePath)}
+		}
+		args = append(args, "-b", branch, absolutePath, "origin/"+baseBranch)
+	}
+	if output, err := runGitIn(clonePath, env, w.gitCloneTimeout, args...); err != nil {
+		return "", fmt.Errorf("failed to add worktree: %v, output: %s", err, output)
+	}
+
+	if err := relativizeWorktreeLink(absolutePath); err != nil {
+		w.RemoveProjectWorktree(dirName)
+		return "", err
+	}
+
+	if source.RecurseSubmodules {
+		if err := w.updateSubmodules(absolutePath, env); err != nil {
+			w.RemoveProjectWorktree(dirName)
+			return "", err
+		}
+	}
+
+	Info("Created task worktree", "projectID", projectID, "taskID", taskID, "workspace", dirName, "branch", branch)
+	return dirName, nil
+}
+
+// updateProjectClone creates the project's bare clone, or fetches the origin branches into it
+// when it already exists. Local branches belong to the task worktrees, origin branches are
+// kept under refs/remotes/origin so fetching never touches a checked out branch. Credentials
+// come from env, so the clone's origin is always the plain repoURL.
+func (w *WorkspaceManager) updateProjectClone(projectID uint, repoURL string, env []string) error {
+	clonePath := w.ProjectClonePath(projectID)
+
+	if w.HasProjectClone(projectID) {
+		// Clones made before credentials moved to env stored an authenticated origin
+		if output, err := runGitIn(clonePath, nil, w.gitCloneTimeout, "remote", "set-url", "origin", repoURL); err != nil {
+			return fmt.Errorf("failed to reset project clone remote: %v, output: %s", err, output)
+		}
+		if output, err := runGitIn(clonePath, env, 2*w.gitCloneTimeout, "fetch", "--prune", "origin"); err != nil {
+			return fmt.Errorf("failed to fetch project clone: %v, output: %s", err, output)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(clonePath), 0755); err != nil {
+		return fmt.Errorf("failed to create project clone directory: %v", err)
+	}
+
+	// Clone next to the final path and rename, so a failed clone never looks like a usable one
+	tempPath := fmt.Sprintf("%s.tmp-%d", clonePath, time.Now().UnixNano())
+	defer os.RemoveAll(tempPath)
+
+	if output, err := runGitIn("", env, 2*w.gitCloneTimeout, "clone", "--bare", repoURL, tempPath); err != nil {
+		return fmt.Errorf("failed to create project clone: %v, output: %s", err, output)
+	}
+	if output, err := runGitIn(tempPath, env, w.gitCloneTimeout, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return fmt.Errorf("failed to configure project clone: %v, output: %s", err, output)
+	}
+	if output, err := runGitIn(tempPath, env, 2*w.gitCloneTimeout, "fetch", "origin"); err != nil {
+		return fmt.Errorf("failed to fetch project clone: %v, output: %s", err, output)
+	}
+
+	if err := os.Rename(tempPath, clonePath); err != nil {
+		return fmt.Errorf("failed to move project clone into place: %v", err)
+	}
+
+	Info("Project clone created", "projectID", projectID, "path", clonePath)
+	return nil
+}
+
+// SwitchToBranch checks out an existing branch
+func (w *WorkspaceManager) SwitchToBranch(workspacePath, branchName string) error {
+	if branchName == "" {
+		return fmt.Errorf("branch name cannot be empty")
+	}
+
+	if output, err := runGitIn(w.GetAbsolutePath(workspacePath), nil, 30*time.Second, "checkout", branchName); err != nil {
+		return fmt.Errorf("failed to switch to branch %s: %v, output: %s", branchName, err, output)
+	}
+	return nil
+}
+
+// RemoveProjectWorktree unregisters a worktree from its project clone and deletes it. The
+// directory is deleted even when the clone is gone or refuses to remove the worktree.
+func (w *WorkspaceManager) RemoveProjectWorktree(workspacePath string) error {
+	absolutePath := w.GetAbsolutePath(workspacePath)
+
+	clonePath, err := worktreeClonePath(absolutePath)
+	if err != nil {
+		return os.RemoveAll(absolutePath)
+	}
+
+	unlock := lockProjectClone(clonePath)
+	defer unlock()
+
+	if output, err := runGitIn(clonePath, nil, time.Minute, "worktree", "remove", "--force", absolutePath); err != nil {
+		Warn("Failed to remove worktree through git, deleting it", "workspace", workspacePath, "error", err, "output", output)
+		if err := os.RemoveAll(absolutePath); err != nil {
+			return err
+		}
+		runGitIn(clonePath, nil, time.Minute, "worktree", "prune")
+	}
+	return nil
+}
+
+// RemoveProjectClone deletes a project's shared clone
+func (w *WorkspaceManager) RemoveProjectClone(projectID uint) error {
+	clonePath := w.ProjectClonePath(projectID)
+	unlock := lockProjectClone(clonePath)
+	defer unlock()
+
+	return os.RemoveAll(clonePath)
+}
+
+// WorktreeCloneMount returns the host path of the clone a worktree workspace belongs to and
+// the path it has to be mounted at when the workspace is mounted at mountPoint, so the relative
+// link in the worktree's .git file resolves. Both are empty for workspaces that are full clones.
+func WorktreeCloneMount(absoluteWorkspacePath, mountPoint string) (string, string) {
+	clonePath, err := worktreeClonePath(absoluteWorkspacePath)
+	if err != nil {
+		return "", ""
+	}
+
+	relativeClonePath, err := filepath.Rel(absoluteWorkspacePath, clonePath)
+	if err != nil {
+		return "", ""
+	}
+	return clonePath, filepath.Join(mountPoint, relativeClonePath)
+}
+
+// WorktreeAdminMount returns the host path of a worktree's admin directory inside its clone and
+// the path it has to be mounted at, like WorktreeCloneMount. The admin directory holds the
+// worktree's HEAD and index, so it stays writable when the clone is mounted read-only.
+func WorktreeAdminMount(absoluteWorkspacePath, mountPoint string) (string, string) {
+	adminDir, err := readWorktreeLink(absoluteWorkspacePath)
+	if err != nil {
+		return "", ""
+	}
+
+	relativeAdminDir, err := filepath.Rel(absoluteWorkspacePath, adminDir)
+	if err != nil {
+		return "", ""
+	}
+	return adminDir, filepath.Join(mountPoint, relativeAdminDir)
+}
+
+// withAuthorizationHeader returns env with git configured to send the credentials of
+// authenticatedURL as a basic authorization header to its host, so they appear neither in the
+// command line nor in the stored remote URL
+func withAuthorizationHeader(env []string, authenticatedURL string) ([]string, error) {
+	parsedURL, err := url.Parse(authenticatedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %v", err)
+	}
+	if parsedURL.User == nil {
+		return env, nil
+	}
+
+	password, _ := parsedURL.User.Password()
+	credentials := base64.StdEncoding.EncodeToString([]byte(parsedURL.User.Username() + ":" + password))
+	return append(append([]string(nil), env...),
+		"GIT_CONFIG_COUNT=1",
+		fmt.Sprintf("GIT_CONFIG_KEY_0=http.%s://%s/.extraHeader", parsedURL.Scheme, parsedURL.Host),
+		"GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials,
+	), nil
+}
+
+// worktreeClonePath returns the clone a worktree belongs to, read from its .git file
+func worktreeClonePath(absoluteWorkspacePath string) (string, error) {
+	adminDir, err := readWorktreeLink(absoluteWorkspacePath)
+	if err != nil {
+		return "", err
+	}
+	// The worktree's admin directory is <clone>/worktrees/<name>
+	return filepath.Dir(filepath.Dir(adminDir)), nil
+}
+
+// readWorktreeLink returns the absolute path of the admin directory a worktree's .git file
+// points at
+func readWorktreeLink(absoluteWorkspacePath string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(absoluteWorkspacePath, ".git"))
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", fmt.Errorf("unexpected .git file content in %s", absoluteWorkspacePath)
+	}
+
+	adminDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(adminDir) {
+		adminDir = filepath.Join(absoluteWorkspacePath, adminDir)
+	}
+	return filepath.Clean(adminDir), nil
+}
+
+// relativizeWorktreeLink rewrites the worktree's .git file with a relative path, git writes
+// an absolute one which does not resolve once the workspace is mounted into a container
+func relativizeWorktreeLink(absoluteWorkspacePath string) error {
+	adminDir, err := readWorktreeLink(absoluteWorkspacePath)
+	if err != nil {
+		return fmt.Errorf("failed to read worktree link: %v", err)
+	}
+
+	relativeAdminDir, err := filepath.Rel(absoluteWorkspacePath, adminDir)
+	if err != nil {
+		return fmt.Errorf("failed to relativize worktree link: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(absoluteWorkspacePath, ".git"), []byte("gitdir: "+relativeAdminDir+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write worktree link: %v", err)
+	}
+	return nil
+}
+
+func lockProjectClone(clonePath string) func() {
+	value, _ := projectCloneLocks.LoadOrStore(clonePath, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func refExists(repoPath, ref string) bool {
+	_, err := runGitIn(repoPath, nil, 30*time.Second, "rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// listOriginBranches returns the origin branches fetched into the project clone, sorted
+func listOriginBranches(clonePath string) []string {
+	output, err := runGitIn(clonePath, nil, 30*time.Second, "for-each-ref", "--sort=refname", "--format=%(refname:lstrip=3)", "refs/remotes/origin")
+	if err != nil {
+		Warn("Failed to list origin branches of project clone", "clonePath", clonePath, "error", err, "output", output)
+		return nil
+	}
+
+	var branches []string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" && line != "HEAD" {
+			branches = append(branches, line)
+		}
+	}
+	return branches
+}
+
+// runGitIn runs git in dir and returns its trimmed combined output. A nil env uses the
+// process environment.
+func runGitIn(dir string, env []string, timeout time.Duration, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = env
+	}
+
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}